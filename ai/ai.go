@@ -4,98 +4,193 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
+	"log/slog"
 	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
 	"unicode"
 
+	"hcmnext/hr"
+	"hcmnext/logging"
+	"hcmnext/tenant"
+	"hcmnext/vectorstore"
+
 	openai "github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
+	"go.opentelemetry.io/otel/codes"
 )
 
+var logger = logging.For("ai")
+
 // Client represents the AI client
 type Client struct {
 	aiClient *openai.Client
+	model    atomic.Value
+	prompts  *PromptLibrary
+	traces   *traceLog
+	displays *DisplayStore
+	vectors  vectorstore.Store
+	org      *hr.OrgService
+	tenants  *tenant.Registry
+}
+
+// defaultModel is used for every chat completion unless the config names another
+const defaultModel = "gpt-4o-mini"
+
+// Config selects the LLM backend. BaseURL points the client at any OpenAI-compatible
+// server, such as a local model, in which case APIKey may be empty.
+type Config struct {
+	APIKey    string
+	BaseURL   string
+	Model     string
+	PromptDir string
 }
 
-// NewClient creates a new AI client
-func NewClient() (*Client, error) {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
+// NewClientFromConfig creates a new AI client for cfg
+func NewClientFromConfig(cfg Config) (*Client, error) {
+	if cfg.APIKey == "" && cfg.BaseURL == "" {
 		return nil, fmt.Errorf("OpenAI API key not set")
 	}
-	return &Client{
-		aiClient: openai.NewClient(apiKey),
-	}, nil
+
+	prompts, err := NewPromptLibrary(cfg.PromptDir)
+	if err != nil {
+		return nil, err
+	}
+
+	openaiConfig := openai.DefaultConfig(cfg.APIKey)
+	if cfg.BaseURL != "" {
+		openaiConfig.BaseURL = cfg.BaseURL
+	}
+
+	c := &Client{
+		aiClient: openai.NewClientWithConfig(openaiConfig),
+		prompts:  prompts,
+		traces:   newTraceLog(200),
+		displays: NewDisplayStore(time.Hour, 1000),
+	}
+	c.SetModel(cfg.Model)
+	return c, nil
+}
+
+// Model returns the chat model the client uses
+func (c *Client) Model() string {
+	return c.model.Load().(string)
+}
+
+// SetModel switches the chat model for requests started from now on; "" selects the default
+func (c *Client) SetModel(model string) {
+	if model == "" {
+		model = defaultModel
+	}
+	c.model.Store(model)
+}
+
+// Prompts returns the prompt template library
+func (c *Client) Prompts() *PromptLibrary {
+	return c.prompts
+}
+
+// Displays returns the store holding sanitized display markup
+func (c *Client) Displays() *DisplayStore {
+	return c.displays
 }
 
 // HandleRequest sends a message to OpenAI and returns the response
-func (c *Client) HandleRequest(messages string) (string, error) {
-	fmt.Printf("Sending message to OpenAI: %s\n", messages)
+func (c *Client) HandleRequest(ctx context.Context, messages string) (response string, err error) {
+	ctx, span := tracer.Start(ctx, "ai.HandleRequest")
+	span.SetAttributes(attrConversation.String(ConversationID(ctx)))
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	// the tenant's daily quota is checked before any model is called
+	ctx, err = c.reserve(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// personal data is swapped for placeholders before anything reaches the provider
+	ctx = WithRedactor(ctx, NewRedactor())
+
+	// record every model call made for this request
+	ctx, trace := NewTrace(ctx)
+	ctx = logging.With(ctx, slog.String("trace", trace.ID))
+	defer func() {
+		trace.Finish(err)
+		c.traces.add(trace)
+		snapshot := trace.Snapshot()
+		logger.InfoContext(ctx, "Request handled",
+			"steps", len(snapshot.Steps),
+			"duration_ms", snapshot.Duration.Milliseconds(),
+			"error", snapshot.Error)
+	}()
+
+	// Create a generic map to store the values cache, tools reach the request context through it
+	values := RequestValues(ctx)
 
 	// Unmarshal the response to an array of ChatCompletionMessage
 	var chatMessages []openai.ChatCompletionMessage
-	err := json.Unmarshal([]byte(messages), &chatMessages)
+	err = json.Unmarshal([]byte(messages), &chatMessages)
 	if err != nil {
-		fmt.Println("Error unmarshaling JSON")
+		logger.WarnContext(ctx, "Error unmarshaling messages", "err", err)
 	}
+	logger.DebugContext(ctx, "Handling request", "messages", len(chatMessages))
 
 	// check if ai should use tool
-	shouldUseTool, err := c.ShouldUseTool(nil, chatMessages)
+	shouldUseTool, err := c.ShouldUseTool(values, chatMessages)
 	if err != nil {
-		fmt.Printf("Error from OpenAI: %v\n", err)
+		logger.ErrorContext(ctx, "Error deciding whether to use tools", "err", err)
 		return "", err
 	}
 
 	// if ai should not use tool, perform chat completion
+	prompt, err := c.renderPrompt(ctx, "chat", chatMessages, nil)
+	if err != nil {
+		return "", err
+	}
 	systemMessage := openai.ChatCompletionMessage{
 		Role:    "system",
-		Content: "I am a helpful assistant that is here to help with all HCM tasks. I can provide information on employees, departments, and other HR-related topics. How can I assist you today?",
+		Content: prompt.Text,
 	}
 
 	// if ai should use tool, generate execution plan
 	if shouldUseTool.UseTool {
 		// generate execution plan
-		executionPlan, err := c.GenerateExecutionPlan(nil, chatMessages)
+		executionPlan, err := c.GenerateExecutionPlan(values, chatMessages)
 		if err != nil {
-			fmt.Printf("Error from OpenAI: %v\n", err)
+			logger.ErrorContext(ctx, "Error generating execution plan", "err", err)
 			return "", err
 		}
-
-		// Create a generic map to store the values cache
-		values := make(map[string]interface{})
-
-		// debug
-		// t := reflect.TypeOf(c)
-
-		// fmt.Println("Methods:")
-		// for i := 0; i < t.NumMethod(); i++ {
-		// 	method := t.Method(i)
-		// 	fmt.Println(method.Name)
-		// }
-
-		// debug see steps
-		fmt.Println()
-		fmt.Println("## Exection Plan ")
-		for _, tool := range executionPlan.Tools {
-			fmt.Printf("|_ Tool: %s\n", tool)
-		}
-		fmt.Println()
+		logger.DebugContext(ctx, "Execution plan", "tools", executionPlan.Tools)
 
 		// Loop through the tools in the execution plan
 		for _, tool := range executionPlan.Tools {
 			// Capitalize the first letter of the tool string
 			tool = string(unicode.ToUpper(rune(tool[0]))) + tool[1:]
 
-			fmt.Printf("-> Tool: %s\n", tool)
+			// every step is a span, and everything the tool logs carries its step
+			stepCtx, step := tracer.Start(ctx, "tool "+tool)
+			step.SetAttributes(attrTool.String(tool))
+			stepCtx = logging.With(stepCtx, slog.String(logging.KeyStep, tool))
+			values[requestContextKey] = stepCtx
 
 			method := reflect.ValueOf(c).MethodByName(tool)
 			if method.IsValid() {
-				fmt.Printf("|_-> Start Tool: %s\n", tool)
+				start := time.Now()
 				// Call the method with two arguments: values and lastMessage
 				args := []reflect.Value{reflect.ValueOf(values), reflect.ValueOf(chatMessages)}
 				results := method.Call(args)
-				fmt.Println("|_-> Done Tool: ", tool)
-				fmt.Println()
+				logger.DebugContext(stepCtx, "Tool done", "duration_ms", time.Since(start).Milliseconds())
+				if len(results) == 2 {
+					if err, ok := results[1].Interface().(error); ok {
+						step.SetStatus(codes.Error, err.Error())
+					}
+				}
+				step.End()
 
 				// Check if the method returns two values (result and error)
 				if len(results) == 2 {
@@ -106,7 +201,7 @@ func (c *Client) HandleRequest(messages string) (string, error) {
 					errInterface := results[1].Interface()
 					if errInterface != nil {
 						if err, ok := errInterface.(error); ok {
-							fmt.Printf("Error calling method: %v\n", err)
+							logger.ErrorContext(stepCtx, "Error calling tool", "err", err)
 							return "", err
 						}
 					}
@@ -114,18 +209,15 @@ func (c *Client) HandleRequest(messages string) (string, error) {
 					// Store the result in the values map
 					values[tool] = result
 				} else {
-					fmt.Printf("Unexpected number of return values from method: %s\n", tool)
+					logger.ErrorContext(stepCtx, "Unexpected number of return values from tool", "results", len(results))
 					return "", fmt.Errorf("unexpected number of return values from method: %s", tool)
 				}
 			} else {
-				fmt.Println("Method not found:", tool)
+				logger.WarnContext(stepCtx, "Tool not found")
+				step.End()
 			}
 		}
-
-		// debug see cachedContext
-		for k, v := range values {
-			fmt.Printf("-=-=-=-=-=->Key: %s, Type: %T, Value: %v\n", k, v, v)
-		}
+		values[requestContextKey] = ctx
 
 		// get the last item from values map and return it
 		// Check if the tools array is not empty
@@ -135,15 +227,20 @@ func (c *Client) HandleRequest(messages string) (string, error) {
 
 			// Safely retrieve the last item from the values map
 			lastItem, exists := values[lastTool]
-			if exists {
-				fmt.Printf("Last item: %v\n", lastItem)
-			} else {
-				fmt.Println("The last tool key does not exist in the values map.")
+			if !exists {
+				logger.WarnContext(ctx, "The last tool left no result", "tool", lastTool)
+				return "", fmt.Errorf("the last tool %s left no result", lastTool)
+			}
+			response, ok := lastItem.(string)
+			if !ok {
+				logger.WarnContext(ctx, "The last tool returned no text", "tool", lastTool, "type", fmt.Sprintf("%T", lastItem))
+				return "", fmt.Errorf("the last tool %s returned %T, not text", lastTool, lastItem)
 			}
 
-			return lastItem.(string), nil
+			// only users allowed to see personal data get the placeholders filled back in
+			return rehydrateFor(ctx, response), nil
 		} else {
-			fmt.Println("Tools array is empty.")
+			logger.WarnContext(ctx, "Execution plan has no tools")
 			return "", fmt.Errorf("tools array is empty")
 		}
 
@@ -151,45 +248,47 @@ func (c *Client) HandleRequest(messages string) (string, error) {
 
 	// generate a new list of messages systemMessage first, remove the first message from chatMessages
 	newList := append([]openai.ChatCompletionMessage{systemMessage}, chatMessages[1:]...)
-	resp, err := c.aiClient.CreateChatCompletion(
-		context.Background(),
+	resp, err := c.createChatCompletion(ctx, "chat", prompt,
 		openai.ChatCompletionRequest{
-			Model:    "gpt-4o-mini",
 			Messages: newList,
 		},
 	)
 	if err != nil {
-		fmt.Printf("Error from OpenAI: %v\n", err)
+		logger.ErrorContext(ctx, "Error from OpenAI", "err", err)
 		return "", err
 	}
 
-	// decode the response
-	aiResponse := resp.Choices[0].Message.Content
-	fmt.Printf("Received response from OpenAI: %s\n", aiResponse)
-
-	// return aiResponse, nil
-	return resp.Choices[len(resp.Choices)-1].Message.Content, nil
+	return rehydrateFor(ctx, resp.Choices[len(resp.Choices)-1].Message.Content), nil
 }
 
 func (c *Client) GenerateOutput(cachedContext map[string]interface{}, chatmessages []openai.ChatCompletionMessage) (string, error) {
-	// inject context into system message from values object
-	systemMessageContent := fmt.Sprintf("Use the information in the system prompt to response to user prompts. always show any ```display``` information in your response to the user. I am a helpful assistant that is here to help with all HCM tasks. I can provide information on employees, departments, and other HR-related topics. How can I assist you today? %v", cachedContext)
+	ctx := requestContext(cachedContext)
+
+	// inject context into system message from values object, with tagged personal data redacted
+	redacted, err := json.Marshal(redactorFromContext(ctx).RedactValue(toolResults(cachedContext)))
+	if err != nil {
+		return "", err
+	}
+	prompt, err := c.renderPrompt(ctx, "generateOutput", chatmessages, map[string]interface{}{
+		"Context": string(redacted),
+	})
+	if err != nil {
+		return "", err
+	}
 	systemMessage := openai.ChatCompletionMessage{
 		Role:    openai.ChatMessageRoleSystem,
-		Content: systemMessageContent,
+		Content: prompt.Text,
 	}
 
 	// generate a new list of messages systemMessage first, remove the first message from chatMessages
 	newList := append([]openai.ChatCompletionMessage{systemMessage}, chatmessages...)
-	resp, err := c.aiClient.CreateChatCompletion(
-		context.Background(),
+	resp, err := c.createChatCompletion(ctx, "generateOutput", prompt,
 		openai.ChatCompletionRequest{
-			Model:    "gpt-4o-mini",
 			Messages: newList,
 		},
 	)
 	if err != nil {
-		fmt.Printf("Error from OpenAI: %v\n", err)
+		logger.ErrorContext(ctx, "Error from OpenAI", "err", err)
 		return "", err
 	}
 
@@ -203,7 +302,7 @@ type ExecutionPlan struct {
 }
 
 func (c *Client) GenerateExecutionPlan(cachedContext map[string]interface{}, chatMessages []openai.ChatCompletionMessage) (ExecutionPlan, error) {
-	ctx := context.Background()
+	ctx := requestContext(cachedContext)
 
 	// get the last prompt
 	prompt := chatMessages[len(chatMessages)-1].Content
@@ -231,113 +330,14 @@ func (c *Client) GenerateExecutionPlan(cachedContext map[string]interface{}, cha
 	}
 
 	// Prepare the initial user message
+	systemPrompt, err := c.renderPrompt(ctx, "generateExecutionPlan", chatMessages, nil)
+	if err != nil {
+		return ExecutionPlan{}, err
+	}
 	dialogue := []openai.ChatCompletionMessage{
 		{
-			Role: openai.ChatMessageRoleSystem,
-			Content: fmt.Sprintln(`I'll help you generate an execution plan, tools are a list of escaped json strings inside a string, You have access to a comprehensive set of tools designed to perform a wide range of tasks, from generating API calls to producing the final output for display. Each tool has a specific function that contributes to the overall process of executing a task. Here is the list of 
-			
-			RULES:
-			Never place the same tools back to back examples of what not to do: [generateMath, generateMath, generateMath,  GenerateDisplayHtml, generateoutput]
-			Only use the minimum number of tools needed to complete the task
-
-
-			tools available:
-generateApi: Generates an API call based on the provided parameters.
-callApi: Executes the API call and retrieves the data.
-parseResponse: Parses the response received from the API call into a usable format.
-generateDisplayHtml: Generates the HTML structure needed to display the parsed data.
-generateOutput: Generates the final output in the chat format, ready for display.
-cacheResults: Stores intermediate results to optimize multistage processes.
-generateMath: Creates mathematical expressions or calculations.
-fetchDatabase: Retrieves data from a database.
-storeData: Saves data into a database.
-processData: Processes raw data into meaningful information.
-filterData: Filters data based on specific criteria.
-sortData: Sorts data in ascending or descending order.
-transformData: Transforms data into a different format or structure.
-generateReport: Generates reports from processed data.
-sendEmail: Sends an email with the generated report or other data.
-generateGraph: Creates visual graphs from data.
-executeScript: Executes a script or a sequence of commands.
-logActivity: Logs the activities performed during the execution plan.
-Example Tool Usages for Execution Plans
-Search for a user in the database:
-
-Tools: [generateApi, callApi, parseResponse, generateDisplayHtml, generateOutput]
-Context: "Search for a user by their email address."
-Calculate the sum of two numbers (113124 and 9201):
-
-Tools: [generateMath, generateDisplayHtml, generateOutput]
-Context: "Calculate the sum of two numbers."
-Retrieve and sort customer data:
-
-Tools: [fetchDatabase, filterData, sortData, generateReport, generateOutput]
-Context: "Retrieve customer data, filter for active users, and sort by registration date."
-Generate and send a sales report:
-
-Tools: [fetchDatabase, processData, generateReport, sendEmail, logActivity]
-Context: "Generate a sales report for Q2 and send it to the finance department."
-Fetch and display product information:
-
-Tools: [generateApi, callApi, parseResponse, generateDisplayHtml, generateOutput]
-Context: "Fetch product details by product ID and display them on the website."
-Calculate and graph monthly revenue:
-
-Tools: [fetchDatabase,generateMath, generateGraph, generateReport, generateOutput]
-Context: "Calculate monthly revenue and generate a graph."
-Create a user account and log the activity:
-
-Tools: [generateApi, callApi, parseResponse, storeData, logActivity]
-Context: "Create a new user account and log the creation event."
-Process and transform sales data:
-
-Tools: [fetchDatabase, processData, transformData, generateReport, 	generateOutput]
-Context: "Process sales data and transform it into a different form	at."
-Generate a list of top-selling products:	
-
-Tools: [fetchDatabase, filterData, sortData, generateReport, generateOutput]
-Context: "Generate a report of the top-selling products for the last quarter."
-Fetch weather data and display it in a dashboard:
-
-Tools: [generateApi, callApi, parseResponse, generateDisplayHtml, generateOutput]
-Context: "Fetch current weather data for a specific location and display it on the dashboard."
-Complex Multistage Execution Plan Examples
-Example 1: Multistage Task - Generate a Large List of Processed Weather Data
-Objective: Fetch weather data for multiple locations, process and compile key weather metrics into a list, and output the entire list for display, using cacheResults between stages to manage intermediate data.
-
-Stage 1: Fetch Weather Data for Multiple Locations
-
-Tools: [generateApi, callApi, cacheResults]
-Context: "Fetch the weather data for multiple locations (e.g., 100 cities)."
-Process: Generate and execute API calls for each location, then cache the raw weather data responses.
-Stage 2: Process and Compile Weather Metrics
-
-Tools: [parseResponse, processData, cacheResults]
-Context: "Process the cached weather data to extract and compile a list of key metrics (temperature, humidity, wind speed) for each location."
-Process: Parse the cached data, extract relevant metrics, and compile them into a large list. Cache the processed list for further use.
-Stage 3: Generate and Output the Final List
-
-Tools: [generateDisplayHtml, generateOutput]
-Context: "Generate the HTML structure to display the compiled list of weather metrics and output it in the final chat format."
-Process: Use the cached list to generate the HTML and output the compiled information for display.
-Example 2: Multistage Task - Generate and Output a Large List of Monthly Performance Data
-Objective: Retrieve and process performance data for multiple departments, compile the data into a comprehensive list, and output the list for reporting, utilizing cacheResults to manage the intermediate results.
-
-Stage 1: Retrieve Performance Data for Multiple Departments
-
-Tools: [fetchDatabase, cacheResults]
-Context: "Fetch the monthly performance data for multiple departments (e.g., 50 departments)."
-Process: Retrieve the data for each department and cache the raw performance data for processing.
-Stage 2: Process and Compile Performance Data
-
-Tools: [processData, generateReport, cacheResults]
-Context: "Process the cached performance data to compile a large list of key metrics (e.g., sales, customer satisfaction) for each department."
-Process: Process the cached data to extract key performance metrics, compile them into a large list, and cache the processed list.
-Stage 3: Generate and Output the Final List
-
-Tools: [generateDisplayHtml, generateOutput]
-Context: "Generate the HTML structure to display the compiled list of performance metrics and output it in the final report format."
-Process: Use the cached list to generate the HTML and produce the final output for display or reporting.`),
+			Role:    openai.ChatMessageRoleSystem,
+			Content: systemPrompt.Text,
 		},
 		{
 			Role:    openai.ChatMessageRoleUser,
@@ -346,9 +346,8 @@ Process: Use the cached list to generate the HTML and produce the final output f
 	}
 
 	// Send the request to OpenAI
-	resp, err := c.aiClient.CreateChatCompletion(ctx,
+	resp, err := c.createChatCompletion(ctx, "generateExecutionPlan", systemPrompt,
 		openai.ChatCompletionRequest{
-			Model:    "gpt-4o-mini",
 			Messages: dialogue,
 			ResponseFormat: &openai.ChatCompletionResponseFormat{
 				Type:       openai.ChatCompletionResponseFormatTypeJSONSchema,
@@ -357,25 +356,21 @@ Process: Use the cached list to generate the HTML and produce the final output f
 		},
 	)
 	if err != nil || len(resp.Choices) != 1 {
-		fmt.Printf("Completion error: err:%v len(choices):%v\n", err, len(resp.Choices))
+		logger.ErrorContext(ctx, "Completion error", "err", err, "choices", len(resp.Choices))
 		return ExecutionPlan{}, err
 	}
 
 	// Process the response and function call
 	msg := resp.Choices[0].Message
-	fmt.Printf("------->  OpenAI response: %v\n", msg.Content)
 
 	var executionPlan ExecutionPlan
 	err = json.Unmarshal([]byte(msg.Content), &executionPlan)
 	if err != nil {
-		fmt.Printf("Error unmarshaling JSON: %v\n", err)
+		logger.ErrorContext(ctx, "Error unmarshaling execution plan", "err", err)
 		return ExecutionPlan{}, err
 	}
 
 	// Return the final response
-	msg = resp.Choices[0].Message
-	fmt.Printf("OpenAI answered the original request with: %v\n",
-		msg.Content)
 	return executionPlan, nil
 }
 
@@ -386,7 +381,7 @@ type ToolResponse struct {
 }
 
 func (c *Client) ShouldUseTool(cachedContext map[string]interface{}, consersation []openai.ChatCompletionMessage) (ToolResponse, error) {
-	ctx := context.Background()
+	ctx := requestContext(cachedContext)
 
 	// Define the JSON schema for the response
 	var schema = openai.ChatCompletionResponseFormatJSONSchema{
@@ -411,10 +406,14 @@ func (c *Client) ShouldUseTool(cachedContext map[string]interface{}, consersatio
 	}
 
 	// Prepare the initial user message
+	prompt, err := c.renderPrompt(ctx, "shouldUseTool", consersation, nil)
+	if err != nil {
+		return ToolResponse{UseTool: false}, err
+	}
 	dialogue := []openai.ChatCompletionMessage{
 		{
 			Role:    openai.ChatMessageRoleSystem,
-			Content: fmt.Sprintln(`I can help you decide whether if a tool should be used or not based on our conversation. I will return a tool call that will return a boolean value indicating whether the tool should be used or not. Always return json `),
+			Content: prompt.Text,
 		},
 	}
 
@@ -422,9 +421,8 @@ func (c *Client) ShouldUseTool(cachedContext map[string]interface{}, consersatio
 	dialogue = append(dialogue, consersation...)
 
 	// Send the request to OpenAI
-	resp, err := c.aiClient.CreateChatCompletion(ctx,
+	resp, err := c.createChatCompletion(ctx, "shouldUseTool", prompt,
 		openai.ChatCompletionRequest{
-			Model:    "gpt-4o-mini",
 			Messages: dialogue,
 			ResponseFormat: &openai.ChatCompletionResponseFormat{
 				Type:       openai.ChatCompletionResponseFormatTypeJSONSchema,
@@ -435,20 +433,30 @@ func (c *Client) ShouldUseTool(cachedContext map[string]interface{}, consersatio
 
 	// Process the response and function call
 	if err != nil || len(resp.Choices) != 1 {
-		fmt.Printf("Completion error: err:%v len(choices):%v\n", err, len(resp.Choices))
+		logger.ErrorContext(ctx, "Completion error", "err", err, "choices", len(resp.Choices))
 		return ToolResponse{UseTool: false}, err
 	}
 
-	fmt.Printf("------->  OpenAI response: %v\n", resp.Choices[len(resp.Choices)-1].Message.Content)
-
 	var toolResponse ToolResponse
 	err = json.Unmarshal([]byte(resp.Choices[0].Message.Content), &toolResponse)
 	if err != nil {
-		fmt.Printf("Error unmarshaling JSON: %v\n", err)
+		logger.ErrorContext(ctx, "Error unmarshaling tool decision", "err", err)
 		return ToolResponse{UseTool: false}, err
 	}
 
 	// Return the final response
-	fmt.Printf("OpenAI answered the original request with: %v\n", resp.Choices[0].Message.Content)
+	logger.DebugContext(ctx, "Tool decision", "use_tool", toolResponse.UseTool)
 	return toolResponse, nil
 }
+
+// toolResults returns the tool results in cachedContext without the entries used for plumbing
+func toolResults(cachedContext map[string]interface{}) map[string]interface{} {
+	results := make(map[string]interface{}, len(cachedContext))
+	for k, v := range cachedContext {
+		if strings.HasPrefix(k, "__") {
+			continue
+		}
+		results[k] = v
+	}
+	return results
+}
@@ -0,0 +1,303 @@
+package ai
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedDisplayElements are kept as-is by the sanitizer
+var allowedDisplayElements = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.Article: true, atom.Aside: true, atom.B: true,
+	atom.Blockquote: true, atom.Br: true, atom.Button: true, atom.Canvas: true, atom.Caption: true,
+	atom.Code: true, atom.Col: true, atom.Colgroup: true, atom.Dd: true, atom.Del: true,
+	atom.Details: true, atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Em: true,
+	atom.Figcaption: true, atom.Figure: true, atom.Footer: true, atom.H1: true, atom.H2: true,
+	atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true, atom.Header: true,
+	atom.Hr: true, atom.I: true, atom.Img: true, atom.Input: true, atom.Ins: true,
+	atom.Kbd: true, atom.Label: true, atom.Li: true, atom.Main: true, atom.Mark: true,
+	atom.Meter: true, atom.Nav: true, atom.Ol: true, atom.Option: true, atom.P: true,
+	atom.Pre: true, atom.Progress: true, atom.S: true, atom.Section: true,
+	atom.Select: true, atom.Small: true, atom.Span: true, atom.Strong: true, atom.Style: true,
+	atom.Sub: true, atom.Summary: true, atom.Sup: true, atom.Table: true, atom.Tbody: true,
+	atom.Td: true, atom.Textarea: true, atom.Tfoot: true, atom.Th: true, atom.Thead: true,
+	atom.Time: true, atom.Tr: true, atom.U: true, atom.Ul: true,
+}
+
+// droppedDisplayElements are removed together with everything inside them
+var droppedDisplayElements = map[atom.Atom]bool{
+	atom.Iframe: true, atom.Frame: true, atom.Frameset: true, atom.Object: true, atom.Embed: true,
+	atom.Applet: true, atom.Base: true, atom.Meta: true, atom.Link: true, atom.Head: true,
+	atom.Title: true, atom.Noscript: true, atom.Template: true, atom.Svg: true, atom.Math: true,
+}
+
+// allowedDisplayAttributes are the attributes kept on any allowed element
+var allowedDisplayAttributes = map[string]bool{
+	"class": true, "id": true, "style": true, "title": true, "role": true, "lang": true,
+	"dir": true, "hidden": true, "tabindex": true, "width": true, "height": true, "alt": true,
+	"colspan": true, "rowspan": true, "scope": true, "type": true, "value": true, "name": true,
+	"placeholder": true, "checked": true, "disabled": true, "selected": true, "min": true,
+	"max": true, "step": true, "for": true, "open": true, "datetime": true, "src": true,
+	"href": true, "target": true, "rel": true,
+}
+
+// DisplayRejectedError is returned when markup references resources that are not allowed
+type DisplayRejectedError struct {
+	Reasons []string
+}
+
+func (e *DisplayRejectedError) Error() string {
+	return fmt.Sprintf("display markup rejected: %s", strings.Join(e.Reasons, "; "))
+}
+
+// SanitizedDisplay is the result of running display markup through the allowlist
+type SanitizedDisplay struct {
+	Markup  string
+	Removed []string
+	// InlineScripts counts the scripts without a src that were dropped; the model's own code
+	// never runs, however it was asked for
+	InlineScripts int
+}
+
+// SanitizeDisplayHtml strips everything outside the allowlist from model-written markup.
+// Inline scripts are dropped, and markup that loads a script from anywhere is rejected
+// outright: the display page loads the libraries it renders charts and diagrams with itself.
+func SanitizeDisplayHtml(markup string) (SanitizedDisplay, error) {
+	root, err := parseDisplayFragment(markup)
+	if err != nil {
+		return SanitizedDisplay{}, fmt.Errorf("error parsing display markup: %v", err)
+	}
+
+	s := &displaySanitizer{}
+	s.cleanChildren(root)
+
+	if len(s.rejected) > 0 {
+		return SanitizedDisplay{Removed: s.removed, InlineScripts: s.inlineScripts}, &DisplayRejectedError{Reasons: s.rejected}
+	}
+
+	out, err := renderDisplayFragment(root)
+	if err != nil {
+		return SanitizedDisplay{}, fmt.Errorf("error rendering display markup: %v", err)
+	}
+	return SanitizedDisplay{Markup: out, Removed: s.removed, InlineScripts: s.inlineScripts}, nil
+}
+
+// parseDisplayFragment parses markup as the contents of a <body> and hangs it off a detached root
+func parseDisplayFragment(markup string) (*html.Node, error) {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(markup), body)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+	return root, nil
+}
+
+func renderDisplayFragment(root *html.Node) (string, error) {
+	var out bytes.Buffer
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&out, c); err != nil {
+			return "", err
+		}
+	}
+	return out.String(), nil
+}
+
+type displaySanitizer struct {
+	removed       []string
+	rejected      []string
+	inlineScripts int
+}
+
+// cleanChildren drops, unwraps or sanitizes every child of n
+func (s *displaySanitizer) cleanChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+
+		switch {
+		case c.Type == html.TextNode:
+		case c.Type != html.ElementNode, droppedDisplayElements[c.DataAtom]:
+			if c.Type == html.ElementNode {
+				s.removed = append(s.removed, "<"+c.Data+">")
+			}
+			n.RemoveChild(c)
+		case c.DataAtom == atom.Script:
+			s.dropScript(c)
+			n.RemoveChild(c)
+		case !allowedDisplayElements[c.DataAtom]:
+			// unknown wrappers (form, html, custom elements, ...) are replaced by their children
+			s.removed = append(s.removed, "<"+c.Data+">")
+			first := c.FirstChild
+			for gc := c.FirstChild; gc != nil; {
+				gnext := gc.NextSibling
+				c.RemoveChild(gc)
+				n.InsertBefore(gc, c)
+				gc = gnext
+			}
+			n.RemoveChild(c)
+			if first != nil {
+				next = first
+			}
+		default:
+			s.cleanAttributes(c)
+			s.cleanChildren(c)
+		}
+
+		c = next
+	}
+}
+
+func (s *displaySanitizer) cleanAttributes(n *html.Node) {
+	kept := n.Attr[:0]
+	for _, attr := range n.Attr {
+		key := strings.ToLower(attr.Key)
+		switch {
+		case attr.Namespace != "":
+			s.removed = append(s.removed, fmt.Sprintf("%s[%s:%s]", n.Data, attr.Namespace, key))
+			continue
+		case strings.HasPrefix(key, "on"):
+			s.removed = append(s.removed, fmt.Sprintf("%s[%s]", n.Data, key))
+			continue
+		case strings.HasPrefix(key, "aria-"), strings.HasPrefix(key, "data-"):
+		case !allowedDisplayAttributes[key]:
+			s.removed = append(s.removed, fmt.Sprintf("%s[%s]", n.Data, key))
+			continue
+		}
+
+		switch key {
+		case "href":
+			if n.DataAtom != atom.A || !isSafeLink(attr.Val) {
+				s.removed = append(s.removed, fmt.Sprintf("%s[href=%q]", n.Data, attr.Val))
+				continue
+			}
+		case "src":
+			if n.DataAtom != atom.Img || !strings.HasPrefix(strings.ToLower(strings.TrimSpace(attr.Val)), "data:image/") {
+				s.removed = append(s.removed, fmt.Sprintf("%s[src=%q]", n.Data, attr.Val))
+				continue
+			}
+		case "style":
+			if strings.Contains(strings.ToLower(attr.Val), "url(") || strings.Contains(strings.ToLower(attr.Val), "expression(") {
+				s.removed = append(s.removed, fmt.Sprintf("%s[style]", n.Data))
+				continue
+			}
+		}
+
+		attr.Key = key
+		kept = append(kept, attr)
+	}
+	n.Attr = kept
+
+	if n.DataAtom == atom.A {
+		// links always open outside the sandbox without a reference back to it
+		setAttr(n, "target", "_blank")
+		setAttr(n, "rel", "noopener noreferrer")
+	}
+}
+
+// dropScript records a script the model wrote. No script of the markup is kept: inline code
+// is dropped, and a script loaded from any source rejects the whole markup.
+func (s *displaySanitizer) dropScript(n *html.Node) {
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, "src") && strings.TrimSpace(attr.Val) != "" {
+			s.rejected = append(s.rejected, fmt.Sprintf("script source %q is not allowed", attr.Val))
+			return
+		}
+	}
+	s.inlineScripts++
+	s.removed = append(s.removed, "<script> without src")
+}
+
+func isSafeLink(href string) bool {
+	href = strings.ToLower(strings.TrimSpace(href))
+	return strings.HasPrefix(href, "https://") || strings.HasPrefix(href, "http://") ||
+		strings.HasPrefix(href, "mailto:") || strings.HasPrefix(href, "#")
+}
+
+func setAttr(n *html.Node, key, val string) {
+	for i := range n.Attr {
+		if n.Attr[i].Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+// DisplayStore keeps sanitized display markup until the browser fetches it from /display/{id}
+type DisplayStore struct {
+	mu       sync.Mutex
+	entries  map[string]displayEntry
+	ttl      time.Duration
+	capacity int
+}
+
+type displayEntry struct {
+	markup  string
+	created time.Time
+}
+
+// NewDisplayStore creates a new DisplayStore
+func NewDisplayStore(ttl time.Duration, capacity int) *DisplayStore {
+	return &DisplayStore{
+		entries:  make(map[string]displayEntry),
+		ttl:      ttl,
+		capacity: capacity,
+	}
+}
+
+// Put stores sanitized markup and returns its id
+func (s *DisplayStore) Put(markup string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictLocked(time.Now())
+	s.entries[id] = displayEntry{markup: markup, created: time.Now()}
+	return id, nil
+}
+
+// Get returns the markup stored under id
+func (s *DisplayStore) Get(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok || time.Since(entry.created) > s.ttl {
+		return "", false
+	}
+	return entry.markup, true
+}
+
+// evictLocked drops expired entries and, if still full, the oldest one
+func (s *DisplayStore) evictLocked(now time.Time) {
+	var oldestID string
+	var oldest time.Time
+	for id, entry := range s.entries {
+		if now.Sub(entry.created) > s.ttl {
+			delete(s.entries, id)
+			continue
+		}
+		if oldestID == "" || entry.created.Before(oldest) {
+			oldestID, oldest = id, entry.created
+		}
+	}
+	if len(s.entries) >= s.capacity && oldestID != "" {
+		delete(s.entries, oldestID)
+	}
+}
@@ -0,0 +1,23 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Ping checks that the LLM provider answers, by listing its models. It costs no tokens.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.aiClient.ListModels(ctx)
+	return err
+}
+
+// EvaluatorVersion returns the version of the Node.js runtime ExecuteMath evaluates expressions with
+func EvaluatorVersion(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "node", "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("node is not available: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
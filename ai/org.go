@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"hcmnext/hr"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// orgMatchLimit is the number of employees described when a question names several
+const orgMatchLimit = 5
+
+// orgReportLimit is the number of indirect reports listed per employee
+const orgReportLimit = 25
+
+// SetOrgService enables the queryOrgChart tool backed by org
+func (c *Client) SetOrgService(org *hr.OrgService) {
+	c.org = org
+}
+
+// Answers reporting-line questions: managers, direct and indirect reports, chains of command and span of control.
+func (c *Client) QueryOrgChart(cachedContext map[string]interface{}, chatMessages []openai.ChatCompletionMessage) (string, error) {
+	ctx := requestContext(cachedContext)
+
+	if c.org == nil {
+		return "", fmt.Errorf("no org service configured")
+	}
+
+	org, err := c.org.Load(ctx, time.Now())
+	if err != nil {
+		logger.ErrorContext(ctx, "Error loading org", "err", err)
+		return "", err
+	}
+
+	// follow-up questions often refer back to someone named earlier in the conversation
+	var matches []*hr.OrgNode
+	for i := len(chatMessages) - 1; i >= 0 && len(matches) == 0; i-- {
+		if chatMessages[i].Role == openai.ChatMessageRoleUser {
+			matches = mentionedEmployees(org, chatMessages[i].Content)
+		}
+	}
+
+	var b strings.Builder
+	if len(matches) == 0 {
+		stats := org.Stats()
+		fmt.Fprintf(&b, "Org overview as of %s: %d active employees, %d managers, %d management layers.\n", org.AsOf.Format("2006-01-02"), stats.Employees, stats.Managers, stats.Layers)
+		fmt.Fprintf(&b, "Span of control: mean %.1f, median %.1f, max %d direct reports; %d managers have a single report.\n", stats.MeanSpan, stats.MedianSpan, stats.MaxSpan, stats.SingleReportMgr)
+		for _, id := range stats.Roots {
+			node := org.Node(id)
+			fmt.Fprintf(&b, "Top of a reporting line: %s (%s, %s)\n", node.Name, node.Title, node.EmployeeID)
+		}
+		for _, span := range stats.WidestManagers {
+			fmt.Fprintf(&b, "Manager %s (%s): %d direct, %d total reports\n", span.Name, span.EmployeeID, span.DirectReports, span.TotalReports)
+		}
+		if issues := org.Issues(); len(issues) > 0 {
+			fmt.Fprintf(&b, "%d reporting-line problems:\n", len(issues))
+			for _, issue := range issues {
+				fmt.Fprintf(&b, "- %s: %s\n", issue.Kind, issue.Detail)
+			}
+		}
+		return b.String(), nil
+	}
+
+	for _, node := range matches {
+		describeOrgNode(&b, org, node)
+	}
+	logger.DebugContext(ctx, "Described employees from the org chart", "count", len(matches))
+	return b.String(), nil
+}
+
+// mentionedEmployees returns the employees whose name or id appears in text
+func mentionedEmployees(org *hr.Org, text string) []*hr.OrgNode {
+	text = strings.ToLower(text)
+
+	var matches []*hr.OrgNode
+	for _, node := range org.Nodes() {
+		if strings.Contains(text, strings.ToLower(node.Name)) || strings.Contains(text, strings.ToLower(node.EmployeeID)) {
+			matches = append(matches, node)
+			if len(matches) == orgMatchLimit {
+				break
+			}
+		}
+	}
+	return matches
+}
+
+func describeOrgNode(b *strings.Builder, org *hr.Org, node *hr.OrgNode) {
+	fmt.Fprintf(b, "%s (%s) is %s in %s.\n", node.Name, node.EmployeeID, node.Title, node.Department)
+
+	chain := org.ChainOfCommand(node.EmployeeID)
+	if len(chain) == 0 {
+		b.WriteString("They have no manager in the org.\n")
+	} else {
+		names := make([]string, len(chain))
+		for i, manager := range chain {
+			names[i] = fmt.Sprintf("%s (%s)", manager.Name, manager.Title)
+		}
+		fmt.Fprintf(b, "Chain of command, nearest first: %s\n", strings.Join(names, " → "))
+	}
+
+	span := org.Span(node.EmployeeID)
+	fmt.Fprintf(b, "Span of control: %d direct reports, %d total reports across %d layers.\n", span.DirectReports, span.TotalReports, span.Layers)
+
+	listed := 0
+	for _, report := range org.Reports(node.EmployeeID, 0) {
+		if listed == orgReportLimit {
+			fmt.Fprintf(b, "... and %d more\n", span.TotalReports-listed)
+			break
+		}
+		kind := "direct"
+		if report.Depth > 1 {
+			kind = fmt.Sprintf("indirect, %d levels down, via %s", report.Depth, report.Manager.Name)
+		}
+		fmt.Fprintf(b, "- %s (%s), %s [%s]\n", report.Name, report.EmployeeID, report.Title, kind)
+		listed++
+	}
+
+	if span.TotalReports > 0 {
+		fmt.Fprintf(b, "Mermaid org chart:\n%s", org.Mermaid(node.EmployeeID, 2))
+	}
+	b.WriteString("\n")
+}
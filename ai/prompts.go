@@ -0,0 +1,215 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+	"text/template"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// embeddedPrompts holds the prompt templates shipped with the binary
+//
+//go:embed prompts/*.tmpl prompts/manifest.json
+var embeddedPrompts embed.FS
+
+// promptManifest lists, for every prompt name, the versions that can be served
+type promptManifest map[string]struct {
+	Variants []promptVariant `json:"variants"`
+}
+
+type promptVariant struct {
+	Version string `json:"version"`
+	File    string `json:"file"`
+	// Weight is the share of conversations assigned to this variant; zero serves it only when pinned
+	Weight int `json:"weight"`
+
+	template *template.Template
+}
+
+// RenderedPrompt is a prompt ready to send along with the version that produced it
+type RenderedPrompt struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Text    string `json:"-"`
+}
+
+// PromptLibrary loads prompt templates from the embedded FS, letting files in an
+// optional directory override the embedded ones with the same name.
+type PromptLibrary struct {
+	mu      sync.RWMutex
+	dir     string
+	prompts map[string][]promptVariant
+}
+
+// NewPromptLibrary loads the prompt templates; dir may be empty to use only the embedded ones
+func NewPromptLibrary(dir string) (*PromptLibrary, error) {
+	p := &PromptLibrary{dir: dir}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload re-reads the manifest and templates, keeping the current ones if anything fails to parse
+func (p *PromptLibrary) Reload() error {
+	fsys := promptFS{dir: p.dir}
+
+	raw, err := fs.ReadFile(fsys, "manifest.json")
+	if err != nil {
+		return fmt.Errorf("error reading prompt manifest: %v", err)
+	}
+	var manifest promptManifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return fmt.Errorf("error parsing prompt manifest: %v", err)
+	}
+
+	prompts := make(map[string][]promptVariant, len(manifest))
+	for name, entry := range manifest {
+		total := 0
+		for _, variant := range entry.Variants {
+			total += variant.Weight
+		}
+		if total <= 0 {
+			return fmt.Errorf("prompt %s has no variant with a positive weight", name)
+		}
+		for _, variant := range entry.Variants {
+			// a zero weight retires a version from assignment while keeping it available to pin
+			if variant.Version == "" || variant.Weight < 0 {
+				return fmt.Errorf("prompt %s: every variant needs a version and a non-negative weight", name)
+			}
+			text, err := fs.ReadFile(fsys, variant.File)
+			if err != nil {
+				return fmt.Errorf("prompt %s@%s: %v", name, variant.Version, err)
+			}
+			variant.template, err = template.New(variant.File).Option("missingkey=error").Parse(string(text))
+			if err != nil {
+				return fmt.Errorf("prompt %s@%s: %v", name, variant.Version, err)
+			}
+			prompts[name] = append(prompts[name], variant)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prompts = prompts
+	return nil
+}
+
+// Render executes the variant of prompt name assigned to assignmentKey with data
+func (p *PromptLibrary) Render(name, assignmentKey string, data interface{}) (RenderedPrompt, error) {
+	p.mu.RLock()
+	variants, ok := p.prompts[name]
+	p.mu.RUnlock()
+	if !ok {
+		return RenderedPrompt{}, fmt.Errorf("unknown prompt %s", name)
+	}
+
+	variant := assignVariant(name, assignmentKey, variants)
+
+	var buf bytes.Buffer
+	if err := variant.template.Execute(&buf, data); err != nil {
+		return RenderedPrompt{}, fmt.Errorf("error rendering prompt %s@%s: %v", name, variant.Version, err)
+	}
+
+	return RenderedPrompt{Name: name, Version: variant.Version, Text: buf.String()}, nil
+}
+
+// RenderVersion executes a specific version of prompt name with data
+func (p *PromptLibrary) RenderVersion(name, version string, data interface{}) (RenderedPrompt, error) {
+	p.mu.RLock()
+	variants, ok := p.prompts[name]
+	p.mu.RUnlock()
+	if !ok {
+		return RenderedPrompt{}, fmt.Errorf("unknown prompt %s", name)
+	}
+
+	for _, variant := range variants {
+		if variant.Version != version {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := variant.template.Execute(&buf, data); err != nil {
+			return RenderedPrompt{}, fmt.Errorf("error rendering prompt %s@%s: %v", name, variant.Version, err)
+		}
+		return RenderedPrompt{Name: name, Version: variant.Version, Text: buf.String()}, nil
+	}
+	return RenderedPrompt{}, fmt.Errorf("unknown version %s of prompt %s", version, name)
+}
+
+// Versions returns the versions available for every prompt
+func (p *PromptLibrary) Versions() map[string][]string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	versions := make(map[string][]string, len(p.prompts))
+	for name, variants := range p.prompts {
+		for _, variant := range variants {
+			versions[name] = append(versions[name], variant.Version)
+		}
+	}
+	return versions
+}
+
+// assignVariant picks a variant by weight. The same key always gets the same variant,
+// so a whole conversation stays on one version of each prompt.
+func assignVariant(name, key string, variants []promptVariant) promptVariant {
+	total := 0
+	for _, variant := range variants {
+		total += variant.Weight
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	bucket := int(h.Sum32() % uint32(total))
+
+	for _, variant := range variants {
+		if bucket < variant.Weight {
+			return variant
+		}
+		bucket -= variant.Weight
+	}
+	return variants[len(variants)-1]
+}
+
+// promptFS serves files from dir when present and from the embedded prompts otherwise
+type promptFS struct {
+	dir string
+}
+
+func (p promptFS) Open(name string) (fs.File, error) {
+	if p.dir != "" {
+		if f, err := os.DirFS(p.dir).Open(name); err == nil {
+			return f, nil
+		}
+	}
+	return embeddedPrompts.Open(path.Join("prompts", name))
+}
+
+type promptVersionsContextKey struct{}
+
+// WithPromptVersions pins prompts to specific versions for every call made with ctx,
+// bypassing A/B assignment. It is used to evaluate one version against another.
+func WithPromptVersions(ctx context.Context, versions map[string]string) context.Context {
+	return context.WithValue(ctx, promptVersionsContextKey{}, versions)
+}
+
+// renderPrompt renders prompt name for a request, honouring versions pinned on ctx
+func (c *Client) renderPrompt(ctx context.Context, name string, chatMessages []openai.ChatCompletionMessage, data interface{}) (RenderedPrompt, error) {
+	if versions, ok := ctx.Value(promptVersionsContextKey{}).(map[string]string); ok {
+		if version, ok := versions[name]; ok {
+			return c.prompts.RenderVersion(name, version, data)
+		}
+	}
+	return c.prompts.Render(name, assignmentKey(ctx, chatMessages), data)
+}
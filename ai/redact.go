@@ -0,0 +1,275 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"hcmnext/auth"
+	"hcmnext/models"
+)
+
+// piiPatterns find personal data in free text, most specific first
+var piiPatterns = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	{"ssn", regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+	{"email", regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+	{"phone", regexp.MustCompile(`\+[1-9]\d{6,14}\b|\(?\b\d{3}\)?[-. ]\d{3}[-. ]\d{4}\b`)},
+}
+
+// placeholderPattern matches the tokens produced by a Redactor
+var placeholderPattern = regexp.MustCompile(`\[[A-Z]+_\d+\]`)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// untaggedPII are personal fields the models leave untagged, as exports and logs keep them, but
+// which model providers must not see
+var untaggedPII = map[string]string{"salary": "salary"}
+
+// piiKeys maps the json and bson names of the personal fields of employees and jobs to their
+// kind, so that the same data is redacted when a tool hands it over as a map or bson.M
+var piiKeys = modelPIIKeys(untaggedPII, reflect.TypeOf(models.Employee{}), reflect.TypeOf(models.Job{}))
+
+// Redactor replaces personal data with placeholders such as [SSN_1] and remembers the
+// originals so they can be put back into a response. One Redactor is used per request,
+// so the same value always maps to the same placeholder within a conversation turn.
+type Redactor struct {
+	mu       sync.Mutex
+	values   map[string]string
+	tokens   map[string]string
+	counters map[string]int
+}
+
+// NewRedactor creates an empty Redactor
+func NewRedactor() *Redactor {
+	return &Redactor{
+		values:   make(map[string]string),
+		tokens:   make(map[string]string),
+		counters: make(map[string]int),
+	}
+}
+
+type redactorContextKey struct{}
+
+// WithRedactor attaches r to ctx so every model call of the request shares it
+func WithRedactor(ctx context.Context, r *Redactor) context.Context {
+	return context.WithValue(ctx, redactorContextKey{}, r)
+}
+
+// redactorFromContext returns the request's Redactor, or a fresh one for calls outside a request
+func redactorFromContext(ctx context.Context) *Redactor {
+	if r, ok := ctx.Value(redactorContextKey{}).(*Redactor); ok {
+		return r
+	}
+	return NewRedactor()
+}
+
+// tokenize returns the placeholder for value, creating one if needed
+func (r *Redactor) tokenize(kind, value string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := kind + "\x00" + value
+	if token, ok := r.tokens[key]; ok {
+		return token
+	}
+	r.counters[kind]++
+	token := fmt.Sprintf("[%s_%d]", strings.ToUpper(kind), r.counters[kind])
+	r.tokens[key] = token
+	r.values[token] = value
+	return token
+}
+
+// RedactText replaces SSNs, email addresses and phone numbers in s
+func (r *Redactor) RedactText(s string) string {
+	for _, p := range piiPatterns {
+		s = p.pattern.ReplaceAllStringFunc(s, func(match string) string {
+			return r.tokenize(p.kind, match)
+		})
+	}
+	return s
+}
+
+// RedactValue returns a JSON-shaped copy of v in which every struct field tagged with pii, and
+// every map entry named like one, is replaced by a placeholder and every other string is passed
+// through RedactText.
+func (r *Redactor) RedactValue(v interface{}) interface{} {
+	return r.redact(reflect.ValueOf(v))
+}
+
+func (r *Redactor) redact(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return r.redact(v.Elem())
+
+	case reflect.String:
+		return r.RedactText(v.String())
+
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface()
+		}
+		out := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := jsonName(field)
+			if name == "-" {
+				continue
+			}
+			kind := field.Tag.Get("pii")
+			if kind == "" {
+				kind = untaggedPII[name]
+			}
+			if kind != "" {
+				if !v.Field(i).IsZero() {
+					out[name] = r.tokenize(kind, piiString(v.Field(i)))
+				}
+				continue
+			}
+			out[name] = r.redact(v.Field(i))
+		}
+		return out
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = r.redact(v.Index(i))
+		}
+		return out
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			if kind, ok := piiKeys[key]; ok {
+				if value := iter.Value(); !value.IsZero() && !(value.Kind() == reflect.Interface && value.Elem().IsZero()) {
+					out[key] = r.tokenize(kind, piiString(value))
+				}
+				continue
+			}
+			out[key] = r.redact(iter.Value())
+		}
+		return out
+
+	default:
+		return v.Interface()
+	}
+}
+
+// Rehydrate puts the original values back in place of the placeholders in s
+func (r *Redactor) Rehydrate(s string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return placeholderPattern.ReplaceAllStringFunc(s, func(token string) string {
+		if value, ok := r.values[token]; ok {
+			return value
+		}
+		return token
+	})
+}
+
+// rehydrateFor returns response with personal data restored when the principal on ctx
+// is allowed to see it, and with the placeholders left in place otherwise.
+func rehydrateFor(ctx context.Context, response string) string {
+	if !auth.FromContext(ctx).HasRole(auth.RoleViewPII) {
+		return response
+	}
+	return redactorFromContext(ctx).Rehydrate(response)
+}
+
+// piiString renders a tagged field as the single value its placeholder stands for
+func piiString(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Type() == timeType:
+		return v.Interface().(time.Time).Format("2006-01-02")
+	case v.Kind() == reflect.String:
+		return v.String()
+	case v.Kind() == reflect.Struct:
+		// e.g. an address: join the non-empty parts
+		var parts []string
+		for i := 0; i < v.NumField(); i++ {
+			if s := strings.TrimSpace(fmt.Sprint(v.Field(i).Interface())); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ", ")
+	default:
+		encoded, _ := json.Marshal(v.Interface())
+		return string(encoded)
+	}
+}
+
+// modelPIIKeys collects the names of the pii tagged fields of types and the structs they hold,
+// starting from extra
+func modelPIIKeys(extra map[string]string, types ...reflect.Type) map[string]string {
+	keys := make(map[string]string, len(extra))
+	for name, kind := range extra {
+		keys[name] = kind
+	}
+	seen := make(map[reflect.Type]bool)
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || t == timeType || seen[t] {
+			return
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if kind := field.Tag.Get("pii"); kind != "" {
+				keys[jsonName(field)] = kind
+				if name, _, _ := strings.Cut(field.Tag.Get("bson"), ","); name != "" {
+					keys[name] = kind
+				}
+				continue
+			}
+			walk(field.Type)
+		}
+	}
+	for _, t := range types {
+		walk(t)
+	}
+	return keys
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,352 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"hcmnext/database"
+	"hcmnext/metrics"
+	"hcmnext/models"
+	"hcmnext/vectorstore"
+
+	openai "github.com/sashabaranov/go-openai"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EmbeddingModel is used for both indexed records and retrieval queries
+const EmbeddingModel = openai.SmallEmbedding3
+
+// EmbeddingDimensions is the vector size produced by EmbeddingModel
+const EmbeddingDimensions = 1536
+
+// retrievalLimit is the number of records handed to the model per question
+const retrievalLimit = 8
+
+// embedBatchSize is the number of texts sent per embeddings request
+const embedBatchSize = 100
+
+// SetVectorStore enables the retrieveRecords tool backed by store
+func (c *Client) SetVectorStore(store vectorstore.Store) {
+	c.vectors = store
+}
+
+// Embed returns one embedding per text. Personal data in the texts is replaced by placeholders
+// before they are sent, as for chat completions.
+func (c *Client) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	redactor := redactorFromContext(ctx)
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embedBatchSize {
+		end := min(start+embedBatchSize, len(texts))
+		input := make([]string, end-start)
+		for i, text := range texts[start:end] {
+			input[i] = redactor.RedactText(text)
+		}
+
+		spanCtx, span := tracer.Start(ctx, "llm embed", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+			attrTool.String("embed"),
+			attrLLMSystem.String("openai"),
+			attrLLMModel.String(string(EmbeddingModel)),
+		))
+		began := time.Now()
+		resp, err := c.aiClient.CreateEmbeddings(spanCtx, openai.EmbeddingRequest{
+			Input: input,
+			Model: EmbeddingModel,
+		})
+		metrics.ObserveLLM("embed", string(EmbeddingModel), time.Since(began), resp.Usage.PromptTokens, 0, err != nil)
+		span.SetAttributes(attrLLMInputTokens.Int(resp.Usage.PromptTokens))
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Data) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(resp.Data))
+		}
+
+		batch := make([][]float32, end-start)
+		for _, embedding := range resp.Data {
+			if embedding.Index < 0 || embedding.Index >= len(batch) || batch[embedding.Index] != nil {
+				return nil, fmt.Errorf("unexpected embedding index %d for %d inputs", embedding.Index, len(batch))
+			}
+			batch[embedding.Index] = embedding.Embedding
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// EmployeeRecord describes an employee for retrieval. Only directory-level fields are used:
+// identifiers, names, job titles, departments, locations, managers and responsibilities.
+// Contact details, SSN, personal details and compensation are never indexed.
+func EmployeeRecord(emp models.Employee) vectorstore.Record {
+	var b strings.Builder
+
+	name := emp.FirstName
+	if emp.PreferredName != "" {
+		name = emp.PreferredName
+	}
+	fmt.Fprintf(&b, "Employee %s: %s %s\n", emp.EmployeeID, name, emp.LastName)
+
+	if status := emp.StatusAsOf(time.Now()); status != nil {
+		fmt.Fprintf(&b, "Status: %s\n", status.Status)
+	}
+
+	for _, job := range emp.JobHistory {
+		period := job.StartDate.Format("2006-01")
+		if job.EndDate != nil {
+			period += " to " + job.EndDate.Format("2006-01")
+		} else {
+			period += " to present"
+		}
+		fmt.Fprintf(&b, "%s, %s department, %s, %s (%s, job %s)", job.Title, job.Department, job.Location, job.EmploymentType, period, job.JobID)
+		if job.Manager != nil && job.Manager.Name != "" {
+			fmt.Fprintf(&b, ", reports to %s", job.Manager.Name)
+		}
+		b.WriteString("\n")
+		if len(job.Responsibilities) > 0 {
+			fmt.Fprintf(&b, "Responsibilities: %s\n", strings.Join(job.Responsibilities, "; "))
+		}
+	}
+
+	return vectorstore.Record{
+		ID:   employeeRecordID(emp.EmployeeID),
+		Text: b.String(),
+		Metadata: map[string]string{
+			"kind":       "employee",
+			"employeeId": emp.EmployeeID,
+		},
+	}
+}
+
+func employeeRecordID(employeeID string) string {
+	return "employee:" + employeeID
+}
+
+// JobRecord describes a job, its positions and required skills for retrieval
+func JobRecord(job models.Job) vectorstore.Record {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Job %s: %s\n", job.JobID, job.JobName)
+	if job.JobDescription != "" {
+		fmt.Fprintf(&b, "%s\n", job.JobDescription)
+	}
+	for _, position := range job.Positions {
+		fmt.Fprintf(&b, "Position: %s (%s, %s, %s), experience: %s\n", position.Title, position.Role, position.Level, position.EmploymentType, position.ExperienceRequired)
+		if len(position.SkillsRequired) > 0 {
+			fmt.Fprintf(&b, "Skills: %s\n", strings.Join(position.SkillsRequired, ", "))
+		}
+		if len(position.Certifications) > 0 {
+			fmt.Fprintf(&b, "Certifications: %s\n", strings.Join(position.Certifications, ", "))
+		}
+	}
+	for _, location := range job.Locations {
+		fmt.Fprintf(&b, "Location: %s, %s, %s (remote eligible: %t)\n", location.OfficeName, location.Address.City, location.Address.Country, location.RemoteEligible)
+	}
+	fmt.Fprintf(&b, "Headcount: %d of %d\n", job.Headcount.CurrentHeadcount, job.Headcount.TargetHeadcount)
+	if job.JobRequirements != nil {
+		if job.JobRequirements.EducationLevel != "" {
+			fmt.Fprintf(&b, "Education: %s\n", job.JobRequirements.EducationLevel)
+		}
+		if len(job.JobRequirements.LanguagesRequired) > 0 {
+			fmt.Fprintf(&b, "Languages: %s\n", strings.Join(job.JobRequirements.LanguagesRequired, ", "))
+		}
+	}
+
+	return vectorstore.Record{
+		ID:   jobRecordID(job.JobID),
+		Text: b.String(),
+		Metadata: map[string]string{
+			"kind":  "job",
+			"jobId": job.JobID,
+		},
+	}
+}
+
+func jobRecordID(jobID string) string {
+	return "job:" + jobID
+}
+
+// IndexRecords embeds the records and upserts them into the vector store for the tenant of ctx
+func (c *Client) IndexRecords(ctx context.Context, records []vectorstore.Record) error {
+	if c.vectors == nil {
+		return fmt.Errorf("no vector store configured")
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	texts := make([]string, len(records))
+	for i, record := range records {
+		texts[i] = record.Text
+	}
+	vectors, err := c.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+	for i := range records {
+		records[i].Vector = vectors[i]
+	}
+	scopeRecords(ctx, records)
+
+	return c.vectors.Upsert(ctx, records)
+}
+
+// IndexDatabase indexes every Employee and Job document of the tenant of ctx and returns the number of records indexed
+func (c *Client) IndexDatabase(ctx context.Context, db *database.Database) (int, error) {
+	total := 0
+	db = db.For(ctx)
+
+	employees, err := db.FindMany("Employee", bson.M{})
+	if err != nil {
+		return total, err
+	}
+	defer employees.Close(ctx)
+
+	var batch []vectorstore.Record
+	for employees.Next(ctx) {
+		var emp models.Employee
+		if err := employees.Decode(&emp); err != nil {
+			return total, err
+		}
+		batch = append(batch, EmployeeRecord(emp))
+		if len(batch) == embedBatchSize {
+			if err := c.IndexRecords(ctx, batch); err != nil {
+				return total, err
+			}
+			total += len(batch)
+			batch = nil
+		}
+	}
+	if err := employees.Err(); err != nil {
+		return total, err
+	}
+
+	jobs, err := db.FindMany("Job", bson.M{})
+	if err != nil {
+		return total, err
+	}
+	defer jobs.Close(ctx)
+
+	for jobs.Next(ctx) {
+		var job models.Job
+		if err := jobs.Decode(&job); err != nil {
+			return total, err
+		}
+		batch = append(batch, JobRecord(job))
+		if len(batch) == embedBatchSize {
+			if err := c.IndexRecords(ctx, batch); err != nil {
+				return total, err
+			}
+			total += len(batch)
+			batch = nil
+		}
+	}
+	if err := jobs.Err(); err != nil {
+		return total, err
+	}
+
+	if err := c.IndexRecords(ctx, batch); err != nil {
+		return total, err
+	}
+	total += len(batch)
+
+	return total, nil
+}
+
+// IndexChange brings the record of a changed employee or job of the tenant of ctx up to date,
+// deleting it when the document is gone. Delete events name no document, so deleted employees
+// are dropped through ForgetEmployee instead.
+func (c *Client) IndexChange(ctx context.Context, db *database.Database, change database.ChangeEvent) error {
+	if c.vectors == nil || change.ID == "" {
+		return nil
+	}
+	db = db.For(ctx)
+
+	var record vectorstore.Record
+	var err error
+	switch change.Collection {
+	case "Employee":
+		var emp models.Employee
+		if err = db.FindOne("Employee", bson.M{"employeeId": change.ID}, &emp); err == nil {
+			record = EmployeeRecord(emp)
+		} else if err == mongo.ErrNoDocuments {
+			return c.deleteRecords(ctx, employeeRecordID(change.ID))
+		}
+	case "Job":
+		var job models.Job
+		if err = db.FindOne("Job", bson.M{"jobId": change.ID}, &job); err == nil {
+			record = JobRecord(job)
+		} else if err == mongo.ErrNoDocuments {
+			return c.deleteRecords(ctx, jobRecordID(change.ID))
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return c.IndexRecords(ctx, []vectorstore.Record{record})
+}
+
+// ForgetEmployee removes a deleted employee of the tenant of ctx from the vector store
+func (c *Client) ForgetEmployee(ctx context.Context, employeeID string) error {
+	if c.vectors == nil {
+		return nil
+	}
+	return c.deleteRecords(ctx, employeeRecordID(employeeID))
+}
+
+// deleteRecords removes records of the tenant of ctx from the vector store
+func (c *Client) deleteRecords(ctx context.Context, ids ...string) error {
+	scoped := make([]string, len(ids))
+	for i, id := range ids {
+		scoped[i] = scopeID(ctx, id)
+	}
+	return c.vectors.Delete(ctx, scoped)
+}
+
+// Searches indexed employee profiles and job descriptions for records relevant to the conversation.
+func (c *Client) RetrieveRecords(cachedContext map[string]interface{}, chatMessages []openai.ChatCompletionMessage) (string, error) {
+	ctx := requestContext(cachedContext)
+
+	if c.vectors == nil {
+		return "", fmt.Errorf("no vector store configured")
+	}
+
+	if len(chatMessages) == 0 {
+		return "", fmt.Errorf("no question to retrieve records for")
+	}
+	query := chatMessages[len(chatMessages)-1].Content
+
+	vectors, err := c.Embed(ctx, []string{query})
+	if err != nil {
+		logger.ErrorContext(ctx, "Error embedding query", "err", err)
+		return "", err
+	}
+
+	matches, err := c.vectors.Search(ctx, vectors[0], retrievalLimit, tenantFilter(ctx))
+	if err != nil {
+		logger.ErrorContext(ctx, "Error searching vector store", "err", err)
+		return "", err
+	}
+
+	if len(matches) == 0 {
+		return "No matching HR records were found.", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("HR records relevant to the question, most relevant first:\n")
+	for _, match := range matches {
+		fmt.Fprintf(&b, "\n[%s, relevance %.2f]\n%s", match.ID, match.Score, match.Text)
+	}
+
+	logger.DebugContext(ctx, "Retrieved records", "count", len(matches))
+	return b.String(), nil
+}
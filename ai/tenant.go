@@ -0,0 +1,86 @@
+package ai
+
+import (
+	"context"
+
+	"hcmnext/database"
+	"hcmnext/models"
+	"hcmnext/tenant"
+	"hcmnext/vectorstore"
+)
+
+// tenantContextKey carries the configuration of the tenant a request was reserved for
+const tenantContextKey contextKey = "tenant"
+
+// tenantMetadataKey tags indexed records with the tenant they belong to
+const tenantMetadataKey = "tenant"
+
+// SetTenants applies each tenant's assistant configuration and daily quotas from registry
+func (c *Client) SetTenants(registry *tenant.Registry) {
+	c.tenants = registry
+}
+
+// reserve counts a request against the quota of the tenant of ctx and attaches its configuration
+func (c *Client) reserve(ctx context.Context) (context.Context, error) {
+	if c.tenants == nil {
+		return ctx, nil
+	}
+	t, err := c.tenants.ReserveAIRequest(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, tenantContextKey, t), nil
+}
+
+// modelFor returns the chat model for the tenant of ctx
+func (c *Client) modelFor(ctx context.Context) string {
+	if t, ok := ctx.Value(tenantContextKey).(*models.Tenant); ok && t.AI.Model != "" {
+		return t.AI.Model
+	}
+	return c.Model()
+}
+
+// recordUsage adds the tokens of a model call to the daily usage of the tenant of ctx
+func (c *Client) recordUsage(ctx context.Context, tokens int) {
+	if c.tenants == nil {
+		return
+	}
+	if err := c.tenants.RecordAITokens(ctx, tokens); err != nil {
+		logger.ErrorContext(ctx, "Error recording AI usage", "err", err)
+	}
+}
+
+// scopeRecords ties records to the tenant of ctx, so ids of different tenants cannot collide
+// and searches made for one tenant never return another's records
+func scopeRecords(ctx context.Context, records []vectorstore.Record) {
+	id := database.TenantFromContext(ctx)
+	if id == "" {
+		return
+	}
+	for i := range records {
+		records[i].ID = scopeID(ctx, records[i].ID)
+		metadata := make(map[string]string, len(records[i].Metadata)+1)
+		for k, v := range records[i].Metadata {
+			metadata[k] = v
+		}
+		metadata[tenantMetadataKey] = id
+		records[i].Metadata = metadata
+	}
+}
+
+// scopeID returns the id a record is stored under for the tenant of ctx
+func scopeID(ctx context.Context, id string) string {
+	if tenant := database.TenantFromContext(ctx); tenant != "" {
+		return tenant + "/" + id
+	}
+	return id
+}
+
+// tenantFilter restricts a search to the records of the tenant of ctx
+func tenantFilter(ctx context.Context) vectorstore.Filter {
+	id := database.TenantFromContext(ctx)
+	if id == "" {
+		return nil
+	}
+	return vectorstore.Filter{tenantMetadataKey: id}
+}
@@ -1,8 +1,8 @@
 package ai
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -19,7 +19,7 @@ type MathResponse struct {
 
 // Creates mathematical expressions or calculations.
 func (c *Client) GenerateMath(cachedContext map[string]interface{}, chatMessages []openai.ChatCompletionMessage) (result MathResponse, err error) {
-	ctx := context.Background()
+	ctx := requestContext(cachedContext)
 
 	expression := chatMessages[len(chatMessages)-1].Content
 
@@ -45,10 +45,14 @@ func (c *Client) GenerateMath(cachedContext map[string]interface{}, chatMessages
 	}
 
 	// Prepare the initial user message
+	prompt, err := c.renderPrompt(ctx, "generateMath", chatMessages, nil)
+	if err != nil {
+		return MathResponse{}, err
+	}
 	dialogue := []openai.ChatCompletionMessage{
 		{
 			Role:    openai.ChatMessageRoleSystem,
-			Content: fmt.Sprintln(`I can help you write a Javascript/es6 IIFE that will calculate the result of a mathematical expression. I will return the value.`),
+			Content: prompt.Text,
 		},
 		{
 			Role:    openai.ChatMessageRoleUser,
@@ -57,9 +61,8 @@ func (c *Client) GenerateMath(cachedContext map[string]interface{}, chatMessages
 	}
 
 	// Send the request to OpenAI
-	resp, err := c.aiClient.CreateChatCompletion(ctx,
+	resp, err := c.createChatCompletion(ctx, "generateMath", prompt,
 		openai.ChatCompletionRequest{
-			Model:    "gpt-4o-mini",
 			Messages: dialogue,
 			ResponseFormat: &openai.ChatCompletionResponseFormat{
 				Type:       openai.ChatCompletionResponseFormatTypeJSONSchema,
@@ -69,30 +72,26 @@ func (c *Client) GenerateMath(cachedContext map[string]interface{}, chatMessages
 	)
 
 	if err != nil || len(resp.Choices) != 1 {
-		fmt.Printf("Completion error: err:%v len(choices):%v\n", err, len(resp.Choices))
+		logger.ErrorContext(ctx, "Completion error", "err", err, "choices", len(resp.Choices))
 		return MathResponse{}, err
 	}
 
-	fmt.Printf("OpenAI response: %v\n", resp.Choices[len(resp.Choices)-1].Message.Content)
-
 	// Process the response and function call
 	msg := resp.Choices[0].Message
 
 	var mathResponse MathResponse
 	err = json.Unmarshal([]byte(msg.Content), &mathResponse)
 	if err != nil {
-		fmt.Println("Error unmarshaling JSON")
+		logger.ErrorContext(ctx, "Error unmarshaling math response", "err", err)
 	}
 
 	// Execute the python code
 	mathResults, err := ExecuteMath(mathResponse.Equation)
 	if err != nil {
-		fmt.Println("Error executing python code")
+		logger.ErrorContext(ctx, "Error executing math", "err", err)
 	}
 
 	mathResponse.Value = mathResults
-
-	fmt.Printf("OpenAI answered the original request with: %v\n", msg.Content)
 	return mathResponse, nil
 }
 
@@ -122,7 +121,7 @@ func ExecuteMath(expression string) (result string, err error) {
 	// Trim any whitespace from the output
 	result = strings.TrimSpace(string(out))
 
-	fmt.Printf("Output from the Node.js process: %s\n", result)
+	logger.Debug("Node.js process done", "output_bytes", len(result))
 
 	return result, nil
 }
@@ -143,13 +142,16 @@ func ExecuteMath(expression string) (result string, err error) {
 // }
 
 type DisplayResponse struct {
-	Markup  string `json:"markup"`
-	Context string `json:"context"`
+	Markup     string   `json:"markup"`
+	Context    string   `json:"context"`
+	URL        string   `json:"url,omitempty"`
+	Rejected   bool     `json:"rejected,omitempty"`
+	Violations []string `json:"violations,omitempty"`
 }
 
 // Generates the HTML structure needed to display the parsed data.
 func (c *Client) GenerateDisplayHtml(cachedContext map[string]interface{}, chatMessages []openai.ChatCompletionMessage) (displayContextStruct DisplayResponse, err error) {
-	ctx := context.Background()
+	ctx := requestContext(cachedContext)
 
 	displayContext := chatMessages[len(chatMessages)-1].Content
 
@@ -175,71 +177,14 @@ func (c *Client) GenerateDisplayHtml(cachedContext map[string]interface{}, chatM
 	}
 
 	// Prepare the initial user message
+	prompt, err := c.renderPrompt(ctx, "generateDisplayHtml", chatMessages, nil)
+	if err != nil {
+		return DisplayResponse{}, err
+	}
 	dialogue := []openai.ChatCompletionMessage{
 		{
-			Role: openai.ChatMessageRoleSystem,
-			Content: fmt.Sprintln(`Always generate HTML contest
-			
-			You are an AI designed to generate the contents of the <body> tag of an HTML document. Your task is to create a body section that utilizes the following external resources. Ensure that the content is visually appealing and functional according to the user's specifications.
-
-			Markup Rules:
-			HTML structure must be valid and semantically correct.
-			Use the specified external resources for styling, functionality, and content rendering.
-			Ensure that the content is responsive and visually appealing.
-			Do not include additional scripts or resources beyond the specified ones.
-			DO NOT include the <head> tag or any meta tags in the generated content.
-			Do NOT include any server-side code or backend functionality.
-			Do not include any CSS links or stylesheets in the content.
-			Must Not Include: head tag, meta tags, CSS links, stylesheets, server-side code, backend functionality,script tags with sources.
-			
-			Code RULES:
-			Code must be browser only, no server-side code.
-			Code must be written in JavaScript with es6 syntax.
-			COde must be only use the specified external resources.
-			Code must be optimized for performance and efficiency.
-			Code must use the lowest amount of characters possible.
-			Code must wait always Defer attribute on script tags.
-			Code must never import any external libraries or scripts.
-
-			External Resources:
-			Tailwind CSS for styling.
-			Marked.js for Markdown parsing.
-			Toastify.js for toast notifications.
-			Mermaid.js for diagram generation.
-			Highlight.js for syntax highlighting.
-			Chart.js for charting and data visualization.
-			Three.js for 3D graphics.
-			React for building interactive UIs.
-			React DOM for rendering React components.
-			HTM for writing React components with HTML-like syntax.
-			Prompt to Generate Body Content:
-
-			Create the contents of the <body> tag with the following requirements:
-
-			Structure:
-			Include a clean and responsive layout using Tailwind CSS.
-			Incorporate sections for different functionalities:
-			Markdown Content: Render Markdown content using Marked.js.
-			Diagrams: Display Mermaid.js diagrams.
-			Charts: Visualize data with Chart.js charts.
-			3D Graphics: Render 3D graphics using Three.js.
-			Interactive UIs: Use React and HTM to build interactive components and dynamic UIs.
-
-			Styling:
-			Use Tailwind CSS classes to style the page content.
-			Ensure the content is visually appealing and adheres to modern design principles.
-
-			JavaScript Integration:
-			Ensure that the content integrates and leverages the external scripts effectively.
-			Use Marked.js for Markdown rendering.
-			Initialize Mermaid.js for diagrams.
-			Configure and display charts with Chart.js.
-			Set up and render a 3D scene with Three.js.
-			Build and render interactive UIs using React and HTM.
-
-			User Input:
-			The user will provide additional details or preferences for the page layout, content, or design. Make sure to incorporate these specifics into the body content.
-		`),
+			Role:    openai.ChatMessageRoleSystem,
+			Content: prompt.Text,
 		},
 		{
 			Role:    openai.ChatMessageRoleUser,
@@ -248,9 +193,8 @@ func (c *Client) GenerateDisplayHtml(cachedContext map[string]interface{}, chatM
 	}
 
 	// Send the request to OpenAI
-	resp, err := c.aiClient.CreateChatCompletion(ctx,
+	resp, err := c.createChatCompletion(ctx, "generateDisplayHtml", prompt,
 		openai.ChatCompletionRequest{
-			Model:    "gpt-4o-mini",
 			Messages: dialogue,
 			ResponseFormat: &openai.ChatCompletionResponseFormat{
 				Type:       openai.ChatCompletionResponseFormatTypeJSONSchema,
@@ -260,25 +204,49 @@ func (c *Client) GenerateDisplayHtml(cachedContext map[string]interface{}, chatM
 	)
 
 	if err != nil || len(resp.Choices) != 1 {
-		fmt.Printf("Completion error: err:%v len(choices):%v\n", err, len(resp.Choices))
+		logger.ErrorContext(ctx, "Completion error", "err", err, "choices", len(resp.Choices))
 		return DisplayResponse{}, err
 	}
 
-	fmt.Printf("OpenAI response: %v\n", resp.Choices[len(resp.Choices)-1].Message.Content)
-
 	// Process the response and function call
 	msg := resp.Choices[0].Message
 
 	var displayResponse DisplayResponse
 	err = json.Unmarshal([]byte(msg.Content), &displayResponse)
 	if err != nil {
-		fmt.Println("Error unmarshaling JSON")
+		logger.ErrorContext(ctx, "Error unmarshaling display response", "err", err)
+	}
+
+	// sanitize the markup before it can reach a browser
+	sanitized, err := SanitizeDisplayHtml(displayResponse.Markup)
+	if len(sanitized.Removed) > 0 {
+		logger.InfoContext(ctx, "Removed from display markup", "removed", sanitized.Removed)
+	}
+	if sanitized.InlineScripts > 0 {
+		logger.WarnContext(ctx, "Dropped inline scripts from display markup", "scripts", sanitized.InlineScripts)
+	}
+	if err != nil {
+		logger.WarnContext(ctx, "Rejected display markup", "err", err)
+		var rejected *DisplayRejectedError
+		if errors.As(err, &rejected) {
+			displayResponse.Violations = rejected.Reasons
+		}
+		displayResponse.Rejected = true
+		displayResponse.Markup = "The generated display was withheld because it referenced resources that are not allowed."
+		return displayResponse, nil
+	}
+
+	// keep the markup server side, the browser loads it into a sandboxed iframe by id
+	id, err := c.displays.Put(sanitized.Markup)
+	if err != nil {
+		logger.ErrorContext(ctx, "Error storing display markup", "err", err)
+		return DisplayResponse{}, err
 	}
+	displayResponse.URL = "/display/" + id
 
-	// wrap markup in markdown display template
-	displayResponse.Markup = fmt.Sprintf("```display\n%s\n```", displayResponse.Markup)
+	// wrap the display url in markdown display template
+	displayResponse.Markup = fmt.Sprintf("```display\n%s\n```", displayResponse.URL)
 
-	fmt.Printf("OpenAI answered the original request with: %v\n", msg.Content)
 	return displayResponse, nil
 }
 
@@ -0,0 +1,234 @@
+package ai
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"time"
+
+	"hcmnext/database"
+	"hcmnext/logging"
+	"hcmnext/metrics"
+
+	openai "github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Trace records every model call made while answering one chat request
+type Trace struct {
+	ID             string        `json:"id"`
+	ConversationID string        `json:"conversationId,omitempty"`
+	Tenant         string        `json:"-"`
+	StartedAt      time.Time     `json:"startedAt"`
+	Duration       time.Duration `json:"duration"`
+	Steps          []TraceStep   `json:"steps"`
+	Error          string        `json:"error,omitempty"`
+
+	mu sync.Mutex
+}
+
+// TraceStep is a single model call and the prompt version that produced it
+type TraceStep struct {
+	Tool             string        `json:"tool"`
+	Prompt           string        `json:"prompt"`
+	PromptVersion    string        `json:"promptVersion"`
+	Model            string        `json:"model"`
+	StartedAt        time.Time     `json:"startedAt"`
+	Duration         time.Duration `json:"duration"`
+	PromptTokens     int           `json:"promptTokens"`
+	CompletionTokens int           `json:"completionTokens"`
+	Error            string        `json:"error,omitempty"`
+}
+
+type contextKey string
+
+const (
+	traceContextKey        contextKey = "trace"
+	conversationContextKey contextKey = "conversation"
+)
+
+// requestContextKey is the cachedContext entry carrying the request context to tools,
+// which are invoked by name and only receive cachedContext and the chat messages.
+const requestContextKey = "__context"
+
+// WithConversationID tags ctx with the conversation a request belongs to
+func WithConversationID(ctx context.Context, id string) context.Context {
+	ctx = logging.With(ctx, slog.String(logging.KeyConversation, id))
+	return context.WithValue(ctx, conversationContextKey, id)
+}
+
+// ConversationID returns the conversation id set with WithConversationID
+func ConversationID(ctx context.Context) string {
+	id, _ := ctx.Value(conversationContextKey).(string)
+	return id
+}
+
+// TraceFromContext returns the trace being recorded for ctx, if any
+func TraceFromContext(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceContextKey).(*Trace)
+	return trace
+}
+
+// NewTrace starts a trace and attaches it to ctx
+func NewTrace(ctx context.Context) (context.Context, *Trace) {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+
+	trace := &Trace{
+		ID:             hex.EncodeToString(buf),
+		ConversationID: ConversationID(ctx),
+		Tenant:         database.TenantFromContext(ctx),
+		StartedAt:      time.Now(),
+	}
+	return context.WithValue(ctx, traceContextKey, trace), trace
+}
+
+func (t *Trace) addStep(step TraceStep) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Steps = append(t.Steps, step)
+}
+
+// Finish stamps the total duration and the error the request ended with, if any
+func (t *Trace) Finish(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Duration = time.Since(t.StartedAt)
+	if err != nil {
+		t.Error = err.Error()
+	}
+}
+
+// Snapshot returns a copy of the trace that is safe to read and encode
+func (t *Trace) Snapshot() *Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &Trace{
+		ID:             t.ID,
+		ConversationID: t.ConversationID,
+		Tenant:         t.Tenant,
+		StartedAt:      t.StartedAt,
+		Duration:       t.Duration,
+		Steps:          append([]TraceStep(nil), t.Steps...),
+		Error:          t.Error,
+	}
+}
+
+// traceLog keeps the most recent finished traces
+type traceLog struct {
+	mu     sync.Mutex
+	traces []*Trace
+	size   int
+}
+
+func newTraceLog(size int) *traceLog {
+	return &traceLog{size: size}
+}
+
+func (l *traceLog) add(trace *Trace) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.traces = append(l.traces, trace.Snapshot())
+	if len(l.traces) > l.size {
+		l.traces = l.traces[len(l.traces)-l.size:]
+	}
+}
+
+func (l *traceLog) list(tenant string) []*Trace {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	traces := []*Trace{}
+	for _, trace := range l.traces {
+		if trace.Tenant == tenant {
+			traces = append(traces, trace)
+		}
+	}
+	return traces
+}
+
+// Traces returns the most recent request traces of the tenant of ctx, oldest first
+func (c *Client) Traces(ctx context.Context) []*Trace {
+	return c.traces.list(database.TenantFromContext(ctx))
+}
+
+// RequestValues returns a fresh cachedContext carrying ctx to the tools
+func RequestValues(ctx context.Context) map[string]interface{} {
+	return map[string]interface{}{requestContextKey: ctx}
+}
+
+// requestContext returns the request context stored in cachedContext, or a background context
+func requestContext(cachedContext map[string]interface{}) context.Context {
+	if ctx, ok := cachedContext[requestContextKey].(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+// assignmentKey decides which prompt variants a request gets: the conversation id when known,
+// otherwise the opening message, which stays the same as the conversation grows.
+func assignmentKey(ctx context.Context, chatMessages []openai.ChatCompletionMessage) string {
+	if id := ConversationID(ctx); id != "" {
+		return id
+	}
+	if len(chatMessages) > 0 {
+		return chatMessages[0].Content
+	}
+	return ""
+}
+
+// createChatCompletion redacts and sends req using prompt and records the call in the request trace
+func (c *Client) createChatCompletion(ctx context.Context, tool string, prompt RenderedPrompt, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	req.Model = c.modelFor(ctx)
+
+	// personal data never leaves for the provider, only placeholders do
+	redactor := redactorFromContext(ctx)
+	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
+	for i, message := range req.Messages {
+		message.Content = redactor.RedactText(message.Content)
+		messages[i] = message
+	}
+	req.Messages = messages
+
+	ctx, span := tracer.Start(ctx, "llm "+tool, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attrTool.String(tool),
+		attrPrompt.String(prompt.Name),
+		attrPromptVersion.String(prompt.Version),
+		attrLLMSystem.String("openai"),
+		attrLLMModel.String(req.Model),
+	))
+	defer span.End()
+
+	start := time.Now()
+	resp, err := c.aiClient.CreateChatCompletion(ctx, req)
+	c.recordUsage(ctx, resp.Usage.TotalTokens)
+	metrics.ObserveLLM(tool, req.Model, time.Since(start), resp.Usage.PromptTokens, resp.Usage.CompletionTokens, err != nil)
+	span.SetAttributes(
+		attrLLMInputTokens.Int(resp.Usage.PromptTokens),
+		attrLLMOutputTokens.Int(resp.Usage.CompletionTokens),
+	)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	if trace := TraceFromContext(ctx); trace != nil {
+		step := TraceStep{
+			Tool:             tool,
+			Prompt:           prompt.Name,
+			PromptVersion:    prompt.Version,
+			Model:            req.Model,
+			StartedAt:        start,
+			Duration:         time.Since(start),
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+		}
+		if err != nil {
+			step.Error = err.Error()
+		}
+		trace.addStep(step)
+	}
+
+	return resp, err
+}
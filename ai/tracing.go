@@ -0,0 +1,21 @@
+package ai
+
+import (
+	"hcmnext/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var tracer = tracing.Tracer("ai")
+
+// Span attributes of model calls; the gen_ai keys follow the OpenTelemetry GenAI conventions
+const (
+	attrTool            = attribute.Key("hcm.tool")
+	attrPrompt          = attribute.Key("hcm.prompt")
+	attrPromptVersion   = attribute.Key("hcm.prompt.version")
+	attrConversation    = attribute.Key("hcm.conversation")
+	attrLLMSystem       = attribute.Key("gen_ai.system")
+	attrLLMModel        = attribute.Key("gen_ai.request.model")
+	attrLLMInputTokens  = attribute.Key("gen_ai.usage.input_tokens")
+	attrLLMOutputTokens = attribute.Key("gen_ai.usage.output_tokens")
+)
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"hcmnext/config"
+	"hcmnext/hr"
+	"hcmnext/tenant"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ask answers one prompt through the assistant, as the chat would, and prints the answer. The
+// prompt is the command's arguments, or stdin when there are none. The vector store is used as
+// last indexed; run reindex to refresh it.
+func ask(args []string) {
+	var tenantID, roles string
+	cfg := loadConfig(config.Command{Name: "ask", Database: true, AI: true, Flags: func(flags *flag.FlagSet) {
+		tenantsFlag(flags, &tenantID)
+		flags.StringVar(&roles, "roles", "", "comma-separated roles to ask with, e.g. pii:read to see personal data")
+	}}, args)
+
+	prompt := strings.Join(cfg.Args, " ")
+	if prompt == "" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fatal("Failed to read prompt", err)
+		}
+		prompt = string(data)
+	}
+	if strings.TrimSpace(prompt) == "" {
+		fatal("Nothing to ask", errors.New("give the prompt as arguments or on stdin"))
+	}
+
+	aiClient := newAIClient(cfg)
+	db := openDatabase(cfg)
+	defer db.Close()
+	if db.MultiTenant() && tenantID == "" {
+		db.Close()
+		fatal("Nothing to ask", errors.New("-tenant is required when tenants are isolated"))
+	}
+
+	aiClient.SetTenants(tenant.NewRegistry(db))
+	aiClient.SetOrgService(hr.NewOrgService(db))
+	vectorStore, err := newVectorStore(cfg.VectorStore)
+	if err != nil {
+		db.Close()
+		fatal("Failed to initialize vector store", err)
+	}
+	if vectorStore != nil {
+		aiClient.SetVectorStore(vectorStore)
+	}
+
+	// the chat sends the conversation so far; here it is the one prompt
+	messages, err := json.Marshal([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: prompt}})
+	if err != nil {
+		db.Close()
+		fatal("Failed to encode prompt", err)
+	}
+
+	var granted []string
+	for _, role := range strings.Split(roles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			granted = append(granted, role)
+		}
+	}
+	answer, err := aiClient.HandleRequest(operatorContext(tenantID, granted...), string(messages))
+	if err != nil {
+		db.Close()
+		fatal("Failed to answer prompt", err)
+	}
+	fmt.Println(answer)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"time"
+
+	"hcmnext/config"
+	"hcmnext/database"
+	"hcmnext/hr"
+	"hcmnext/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// auditReport is the outcome of audit verify for one tenant
+type auditReport struct {
+	Tenant           string              `json:"tenant,omitempty"`
+	Employees        int                 `json:"employees"`
+	InvalidEmployees []invalidRecord     `json:"invalidEmployees"`
+	OrgIssues        []hr.OrgIssue       `json:"orgIssues"`
+	HeadcountIssues  []hr.HeadcountIssue `json:"headcountIssues"`
+}
+
+// invalidRecord is an employee breaking the schema or the history rules
+type invalidRecord struct {
+	EmployeeID string   `json:"employeeId"`
+	Problems   []string `json:"problems"`
+}
+
+func (r *auditReport) clean() bool {
+	return len(r.InvalidEmployees) == 0 && len(r.OrgIssues) == 0 && len(r.HeadcountIssues) == 0
+}
+
+// audit verify checks every tenant's data the way the API would: employees against the schema
+// and history rules, reporting lines for cycles and missing managers, and job headcount against
+// the employees in each job. It prints a JSON report and exits with status 1 if anything is wrong.
+func audit(args []string) {
+	if len(args) == 0 || args[0] != "verify" {
+		fatal("Unknown audit", errors.New("the audit command has one subcommand: verify"))
+	}
+
+	var tenant string
+	cfg := loadConfig(config.Command{Name: "audit verify", Database: true, Flags: func(flags *flag.FlagSet) {
+		tenantsFlag(flags, &tenant)
+	}}, args[1:])
+
+	db := openDatabase(cfg)
+	defer db.Close()
+
+	clean := true
+	var reports []*auditReport
+	for _, id := range tenantIDs(db, tenant) {
+		report, err := verifyTenant(operatorContext(id), db)
+		if err != nil {
+			db.Close()
+			fatal("Failed to audit tenant "+id, err)
+		}
+		reports = append(reports, report)
+		clean = clean && report.clean()
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(reports); err != nil {
+		slog.Error("Error writing audit report", "err", err)
+	}
+	if !clean {
+		db.Close()
+		os.Exit(1)
+	}
+}
+
+// verifyTenant audits the data of the tenant of ctx
+func verifyTenant(ctx context.Context, db *database.Database) (*auditReport, error) {
+	report := &auditReport{
+		Tenant:           database.TenantFromContext(ctx),
+		InvalidEmployees: []invalidRecord{},
+	}
+
+	cursor, err := db.For(ctx).FindMany("Employee", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		report.Employees++
+		var emp models.Employee
+		if err := cursor.Decode(&emp); err != nil {
+			id, _ := cursor.Current.Lookup("employeeId").StringValueOK()
+			report.InvalidEmployees = append(report.InvalidEmployees, invalidRecord{EmployeeID: id, Problems: []string{err.Error()}})
+			continue
+		}
+		var invalid *models.ValidationError
+		if err := emp.Validate(); errors.As(err, &invalid) {
+			report.InvalidEmployees = append(report.InvalidEmployees, invalidRecord{EmployeeID: emp.EmployeeID, Problems: invalid.Messages()})
+		} else if err != nil {
+			report.InvalidEmployees = append(report.InvalidEmployees, invalidRecord{EmployeeID: emp.EmployeeID, Problems: []string{err.Error()}})
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	org, err := hr.NewOrgService(db).Load(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	report.OrgIssues = org.Issues()
+
+	if report.HeadcountIssues, err = hr.NewPositionService(db).Check(ctx); err != nil {
+		return nil, err
+	}
+	if report.HeadcountIssues == nil {
+		report.HeadcountIssues = []hr.HeadcountIssue{}
+	}
+	return report, nil
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"hcmnext/logging"
+	"hcmnext/problem"
+)
+
+// RoleViewPII allows a principal to see personal data the AI layer redacts
+const RoleViewPII = "pii:read"
+
+// RoleProvision allows an identity provider to read and update users over SCIM
+const RoleProvision = "scim:provision"
+
+// RoleManageWebhooks allows a principal to subscribe to domain events and manage their deliveries
+const RoleManageWebhooks = "webhooks:manage"
+
+// RoleAdmin allows a principal to see the status of the server and its dependencies
+const RoleAdmin = "admin"
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+	// Tenant is the tenant the token was issued for, if any
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// Anonymous is the principal of requests without credentials
+var Anonymous = Principal{Subject: "anonymous"}
+
+// HasRole reports whether the principal was granted role
+func (p Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// WithPrincipal attaches p to ctx; every record logged with ctx carries its subject
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	ctx = logging.With(ctx, slog.String(logging.KeyUser, p.Subject))
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal of the request, or Anonymous
+func FromContext(ctx context.Context) Principal {
+	if p, ok := ctx.Value(contextKey{}).(Principal); ok {
+		return p
+	}
+	return Anonymous
+}
+
+// Authenticator resolves principals from static bearer tokens
+type Authenticator struct {
+	mu     sync.RWMutex
+	tokens map[[sha256.Size]byte]Principal
+}
+
+// NewAuthenticator parses a token spec of the form
+// "token=subject:role1,role2;token2=tenant/subject2" into an Authenticator.
+// A token whose subject is prefixed with a tenant only works for that tenant.
+func NewAuthenticator(spec string) (*Authenticator, error) {
+	tokens, err := parseTokens(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &Authenticator{tokens: tokens}, nil
+}
+
+// SetTokens replaces the accepted tokens with those in spec, keeping the current ones if spec is invalid
+func (a *Authenticator) SetTokens(spec string) error {
+	tokens, err := parseTokens(spec)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tokens = tokens
+	return nil
+}
+
+func parseTokens(spec string) (map[[sha256.Size]byte]Principal, error) {
+	tokens := make(map[[sha256.Size]byte]Principal)
+
+	for i, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		token, identity, ok := strings.Cut(entry, "=")
+		if !ok || token == "" || identity == "" {
+			// the entry is not quoted, it holds a secret
+			return nil, fmt.Errorf("invalid token entry %d, expected token=subject:roles", i+1)
+		}
+
+		subject, roles, _ := strings.Cut(identity, ":")
+		p := Principal{Subject: subject}
+		if tenant, rest, ok := strings.Cut(subject, "/"); ok {
+			p.Tenant, p.Subject = tenant, rest
+		}
+		for _, role := range strings.Split(roles, ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				p.Roles = append(p.Roles, role)
+			}
+		}
+		tokens[sha256.Sum256([]byte(token))] = p
+	}
+
+	return tokens, nil
+}
+
+// Authenticate returns the principal for token
+func (a *Authenticator) Authenticate(token string) (Principal, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	p, ok := a.tokens[sha256.Sum256([]byte(token))]
+	return p, ok
+}
+
+// Middleware attaches the principal of the request's bearer token to its context.
+// Requests without a token are anonymous; requests with an unknown token are rejected.
+// WebSocket clients cannot set headers, so an access_token query parameter is accepted too.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), Anonymous)))
+			return
+		}
+
+		p, ok := a.Authenticate(token)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid access token")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	})
+}
+
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if scheme, token, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return r.URL.Query().Get("access_token")
+}
@@ -0,0 +1,503 @@
+package bulk
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"hcmnext/database"
+	"hcmnext/hr"
+	"hcmnext/models"
+
+	"github.com/parquet-go/parquet-go"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Export formats, alongside FormatCSV
+const (
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// flushEvery is the number of rows written between flushes to the client
+const flushEvery = 500
+
+// parquetRowGroupSize is the number of rows per Parquet row group
+const parquetRowGroupSize = 10000
+
+// columnKind is the type of a flat export column
+type columnKind int
+
+const (
+	stringColumn columnKind = iota
+	floatColumn
+	intColumn
+	boolColumn
+	timeColumn
+)
+
+// column is one field of the flat CSV and Parquet exports
+type column[T any] struct {
+	name string
+	kind columnKind
+	// pii names the kind of personal data the column holds, if any
+	pii   string
+	value func(row T) interface{}
+}
+
+// employeeRow is an employee with their current view, the unit of the flat employee export
+type employeeRow struct {
+	emp  *models.Employee
+	view models.CurrentView
+}
+
+var employeeColumns = []column[employeeRow]{
+	{"employeeId", stringColumn, "", func(r employeeRow) interface{} { return r.emp.EmployeeID }},
+	{"firstName", stringColumn, "", func(r employeeRow) interface{} { return r.emp.FirstName }},
+	{"lastName", stringColumn, "", func(r employeeRow) interface{} { return r.emp.LastName }},
+	{"preferredName", stringColumn, "", func(r employeeRow) interface{} { return r.emp.PreferredName }},
+	{"email", stringColumn, "email", func(r employeeRow) interface{} { return r.emp.Email }},
+	{"phone", stringColumn, "phone", func(r employeeRow) interface{} { return r.emp.Phone }},
+	{"asOf", timeColumn, "", func(r employeeRow) interface{} { return r.view.AsOf }},
+	{"jobId", stringColumn, "", func(r employeeRow) interface{} { return r.view.JobID }},
+	{"title", stringColumn, "", func(r employeeRow) interface{} { return r.view.Title }},
+	{"department", stringColumn, "", func(r employeeRow) interface{} { return r.view.Department }},
+	{"location", stringColumn, "", func(r employeeRow) interface{} { return r.view.Location }},
+	{"employmentType", stringColumn, "", func(r employeeRow) interface{} { return r.view.EmploymentType }},
+	{"jobStartDate", timeColumn, "", func(r employeeRow) interface{} { return r.view.JobStartDate }},
+	{"managerId", stringColumn, "", func(r employeeRow) interface{} {
+		if r.view.Manager == nil {
+			return nil
+		}
+		return r.view.Manager.EmployeeID
+	}},
+	{"managerName", stringColumn, "", func(r employeeRow) interface{} {
+		if r.view.Manager == nil {
+			return nil
+		}
+		return r.view.Manager.Name
+	}},
+	{"status", stringColumn, "", func(r employeeRow) interface{} { return r.view.Status }},
+	{"statusSince", timeColumn, "", func(r employeeRow) interface{} { return r.view.StatusSince }},
+	{"salary", floatColumn, "", func(r employeeRow) interface{} {
+		if r.view.CompensationEffective == nil {
+			return nil
+		}
+		return r.view.Salary
+	}},
+	{"currency", stringColumn, "", func(r employeeRow) interface{} { return r.view.Currency }},
+	{"payFrequency", stringColumn, "", func(r employeeRow) interface{} { return r.view.PayFrequency }},
+	{"compensationEffective", timeColumn, "", func(r employeeRow) interface{} { return r.view.CompensationEffective }},
+}
+
+var jobColumns = []column[*models.Job]{
+	{"jobId", stringColumn, "", func(j *models.Job) interface{} { return j.JobID }},
+	{"jobName", stringColumn, "", func(j *models.Job) interface{} { return j.JobName }},
+	{"positions", stringColumn, "", func(j *models.Job) interface{} {
+		titles := make([]string, len(j.Positions))
+		for i, p := range j.Positions {
+			titles[i] = p.Title
+		}
+		return strings.Join(titles, "; ")
+	}},
+	{"locations", stringColumn, "", func(j *models.Job) interface{} {
+		names := make([]string, len(j.Locations))
+		for i, l := range j.Locations {
+			names[i] = l.OfficeName
+		}
+		return strings.Join(names, "; ")
+	}},
+	{"currentHeadcount", intColumn, "", func(j *models.Job) interface{} { return j.Headcount.CurrentHeadcount }},
+	{"targetHeadcount", intColumn, "", func(j *models.Job) interface{} { return j.Headcount.TargetHeadcount }},
+	{"openings", intColumn, "", func(j *models.Job) interface{} {
+		return max(j.Headcount.TargetHeadcount-j.Headcount.CurrentHeadcount, 0)
+	}},
+	{"totalBudget", floatColumn, "", func(j *models.Job) interface{} { return j.Budget.TotalBudget }},
+	{"budgetCurrency", stringColumn, "", func(j *models.Job) interface{} { return j.Budget.Currency }},
+	{"postingStatus", stringColumn, "", func(j *models.Job) interface{} {
+		if j.JobPostingDetails == nil {
+			return nil
+		}
+		return j.JobPostingDetails.PostingStatus
+	}},
+	{"recruiterName", stringColumn, "", func(j *models.Job) interface{} {
+		if j.JobPostingDetails == nil || j.JobPostingDetails.Recruiter == nil {
+			return nil
+		}
+		return j.JobPostingDetails.Recruiter.RecruiterName
+	}},
+	{"recruiterEmail", stringColumn, "email", func(j *models.Job) interface{} {
+		if j.JobPostingDetails == nil || j.JobPostingDetails.Recruiter == nil {
+			return nil
+		}
+		return j.JobPostingDetails.Recruiter.RecruiterEmail
+	}},
+	{"creationDate", timeColumn, "", func(j *models.Job) interface{} { return j.CreationDate }},
+}
+
+// ExportOptions selects what an export writes
+type ExportOptions struct {
+	Format string
+	// Fields limits the export to these columns, or these top-level fields for NDJSON; empty means all
+	Fields []string
+	// ShowPII writes personal data unmasked
+	ShowPII bool
+}
+
+// Exporter streams employees and jobs from the database
+type Exporter struct {
+	db *database.Database
+}
+
+// NewExporter creates an exporter
+func NewExporter(db *database.Database) *Exporter {
+	return &Exporter{db: db}
+}
+
+// CheckEmployeeOptions validates opts for an employee export before any output is written
+func CheckEmployeeOptions(opts ExportOptions) error {
+	return checkOptions(opts, employeeColumns, models.Employee{})
+}
+
+// CheckJobOptions validates opts for a job export before any output is written
+func CheckJobOptions(opts ExportOptions) error {
+	return checkOptions(opts, jobColumns, models.Job{})
+}
+
+// ExportEmployees writes every employee matching filter to w: CSV and Parquet hold one flattened
+// row per employee from their current view on filter.AsOf, NDJSON holds the full documents
+func (e *Exporter) ExportEmployees(ctx context.Context, w io.Writer, filter hr.EmployeeFilter, opts ExportOptions) (int, error) {
+	cursor, err := e.db.For(ctx).FindMany("Employee", filter.Query())
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	out, err := newRecordWriter(w, opts, employeeColumns)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for cursor.Next(ctx) {
+		var emp models.Employee
+		if err := cursor.Decode(&emp); err != nil {
+			return count, err
+		}
+		if !filter.Matches(&emp) {
+			continue
+		}
+		if err := out.write(employeeRow{emp: &emp, view: emp.CurrentAsOf(filter.AsOf)}, &emp); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+	return count, out.close()
+}
+
+// ExportJobs writes every job to w: CSV and Parquet hold one summary row per job, NDJSON the full documents
+func (e *Exporter) ExportJobs(ctx context.Context, w io.Writer, opts ExportOptions) (int, error) {
+	cursor, err := e.db.For(ctx).FindMany("Job", bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	out, err := newRecordWriter(w, opts, jobColumns)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for cursor.Next(ctx) {
+		var job models.Job
+		if err := cursor.Decode(&job); err != nil {
+			return count, err
+		}
+		if err := out.write(&job, &job); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+	return count, out.close()
+}
+
+func checkOptions[T any](opts ExportOptions, columns []column[T], document interface{}) error {
+	switch opts.Format {
+	case FormatCSV, FormatParquet:
+		_, err := selectColumns(columns, opts.Fields)
+		return err
+	case FormatNDJSON:
+		for _, field := range opts.Fields {
+			if _, ok := fieldByJSONName(reflect.TypeOf(document), field); !ok {
+				return fmt.Errorf("unknown field %q", field)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported format %q, use csv, ndjson or parquet", opts.Format)
+	}
+}
+
+// recordWriter writes rows of one export in its format
+type recordWriter[T any] struct {
+	opts    ExportOptions
+	columns []column[T]
+	flusher http.Flusher
+	rows    int
+
+	csv     *csv.Writer
+	json    *json.Encoder
+	parquet *parquet.Writer
+	index   []int
+}
+
+func newRecordWriter[T any](w io.Writer, opts ExportOptions, all []column[T]) (*recordWriter[T], error) {
+	columns, err := selectColumns(all, opts.Fields)
+	if err != nil {
+		return nil, err
+	}
+	rw := &recordWriter[T]{opts: opts, columns: columns}
+	rw.flusher, _ = w.(http.Flusher)
+
+	switch opts.Format {
+	case FormatCSV:
+		rw.csv = csv.NewWriter(w)
+		header := make([]string, len(columns))
+		for i, c := range columns {
+			header[i] = c.name
+		}
+		if err := rw.csv.Write(header); err != nil {
+			return nil, err
+		}
+
+	case FormatNDJSON:
+		rw.json = json.NewEncoder(w)
+
+	case FormatParquet:
+		group := parquet.Group{}
+		for _, c := range columns {
+			group[c.name] = parquet.Optional(parquetNode(c.kind))
+		}
+		schema := parquet.NewSchema("export", group)
+		rw.parquet = parquet.NewWriter(w, schema)
+
+		// the schema orders columns by name, rows are built in that order
+		position := make(map[string]int, len(columns))
+		for i, path := range schema.Columns() {
+			position[path[0]] = i
+		}
+		rw.index = make([]int, len(columns))
+		for i, c := range columns {
+			rw.index[i] = position[c.name]
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported format %q, use csv, ndjson or parquet", opts.Format)
+	}
+	return rw, nil
+}
+
+// write writes one record: row feeds the flat formats, document is the full NDJSON document
+func (rw *recordWriter[T]) write(row T, document interface{}) error {
+	var err error
+	switch {
+	case rw.csv != nil:
+		record := make([]string, len(rw.columns))
+		for i, c := range rw.columns {
+			record[i] = csvValue(rw.value(c, row))
+		}
+		err = rw.csv.Write(record)
+
+	case rw.json != nil:
+		var doc interface{} = document
+		if !rw.opts.ShowPII || len(rw.opts.Fields) > 0 {
+			doc = rw.document(document)
+		}
+		err = rw.json.Encode(doc)
+
+	case rw.parquet != nil:
+		values := make(parquet.Row, len(rw.columns))
+		for i, c := range rw.columns {
+			values[rw.index[i]] = parquetValue(c.kind, rw.value(c, row)).Level(0, 1, rw.index[i])
+			if values[rw.index[i]].IsNull() {
+				values[rw.index[i]] = parquet.NullValue().Level(0, 0, rw.index[i])
+			}
+		}
+		_, err = rw.parquet.WriteRows([]parquet.Row{values})
+	}
+	if err != nil {
+		return err
+	}
+
+	rw.rows++
+	if rw.parquet != nil && rw.rows%parquetRowGroupSize == 0 {
+		if err := rw.parquet.Flush(); err != nil {
+			return err
+		}
+	}
+	if rw.rows%flushEvery == 0 {
+		rw.flush()
+	}
+	return nil
+}
+
+// document masks and narrows a full document for NDJSON
+func (rw *recordWriter[T]) document(document interface{}) interface{} {
+	var doc map[string]interface{}
+	if rw.opts.ShowPII {
+		raw, _ := json.Marshal(document)
+		json.Unmarshal(raw, &doc)
+	} else {
+		doc = maskedDocument(document).(map[string]interface{})
+	}
+	if len(rw.opts.Fields) == 0 {
+		return doc
+	}
+
+	narrowed := make(map[string]interface{}, len(rw.opts.Fields))
+	for _, field := range rw.opts.Fields {
+		for name, value := range doc {
+			if strings.EqualFold(name, field) {
+				narrowed[name] = value
+			}
+		}
+	}
+	return narrowed
+}
+
+// value returns the column's value for row, masked when it holds personal data the caller may not see
+func (rw *recordWriter[T]) value(c column[T], row T) interface{} {
+	value := c.value(row)
+	if c.pii != "" && !rw.opts.ShowPII {
+		if s, ok := value.(string); ok {
+			return maskPII(c.pii, s)
+		}
+	}
+	return value
+}
+
+func (rw *recordWriter[T]) flush() {
+	if rw.csv != nil {
+		rw.csv.Flush()
+	}
+	if rw.flusher != nil {
+		rw.flusher.Flush()
+	}
+}
+
+func (rw *recordWriter[T]) close() error {
+	if rw.csv != nil {
+		rw.csv.Flush()
+		return rw.csv.Error()
+	}
+	if rw.parquet != nil {
+		return rw.parquet.Close()
+	}
+	return nil
+}
+
+// selectColumns returns the named columns in the order given, or every column when fields is empty
+func selectColumns[T any](columns []column[T], fields []string) ([]column[T], error) {
+	if len(fields) == 0 {
+		return columns, nil
+	}
+
+	selected := make([]column[T], 0, len(fields))
+	for _, field := range fields {
+		found := false
+		for _, c := range columns {
+			if c.name == field {
+				selected = append(selected, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+	}
+	return selected, nil
+}
+
+func parquetNode(kind columnKind) parquet.Node {
+	switch kind {
+	case floatColumn:
+		return parquet.Leaf(parquet.DoubleType)
+	case intColumn:
+		return parquet.Leaf(parquet.Int64Type)
+	case boolColumn:
+		return parquet.Leaf(parquet.BooleanType)
+	case timeColumn:
+		return parquet.Timestamp(parquet.Millisecond)
+	default:
+		return parquet.String()
+	}
+}
+
+// parquetValue converts a column value, returning a null value for missing ones
+func parquetValue(kind columnKind, value interface{}) parquet.Value {
+	switch v := value.(type) {
+	case nil:
+		return parquet.NullValue()
+	case string:
+		if v == "" {
+			return parquet.NullValue()
+		}
+		return parquet.ByteArrayValue([]byte(v))
+	case float64:
+		return parquet.DoubleValue(v)
+	case int:
+		return parquet.Int64Value(int64(v))
+	case bool:
+		return parquet.BooleanValue(v)
+	case time.Time:
+		if v.IsZero() {
+			return parquet.NullValue()
+		}
+		return parquet.Int64Value(v.UnixMilli())
+	case *time.Time:
+		if v == nil {
+			return parquet.NullValue()
+		}
+		return parquetValue(kind, *v)
+	default:
+		return parquet.ByteArrayValue([]byte(fmt.Sprint(v)))
+	}
+}
+
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.UTC().Format("2006-01-02")
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return csvValue(*v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
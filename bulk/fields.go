@@ -0,0 +1,179 @@
+// Package bulk moves employee and job records in and out of the database in bulk:
+// spreadsheet imports and streaming exports.
+package bulk
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPathIndex bounds the array index a column may address, e.g. jobHistory.49.title
+const maxPathIndex = 49
+
+// dateLayouts are the date formats accepted in spreadsheet cells
+var dateLayouts = []string{"2006-01-02", time.RFC3339, "2006-01-02 15:04:05", "01/02/2006", "1/2/2006", "01-02-06"}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// fieldByJSONName returns the struct field of t whose json name matches name, ignoring case
+func fieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == "" {
+			tag = field.Name
+		}
+		if strings.EqualFold(tag, name) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// checkPath verifies that path, a dotted list of json field names and array indexes such as
+// personalDetails.address.city or jobHistory.0.title, names a settable leaf of t
+func checkPath(t reflect.Type, path string) error {
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		switch {
+		case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index > maxPathIndex {
+				return fmt.Errorf("%s: %q must be an index between 0 and %d", path, segment, maxPathIndex)
+			}
+			t = t.Elem()
+		case t.Kind() == reflect.Struct && t != timeType:
+			field, ok := fieldByJSONName(t, segment)
+			if !ok {
+				return fmt.Errorf("%s: unknown field %q", path, segment)
+			}
+			t = field.Type
+		default:
+			return fmt.Errorf("%s: %q is not a field of %s", path, segment, strings.Join(segments[:i], "."))
+		}
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct && t != timeType || t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct {
+		return fmt.Errorf("%s does not name a single value", path)
+	}
+	return nil
+}
+
+// setPath parses raw into the leaf of v named by path, allocating pointers and growing slices on the way
+func setPath(v reflect.Value, path, raw string) error {
+	for _, segment := range strings.Split(path, ".") {
+		v = deref(v)
+		if v.Kind() == reflect.Slice {
+			index, _ := strconv.Atoi(segment)
+			if v.Len() <= index {
+				grown := reflect.MakeSlice(v.Type(), index+1, index+1)
+				reflect.Copy(grown, v)
+				v.Set(grown)
+			}
+			v = v.Index(index)
+			continue
+		}
+		field, _ := fieldByJSONName(v.Type(), segment)
+		v = v.FieldByIndex(field.Index)
+	}
+	return setValue(deref(v), raw)
+}
+
+// storedField returns the dotted path under which the leaf of v named by path is stored, such as
+// jobHistory.0.title, and the leaf's value. setPath must have filled path.
+func storedField(v reflect.Value, path string) (string, interface{}) {
+	segments := strings.Split(path, ".")
+	stored := make([]string, len(segments))
+	for i, segment := range segments {
+		v = reflect.Indirect(v)
+		if v.Kind() == reflect.Slice {
+			index, _ := strconv.Atoi(segment)
+			stored[i] = strconv.Itoa(index)
+			v = v.Index(index)
+			continue
+		}
+		field, _ := fieldByJSONName(v.Type(), segment)
+		stored[i] = bsonName(field)
+		v = v.FieldByIndex(field.Index)
+	}
+	return strings.Join(stored, "."), reflect.Indirect(v).Interface()
+}
+
+// bsonName is the name field is stored under: its bson tag, or else its name in lower case
+func bsonName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("bson"), ","); name != "" {
+		return name
+	}
+	return strings.ToLower(field.Name)
+}
+
+// deref follows pointers, allocating nil ones
+func deref(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == timeType {
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, raw); err == nil {
+				v.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not a date, use YYYY-MM-DD", raw)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.ReplaceAll(raw, ",", ""), 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.ReplaceAll(raw, ",", ""), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", v.Type())
+		}
+		// lists of strings are written in one cell separated by semicolons
+		var items []string
+		for _, item := range strings.Split(raw, ";") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
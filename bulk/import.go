@@ -0,0 +1,612 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"hcmnext/auth"
+	"hcmnext/database"
+	"hcmnext/events"
+	"hcmnext/logging"
+	"hcmnext/models"
+
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var logger = logging.For("bulk")
+
+// importChunkSize is the number of employees written per bulk write
+const importChunkSize = 500
+
+// importRetention is how long an import stays available to poll after its last progress
+const importRetention = 24 * time.Hour
+
+// importCollection holds the reports of the imports started with Start, so any replica can answer
+// for them and they outlive restarts
+const importCollection = "ImportJob"
+
+// maxImportErrors bounds the row errors kept per import so a bad file cannot exhaust memory
+const maxImportErrors = 1000
+
+// Import formats
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Import job statuses
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// Mapping maps spreadsheet column headers to employee field paths, e.g.
+// "Employee ID" to employeeId or "Title" to jobHistory.0.title. Columns mapped to ""
+// are ignored; columns left out are expected to be named by their field path.
+type Mapping map[string]string
+
+// RowError is a problem with one row of an import. Row numbers count the header as row 1.
+type RowError struct {
+	Row        int      `bson:"row" json:"row"`
+	EmployeeID string   `bson:"employeeId,omitempty" json:"employeeId,omitempty"`
+	Problems   []string `bson:"problems" json:"problems"`
+}
+
+// FileError is a problem with an import file as a whole, such as a file without a header row or
+// columns that name no field
+type FileError struct {
+	Err error
+}
+
+func (e *FileError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// ImportReport is the outcome of an import, or of a dry run
+type ImportReport struct {
+	ID         string     `bson:"importId" json:"id,omitempty"`
+	Status     string     `bson:"status" json:"status"`
+	DryRun     bool       `bson:"dryRun" json:"dryRun"`
+	Rows       int        `bson:"rows" json:"rows"`
+	Processed  int        `bson:"processed" json:"processed"`
+	Valid      int        `bson:"valid" json:"valid"`
+	Inserted   int64      `bson:"inserted" json:"inserted"`
+	Updated    int64      `bson:"updated" json:"updated"`
+	Failed     int        `bson:"failed" json:"failed"`
+	Errors     []RowError `bson:"errors" json:"errors"`
+	Truncated  bool       `bson:"errorsTruncated,omitempty" json:"errorsTruncated,omitempty"`
+	Message    string     `bson:"message,omitempty" json:"message,omitempty"`
+	StartedAt  time.Time  `bson:"startedAt" json:"startedAt"`
+	FinishedAt *time.Time `bson:"finishedAt,omitempty" json:"finishedAt,omitempty"`
+	// ExpiresAt is when the stored report is deleted
+	ExpiresAt time.Time `bson:"expiresAt" json:"-"`
+}
+
+// Importer reads employee spreadsheets and upserts them by employeeId
+type Importer struct {
+	db *database.Database
+}
+
+// NewImporter creates an importer
+func NewImporter(db *database.Database) *Importer {
+	return &Importer{db: db}
+}
+
+// parsedRow is a spreadsheet row turned into an employee, or the problems preventing it
+type parsedRow struct {
+	row      int
+	employee models.Employee
+	// fields are the field paths of the row's cells, which an import writes over the stored employee
+	fields []string
+	// cells are the values of fields as written in the file
+	cells    []string
+	problems []string
+}
+
+// DryRun validates every row against the employees of the tenant of ctx without writing anything
+func (im *Importer) DryRun(ctx context.Context, r io.Reader, format string, mapping Mapping) (*ImportReport, error) {
+	rows, err := parseEmployees(r, format, mapping)
+	if err != nil {
+		return nil, err
+	}
+	if err := im.validate(ctx, rows); err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{Status: ImportCompleted, DryRun: true, Rows: len(rows), StartedAt: time.Now(), Errors: []RowError{}}
+	for _, row := range rows {
+		report.Processed++
+		if len(row.problems) > 0 {
+			report.addError(row)
+			continue
+		}
+		report.Valid++
+	}
+	finished := time.Now()
+	report.FinishedAt = &finished
+	return report, nil
+}
+
+// Start parses the spreadsheet and upserts its valid rows in the background, returning the
+// job to poll with Job. Parsing problems with the file as a whole are returned immediately.
+// The caller on ctx is recorded as the actor of the events the import emits.
+func (im *Importer) Start(ctx context.Context, r io.Reader, format string, mapping Mapping) (*ImportReport, error) {
+	rows, err := parseEmployees(r, format, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newImportID()
+	if err != nil {
+		return nil, err
+	}
+	report := &ImportReport{ID: id, Status: ImportQueued, Rows: len(rows), StartedAt: time.Now(), Errors: []RowError{}}
+	if err := im.save(ctx, report); err != nil {
+		return nil, err
+	}
+	snapshot := *report
+
+	// the request that started the import is over long before a large import finishes
+	runCtx := auth.WithPrincipal(context.Background(), auth.FromContext(ctx))
+	runCtx = database.WithTenant(runCtx, database.TenantFromContext(ctx))
+	go im.run(runCtx, report, rows)
+
+	return &snapshot, nil
+}
+
+// Import parses the spreadsheet and upserts its valid rows before returning, for imports run from
+// the command line. The caller on ctx is recorded as the actor of the events the import emits.
+func (im *Importer) Import(ctx context.Context, r io.Reader, format string, mapping Mapping) (*ImportReport, error) {
+	rows, err := parseEmployees(r, format, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{Status: ImportQueued, Rows: len(rows), StartedAt: time.Now(), Errors: []RowError{}}
+	im.run(ctx, report, rows)
+	return report, nil
+}
+
+// Job returns the report of an import the tenant of ctx started with Start, or nil if there is no such import
+func (im *Importer) Job(ctx context.Context, id string) (*ImportReport, error) {
+	var report ImportReport
+	err := im.db.For(ctx).FindOne(importCollection, bson.M{"importId": id}, &report)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// save stores the progress of an import started with Start; imports run from the command line
+// have no id and are not stored
+func (im *Importer) save(ctx context.Context, report *ImportReport) error {
+	if report.ID == "" {
+		return nil
+	}
+	report.ExpiresAt = time.Now().Add(importRetention)
+	var stored ImportReport
+	return im.db.For(ctx).FindOneAndUpsert(importCollection, bson.M{"importId": report.ID}, bson.M{"$set": report}, &stored)
+}
+
+// progress stores the progress of an import, which goes on if it cannot be stored
+func (im *Importer) progress(ctx context.Context, report *ImportReport) {
+	if err := im.save(ctx, report); err != nil {
+		logger.ErrorContext(ctx, "Error saving import progress", "import", report.ID, "err", err)
+	}
+}
+
+func (im *Importer) run(ctx context.Context, report *ImportReport, rows []parsedRow) {
+	report.Status = ImportRunning
+	im.progress(ctx, report)
+
+	if err := im.validate(ctx, rows); err != nil {
+		im.finish(ctx, report, err)
+		return
+	}
+
+	var chunk []parsedRow
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		defer func() { chunk = chunk[:0] }()
+
+		before, err := im.load(ctx, chunk)
+		if err != nil {
+			return err
+		}
+		operations := make([]mongo.WriteModel, len(chunk))
+		for i, row := range chunk {
+			update, err := importUpdate(row, before[row.employee.EmployeeID] != nil)
+			if err != nil {
+				return err
+			}
+			operations[i] = mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"employeeId": row.employee.EmployeeID}).
+				SetUpdate(update).
+				SetUpsert(true)
+		}
+
+		result, err := im.db.For(ctx).BulkWrite("Employee", operations)
+		if result != nil {
+			if err := im.recordEvents(ctx, chunk, before, err); err != nil {
+				logger.ErrorContext(ctx, "Error recording events", "import", report.ID, "err", err)
+			}
+		}
+		report.Processed += len(chunk)
+		if result != nil {
+			report.Inserted += result.UpsertedCount
+			report.Updated += result.MatchedCount
+		}
+		var bulkErr mongo.BulkWriteException
+		if errors.As(err, &bulkErr) {
+			for _, writeErr := range bulkErr.WriteErrors {
+				row := chunk[writeErr.Index]
+				row.problems = []string{writeErr.Message}
+				report.addError(row)
+			}
+		}
+		im.progress(ctx, report)
+
+		if err != nil && !errors.As(err, &bulkErr) {
+			return err
+		}
+		return nil
+	}
+
+	for _, row := range rows {
+		if err := ctx.Err(); err != nil {
+			im.finish(ctx, report, err)
+			return
+		}
+		if len(row.problems) > 0 {
+			report.Processed++
+			report.addError(row)
+			continue
+		}
+
+		report.Valid++
+		chunk = append(chunk, row)
+		if len(chunk) == importChunkSize {
+			if err := flush(); err != nil {
+				im.finish(ctx, report, err)
+				return
+			}
+		}
+	}
+	im.finish(ctx, report, flush())
+}
+
+// validate checks the employee every row would leave stored. A new employee must be complete; a
+// stored one must stay valid, history order included, once the row's cells are applied to it, so
+// a file may carry only the columns it updates.
+func (im *Importer) validate(ctx context.Context, rows []parsedRow) error {
+	for start := 0; start < len(rows); start += importChunkSize {
+		chunk := rows[start:min(start+importChunkSize, len(rows))]
+		stored, err := im.load(ctx, chunk)
+		if err != nil {
+			return err
+		}
+
+		for i := range chunk {
+			row := &chunk[i]
+			if len(row.problems) > 0 {
+				continue
+			}
+			emp := &row.employee
+			if existing := stored[row.employee.EmployeeID]; existing != nil {
+				target := reflect.ValueOf(existing).Elem()
+				for j, path := range row.fields {
+					// the cells parsed once already
+					setPath(target, path, row.cells[j])
+				}
+				emp = existing
+			}
+
+			var validationErr *models.ValidationError
+			if err := emp.Validate(); errors.As(err, &validationErr) {
+				row.problems = append(row.problems, validationErr.Messages()...)
+			}
+		}
+	}
+	return nil
+}
+
+// load returns the stored employees of chunk by employeeId
+func (im *Importer) load(ctx context.Context, chunk []parsedRow) (map[string]*models.Employee, error) {
+	ids := make([]string, len(chunk))
+	for i, row := range chunk {
+		ids[i] = row.employee.EmployeeID
+	}
+	cursor, err := im.db.For(ctx).FindMany("Employee", bson.M{"employeeId": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var employees []models.Employee
+	if err := cursor.All(ctx, &employees); err != nil {
+		return nil, err
+	}
+	stored := make(map[string]*models.Employee, len(employees))
+	for i := range employees {
+		stored[employees[i].EmployeeID] = &employees[i]
+	}
+	return stored, nil
+}
+
+// importUpdate returns the update writing row. The fields the file has columns for are set and the
+// rest of a stored employee is left as it is, so a file with a few columns cannot wipe history or
+// compensation. A new employee also gets every other field as parsed. Array entries such as
+// jobHistory.0.title are set in place on stored employees; new ones get whole arrays, as an index
+// cannot create an array.
+func importUpdate(row parsedRow, exists bool) (bson.M, error) {
+	raw, err := bson.Marshal(row.employee)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	set := bson.M{}
+	target := reflect.ValueOf(row.employee)
+	for _, path := range row.fields {
+		key, value := storedField(target, path)
+		if !exists {
+			// whole top-level fields, as parsed; fields left empty are not stored
+			key, _, _ = strings.Cut(key, ".")
+			var ok bool
+			if value, ok = doc[key]; !ok {
+				continue
+			}
+		}
+		set[key] = value
+	}
+
+	setOnInsert := bson.M{}
+	for key, value := range doc {
+		if !setsField(set, key) {
+			setOnInsert[key] = value
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(setOnInsert) > 0 {
+		update["$setOnInsert"] = setOnInsert
+	}
+	return update, nil
+}
+
+// setsField reports whether set writes the top-level field key or anything inside it
+func setsField(set bson.M, key string) bool {
+	for path := range set {
+		if path == key || strings.HasPrefix(path, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// recordEvents writes the events of every row of chunk that was written, with the employees as they
+// now stand. Bulk writes cannot share a transaction with the outbox, so the events follow the write
+// rather than commit with it.
+func (im *Importer) recordEvents(ctx context.Context, chunk []parsedRow, before map[string]*models.Employee, writeErr error) error {
+	failed := make(map[int]bool)
+	var bulkErr mongo.BulkWriteException
+	if errors.As(writeErr, &bulkErr) {
+		for _, e := range bulkErr.WriteErrors {
+			failed[e.Index] = true
+		}
+	}
+
+	var written []parsedRow
+	for i, row := range chunk {
+		if !failed[i] {
+			written = append(written, row)
+		}
+	}
+	if len(written) == 0 {
+		return nil
+	}
+	after, err := im.load(ctx, written)
+	if err != nil {
+		return err
+	}
+
+	var recorded []models.OutboxEvent
+	for _, row := range written {
+		emp, ok := after[row.employee.EmployeeID]
+		if !ok {
+			continue
+		}
+		changes, err := events.EmployeeChanges(ctx, before[row.employee.EmployeeID], emp)
+		if err != nil {
+			return err
+		}
+		recorded = append(recorded, changes...)
+	}
+	return events.RecordBatch(im.db.For(ctx), recorded)
+}
+
+func (im *Importer) finish(ctx context.Context, report *ImportReport, err error) {
+	finished := time.Now()
+	report.FinishedAt = &finished
+	report.Status = ImportCompleted
+	if err != nil {
+		report.Status = ImportFailed
+		report.Message = err.Error()
+	}
+	im.progress(ctx, report)
+	logger.InfoContext(ctx, "Import finished", "import", report.ID, "status", report.Status, "rows", report.Rows, "inserted", report.Inserted, "updated", report.Updated, "failed", report.Failed)
+}
+
+func (report *ImportReport) addError(row parsedRow) {
+	report.Failed++
+	if len(report.Errors) == maxImportErrors {
+		report.Truncated = true
+		return
+	}
+	report.Errors = append(report.Errors, RowError{Row: row.row, EmployeeID: row.employee.EmployeeID, Problems: row.problems})
+}
+
+// parseEmployees reads every row of the spreadsheet into an employee; validate checks the rows
+// against the stored employees
+func parseEmployees(r io.Reader, format string, mapping Mapping) ([]parsedRow, error) {
+	records, err := readRecords(r, format)
+	if err != nil {
+		return nil, &FileError{Err: err}
+	}
+	if len(records) == 0 {
+		return nil, &FileError{Err: fmt.Errorf("the file has no header row")}
+	}
+
+	paths, err := columnPaths(records[0], mapping)
+	if err != nil {
+		return nil, &FileError{Err: err}
+	}
+
+	rows := make([]parsedRow, 0, len(records)-1)
+	seen := make(map[string]int)
+	for i, record := range records[1:] {
+		row := parsedRow{row: i + 2}
+		if blankRecord(record) {
+			continue
+		}
+
+		target := reflect.ValueOf(&row.employee).Elem()
+		for col, path := range paths {
+			if path == "" || col >= len(record) {
+				continue
+			}
+			cell := strings.TrimSpace(record[col])
+			if cell == "" {
+				continue
+			}
+			if err := setPath(target, path, cell); err != nil {
+				row.problems = append(row.problems, fmt.Sprintf("%s: %v", records[0][col], err))
+				continue
+			}
+			row.fields = append(row.fields, path)
+			row.cells = append(row.cells, cell)
+		}
+
+		if id := row.employee.EmployeeID; id == "" {
+			row.problems = append(row.problems, "employeeId is required")
+		} else if first, ok := seen[id]; ok {
+			row.problems = append(row.problems, fmt.Sprintf("employeeId %s already appears on row %d", id, first))
+		} else {
+			seen[id] = row.row
+		}
+
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// columnPaths resolves the field path of every column, failing on columns that name no field
+func columnPaths(header []string, mapping Mapping) ([]string, error) {
+	employeeType := reflect.TypeOf(models.Employee{})
+
+	paths := make([]string, len(header))
+	var problems []string
+	for i, column := range header {
+		column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		path, mapped := mapping[column]
+		if !mapped {
+			path = column
+		}
+		if path == "" {
+			continue
+		}
+		if err := checkPath(employeeType, path); err != nil {
+			problems = append(problems, fmt.Sprintf("column %q: %v", column, err))
+			continue
+		}
+		paths[i] = path
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("unmapped columns: %s", strings.Join(problems, "; "))
+	}
+	return paths, nil
+}
+
+func readRecords(r io.Reader, format string) ([][]string, error) {
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV: %v", err)
+		}
+		return records, nil
+
+	case FormatXLSX:
+		raw, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		book, err := excelize.OpenReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("error reading XLSX: %v", err)
+		}
+		defer book.Close()
+
+		sheets := book.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, fmt.Errorf("the workbook has no sheets")
+		}
+		// cells come back formatted as displayed, so dates read like 2024-01-31 when formatted that way
+		records, err := book.GetRows(sheets[0])
+		if err != nil {
+			return nil, fmt.Errorf("error reading sheet %s: %v", sheets[0], err)
+		}
+		return records, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported format %q, use csv or xlsx", format)
+	}
+}
+
+func blankRecord(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func newImportID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,95 @@
+package bulk
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// masked stands in for personal data that has no partially visible form
+const masked = "[REDACTED]"
+
+// maskPII returns a masked form of value according to its pii kind: SSNs and phone numbers
+// keep their last four digits, email addresses keep their domain, everything else is hidden
+func maskPII(kind, value string) string {
+	if value == "" {
+		return ""
+	}
+	switch kind {
+	case "ssn":
+		if len(value) >= 4 {
+			return "***-**-" + value[len(value)-4:]
+		}
+	case "phone":
+		if len(value) >= 4 {
+			return "***" + value[len(value)-4:]
+		}
+	case "email":
+		if _, domain, ok := strings.Cut(value, "@"); ok {
+			return "***@" + domain
+		}
+	}
+	return masked
+}
+
+// maskedDocument returns a JSON-shaped copy of v in which every struct field tagged with pii is masked
+func maskedDocument(v interface{}) interface{} {
+	return maskValue(reflect.ValueOf(v))
+}
+
+func maskValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return maskValue(v.Elem())
+
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface()
+		}
+		out := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if strings.Contains(opts, "omitempty") && v.Field(i).IsZero() {
+				continue
+			}
+			if kind := field.Tag.Get("pii"); kind != "" {
+				if !v.Field(i).IsZero() {
+					out[name] = maskPII(kind, fmt.Sprint(deref(v.Field(i)).Interface()))
+				}
+				continue
+			}
+			out[name] = maskValue(v.Field(i))
+		}
+		return out
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = maskValue(v.Index(i))
+		}
+		return out
+
+	default:
+		return v.Interface()
+	}
+}
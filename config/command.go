@@ -0,0 +1,32 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+)
+
+// Command is a subcommand of the server binary and the parts of the configuration it uses.
+// Only those parts are validated, so migrating the database needs no LLM API key.
+type Command struct {
+	Name string
+	// Flags declares the command's own flags, beside the configuration flags
+	Flags func(*flag.FlagSet)
+	// Serves validates the HTTP server and health check settings
+	Serves bool
+	// Database validates the MongoDB settings
+	Database bool
+	// AI validates the LLM settings
+	AI bool
+}
+
+// Serve is the command running the server, which uses every setting
+var Serve = Command{Name: "serve", Serves: true, Database: true, AI: true}
+
+// UsageFor describes the flags cmd accepts
+func UsageFor(cmd Command) string {
+	flags, _, _ := newFlagSet(Default(), cmd)
+	var b bytes.Buffer
+	flags.SetOutput(&b)
+	flags.PrintDefaults()
+	return b.String()
+}
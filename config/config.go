@@ -0,0 +1,165 @@
+// Package config loads the server configuration from defaults, an optional YAML file, the
+// environment and command-line flags, in increasing order of precedence, and validates it.
+//
+// Every setting has a YAML key and most have an environment variable and a flag, declared with
+// the yaml, env and flag struct tags. Settings tagged reload can change while the server runs,
+// see Watch; the others are structural and take effect on the next start.
+package config
+
+import (
+	"time"
+
+	"hcmnext/logging"
+)
+
+var logger = logging.For("config")
+
+// Config is the complete server configuration
+type Config struct {
+	Server      Server      `yaml:"server"`
+	Database    Database    `yaml:"database"`
+	Tenancy     Tenancy     `yaml:"tenancy"`
+	Auth        Auth        `yaml:"auth"`
+	AI          AI          `yaml:"ai"`
+	VectorStore VectorStore `yaml:"vectorStore"`
+	Log         Log         `yaml:"log"`
+	Tracing     Tracing     `yaml:"tracing"`
+	Health      Health      `yaml:"health"`
+
+	// File is the YAML file the configuration was read from, if any
+	File string `yaml:"-"`
+	// Args are the arguments left after the flags, for commands that take operands
+	Args []string `yaml:"-"`
+	// command and args are the command and its command-line arguments, kept to load the
+	// configuration again on reload
+	command Command
+	args    []string
+}
+
+// Server configures the HTTP server
+type Server struct {
+	Addr            string        `yaml:"addr" env:"HTTP_ADDR" flag:"addr"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" reload:"true"`
+	// DrainTimeout is how long chat answers in progress may take to finish on shutdown, before
+	// ShutdownTimeout applies to the other requests
+	DrainTimeout time.Duration `yaml:"drainTimeout" env:"DRAIN_TIMEOUT" flag:"drain-timeout" reload:"true"`
+	StaticDir    string        `yaml:"staticDir" env:"STATIC_DIR" flag:"static-dir"`
+	// AllowedOrigins are the origins, besides the server's own, allowed to open the chat WebSocket
+	AllowedOrigins []string `yaml:"allowedOrigins" env:"ALLOWED_ORIGINS" reload:"true"`
+	// CheckResponses logs API responses that do not match the OpenAPI description, at the cost of
+	// buffering them; for development and staging
+	CheckResponses bool `yaml:"checkResponses" env:"CHECK_RESPONSES" flag:"check-responses" reload:"true"`
+}
+
+// Database configures MongoDB
+type Database struct {
+	URI  string `yaml:"uri" env:"MONGO_URI" flag:"mongo-uri"`
+	Name string `yaml:"name" env:"DB_NAME" flag:"db-name"`
+	// Collections are counted at startup and streamed to open browser sessions as they change
+	Collections []string `yaml:"collections" env:"DB_COLLECTIONS"`
+}
+
+// Tenancy configures tenant isolation
+type Tenancy struct {
+	// MultiTenant gives every tenant a database of its own, named after the database name and the tenant id
+	MultiTenant bool `yaml:"multiTenant" env:"MULTI_TENANT" flag:"multi-tenant"`
+	// Domain is the domain tenant subdomains live under, e.g. hcm.example.com for acme.hcm.example.com
+	Domain string `yaml:"domain" env:"TENANT_DOMAIN"`
+}
+
+// Auth configures API access
+type Auth struct {
+	// Tokens is the bearer token spec parsed by auth.NewAuthenticator
+	Tokens string `yaml:"tokens" env:"API_TOKENS" reload:"true"`
+}
+
+// AI configures the LLM backend. BaseURL points the client at any OpenAI-compatible
+// server, such as a local model, in which case APIKey may be empty.
+type AI struct {
+	APIKey  string `yaml:"apiKey" env:"OPENAI_API_KEY"`
+	BaseURL string `yaml:"baseURL" env:"OPENAI_BASE_URL" flag:"openai-base-url"`
+	// Model is the chat model; the AI client picks its default when empty
+	Model string `yaml:"model" env:"OPENAI_MODEL" flag:"openai-model" reload:"true"`
+	// PromptDir lets prompt templates be edited on disk without a rebuild
+	PromptDir string `yaml:"promptDir" env:"PROMPT_DIR" flag:"prompt-dir"`
+}
+
+// VectorStore selects and configures the vector store used for retrieval
+type VectorStore struct {
+	// Kind is hnsw, milvus or none
+	Kind string `yaml:"kind" env:"VECTOR_STORE" flag:"vector-store"`
+	// HNSWIndexPath is where the in-process index is loaded from and saved to, if set
+	HNSWIndexPath string `yaml:"hnswIndexPath" env:"HNSW_INDEX_PATH"`
+	Milvus        Milvus `yaml:"milvus"`
+}
+
+// Milvus configures the Milvus vector store
+type Milvus struct {
+	Address    string `yaml:"address" env:"MILVUS_ADDRESS"`
+	Token      string `yaml:"token" env:"MILVUS_TOKEN"`
+	Database   string `yaml:"database" env:"MILVUS_DATABASE"`
+	Collection string `yaml:"collection" env:"MILVUS_COLLECTION"`
+}
+
+// Log configures logging
+type Log struct {
+	// Level is the minimum level logged: debug, info, warn or error
+	Level string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" reload:"true"`
+	// Packages override Level for single packages, as package=level, e.g. ai=debug
+	Packages []string `yaml:"packages" env:"LOG_PACKAGES" reload:"true"`
+}
+
+// Tracing configures OpenTelemetry tracing
+type Tracing struct {
+	// Exporter is otlp, stdout or none
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER" flag:"tracing-exporter"`
+	// Endpoint is the OTLP/HTTP collector URL; OTEL_EXPORTER_OTLP_ENDPOINT applies when it is empty
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT"`
+	ServiceName string  `yaml:"serviceName" env:"OTEL_SERVICE_NAME"`
+	SampleRatio float64 `yaml:"sampleRatio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+// Health configures the dependency checks of the health endpoints
+type Health struct {
+	// Timeout bounds each check
+	Timeout time.Duration `yaml:"timeout" env:"HEALTH_TIMEOUT"`
+	// CheckLLM checks that the LLM provider answers, at most once per LLMInterval; a failure
+	// degrades readiness but does not fail it
+	CheckLLM    bool          `yaml:"checkLLM" env:"HEALTH_CHECK_LLM"`
+	LLMInterval time.Duration `yaml:"llmInterval" env:"HEALTH_LLM_INTERVAL"`
+}
+
+// Default returns the configuration used for every setting no source sets
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Addr:            ":8080",
+			ShutdownTimeout: 5 * time.Second,
+			DrainTimeout:    20 * time.Second,
+			StaticDir:       "static",
+			AllowedOrigins:  []string{"http://localhost:8080", "127.0.0.1:8800"},
+		},
+		Database: Database{
+			Collections: []string{"Employee", "Job"},
+		},
+		VectorStore: VectorStore{
+			Kind: "hnsw",
+			Milvus: Milvus{
+				Collection: "hr_records",
+			},
+		},
+		Log: Log{
+			Level: "info",
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			ServiceName: "hcmnext",
+			SampleRatio: 1,
+		},
+		Health: Health{
+			Timeout:     2 * time.Second,
+			CheckLLM:    true,
+			LLMInterval: time.Minute,
+		},
+	}
+}
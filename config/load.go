@@ -0,0 +1,214 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Error lists every problem found while loading or validating a configuration
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return strings.Join(e.Problems, "\n")
+}
+
+// setting is one leaf of the configuration and the struct field it is stored in
+type setting struct {
+	key   string
+	field reflect.StructField
+	value reflect.Value
+}
+
+// Load builds the configuration of the server from, in increasing order of precedence: the
+// defaults, the YAML file named by -config or CONFIG_FILE, a .env file if there is one, the
+// environment and the flags in args. It returns an *Error listing every problem when the result
+// is not valid, or flag.ErrHelp when args ask for help.
+func Load(args []string) (*Config, error) {
+	return LoadCommand(Serve, args)
+}
+
+// LoadCommand builds the configuration like Load for cmd, whose own flags are parsed from args
+// too. The arguments left after the flags are in Args.
+func LoadCommand(cmd Command, args []string) (*Config, error) {
+	var problems []string
+
+	// a .env file is a development convenience, containers set the environment directly
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		problems = append(problems, fmt.Sprintf(".env: %v", err))
+	}
+
+	cfg := Default()
+	cfg.command = cmd
+	cfg.args = args
+
+	flags, file, values := newFlagSet(cfg, cmd)
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return nil, err
+	} else if err != nil {
+		return nil, &Error{Problems: append(problems, err.Error())}
+	}
+
+	cfg.Args = flags.Args()
+	cfg.File = *file
+	if cfg.File == "" {
+		cfg.File = os.Getenv("CONFIG_FILE")
+	}
+	if cfg.File != "" {
+		if err := readFile(cfg, cfg.File); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", cfg.File, err))
+		}
+	}
+
+	for _, s := range settings(cfg) {
+		name := s.field.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		if raw, ok := os.LookupEnv(name); ok {
+			if err := set(s.value, raw); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		s, ok := values[f.Name]
+		if !ok {
+			return
+		}
+		if err := set(s.value, f.Value.String()); err != nil {
+			problems = append(problems, fmt.Sprintf("-%s: %v", f.Name, err))
+		}
+	})
+
+	problems = append(problems, cfg.validate(cmd)...)
+	if len(problems) > 0 {
+		return nil, &Error{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// newFlagSet declares -config, a flag for every setting with a flag tag and the flags of cmd
+func newFlagSet(cfg *Config, cmd Command) (*flag.FlagSet, *string, map[string]setting) {
+	flags := flag.NewFlagSet("hcmnext "+cmd.Name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	file := flags.String("config", "", "YAML configuration file (env CONFIG_FILE)")
+
+	values := make(map[string]setting)
+	for _, s := range settings(cfg) {
+		name := s.field.Tag.Get("flag")
+		if name == "" {
+			continue
+		}
+		usage := "sets " + s.key
+		if env := s.field.Tag.Get("env"); env != "" {
+			usage += " (env " + env + ")"
+		}
+		flags.String(name, "", usage)
+		values[name] = s
+	}
+	if cmd.Flags != nil {
+		cmd.Flags(flags)
+	}
+	return flags, file, values
+}
+
+// Usage describes the flags Load accepts
+func Usage() string {
+	return UsageFor(Serve)
+}
+
+// readFile decodes the YAML file at path over cfg, rejecting keys that match no setting
+func readFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// settings lists the leaves of cfg with their dotted YAML keys, e.g. server.addr
+func settings(cfg *Config) []setting {
+	var out []setting
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if !field.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			key := prefix + name
+			if field.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), key+".")
+				continue
+			}
+			out = append(out, setting{key: key, field: field, value: v.Field(i)})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return out
+}
+
+// set parses raw into v; lists are comma separated
+func set(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+)
+
+// pollInterval is how often the configuration file is checked for changes
+const pollInterval = 5 * time.Second
+
+// Changes lists the keys of the settings that differ between two configurations
+type Changes struct {
+	// Reloadable settings are applied while the server runs
+	Reloadable []string
+	// Structural settings only take effect after a restart
+	Structural []string
+}
+
+// Diff compares cfg with next
+func (cfg *Config) Diff(next *Config) Changes {
+	var changes Changes
+	current := settings(cfg)
+	for i, s := range settings(next) {
+		if reflect.DeepEqual(current[i].value.Interface(), s.value.Interface()) {
+			continue
+		}
+		if s.field.Tag.Get("reload") == "true" {
+			changes.Reloadable = append(changes.Reloadable, s.key)
+		} else {
+			changes.Structural = append(changes.Structural, s.key)
+		}
+	}
+	return changes
+}
+
+// withReloadable returns a copy of cfg with the reloadable settings taken from next
+func (cfg *Config) withReloadable(next *Config) *Config {
+	merged := *cfg
+	from := settings(next)
+	for i, s := range settings(&merged) {
+		if s.field.Tag.Get("reload") == "true" {
+			s.value.Set(from[i].value)
+		}
+	}
+	return &merged
+}
+
+// Watch loads the configuration again whenever the process receives SIGHUP or the configuration
+// file changes, until ctx is cancelled. When the new configuration is valid its reloadable settings
+// are handed to apply; changed structural settings are logged and wait for a restart. An invalid
+// configuration is logged with all its problems and the running one is kept.
+func (cfg *Config) Watch(ctx context.Context, apply func(*Config)) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	current := cfg
+	modified := modTime(cfg.File)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+		case <-ticker.C:
+			if cfg.File == "" {
+				continue
+			}
+			latest := modTime(cfg.File)
+			if latest.Equal(modified) {
+				continue
+			}
+			modified = latest
+		}
+
+		next, err := LoadCommand(current.command, current.args)
+		var invalid *Error
+		if errors.As(err, &invalid) {
+			logger.Error("Keeping the running configuration, the new one is invalid", "problems", invalid.Problems)
+			continue
+		}
+		if err != nil {
+			logger.Error("Error reloading configuration", "err", err)
+			continue
+		}
+
+		changes := current.Diff(next)
+		if len(changes.Structural) > 0 {
+			logger.Warn("Restart to apply the changes", "settings", changes.Structural)
+		}
+		if len(changes.Reloadable) == 0 {
+			continue
+		}
+		current = current.withReloadable(next)
+		apply(current)
+		logger.Info("Applied the changes", "settings", changes.Reloadable)
+	}
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
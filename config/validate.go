@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"hcmnext/auth"
+	"hcmnext/logging"
+)
+
+// maxTenantDBNameLength leaves room in MongoDB's 63 byte database names for "_" and a 32 byte tenant id
+const maxTenantDBNameLength = 30
+
+// Validate returns every problem with the configuration, or nothing when it is usable
+func (cfg *Config) Validate() []string {
+	return cfg.validate(Serve)
+}
+
+// validate returns the problems with the settings cmd uses
+func (cfg *Config) validate(cmd Command) []string {
+	var problems []string
+	add := func(key, format string, args ...interface{}) {
+		problems = append(problems, key+": "+fmt.Sprintf(format, args...))
+	}
+
+	if cmd.Serves {
+		cfg.validateServer(add)
+	}
+	if cmd.Database {
+		cfg.validateDatabase(add)
+	}
+	if cmd.AI && cfg.AI.APIKey == "" && cfg.AI.BaseURL == "" {
+		add("ai.apiKey", "is required unless ai.baseURL points at a server that needs none (env OPENAI_API_KEY)")
+	}
+
+	if _, err := auth.NewAuthenticator(cfg.Auth.Tokens); err != nil {
+		add("auth.tokens", "%v", err)
+	}
+
+	switch cfg.VectorStore.Kind {
+	case "hnsw", "none":
+	case "milvus":
+		if cfg.VectorStore.Milvus.Address == "" {
+			add("vectorStore.milvus.address", "is required for the milvus vector store (env MILVUS_ADDRESS)")
+		}
+		if cfg.VectorStore.Milvus.Collection == "" {
+			add("vectorStore.milvus.collection", "must not be empty")
+		}
+	default:
+		add("vectorStore.kind", "must be hnsw, milvus or none, got %q", cfg.VectorStore.Kind)
+	}
+
+	switch cfg.Tracing.Exporter {
+	case "otlp", "stdout", "none":
+	default:
+		add("tracing.exporter", "must be otlp, stdout or none, got %q", cfg.Tracing.Exporter)
+	}
+	if cfg.Tracing.Endpoint != "" && !strings.HasPrefix(cfg.Tracing.Endpoint, "http://") && !strings.HasPrefix(cfg.Tracing.Endpoint, "https://") {
+		add("tracing.endpoint", "must be an http:// or https:// URL")
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		add("tracing.sampleRatio", "must be between 0 and 1")
+	}
+
+	if _, _, err := logging.ParseLevels(cfg.Log.Level, cfg.Log.Packages); err != nil {
+		add("log", "%v", err)
+	}
+
+	return problems
+}
+
+// validateServer checks the settings only the server uses
+func (cfg *Config) validateServer(add func(key, format string, args ...interface{})) {
+	if _, _, err := net.SplitHostPort(cfg.Server.Addr); err != nil {
+		add("server.addr", "must be host:port or :port, got %q", cfg.Server.Addr)
+	}
+	if cfg.Server.ShutdownTimeout <= 0 {
+		add("server.shutdownTimeout", "must be positive")
+	}
+	if cfg.Server.DrainTimeout < 0 {
+		add("server.drainTimeout", "must not be negative")
+	}
+	if info, err := os.Stat(cfg.Server.StaticDir); err != nil || !info.IsDir() {
+		add("server.staticDir", "%q is not a directory", cfg.Server.StaticDir)
+	}
+
+	if cfg.Health.Timeout <= 0 {
+		add("health.timeout", "must be positive")
+	}
+	if cfg.Health.CheckLLM && cfg.Health.LLMInterval <= 0 {
+		add("health.llmInterval", "must be positive")
+	}
+}
+
+// validateDatabase checks the MongoDB settings
+func (cfg *Config) validateDatabase(add func(key, format string, args ...interface{})) {
+	switch {
+	case cfg.Database.URI == "":
+		add("database.uri", "is required (env MONGO_URI)")
+	case !strings.HasPrefix(cfg.Database.URI, "mongodb://") && !strings.HasPrefix(cfg.Database.URI, "mongodb+srv://"):
+		add("database.uri", "must be a mongodb:// or mongodb+srv:// URI")
+	}
+	switch {
+	case cfg.Database.Name == "":
+		add("database.name", "is required (env DB_NAME)")
+	case strings.ContainsAny(cfg.Database.Name, `/\. "$`):
+		add("database.name", "must not contain / \\ . space \" or $")
+	case cfg.Tenancy.MultiTenant && len(cfg.Database.Name) > maxTenantDBNameLength:
+		add("database.name", "must be at most %d characters when tenants are isolated", maxTenantDBNameLength)
+	}
+	if len(cfg.Database.Collections) == 0 {
+		add("database.collections", "must list at least one collection")
+	}
+
+	if cfg.Tenancy.Domain != "" && (strings.Contains(cfg.Tenancy.Domain, "/") || strings.Contains(cfg.Tenancy.Domain, ":")) {
+		add("tenancy.domain", "must be a bare domain such as hcm.example.com")
+	}
+	if cfg.Tenancy.Domain != "" && !cfg.Tenancy.MultiTenant {
+		add("tenancy.domain", "is only used when tenancy.multiTenant is set")
+	}
+}
@@ -1,20 +1,37 @@
 package controller
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
+	"context"
+	"errors"
 	"net/http"
+	"net/url"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"hcmnext/database"
+	"hcmnext/events"
+	"hcmnext/hr"
 	"hcmnext/models"
+	"hcmnext/problem"
 )
 
 // API struct holds dependencies for the API handlers
 type API struct {
 	DB *database.Database
+	// index drops deleted employees from retrieval, see SetIndex
+	index EmployeeIndex
+}
+
+// EmployeeIndex is a search index of employees, such as the retrieval index of the assistant
+type EmployeeIndex interface {
+	ForgetEmployee(ctx context.Context, employeeID string) error
+}
+
+// employeeResponse is an employee together with the view of them in effect on the requested day
+type employeeResponse struct {
+	models.Employee
+	Current models.CurrentView `json:"current"`
 }
 
 // NewAPI creates a new instance of API
@@ -22,127 +39,191 @@ func NewAPI(db *database.Database) *API {
 	return &API{DB: db}
 }
 
-// CreateEmployee handles the creation of a new employee
-func (api *API) CreateEmployee(w http.ResponseWriter, r *http.Request) {
+// SetIndex removes deleted employees from index
+func (api *API) SetIndex(index EmployeeIndex) {
+	api.index = index
+}
+
+// CreateEmployee handles the creation of a new employee, answering with the employee created
+func (api *API) CreateEmployee(w http.ResponseWriter, r *http.Request) error {
 	var emp models.Employee
-	if err := json.NewDecoder(r.Body).Decode(&emp); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+	if err := decodeJSON(r, &emp); err != nil {
+		return err
+	}
+
+	if err := emp.Validate(); err != nil {
+		return err
 	}
 
-	result, err := api.DB.InsertOne("Employee", emp)
+	err := api.DB.WithTransaction(r.Context(), func(tx *database.Tx) error {
+		if _, err := tx.InsertOne("Employee", emp); err != nil {
+			return err
+		}
+		created, err := events.EmployeeChanges(r.Context(), nil, &emp)
+		if err != nil {
+			return err
+		}
+		return events.Record(tx, created...)
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return hr.ErrEmployeeExists
+	}
 	if err != nil {
-		http.Error(w, "Failed to create employee", http.StatusInternalServerError)
-		return
+		logger.ErrorContext(r.Context(), "Error creating employee", "handler", "CreateEmployee", "err", err)
+		return internalError("Failed to create employee")
 	}
 
+	w.Header().Set("Location", "/api/employees/"+url.PathEscape(emp.EmployeeID))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(result)
+	writeJSON(w, "CreateEmployee", employeeResponse{Employee: emp, Current: emp.CurrentAsOf(time.Now())})
+	return nil
 }
 
-// GetEmployees retrieves all employees
-func (api *API) GetEmployees(w http.ResponseWriter, r *http.Request) {
-	log.Println("GetEmployees: Start retrieving employees")
+// GetEmployees retrieves all employees, or those whose current view matches the
+// department, location, jobId, title, employmentType, managerId and status query parameters
+func (api *API) GetEmployees(w http.ResponseWriter, r *http.Request) error {
+	logger.DebugContext(r.Context(), "Retrieving employees", "handler", "GetEmployees")
 
-	filter := bson.M{}
-	cursor, err := api.DB.FindMany("Employee", filter)
+	filter, err := parseEmployeeFilter(r)
 	if err != nil {
-		log.Printf("GetEmployees: Error retrieving employees from database: %v", err)
-		http.Error(w, "Failed to retrieve employees", http.StatusInternalServerError)
-		return
+		return err
+	}
+
+	cursor, err := api.DB.For(r.Context()).FindMany("Employee", filter.Query())
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error retrieving employees from database", "handler", "GetEmployees", "err", err)
+		return internalError("Failed to retrieve employees")
 	}
 	defer cursor.Close(r.Context())
 
-	var employees []models.Employee
-	if err = cursor.All(r.Context(), &employees); err != nil {
-		log.Printf("GetEmployees: Error decoding employees: %v", err)
-		http.Error(w, "Failed to process employees", http.StatusInternalServerError)
-		return
+	employees := []models.Employee{}
+	for cursor.Next(r.Context()) {
+		var emp models.Employee
+		if err := cursor.Decode(&emp); err != nil {
+			logger.ErrorContext(r.Context(), "Error decoding employees", "handler", "GetEmployees", "err", err)
+			return internalError("Failed to process employees")
+		}
+		if filter.Matches(&emp) {
+			employees = append(employees, emp)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		logger.ErrorContext(r.Context(), "Error decoding employees", "handler", "GetEmployees", "err", err)
+		return internalError("Failed to process employees")
 	}
 
-	log.Printf("GetEmployees: Retrieved %d employees", len(employees))
+	logger.DebugContext(r.Context(), "Retrieved employees", "handler", "GetEmployees", "count", len(employees))
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(employees); err != nil {
-		log.Printf("GetEmployees: Error encoding response to JSON: %v", err)
-		http.Error(w, "Failed to encode employees as JSON", http.StatusInternalServerError)
-	}
+	writeJSON(w, "GetEmployees", employees)
+	return nil
 }
 
-// GetEmployee retrieves a single employee by ID
-func (api *API) GetEmployee(w http.ResponseWriter, r *http.Request) {
+// GetEmployee retrieves a single employee by ID along with their current view,
+// as of today or the day given in the asOf query parameter (YYYY-MM-DD)
+func (api *API) GetEmployee(w http.ResponseWriter, r *http.Request) error {
 	employeeID := r.PathValue("id")
 
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		return err
+	}
+
 	var emp models.Employee
 	filter := bson.M{"employeeId": employeeID}
-	err := api.DB.FindOne("Employee", filter, &emp)
+	err = api.DB.For(r.Context()).FindOne("Employee", filter, &emp)
+	if err == mongo.ErrNoDocuments {
+		return hr.ErrEmployeeNotFound
+	}
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			http.Error(w, "Employee not found", http.StatusNotFound)
-		} else {
-			log.Printf("GetEmployee: Error retrieving employee: %v", err)
-			http.Error(w, "Failed to retrieve employee", http.StatusInternalServerError)
-		}
-		return
+		logger.ErrorContext(r.Context(), "Error retrieving employee", "handler", "GetEmployee", "err", err)
+		return internalError("Failed to retrieve employee")
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(emp); err != nil {
-		log.Printf("GetEmployee: Error encoding response to JSON: %v", err)
-		http.Error(w, "Failed to encode employee as JSON", http.StatusInternalServerError)
-	}
+	writeJSON(w, "GetEmployee", employeeResponse{Employee: emp, Current: emp.CurrentAsOf(asOf)})
+	return nil
 }
 
-// UpdateEmployee updates an existing employee
-func (api *API) UpdateEmployee(w http.ResponseWriter, r *http.Request) {
+// UpdateEmployee replaces an existing employee, answering with the employee updated
+func (api *API) UpdateEmployee(w http.ResponseWriter, r *http.Request) error {
 	employeeID := r.PathValue("id")
 
 	var emp models.Employee
-	if err := json.NewDecoder(r.Body).Decode(&emp); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+	if err := decodeJSON(r, &emp); err != nil {
+		return err
 	}
 
 	if emp.EmployeeID != employeeID {
-		http.Error(w, "ID in URL does not match ID in request body", http.StatusBadRequest)
-		return
+		return problem.Invalid("ID in URL does not match ID in request body",
+			problem.Pointer("employeeId", "must be "+employeeID))
+	}
+
+	if err := emp.Validate(); err != nil {
+		return err
 	}
 
 	filter := bson.M{"employeeId": employeeID}
 	update := bson.M{"$set": emp}
 
-	result, err := api.DB.UpdateOne("Employee", filter, update)
-	if err != nil {
-		http.Error(w, "Failed to update employee", http.StatusInternalServerError)
-		return
+	err := api.DB.WithTransaction(r.Context(), func(tx *database.Tx) error {
+		var before models.Employee
+		if err := tx.FindOne("Employee", filter, &before); err != nil {
+			return err
+		}
+		result, err := tx.UpdateOne("Employee", filter, update)
+		if err != nil || result.ModifiedCount == 0 {
+			return err
+		}
+		changes, err := events.EmployeeChanges(r.Context(), &before, &emp)
+		if err != nil {
+			return err
+		}
+		return events.Record(tx, changes...)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return hr.ErrEmployeeNotFound
 	}
-
-	if result.ModifiedCount == 0 {
-		http.Error(w, "Employee not found", http.StatusNotFound)
-		return
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error updating employee", "handler", "UpdateEmployee", "err", err)
+		return internalError("Failed to update employee")
 	}
 
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Employee updated successfully")
+	writeJSON(w, "UpdateEmployee", employeeResponse{Employee: emp, Current: emp.CurrentAsOf(time.Now())})
+	return nil
 }
 
 // DeleteEmployee removes an employee from the database
-func (api *API) DeleteEmployee(w http.ResponseWriter, r *http.Request) {
+func (api *API) DeleteEmployee(w http.ResponseWriter, r *http.Request) error {
 	employeeID := r.PathValue("id")
 
 	filter := bson.M{"employeeId": employeeID}
-	result, err := api.DB.DeleteOne("Employee", filter)
+	err := api.DB.WithTransaction(r.Context(), func(tx *database.Tx) error {
+		result, err := tx.DeleteOne("Employee", filter)
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+		deleted, err := events.EmployeeDeleted(r.Context(), employeeID)
+		if err != nil {
+			return err
+		}
+		return events.Record(tx, deleted)
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return hr.ErrEmployeeNotFound
+	}
 	if err != nil {
-		http.Error(w, "Failed to delete employee", http.StatusInternalServerError)
-		return
+		logger.ErrorContext(r.Context(), "Error deleting employee", "handler", "DeleteEmployee", "err", err)
+		return internalError("Failed to delete employee")
 	}
-
-	if result.DeletedCount == 0 {
-		http.Error(w, "Employee not found", http.StatusNotFound)
-		return
+	if api.index != nil {
+		if err := api.index.ForgetEmployee(r.Context(), employeeID); err != nil {
+			logger.ErrorContext(r.Context(), "Error removing employee from the index", "handler", "DeleteEmployee", "err", err)
+		}
 	}
 
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Employee deleted successfully")
+	w.WriteHeader(http.StatusNoContent)
+	return nil
 }
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"hcmnext/database"
+	"hcmnext/events"
+)
+
+// keepAliveInterval is how often an idle change feed sends a comment so proxies keep it open
+const keepAliveInterval = 25 * time.Second
+
+// ChangeFeedController streams employee and job changes to the browser
+type ChangeFeedController struct {
+	hub *events.Hub
+}
+
+// NewChangeFeedController creates a new instance of ChangeFeedController
+func NewChangeFeedController(hub *events.Hub) *ChangeFeedController {
+	return &ChangeFeedController{hub: hub}
+}
+
+// StreamChanges sends every change to the caller's tenant as a server-sent "change" event until
+// the client goes away. The stream ends when the client falls behind; EventSource reconnects on
+// its own and the client should reload what it shows, as changes may have been missed.
+func (c *ChangeFeedController) StreamChanges(w http.ResponseWriter, r *http.Request) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return internalError("Streaming is not supported")
+	}
+
+	changes, unsubscribe := c.hub.Subscribe(database.TenantFromContext(r.Context()))
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			flusher.Flush()
+
+		case change, ok := <-changes:
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(change)
+			if err != nil {
+				logger.ErrorContext(r.Context(), "Error encoding change", "handler", "StreamChanges", "err", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: change\ndata: %s\n\n", data); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -2,71 +2,272 @@ package controller
 
 import (
 	"context"
-	"fmt"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"net/http"
+	"sync"
+	"sync/atomic"
 
 	"hcmnext/ai"
 	"hcmnext/database"
+	"hcmnext/logging"
+	"hcmnext/metrics"
+	"hcmnext/problem"
+	"hcmnext/tenant"
+	"hcmnext/tracing"
 
 	"github.com/coder/websocket"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var logger = logging.For("controller")
+
+var tracer = tracing.Tracer("controller")
+
 type Controller struct {
 	aiClient *ai.Client
 	db       *database.Database
+	// origins are the origins, besides the server's own, allowed to open the WebSocket
+	origins atomic.Pointer[[]string]
+
+	// sessions are the open chat connections, see Drain
+	mu       sync.Mutex
+	sessions map[*chatSession]struct{}
+	draining bool
+	// drained is closed once draining has started and the last session has ended
+	drained     chan struct{}
+	drainedOnce sync.Once
+}
+
+// chatSession is an open chat connection
+type chatSession struct {
+	conn *websocket.Conn
+	// cancel aborts the message being answered
+	cancel context.CancelFunc
+	// busy is set while a message is being answered
+	busy bool
+}
+
+// closeGoingAway closes the connection with StatusGoingAway, which tells the page to reconnect,
+// reaching another instance of the server
+func (s *chatSession) closeGoingAway() {
+	s.conn.Close(websocket.StatusGoingAway, "server is restarting")
 }
 
 func NewController(aiClient *ai.Client, db *database.Database) *Controller {
 	return &Controller{
 		aiClient: aiClient,
 		db:       db,
+		sessions: make(map[*chatSession]struct{}),
+		drained:  make(chan struct{}),
+	}
+}
+
+// SetAllowedOrigins replaces the origins allowed to open the WebSocket; open connections are kept
+func (c *Controller) SetAllowedOrigins(origins []string) {
+	c.origins.Store(&origins)
+}
+
+// allowedOrigins returns the origins set with SetAllowedOrigins
+func (c *Controller) allowedOrigins() []string {
+	if origins := c.origins.Load(); origins != nil {
+		return *origins
 	}
+	return nil
 }
 
 // HandleWebSocket manages the WebSocket connection
 func (c *Controller) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if c.isDraining() {
+		w.Header().Set("Connection", "close")
+		problem.Error(w, r, http.StatusServiceUnavailable, problem.CodeShuttingDown, "Server is shutting down")
+		return
+	}
+
 	// Upgrade the HTTP connection to a WebSocket connection
 	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
-		OriginPatterns: []string{"http://localhost:8080", "127.0.0.1:8800"},
+		OriginPatterns: c.allowedOrigins(),
 	})
 	if err != nil {
-		fmt.Printf("WebSocket accept error: %v\n", err)
+		logger.WarnContext(r.Context(), "WebSocket accept error", "err", err)
 		return
 	}
 	defer conn.Close(websocket.StatusNormalClosure, "closing connection")
-
-	fmt.Println("WebSocket connection established")
+	defer metrics.WebSocketOpened()()
 
 	c.handleWebSocketConnection(r.Context(), conn)
 }
 
 func (c *Controller) handleWebSocketConnection(ctx context.Context, conn *websocket.Conn) {
+	// every message on this connection belongs to the same conversation
+	ctx = ai.WithConversationID(ctx, newConversationID())
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	session := &chatSession{conn: conn, cancel: cancel}
+	if !c.open(session) {
+		session.closeGoingAway()
+		return
+	}
+	defer c.close(session)
+	logger.InfoContext(ctx, "WebSocket connection established")
+
 	for {
 		// Read message from client
 		_, msg, err := conn.Read(ctx)
 		if err != nil {
-			fmt.Printf("Read error: %v\n", err)
+			logger.DebugContext(ctx, "WebSocket read ended", "err", err)
 			break
 		}
 
-		fmt.Printf("Received message from client: %s\n", msg)
-
-		// Send the message to the AI and get the response
-		aiResponse, err := c.aiClient.HandleRequest(string(msg))
+		// once the server drains, messages are no longer answered: the page sends them again
+		// after reconnecting
+		if !c.begin(session) {
+			session.closeGoingAway()
+			break
+		}
+		err = c.handleMessage(ctx, conn, msg)
+		if !c.end(session) {
+			// the answer is out, the page may move on to another instance
+			session.closeGoingAway()
+			break
+		}
 		if err != nil {
-			fmt.Printf("AI request error: %v\n", err)
 			break
 		}
+	}
+
+	logger.InfoContext(ctx, "WebSocket connection closed")
+}
 
-		// Send the AI's response back to the client
-		err = conn.Write(ctx, websocket.MessageText, []byte(aiResponse))
+// handleMessage answers one chat message. Connections last long, so every message is a trace of
+// its own, linked to the trace of the connection.
+func (c *Controller) handleMessage(ctx context.Context, conn *websocket.Conn, msg []byte) (err error) {
+	ctx, span := tracer.Start(ctx, "chat message", trace.WithNewRoot(), trace.WithLinks(trace.LinkFromContext(ctx)))
+	defer func() {
 		if err != nil {
-			fmt.Printf("Write error: %v\n", err)
-			break
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	logger.DebugContext(ctx, "Received message from client", "bytes", len(msg))
+
+	// Send the message to the AI and get the response
+	aiResponse, err := c.aiClient.HandleRequest(ctx, string(msg))
+	if errors.Is(err, tenant.ErrQuotaExceeded) || errors.Is(err, tenant.ErrAIDisabled) {
+		// the connection stays open, the user may try again tomorrow or ask an admin
+		aiResponse, err = err.Error(), nil
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "AI request error", "err", err)
+		return err
+	}
+
+	// Send the AI's response back to the client
+	err = conn.Write(ctx, websocket.MessageText, []byte(aiResponse))
+	if err != nil {
+		logger.WarnContext(ctx, "WebSocket write error", "err", err)
+		return err
+	}
+
+	logger.DebugContext(ctx, "Response sent to client", "bytes", len(aiResponse))
+	return nil
+}
+
+// Drain stops the chat for shutdown. New connections are refused and idle ones are closed with
+// StatusGoingAway, so the page reconnects to another instance; connections answering a message are
+// closed the same way once the answer is sent. Connections still busy when ctx ends are closed
+// anyway and their answers are cut off.
+func (c *Controller) Drain(ctx context.Context) {
+	c.mu.Lock()
+	c.draining = true
+	busy := 0
+	for s := range c.sessions {
+		if s.busy {
+			busy++
+		} else {
+			go s.closeGoingAway()
 		}
+	}
+	if len(c.sessions) == 0 {
+		c.drainedOnce.Do(func() { close(c.drained) })
+	}
+	c.mu.Unlock()
 
-		fmt.Println("Response sent to client")
+	logger.InfoContext(ctx, "Draining chat sessions", "busy", busy)
+	select {
+	case <-c.drained:
+		logger.InfoContext(ctx, "Chat sessions drained")
+	case <-ctx.Done():
+		c.mu.Lock()
+		logger.WarnContext(ctx, "Closing chat sessions before their answers are sent", "sessions", len(c.sessions))
+		for s := range c.sessions {
+			s.cancel()
+			go s.closeGoingAway()
+		}
+		c.mu.Unlock()
 	}
+}
 
-	fmt.Println("WebSocket connection closed")
-}
\ No newline at end of file
+// CheckAccepting fails once the chat drains, so the readiness probe takes the server out of rotation
+func (c *Controller) CheckAccepting(ctx context.Context) error {
+	if c.isDraining() {
+		return errors.New("draining chat sessions for shutdown")
+	}
+	return nil
+}
+
+func (c *Controller) isDraining() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.draining
+}
+
+// open registers session, unless the chat is draining
+func (c *Controller) open(s *chatSession) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.draining {
+		return false
+	}
+	c.sessions[s] = struct{}{}
+	return true
+}
+
+// close unregisters session
+func (c *Controller) close(s *chatSession) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.sessions, s)
+	if c.draining && len(c.sessions) == 0 {
+		c.drainedOnce.Do(func() { close(c.drained) })
+	}
+}
+
+// begin marks session busy answering a message, unless the chat is draining
+func (c *Controller) begin(s *chatSession) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.draining {
+		return false
+	}
+	s.busy = true
+	return true
+}
+
+// end marks session idle again and reports whether it may wait for another message
+func (c *Controller) end(s *chatSession) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s.busy = false
+	return !c.draining
+}
+
+func newConversationID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"hcmnext/ai"
+	"hcmnext/problem"
+)
+
+// displayPage is the document generated display markup is rendered into. Its own scripts carry
+// the per-response nonce and are the only ones the CSP lets run; the markup itself holds no
+// scripts and is rendered from data: Mermaid diagrams and Chart.js configurations.
+var displayPage = template.Must(template.New("display").Parse(`<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <meta charset="UTF-8" />
+    <meta name="viewport" content="width=device-width, initial-scale=1.0" />
+    <title>Display</title>
+    <script nonce="{{.Nonce}}" src="https://cdn.tailwindcss.com"></script>
+    <script nonce="{{.Nonce}}" src="https://cdn.jsdelivr.net/npm/chart.js"></script>
+    <script nonce="{{.Nonce}}" defer src="https://cdn.jsdelivr.net/npm/mermaid/dist/mermaid.min.js"></script>
+    <script nonce="{{.Nonce}}">
+      tailwind.config = {
+        theme: {
+          extend: {
+            colors: {
+              ukg: {
+                blue: "#0066cc",
+                green: "#00a650",
+                orange: "#ff6a39",
+              },
+            },
+          },
+        },
+      };
+      window.addEventListener("DOMContentLoaded", () => {
+        // the markup holds no code; charts are Chart.js configurations in data-chart
+        document.querySelectorAll("canvas[data-chart]").forEach((canvas) => {
+          try {
+            new Chart(canvas, JSON.parse(canvas.dataset.chart));
+          } catch (err) {
+            console.error("Invalid chart configuration", err);
+          }
+        });
+        mermaid.initialize({
+          startOnLoad: true,
+          theme: "default",
+          flowchart: { curve: "linear", useMaxWidth: true, htmlLabels: true },
+        });
+      });
+    </script>
+  </head>
+  <body class="bg-gray-100 font-sans">
+  {{.Content}}
+  </body>
+</html>`))
+
+// DisplayController serves sanitized AI display markup
+type DisplayController struct {
+	displays *ai.DisplayStore
+}
+
+// NewDisplayController creates a new instance of DisplayController
+func NewDisplayController(displays *ai.DisplayStore) *DisplayController {
+	return &DisplayController{
+		displays: displays,
+	}
+}
+
+// ServeDisplay renders stored display markup under a strict Content-Security-Policy
+func (dc *DisplayController) ServeDisplay(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	markup, ok := dc.displays.Get(id)
+	if !ok {
+		problem.Error(w, r, http.StatusNotFound, problem.CodeDisplayNotFound, "Display not found")
+		return
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error generating nonce", "handler", "ServeDisplay", "err", err)
+		problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Failed to render display")
+		return
+	}
+
+	csp := []string{
+		"default-src 'none'",
+		fmt.Sprintf("script-src 'nonce-%s'", nonce),
+		"style-src 'unsafe-inline'",
+		"img-src data: blob:",
+		"connect-src 'none'",
+		"form-action 'none'",
+		"base-uri 'none'",
+		"frame-ancestors 'self'",
+		"sandbox allow-scripts",
+	}
+
+	w.Header().Set("Content-Security-Policy", strings.Join(csp, "; "))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	err = displayPage.Execute(w, struct {
+		Nonce   string
+		Content template.HTML
+	}{
+		Nonce:   nonce,
+		Content: template.HTML(markup),
+	})
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error rendering display", "handler", "ServeDisplay", "err", err)
+	}
+}
+
+func newNonce() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"hcmnext/auth"
+	"hcmnext/bulk"
+	"hcmnext/problem"
+)
+
+// exportContentTypes maps export formats to their media types
+var exportContentTypes = map[string]string{
+	bulk.FormatCSV:     "text/csv; charset=utf-8",
+	bulk.FormatNDJSON:  "application/x-ndjson",
+	bulk.FormatParquet: "application/vnd.apache.parquet",
+}
+
+// exportFormats maps the media types exports are offered in to their formats
+var exportFormats = map[string]string{
+	"text/csv":                       bulk.FormatCSV,
+	"application/x-ndjson":           bulk.FormatNDJSON,
+	"application/vnd.apache.parquet": bulk.FormatParquet,
+}
+
+// ExportController streams bulk extracts of employees and jobs
+type ExportController struct {
+	exporter *bulk.Exporter
+}
+
+// NewExportController creates a new instance of ExportController
+func NewExportController(exporter *bulk.Exporter) *ExportController {
+	return &ExportController{exporter: exporter}
+}
+
+// ExportEmployees streams the employees matching the list filters as csv (the default), ndjson or
+// parquet, chosen by the format query parameter or else the Accept header. The fields query
+// parameter is a comma-separated list of columns to include. Personal data is masked unless the
+// caller may read it.
+func (c *ExportController) ExportEmployees(w http.ResponseWriter, r *http.Request) error {
+	filter, err := parseEmployeeFilter(r)
+	if err != nil {
+		return err
+	}
+	opts, err := exportOptions(r)
+	if err != nil {
+		return err
+	}
+	if err := bulk.CheckEmployeeOptions(opts); err != nil {
+		return problem.InvalidParameter("fields", err.Error())
+	}
+
+	out := &exportWriter{ResponseWriter: w, name: "employees", format: opts.Format}
+	count, err := c.exporter.ExportEmployees(r.Context(), out, filter, opts)
+	if err != nil && !out.started {
+		return err
+	}
+	finishExport(r, "ExportEmployees", count, err)
+	return nil
+}
+
+// ExportJobs streams every job as csv (the default), ndjson or parquet
+func (c *ExportController) ExportJobs(w http.ResponseWriter, r *http.Request) error {
+	opts, err := exportOptions(r)
+	if err != nil {
+		return err
+	}
+	if err := bulk.CheckJobOptions(opts); err != nil {
+		return problem.InvalidParameter("fields", err.Error())
+	}
+
+	out := &exportWriter{ResponseWriter: w, name: "jobs", format: opts.Format}
+	count, err := c.exporter.ExportJobs(r.Context(), out, opts)
+	if err != nil && !out.started {
+		return err
+	}
+	finishExport(r, "ExportJobs", count, err)
+	return nil
+}
+
+func exportOptions(r *http.Request) (bulk.ExportOptions, error) {
+	query := r.URL.Query()
+
+	opts := bulk.ExportOptions{
+		Format:  strings.ToLower(query.Get("format")),
+		ShowPII: auth.FromContext(r.Context()).HasRole(auth.RoleViewPII),
+	}
+	if opts.Format == "" {
+		opts.Format = exportFormats[mediaType(r)]
+	}
+	if _, ok := exportContentTypes[opts.Format]; !ok {
+		return opts, problem.InvalidParameter("format", "format must be csv, ndjson or parquet")
+	}
+	for _, field := range strings.Split(query.Get("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			opts.Fields = append(opts.Fields, field)
+		}
+	}
+	return opts, nil
+}
+
+// exportWriter sends the status and attachment headers of an export with its first bytes, so an
+// export that fails before writing anything, such as one whose query fails, is answered with a problem
+type exportWriter struct {
+	http.ResponseWriter
+	name, format string
+	started      bool
+}
+
+func (ew *exportWriter) Write(p []byte) (int, error) {
+	if !ew.started {
+		ew.started = true
+		startExport(ew.ResponseWriter, ew.name, ew.format)
+	}
+	return ew.ResponseWriter.Write(p)
+}
+
+// Flush sends what was written so far; before anything was, it leaves the response uncommitted
+func (ew *exportWriter) Flush() {
+	if flusher, ok := ew.ResponseWriter.(http.Flusher); ok && ew.started {
+		flusher.Flush()
+	}
+}
+
+func startExport(w http.ResponseWriter, name, format string) {
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102"), format)
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// finishExport logs the outcome; once streaming has started an error can only cut the body short
+func finishExport(r *http.Request, handler string, count int, err error) {
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Export failed", "handler", handler, "records", count, "err", err)
+		return
+	}
+	logger.InfoContext(r.Context(), "Exported records", "handler", handler, "records", count)
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"hcmnext/ai"
+	"hcmnext/auth"
+	"hcmnext/database"
+	"hcmnext/health"
+	"hcmnext/metrics"
+)
+
+// HealthController serves the liveness and readiness probes and the admin status page
+type HealthController struct {
+	checker  *health.Checker
+	aiClient *ai.Client
+	db       *database.Database
+	started  time.Time
+}
+
+// NewHealthController creates a new instance of HealthController
+func NewHealthController(checker *health.Checker, aiClient *ai.Client, db *database.Database) *HealthController {
+	return &HealthController{
+		checker:  checker,
+		aiClient: aiClient,
+		db:       db,
+		started:  time.Now(),
+	}
+}
+
+// Live answers the liveness probe
+func (c *HealthController) Live(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, c.checker.Live(r.Context()))
+}
+
+// Ready answers the readiness probe
+func (c *HealthController) Ready(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, c.checker.Ready(r.Context()))
+}
+
+// writeReport writes report, with 503 Service Unavailable when it failed
+func writeReport(w http.ResponseWriter, report health.Report) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status == health.StatusFailed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJSON(w, "writeReport", report)
+}
+
+// statusResponse is the admin status page
+type statusResponse struct {
+	health.Report
+	Versions      statusVersions     `json:"versions"`
+	Model         string             `json:"model"`
+	StartedAt     time.Time          `json:"startedAt"`
+	UptimeSeconds int64              `json:"uptimeSeconds"`
+	MultiTenant   bool               `json:"multiTenant"`
+	Connections   database.PoolStats `json:"mongoConnections"`
+	WebSockets    int64              `json:"webSockets"`
+}
+
+// statusVersions are the versions of the server and of the dependencies it runs against
+type statusVersions struct {
+	Server string `json:"server"`
+	Go     string `json:"go"`
+	Mongo  string `json:"mongo,omitempty"`
+	Node   string `json:"node,omitempty"`
+}
+
+// GetStatus describes the server, its dependencies and its connections, for administrators
+func (c *HealthController) GetStatus(w http.ResponseWriter, r *http.Request) error {
+	if err := requireRole(w, r, auth.RoleAdmin); err != nil {
+		return err
+	}
+
+	report := c.checker.Ready(r.Context())
+
+	// the checks already reported why a version is missing
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	mongoVersion, _ := c.db.ServerVersion(ctx)
+	nodeVersion, _ := ai.EvaluatorVersion(ctx)
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, "GetStatus", statusResponse{
+		Report: report,
+		Versions: statusVersions{
+			Server: serverVersion(),
+			Go:     runtime.Version(),
+			Mongo:  mongoVersion,
+			Node:   nodeVersion,
+		},
+		Model:         c.aiClient.Model(),
+		StartedAt:     c.started,
+		UptimeSeconds: int64(time.Since(c.started).Seconds()),
+		MultiTenant:   c.db.MultiTenant(),
+		Connections:   c.db.PoolStats(),
+		WebSockets:    metrics.OpenWebSockets(),
+	})
+	return nil
+}
+
+// serverVersion is the module version the binary was built from, with its VCS revision if recorded
+func serverVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	version := info.Main.Version
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			version += " " + setting.Value
+		}
+	}
+	return version
+}
@@ -1,8 +1,14 @@
 package controller
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"os"
 	"path/filepath"
+	"strings"
+
+	"hcmnext/problem"
 )
 
 // HomeController handles requests for the home page
@@ -17,8 +23,13 @@ func NewHomeController(staticDir string) *HomeController {
 	}
 }
 
-// ServeHome serves the static index.html file
+// ServeHome serves the static index.html file, and answers paths under /api/ that no route
+// serves with a problem
 func (hc *HomeController) ServeHome(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		problem.Error(w, r, http.StatusNotFound, problem.CodeRouteNotFound, "No operation of the API serves "+r.Method+" "+r.URL.Path)
+		return
+	}
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
 		return
@@ -26,4 +37,21 @@ func (hc *HomeController) ServeHome(w http.ResponseWriter, r *http.Request) {
 
 	indexPath := filepath.Join(hc.staticDir, "index.html")
 	http.ServeFile(w, r, indexPath)
-}
\ No newline at end of file
+}
+
+// StaticFiles serves the files of the static directory
+func (hc *HomeController) StaticFiles() http.Handler {
+	return http.FileServer(http.Dir(hc.staticDir))
+}
+
+// CheckAssets checks that the static directory holds the home page, for the health checks
+func (hc *HomeController) CheckAssets(ctx context.Context) error {
+	info, err := os.Stat(filepath.Join(hc.staticDir, "index.html"))
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", filepath.Join(hc.staticDir, "index.html"))
+	}
+	return nil
+}
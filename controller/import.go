@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"hcmnext/bulk"
+	"hcmnext/problem"
+)
+
+// maxImportSize bounds the size of an uploaded spreadsheet
+const maxImportSize = 64 << 20
+
+// ImportController serves bulk employee imports
+type ImportController struct {
+	importer *bulk.Importer
+}
+
+// NewImportController creates a new instance of ImportController
+func NewImportController(importer *bulk.Importer) *ImportController {
+	return &ImportController{importer: importer}
+}
+
+// ImportEmployees imports a CSV or XLSX file of employees, upserting by employeeId.
+// The file is sent as the "file" field of a multipart form, with optional "mapping" (a JSON
+// object from column header to field path) and "dryRun" fields, or as the raw request body
+// with the same options as query parameters. A dry run answers with the validation report;
+// otherwise the import runs in the background and the response points at its status.
+func (c *ImportController) ImportEmployees(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	var (
+		file     io.Reader
+		filename string
+	)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxImportSize); err != nil {
+			var sizeErr *http.MaxBytesError
+			if errors.As(err, &sizeErr) {
+				return err
+			}
+			return problem.New(http.StatusBadRequest, problem.CodeInvalidBody, "Invalid multipart form")
+		}
+		upload, header, err := r.FormFile("file")
+		if err != nil {
+			return problem.InvalidParameter("file", "Missing 'file' form field")
+		}
+		defer upload.Close()
+		file, filename = upload, header.Filename
+	} else {
+		file = r.Body
+	}
+
+	format := importFormat(r.FormValue("format"), filename, r.Header.Get("Content-Type"))
+	if format == "" {
+		return problem.InvalidParameter("format", "Unknown file format, set format to csv or xlsx")
+	}
+
+	mapping := bulk.Mapping{}
+	if raw := r.FormValue("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			return problem.InvalidParameter("mapping", "mapping must be a JSON object from column header to field path")
+		}
+	}
+
+	dryRun, _ := strconv.ParseBool(r.FormValue("dryRun"))
+	if dryRun {
+		report, err := c.importer.DryRun(r.Context(), file, format, mapping)
+		if err != nil {
+			return unreadableFile(err)
+		}
+		writeJSON(w, "ImportEmployees", report)
+		return nil
+	}
+
+	report, err := c.importer.Start(r.Context(), file, format, mapping)
+	if err != nil {
+		return unreadableFile(err)
+	}
+	logger.InfoContext(r.Context(), "Started import", "handler", "ImportEmployees", "import", report.ID, "rows", report.Rows)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/imports/"+report.ID)
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, "ImportEmployees", report)
+	return nil
+}
+
+// unreadableFile describes a file the importer could not read, such as a spreadsheet without a
+// header row or a mapping naming unknown fields; other errors are the server's
+func unreadableFile(err error) error {
+	var sizeErr *http.MaxBytesError
+	var fileErr *bulk.FileError
+	if errors.As(err, &sizeErr) || !errors.As(err, &fileErr) {
+		return err
+	}
+	return problem.New(http.StatusBadRequest, problem.CodeInvalidBody, err.Error())
+}
+
+// GetImport reports the progress of an import
+func (c *ImportController) GetImport(w http.ResponseWriter, r *http.Request) error {
+	report, err := c.importer.Job(r.Context(), r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	if report == nil {
+		return problem.New(http.StatusNotFound, problem.CodeImportNotFound, "Import not found")
+	}
+	writeJSON(w, "GetImport", report)
+	return nil
+}
+
+// importFormat picks the file format from an explicit value, the file name or the content type
+func importFormat(explicit, filename, contentType string) string {
+	switch strings.ToLower(explicit) {
+	case bulk.FormatCSV, bulk.FormatXLSX:
+		return strings.ToLower(explicit)
+	case "":
+	default:
+		return ""
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return bulk.FormatCSV
+	case ".xlsx":
+		return bulk.FormatXLSX
+	}
+
+	switch {
+	case strings.HasPrefix(contentType, "text/csv"):
+		return bulk.FormatCSV
+	case strings.HasPrefix(contentType, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"):
+		return bulk.FormatXLSX
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"hcmnext/hr"
+	"hcmnext/models"
+	"hcmnext/problem"
+)
+
+// LifecycleController serves the employee lifecycle actions
+type LifecycleController struct {
+	lifecycle *hr.Lifecycle
+}
+
+// NewLifecycleController creates a new instance of LifecycleController
+func NewLifecycleController(lifecycle *hr.Lifecycle) *LifecycleController {
+	return &LifecycleController{lifecycle: lifecycle}
+}
+
+// Hire creates an employee with their first job, status and compensation
+func (c *LifecycleController) Hire(w http.ResponseWriter, r *http.Request) error {
+	var action hr.HireAction
+	if err := decodeJSON(r, &action); err != nil {
+		return err
+	}
+	if action.Employee.EmployeeID != r.PathValue("id") {
+		return problem.Invalid("ID in URL does not match ID in request body",
+			problem.Pointer("employee.employeeId", "must be "+r.PathValue("id")))
+	}
+
+	emp, err := c.lifecycle.Hire(r.Context(), action)
+	return c.respond(w, r, "hire", emp, err, http.StatusCreated)
+}
+
+// Transfer moves an employee to another job, department, location or manager
+func (c *LifecycleController) Transfer(w http.ResponseWriter, r *http.Request) error {
+	var action hr.TransferAction
+	if err := decodeJSON(r, &action); err != nil {
+		return err
+	}
+
+	emp, err := c.lifecycle.Transfer(r.Context(), r.PathValue("id"), action)
+	return c.respond(w, r, "transfer", emp, err, http.StatusOK)
+}
+
+// Promote moves an employee into a new job with new compensation
+func (c *LifecycleController) Promote(w http.ResponseWriter, r *http.Request) error {
+	var action hr.PromoteAction
+	if err := decodeJSON(r, &action); err != nil {
+		return err
+	}
+
+	emp, err := c.lifecycle.Promote(r.Context(), r.PathValue("id"), action)
+	return c.respond(w, r, "promote", emp, err, http.StatusOK)
+}
+
+// Terminate ends an employee's employment
+func (c *LifecycleController) Terminate(w http.ResponseWriter, r *http.Request) error {
+	var action hr.TerminateAction
+	if err := decodeJSON(r, &action); err != nil {
+		return err
+	}
+
+	emp, err := c.lifecycle.Terminate(r.Context(), r.PathValue("id"), action)
+	return c.respond(w, r, "terminate", emp, err, http.StatusOK)
+}
+
+// Rehire brings back a terminated or retired employee
+func (c *LifecycleController) Rehire(w http.ResponseWriter, r *http.Request) error {
+	var action hr.RehireAction
+	if err := decodeJSON(r, &action); err != nil {
+		return err
+	}
+
+	emp, err := c.lifecycle.Rehire(r.Context(), r.PathValue("id"), action)
+	return c.respond(w, r, "rehire", emp, err, http.StatusOK)
+}
+
+// respond writes the updated employee, or returns the action's error for Handle to map to a
+// status; a job the action names that does not exist is a problem with the body, not a missing
+// resource
+func (c *LifecycleController) respond(w http.ResponseWriter, r *http.Request, action string, emp *models.Employee, err error, status int) error {
+	if errors.Is(err, hr.ErrJobNotFound) {
+		return problem.Invalid("The job does not exist", problem.Pointer("job.jobId", err.Error()))
+	}
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(emp); err != nil {
+		logger.WarnContext(r.Context(), "Error encoding response to JSON", "action", action, "err", err)
+	}
+	return nil
+}
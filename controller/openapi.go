@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"hcmnext/database"
+	"hcmnext/models"
+	"hcmnext/openapi"
+	"hcmnext/scim"
+)
+
+// OpenAPIController serves the OpenAPI description of the REST and SCIM APIs and a page to read it
+type OpenAPIController struct {
+	doc *openapi.Document
+}
+
+// NewOpenAPIController creates a new instance of OpenAPIController; Describe builds its document
+func NewOpenAPIController() *OpenAPIController {
+	return &OpenAPIController{}
+}
+
+// collections pairs the models stored in a collection with its validator, whose enums, patterns
+// and minimums the API description repeats
+var collections = []struct {
+	model     interface{}
+	validator string
+}{
+	{models.Employee{}, database.EmployeSchema},
+	{models.Job{}, database.JobSchema},
+	{models.PositionSlot{}, database.PositionSlotSchema},
+	{models.OutboxEvent{}, database.OutboxSchema},
+	{models.WebhookSubscription{}, database.WebhookSubscriptionSchema},
+	{models.WebhookDelivery{}, database.WebhookDeliverySchema},
+	{models.Tenant{}, database.TenantSchema},
+}
+
+// Describe builds the description of the routes registered for patterns, which must be the
+// patterns of every route under /api and /scim. A route without documentation, or documentation
+// without a route, is an error.
+func (c *OpenAPIController) Describe(patterns []string) (*openapi.Document, error) {
+	b := openapi.NewBuilder(openapi.Info{
+		Title:       "hcmnext",
+		Version:     serverVersion(),
+		Description: apiDescription,
+	})
+	for _, collection := range collections {
+		if err := b.Constrain(collection.model, collection.validator); err != nil {
+			return nil, err
+		}
+	}
+	for _, tag := range apiTags {
+		b.Tag(tag.Name, tag.Description)
+	}
+
+	// SCIM resources are projected to the attributes a query selects, so lists and single
+	// resources only promise what every resource has
+	b.Component("ScimResource", &openapi.Schema{
+		Type:        openapi.Types{"object"},
+		Description: "A User or Group with the attributes the query selects, see User and Group",
+		Properties: openapi.Properties{
+			{Name: "schemas", Schema: openapi.Array(openapi.String())},
+			{Name: "id", Schema: openapi.String()},
+			{Name: "meta", Schema: b.Schema(scim.Meta{})},
+		},
+		Required: []string{"schemas", "id"},
+	})
+	b.Component("ScimListResponse", &openapi.Schema{
+		Type:        openapi.Types{"object"},
+		Description: "A page of resources; startIndex counts from 1",
+		Properties: openapi.Properties{
+			{Name: "schemas", Schema: openapi.Array(openapi.Enum(scim.ListResponseSchema))},
+			{Name: "totalResults", Schema: openapi.Integer()},
+			{Name: "startIndex", Schema: openapi.Integer()},
+			{Name: "itemsPerPage", Schema: openapi.Integer()},
+			{Name: "Resources", Schema: openapi.Array(openapi.Ref("ScimResource"))},
+		},
+		Required: []string{"schemas", "totalResults", "startIndex", "itemsPerPage", "Resources"},
+	})
+	b.Component("ScimError", &openapi.Schema{
+		Type: openapi.Types{"object"},
+		Properties: openapi.Properties{
+			{Name: "schemas", Schema: openapi.Array(openapi.Enum(scim.ErrorSchema))},
+			{Name: "status", Schema: openapi.String().Describe("The HTTP status code")},
+			{Name: "scimType", Schema: openapi.String()},
+			{Name: "detail", Schema: openapi.String()},
+		},
+		Required: []string{"schemas", "status", "detail"},
+	})
+	b.Schema(scim.User{})
+	b.Schema(scim.Group{})
+
+	registered := map[string]bool{}
+	for _, pattern := range patterns {
+		_, path, _ := strings.Cut(pattern, " ")
+		if !strings.HasPrefix(path, "/api/") && !strings.HasPrefix(path, "/scim/") {
+			continue
+		}
+		registered[pattern] = true
+		route, ok := apiRoutes[pattern]
+		if !ok {
+			return nil, fmt.Errorf("route %q is not documented", pattern)
+		}
+		if strings.HasPrefix(path, "/api/") {
+			// copied, as routes share replies
+			replies := append([]openapi.Reply{}, route.Replies...)
+			route.Replies = append(replies, negotiationReplies(route)...)
+		}
+		if err := b.Add(pattern, route); err != nil {
+			return nil, err
+		}
+	}
+
+	var stale []string
+	for pattern := range apiRoutes {
+		if !registered[pattern] {
+			stale = append(stale, pattern)
+		}
+	}
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		return nil, fmt.Errorf("documented routes %s are not registered", strings.Join(stale, ", "))
+	}
+
+	c.doc = b.Document()
+	return c.doc, nil
+}
+
+// negotiationReplies are the problems Handle answers REST API routes with before their handler runs,
+// and those of reading a JSON body
+func negotiationReplies(route openapi.Route) []openapi.Reply {
+	replies := []openapi.Reply{
+		openapi.Error(http.StatusNotAcceptable, "The request accepts none of the media types of the response"),
+	}
+	if route.Body != nil {
+		replies = append(replies,
+			openapi.Error(http.StatusBadRequest, "The body is not valid JSON"),
+			openapi.Error(http.StatusUnsupportedMediaType, "The body is not JSON"))
+	}
+	return replies
+}
+
+// GetSpec serves the OpenAPI document
+func (c *OpenAPIController) GetSpec(w http.ResponseWriter, r *http.Request) error {
+	c.doc.ServeHTTP(w, r)
+	return nil
+}
+
+// GetDocs serves the API reference page, which renders the OpenAPI document
+func (c *OpenAPIController) GetDocs(w http.ResponseWriter, r *http.Request) error {
+	openapi.DocsHandler().ServeHTTP(w, r)
+	return nil
+}
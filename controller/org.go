@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"hcmnext/hr"
+	"hcmnext/problem"
+)
+
+// OrgController serves reporting lines and org charts
+type OrgController struct {
+	org *hr.OrgService
+}
+
+// reportsResponse lists the reports of an employee with their span of control
+type reportsResponse struct {
+	EmployeeID string           `json:"employeeId"`
+	AsOf       time.Time        `json:"asOf"`
+	Span       hr.SpanOfControl `json:"span"`
+	Reports    []hr.Report      `json:"reports"`
+}
+
+// chainResponse lists the managers of an employee, nearest first
+type chainResponse struct {
+	EmployeeID     string        `json:"employeeId"`
+	AsOf           time.Time     `json:"asOf"`
+	ChainOfCommand []*hr.OrgNode `json:"chainOfCommand"`
+}
+
+// NewOrgController creates a new instance of OrgController
+func NewOrgController(org *hr.OrgService) *OrgController {
+	return &OrgController{org: org}
+}
+
+// GetReports lists an employee's direct reports, or every report down to the depth query parameter (0 for all levels)
+func (c *OrgController) GetReports(w http.ResponseWriter, r *http.Request) error {
+	depth, err := parseDepth(r, 1)
+	if err != nil {
+		return err
+	}
+
+	org, err := c.load(r)
+	if err != nil {
+		return err
+	}
+	employeeID := r.PathValue("id")
+	if org.Node(employeeID) == nil {
+		return errNotInOrg
+	}
+
+	reports := org.Reports(employeeID, depth)
+	if reports == nil {
+		reports = []hr.Report{}
+	}
+	writeJSON(w, "GetReports", reportsResponse{
+		EmployeeID: employeeID,
+		AsOf:       org.AsOf,
+		Span:       org.Span(employeeID),
+		Reports:    reports,
+	})
+	return nil
+}
+
+// GetChainOfCommand lists an employee's managers up to the top of the org
+func (c *OrgController) GetChainOfCommand(w http.ResponseWriter, r *http.Request) error {
+	org, err := c.load(r)
+	if err != nil {
+		return err
+	}
+	employeeID := r.PathValue("id")
+	if org.Node(employeeID) == nil {
+		return errNotInOrg
+	}
+
+	chain := org.ChainOfCommand(employeeID)
+	if chain == nil {
+		chain = []*hr.OrgNode{}
+	}
+	writeJSON(w, "GetChainOfCommand", chainResponse{
+		EmployeeID:     employeeID,
+		AsOf:           org.AsOf,
+		ChainOfCommand: chain,
+	})
+	return nil
+}
+
+// GetStats reports span of control across the org
+func (c *OrgController) GetStats(w http.ResponseWriter, r *http.Request) error {
+	org, err := c.load(r)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, "GetStats", org.Stats())
+	return nil
+}
+
+// GetIssues reports reporting cycles and employees whose manager is not active
+func (c *OrgController) GetIssues(w http.ResponseWriter, r *http.Request) error {
+	org, err := c.load(r)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, "GetIssues", org.Issues())
+	return nil
+}
+
+// GetChart exports the org chart as a JSON tree, or as a Mermaid flowchart with format=mermaid
+// or when the request prefers text/plain. The root and depth query parameters limit the chart
+// to part of the org.
+func (c *OrgController) GetChart(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	depth, err := parseDepth(r, 0)
+	if err != nil {
+		return err
+	}
+	format := query.Get("format")
+	switch format {
+	case "json", "mermaid":
+	case "":
+		format = "json"
+		if mediaType(r) == mediaText {
+			format = "mermaid"
+		}
+	default:
+		return problem.InvalidParameter("format", "format must be json or mermaid")
+	}
+
+	org, err := c.load(r)
+	if err != nil {
+		return err
+	}
+	root := query.Get("root")
+	if root != "" && org.Node(root) == nil {
+		return errNotInOrg
+	}
+
+	if format == "mermaid" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(org.Mermaid(root, depth)))
+		return nil
+	}
+	writeJSON(w, "GetChart", org.Chart(root, depth))
+	return nil
+}
+
+// load builds the org as of the asOf query parameter
+func (c *OrgController) load(r *http.Request) (*hr.Org, error) {
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := c.org.Load(r.Context(), asOf)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error loading employees", "handler", "Org", "err", err)
+		return nil, internalError("Failed to load the org")
+	}
+	return org, nil
+}
+
+// errNotInOrg answers for employees missing from the org as of the day asked for
+var errNotInOrg = problem.New(http.StatusNotFound, problem.CodeEmployeeNotFound, "Employee not found in the org")
+
+// parseDepth reads the depth query parameter, a non-negative integer defaulting to def
+func parseDepth(r *http.Request, def int) (int, error) {
+	param := r.URL.Query().Get("depth")
+	if param == "" {
+		return def, nil
+	}
+	depth, err := strconv.Atoi(param)
+	if err != nil || depth < 0 {
+		return 0, problem.InvalidParameter("depth", "depth must be a non-negative integer")
+	}
+	return depth, nil
+}
+
+// parseAsOf reads the asOf query parameter (YYYY-MM-DD), defaulting to today
+func parseAsOf(r *http.Request) (time.Time, error) {
+	param := r.URL.Query().Get("asOf")
+	if param == "" {
+		return time.Now(), nil
+	}
+	asOf, err := time.Parse("2006-01-02", param)
+	if err != nil {
+		return time.Time{}, problem.InvalidParameter("asOf", "asOf must be a date in the form YYYY-MM-DD")
+	}
+	return asOf, nil
+}
+
+// parseEmployeeFilter reads the employee list filters from the query parameters
+func parseEmployeeFilter(r *http.Request) (hr.EmployeeFilter, error) {
+	if _, err := parseAsOf(r); err != nil {
+		return hr.EmployeeFilter{}, err
+	}
+	return hr.ParseEmployeeFilter(r.URL.Query())
+}
+
+// writeJSON encodes v as the response body
+func writeJSON(w http.ResponseWriter, handler string, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Warn("Error encoding response to JSON", "handler", handler, "err", err)
+	}
+}
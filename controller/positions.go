@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"net/http"
+
+	"hcmnext/hr"
+)
+
+// PositionController serves position slots, vacancies and headcount checks
+type PositionController struct {
+	positions *hr.PositionService
+}
+
+// openSlotsRequest is the body of OpenSlots; count defaults to 1
+type openSlotsRequest struct {
+	PositionTitle string `json:"positionTitle"`
+	Count         int    `json:"count"`
+}
+
+// freezeRequest is the body of FreezeSlot
+type freezeRequest struct {
+	Reason string `json:"reason"`
+}
+
+// NewPositionController creates a new instance of PositionController
+func NewPositionController(positions *hr.PositionService) *PositionController {
+	return &PositionController{positions: positions}
+}
+
+// GetSlots lists the position slots of a job
+func (c *PositionController) GetSlots(w http.ResponseWriter, r *http.Request) error {
+	slots, err := c.positions.Slots(r.Context(), r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error retrieving slots", "handler", "GetSlots", "err", err)
+		return internalError("Failed to retrieve positions")
+	}
+	writeJSON(w, "GetSlots", slots)
+	return nil
+}
+
+// OpenSlots adds open slots for one of a job's positions
+func (c *PositionController) OpenSlots(w http.ResponseWriter, r *http.Request) error {
+	var body openSlotsRequest
+	if err := decodeJSON(r, &body); err != nil {
+		return err
+	}
+	if body.Count == 0 {
+		body.Count = 1
+	}
+
+	slots, err := c.positions.OpenSlots(r.Context(), r.PathValue("id"), body.PositionTitle, body.Count)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, "OpenSlots", slots)
+	return nil
+}
+
+// FreezeSlot freezes an open slot
+func (c *PositionController) FreezeSlot(w http.ResponseWriter, r *http.Request) error {
+	var body freezeRequest
+	if err := decodeJSON(r, &body); err != nil {
+		return err
+	}
+
+	slot, err := c.positions.Freeze(r.Context(), r.PathValue("slotId"), body.Reason)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, "FreezeSlot", slot)
+	return nil
+}
+
+// UnfreezeSlot reopens a frozen slot
+func (c *PositionController) UnfreezeSlot(w http.ResponseWriter, r *http.Request) error {
+	slot, err := c.positions.Unfreeze(r.Context(), r.PathValue("slotId"))
+	if err != nil {
+		return err
+	}
+	writeJSON(w, "UnfreezeSlot", slot)
+	return nil
+}
+
+// GetVacancies reports the open and frozen seats of every job
+func (c *PositionController) GetVacancies(w http.ResponseWriter, r *http.Request) error {
+	vacancies, err := c.positions.Vacancies(r.Context())
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error building vacancy report", "handler", "GetVacancies", "err", err)
+		return internalError("Failed to build vacancy report")
+	}
+	writeJSON(w, "GetVacancies", vacancies)
+	return nil
+}
+
+// GetConsistency lists jobs whose headcount figures disagree with their employees or slots
+func (c *PositionController) GetConsistency(w http.ResponseWriter, r *http.Request) error {
+	issues, err := c.positions.Check(r.Context())
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error checking headcount", "handler", "GetConsistency", "err", err)
+		return internalError("Failed to check headcount")
+	}
+	writeJSON(w, "GetConsistency", issues)
+	return nil
+}
+
+// RecalculateHeadcount rebuilds a job's positionsFilled and currentHeadcount from its employees
+func (c *PositionController) RecalculateHeadcount(w http.ResponseWriter, r *http.Request) error {
+	job, err := c.positions.Recalculate(r.Context(), r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	writeJSON(w, "RecalculateHeadcount", job)
+	return nil
+}
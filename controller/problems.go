@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hcmnext/events"
+	"hcmnext/hr"
+	"hcmnext/models"
+	"hcmnext/problem"
+)
+
+const (
+	mediaJSON = "application/json"
+	mediaText = "text/plain"
+)
+
+// HandlerFunc is a REST API handler. The error it returns is answered with a problem, see
+// problemFor, so handlers return errors only before they start writing the response.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+type mediaTypeKey struct{}
+
+// Handle serves fn for requests accepting one of offers, application/json when there are none,
+// and answers other requests with 406. fn reads the media type chosen with mediaType.
+func Handle(fn HandlerFunc, offers ...string) http.Handler {
+	if len(offers) == 0 {
+		offers = []string{mediaJSON}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chosen, ok := negotiate(r.Header.Get("Accept"), offers)
+		if !ok {
+			problem.Error(w, r, http.StatusNotAcceptable, problem.CodeNotAcceptable,
+				"The response is available as "+strings.Join(offers, ", "))
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), mediaTypeKey{}, chosen))
+		if err := fn(w, r); err != nil {
+			problem.Write(w, r, problemFor(r, err))
+		}
+	})
+}
+
+// mediaType returns the media type negotiated for the response to r
+func mediaType(r *http.Request) string {
+	chosen, _ := r.Context().Value(mediaTypeKey{}).(string)
+	return chosen
+}
+
+// negotiate picks the offer the Accept header prefers, the first among equals, and the first offer
+// when there is no header. Problems are sent whatever the request accepts.
+func negotiate(accept string, offers []string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0], true
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		// the most specific range matching the offer sets its quality
+		q, specificity := 0.0, -1
+		for _, part := range strings.Split(accept, ",") {
+			mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			s := matchRange(mediaRange, offer)
+			if s <= specificity {
+				continue
+			}
+			specificity, q = s, 1
+			if param, ok := params["q"]; ok {
+				if parsed, err := strconv.ParseFloat(param, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best, best != ""
+}
+
+// matchRange returns how specifically mediaRange matches mediaType: 2 for the type itself, 1 for
+// its type/*, 0 for */* and -1 when it does not match
+func matchRange(mediaRange, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	}
+	return -1
+}
+
+// decodeJSON reads the JSON body of r into v. A body in another media type is answered with 415,
+// one that is not valid JSON or does not fit v with 400.
+func decodeJSON(r *http.Request, v interface{}) error {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mt, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mt != mediaJSON && !strings.HasSuffix(mt, "+json")) {
+			return problem.New(http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType, "The body must be application/json")
+		}
+	}
+
+	err := json.NewDecoder(r.Body).Decode(v)
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var sizeErr *http.MaxBytesError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, io.EOF):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidBody, "The body is empty")
+	case errors.As(err, &syntaxErr):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidBody, fmt.Sprintf("The body is not valid JSON at offset %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return problem.Invalid("Invalid request body", problem.Pointer(typeErr.Field, "must not be a "+typeErr.Value))
+	case errors.As(err, &sizeErr):
+		return err
+	default:
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body")
+	}
+}
+
+// internalError is a failure of the server, logged by the handler that returns it
+func internalError(detail string) error {
+	return problem.New(http.StatusInternalServerError, problem.CodeInternal, detail)
+}
+
+// problemFor describes err: a *problem.Details as it is, a domain error with the status it maps
+// to and anything else as a failure of the server, which is logged
+func problemFor(r *http.Request, err error) *problem.Details {
+	var (
+		details    *problem.Details
+		historyErr *models.HistoryError
+		invalidErr *models.ValidationError
+		actionErr  *hr.ActionError
+		sizeErr    *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &details):
+		return details
+	case errors.Is(err, hr.ErrEmployeeNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeEmployeeNotFound, "Employee not found")
+	case errors.Is(err, hr.ErrEmployeeExists):
+		return problem.New(http.StatusConflict, problem.CodeEmployeeExists, "Employee already exists")
+	case errors.Is(err, hr.ErrJobNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeJobNotFound, "Job not found")
+	case errors.Is(err, hr.ErrSlotNotFound):
+		return problem.New(http.StatusNotFound, problem.CodePositionNotFound, "Position not found")
+	case errors.Is(err, events.ErrSubscriptionNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeSubscriptionNotFound, "Subscription not found")
+	case errors.Is(err, events.ErrDeliveryNotFound):
+		return problem.New(http.StatusNotFound, problem.CodeDeliveryNotFound, "Delivery not found")
+	case errors.Is(err, events.ErrInvalidSubscription):
+		return problem.Invalid(err.Error())
+	case errors.As(err, &historyErr):
+		return problem.Invalid("The employee's history is not valid", fieldProblems(historyErr.Problems)...)
+	case errors.As(err, &invalidErr):
+		return problem.Invalid("The employee is not valid", fieldProblems(invalidErr.Problems)...)
+	case errors.As(err, &actionErr):
+		return problem.New(http.StatusConflict, problem.CodeActionNotAllowed, err.Error())
+	case errors.As(err, &sizeErr):
+		return problem.New(http.StatusRequestEntityTooLarge, problem.CodeBodyTooLarge, fmt.Sprintf("The body is over %d bytes", sizeErr.Limit))
+	case errors.Is(err, context.DeadlineExceeded):
+		return problem.New(http.StatusGatewayTimeout, problem.CodeTimeout, "Timed out serving the request")
+	default:
+		logger.ErrorContext(r.Context(), "Error serving request", "method", r.Method, "path", r.URL.Path, "err", err)
+		return problem.New(http.StatusInternalServerError, problem.CodeInternal, "Internal server error")
+	}
+}
+
+// fieldProblems points at the fields of the body breaking the rules of a record
+func fieldProblems(problems []models.Problem) []problem.Field {
+	fields := make([]problem.Field, len(problems))
+	for i, p := range problems {
+		fields[i] = problem.Pointer(p.Field, p.Message)
+	}
+	return fields
+}
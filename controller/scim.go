@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"hcmnext/auth"
+	"hcmnext/scim"
+)
+
+// SCIMController serves employees and departments to identity providers over SCIM 2.0
+type SCIMController struct {
+	scim *scim.Service
+}
+
+// NewSCIMController creates a new instance of SCIMController
+func NewSCIMController(service *scim.Service) *SCIMController {
+	return &SCIMController{scim: service}
+}
+
+// ListUsers lists employees as Users, honouring filter, startIndex, count and attribute selection
+func (c *SCIMController) ListUsers(w http.ResponseWriter, r *http.Request) {
+	if !authorizeSCIM(w, r) {
+		return
+	}
+	q, err := scim.ParseQuery(r.URL.Query())
+	if err != nil {
+		writeSCIMError(w, r, "ListUsers", err)
+		return
+	}
+	list, err := c.scim.ListUsers(r.Context(), q, scimBaseURL(r))
+	if err != nil {
+		writeSCIMError(w, r, "ListUsers", err)
+		return
+	}
+	writeSCIM(w, "ListUsers", http.StatusOK, list)
+}
+
+// GetUser returns one employee as a User
+func (c *SCIMController) GetUser(w http.ResponseWriter, r *http.Request) {
+	if !authorizeSCIM(w, r) {
+		return
+	}
+	user, err := c.scim.GetUser(r.Context(), r.PathValue("id"), scimBaseURL(r))
+	if err != nil {
+		writeSCIMError(w, r, "GetUser", err)
+		return
+	}
+	writeSCIMResource(w, r, "GetUser", user)
+}
+
+// PatchUser updates the name, nickName, email or phone of an employee
+func (c *SCIMController) PatchUser(w http.ResponseWriter, r *http.Request) {
+	if !authorizeSCIM(w, r) {
+		return
+	}
+	var patch scim.PatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		writeSCIMError(w, r, "PatchUser", &scim.Error{Status: http.StatusBadRequest, ScimType: "invalidSyntax", Detail: "Invalid request payload"})
+		return
+	}
+
+	user, err := c.scim.PatchUser(r.Context(), r.PathValue("id"), patch, scimBaseURL(r))
+	if err != nil {
+		writeSCIMError(w, r, "PatchUser", err)
+		return
+	}
+	logger.InfoContext(r.Context(), "Updated employee", "handler", "PatchUser", "employee", user.ID)
+	writeSCIMResource(w, r, "PatchUser", user)
+}
+
+// UnsupportedUserWrite rejects creating, replacing and deleting Users, which HR owns
+func (c *SCIMController) UnsupportedUserWrite(w http.ResponseWriter, r *http.Request) {
+	if !authorizeSCIM(w, r) {
+		return
+	}
+	writeSCIMError(w, r, "UnsupportedUserWrite", &scim.Error{
+		Status: http.StatusNotImplemented,
+		Detail: "Users are hired, transferred and terminated through the HR lifecycle actions; only PATCH is supported",
+	})
+}
+
+// ListGroups lists departments as Groups of their active employees
+func (c *SCIMController) ListGroups(w http.ResponseWriter, r *http.Request) {
+	if !authorizeSCIM(w, r) {
+		return
+	}
+	q, err := scim.ParseQuery(r.URL.Query())
+	if err != nil {
+		writeSCIMError(w, r, "ListGroups", err)
+		return
+	}
+	list, err := c.scim.ListGroups(r.Context(), q, scimBaseURL(r))
+	if err != nil {
+		writeSCIMError(w, r, "ListGroups", err)
+		return
+	}
+	writeSCIM(w, "ListGroups", http.StatusOK, list)
+}
+
+// GetGroup returns one department as a Group
+func (c *SCIMController) GetGroup(w http.ResponseWriter, r *http.Request) {
+	if !authorizeSCIM(w, r) {
+		return
+	}
+	group, err := c.scim.GetGroup(r.Context(), r.PathValue("id"), scimBaseURL(r))
+	if err != nil {
+		writeSCIMError(w, r, "GetGroup", err)
+		return
+	}
+	writeSCIMResource(w, r, "GetGroup", group)
+}
+
+// UnsupportedGroupWrite rejects changes to Groups, whose members follow the job history
+func (c *SCIMController) UnsupportedGroupWrite(w http.ResponseWriter, r *http.Request) {
+	if !authorizeSCIM(w, r) {
+		return
+	}
+	writeSCIMError(w, r, "UnsupportedGroupWrite", &scim.Error{
+		Status: http.StatusNotImplemented,
+		Detail: "Groups are the departments of the employees' current jobs and cannot be changed over SCIM",
+	})
+}
+
+// GetSchemas lists the User, enterprise User and Group schemas
+func (c *SCIMController) GetSchemas(w http.ResponseWriter, r *http.Request) {
+	schemas := scim.Schemas(scimBaseURL(r))
+	resources := make([]interface{}, len(schemas))
+	for i := range schemas {
+		resources[i] = schemas[i]
+	}
+	writeSCIMList(w, r, "GetSchemas", resources)
+}
+
+// GetSchema returns one schema by its URN
+func (c *SCIMController) GetSchema(w http.ResponseWriter, r *http.Request) {
+	for _, schema := range scim.Schemas(scimBaseURL(r)) {
+		if strings.EqualFold(schema.ID, r.PathValue("id")) {
+			writeSCIM(w, "GetSchema", http.StatusOK, schema)
+			return
+		}
+	}
+	writeSCIMError(w, r, "GetSchema", &scim.Error{Status: http.StatusNotFound, Detail: "Schema not found"})
+}
+
+// GetResourceTypes lists the User and Group resource types
+func (c *SCIMController) GetResourceTypes(w http.ResponseWriter, r *http.Request) {
+	types := scim.ResourceTypes(scimBaseURL(r))
+	resources := make([]interface{}, len(types))
+	for i := range types {
+		resources[i] = types[i]
+	}
+	writeSCIMList(w, r, "GetResourceTypes", resources)
+}
+
+// GetResourceType returns one resource type by name
+func (c *SCIMController) GetResourceType(w http.ResponseWriter, r *http.Request) {
+	for _, resourceType := range scim.ResourceTypes(scimBaseURL(r)) {
+		if strings.EqualFold(resourceType.ID, r.PathValue("id")) {
+			writeSCIM(w, "GetResourceType", http.StatusOK, resourceType)
+			return
+		}
+	}
+	writeSCIMError(w, r, "GetResourceType", &scim.Error{Status: http.StatusNotFound, Detail: "Resource type not found"})
+}
+
+// GetServiceProviderConfig describes the SCIM features this server supports
+func (c *SCIMController) GetServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	writeSCIM(w, "GetServiceProviderConfig", http.StatusOK, scim.ServiceProviderConfig(scimBaseURL(r)))
+}
+
+// authorizeSCIM allows only callers with the provisioning role, which sees unmasked emails and phones
+func authorizeSCIM(w http.ResponseWriter, r *http.Request) bool {
+	principal := auth.FromContext(r.Context())
+	if principal.HasRole(auth.RoleProvision) {
+		return true
+	}
+	if principal.Subject == auth.Anonymous.Subject {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeSCIMError(w, r, "authorizeSCIM", &scim.Error{Status: http.StatusUnauthorized, Detail: "Authentication required"})
+		return false
+	}
+	writeSCIMError(w, r, "authorizeSCIM", &scim.Error{Status: http.StatusForbidden, Detail: "The " + auth.RoleProvision + " role is required"})
+	return false
+}
+
+// scimBaseURL is the root of the SCIM API as the client reached it, used for locations and references
+func scimBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + "/scim/v2"
+}
+
+func writeSCIM(w http.ResponseWriter, handler string, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Warn("Error encoding response to JSON", "handler", handler, "err", err)
+	}
+}
+
+// writeSCIMResource writes a single resource, keeping only the attributes the query selects
+func writeSCIMResource(w http.ResponseWriter, r *http.Request, handler string, resource interface{}) {
+	q, err := scim.ParseQuery(r.URL.Query())
+	if err != nil {
+		writeSCIMError(w, r, handler, err)
+		return
+	}
+	doc, err := scim.Project(resource, q.Attributes, q.ExcludedAttributes)
+	if err != nil {
+		writeSCIMError(w, r, handler, err)
+		return
+	}
+	writeSCIM(w, handler, http.StatusOK, doc)
+}
+
+func writeSCIMList(w http.ResponseWriter, r *http.Request, handler string, resources []interface{}) {
+	list, err := scim.NewListResponse(resources, scim.Query{StartIndex: 1, Count: len(resources)})
+	if err != nil {
+		writeSCIMError(w, r, handler, err)
+		return
+	}
+	writeSCIM(w, handler, http.StatusOK, list)
+}
+
+// writeSCIMError writes err in the SCIM error schema; errors other than *scim.Error are logged and reported as 500
+func writeSCIMError(w http.ResponseWriter, r *http.Request, handler string, err error) {
+	var scimErr *scim.Error
+	if !errors.As(err, &scimErr) {
+		logger.ErrorContext(r.Context(), "Error provisioning", "handler", handler, "err", err)
+		scimErr = &scim.Error{Status: http.StatusInternalServerError, Detail: "Internal server error"}
+	}
+	writeSCIM(w, handler, scimErr.Status, scimErr)
+}
@@ -0,0 +1,618 @@
+package controller
+
+import (
+	"net/http"
+
+	"hcmnext/ai"
+	"hcmnext/bulk"
+	"hcmnext/hr"
+	"hcmnext/models"
+	"hcmnext/openapi"
+	"hcmnext/scim"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// apiDescription introduces the API description
+const apiDescription = `The REST API of the HR system and its SCIM 2.0 provisioning endpoints.
+
+Requests without a token are anonymous. Some operations need a token granted a role, and a token that is not valid is rejected with 401 everywhere.
+
+Errors are RFC 9457 problems in application/problem+json, told apart by their code. Requests accepting none of the media types an operation answers in get 406, and request bodies that are not JSON get 415.
+
+When tenants are isolated, every request outside /api/admin/status, /api/openapi.json and /api/docs names its tenant through its host name or its token; a request naming no tenant gets 400, an unknown tenant 404, and a host and token naming different tenants 403.`
+
+// apiTags describe the groups of operations
+var apiTags = []openapi.Tag{
+	{Name: "Employees", Description: "Employee records with their job, status and compensation history"},
+	{Name: "Lifecycle", Description: "Hiring, transfers, promotions, terminations and rehires, each recorded in the employee's history"},
+	{Name: "Bulk", Description: "Spreadsheet imports and extracts of employees and jobs"},
+	{Name: "Org", Description: "Reporting lines derived from the managers on current jobs"},
+	{Name: "Positions", Description: "Position slots and headcount of jobs"},
+	{Name: "Webhooks", Description: "Subscriptions to domain events, which need the webhooks role"},
+	{Name: "Changes", Description: "Live employee and job changes"},
+	{Name: "Tenant", Description: "The caller's tenant"},
+	{Name: "Admin", Description: "Server status for administrators"},
+	{Name: "SCIM", Description: "SCIM 2.0 (RFC 7643, RFC 7644) Users and Groups for identity providers, which need the provisioning role"},
+	{Name: "Assistant", Description: "Single steps of the assistant, for testing prompts"},
+	{Name: "API", Description: "This description of the API"},
+}
+
+var (
+	asOfParam = openapi.Param{Name: "asOf", Description: "The day to answer for; today when empty", Schema: openapi.Date()}
+
+	// employeeFilters select employees by their current view, ignoring case
+	employeeFilters = []openapi.Param{
+		asOfParam,
+		{Name: "department", Description: "Department of the current job"},
+		{Name: "location", Description: "Location of the current job"},
+		{Name: "jobId", Description: "Id of the current job"},
+		{Name: "title", Description: "Title of the current job"},
+		{Name: "employmentType", Description: "Employment type of the current job"},
+		{Name: "managerId", Description: "Employee id of the manager on the current job"},
+		{Name: "status", Description: "Current status"},
+	}
+
+	depthSchema = &openapi.Schema{Type: openapi.Types{"integer"}, Minimum: new(float64)}
+
+	exportParams = []openapi.Param{
+		{Name: "format", Description: "The format the Accept header prefers when empty, csv by default", Schema: openapi.Enum(bulk.FormatCSV, bulk.FormatNDJSON, bulk.FormatParquet)},
+		{Name: "fields", Description: "Comma-separated columns to include; all when empty"},
+	}
+
+	exportReplies = []openapi.Reply{
+		{Status: http.StatusOK, Description: "The extract, as an attachment", ContentType: openapi.MediaCSV, Schema: openapi.String()},
+		{Status: http.StatusOK, Description: "one JSON document per line", ContentType: openapi.MediaNDJSON, Schema: openapi.String()},
+		{Status: http.StatusOK, Description: "or a Parquet file", ContentType: openapi.MediaParquet, Schema: &openapi.Schema{Type: openapi.Types{"string"}, Format: "binary"}},
+		openapi.Error(http.StatusBadRequest, "The format, fields or filters are not valid"),
+		openapi.Error(http.StatusInternalServerError, "The export failed before writing anything; later failures cut the extract short"),
+	}
+
+	scimQuery = []openapi.Param{
+		{Name: "filter", Description: "A SCIM filter expression, such as userName eq \"jane.doe@example.com\""},
+		{Name: "startIndex", Description: "1-based index of the first result", Schema: openapi.Integer()},
+		{Name: "count", Description: "Results per page, at most 200", Schema: openapi.Integer()},
+		{Name: "attributes", Description: "Comma-separated attributes to return"},
+		{Name: "excludedAttributes", Description: "Comma-separated attributes to leave out"},
+	}
+
+	// scimAttributes select the attributes of a single resource
+	scimAttributes = scimQuery[3:]
+
+	promptParam = openapi.Param{Name: "prompt", Required: true}
+)
+
+// lifecycleReplies are the responses of a lifecycle action answering with status on success
+func lifecycleReplies(status int, action string) []openapi.Reply {
+	return []openapi.Reply{
+		openapi.JSON(status, "The employee after the "+action, models.Employee{}),
+		openapi.Error(http.StatusBadRequest, "The body is not valid, names an unknown job or breaks the history"),
+		openapi.Error(http.StatusNotFound, "Employee not found"),
+		openapi.Error(http.StatusConflict, "The action is not valid for the employee's current state"),
+		openapi.Error(http.StatusGatewayTimeout, "Timed out applying the action"),
+		openapi.Error(http.StatusInternalServerError, "Failed to apply the action"),
+	}
+}
+
+// roleReplies are the responses of operations needing a role
+func roleReplies(replies ...openapi.Reply) []openapi.Reply {
+	return append(replies,
+		openapi.Error(http.StatusUnauthorized, "No access token, or one that is not valid"),
+		openapi.Error(http.StatusForbidden, "The token was not granted the role"))
+}
+
+func scimReply(status int, description, schema string) openapi.Reply {
+	return openapi.Reply{Status: status, Description: description, ContentType: openapi.MediaSCIM, Schema: openapi.Ref(schema)}
+}
+
+// scimReplies are the responses of SCIM operations needing the provisioning role
+func scimReplies(replies ...openapi.Reply) []openapi.Reply {
+	return append(replies,
+		scimReply(http.StatusUnauthorized, "No access token, or one that is not valid", "ScimError"),
+		scimReply(http.StatusForbidden, "The token was not granted the provisioning role", "ScimError"),
+		scimReply(http.StatusInternalServerError, "Internal server error", "ScimError"))
+}
+
+// apiRoutes documents the routes of the REST and SCIM APIs by pattern
+var apiRoutes = map[string]openapi.Route{
+	"POST /api/employees": {
+		ID: "createEmployee", Tag: "Employees", Summary: "Create an employee",
+		Description: "Prefer the hire action, which also keeps job headcount in line.",
+		Body:        models.Employee{},
+		Replies: []openapi.Reply{
+			{Status: http.StatusCreated, Description: "The employee created", Body: employeeResponse{},
+				Headers: map[string]string{"Location": "The employee's URL"}},
+			openapi.Error(http.StatusBadRequest, "The body is not valid, or its fields break the rules of the employee or its history"),
+			openapi.Error(http.StatusConflict, "Employee already exists"),
+			openapi.Error(http.StatusInternalServerError, "Failed to create employee"),
+		},
+	},
+	"GET /api/employees": {
+		ID: "listEmployees", Tag: "Employees", Summary: "List employees",
+		Description: "Every employee, or those whose current view matches every filter given.",
+		Query:       employeeFilters,
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The employees", []models.Employee{}),
+			openapi.Error(http.StatusBadRequest, "asOf is not a date"),
+			openapi.Error(http.StatusInternalServerError, "Failed to retrieve employees"),
+		},
+	},
+	"GET /api/employees/{id}": {
+		ID: "getEmployee", Tag: "Employees", Summary: "Get an employee",
+		Description: "The employee with their current view: the job, status and pay in effect on asOf.",
+		Query:       []openapi.Param{asOfParam},
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The employee", employeeResponse{}),
+			openapi.Error(http.StatusBadRequest, "asOf is not a date"),
+			openapi.Error(http.StatusNotFound, "Employee not found"),
+			openapi.Error(http.StatusInternalServerError, "Failed to retrieve employee"),
+		},
+	},
+	"PUT /api/employees/{id}": {
+		ID: "updateEmployee", Tag: "Employees", Summary: "Replace an employee",
+		Body: models.Employee{},
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The employee updated", employeeResponse{}),
+			openapi.Error(http.StatusBadRequest, "The body is not valid, names another employee, or its fields break the rules of the employee or its history"),
+			openapi.Error(http.StatusNotFound, "Employee not found"),
+			openapi.Error(http.StatusInternalServerError, "Failed to update employee"),
+		},
+	},
+	"DELETE /api/employees/{id}": {
+		ID: "deleteEmployee", Tag: "Employees", Summary: "Delete an employee",
+		Replies: []openapi.Reply{
+			openapi.Empty(http.StatusNoContent, "The employee is deleted"),
+			openapi.Error(http.StatusNotFound, "Employee not found"),
+			openapi.Error(http.StatusInternalServerError, "Failed to delete employee"),
+		},
+	},
+
+	"POST /api/employees/import": {
+		ID: "importEmployees", Tag: "Bulk", Summary: "Import employees from a spreadsheet",
+		Description: "Upserts the employees of a CSV or XLSX file by employeeId, writing only the fields the file has columns " +
+			"for over stored employees. New employees must be complete; stored ones must stay valid with the file's " +
+			"columns applied. The file is the file field of a multipart form, " +
+			"with the options as form fields, or the raw body with the options as query parameters. A dry run answers with " +
+			"the validation report; otherwise the import runs in the background.",
+		Query: []openapi.Param{
+			{Name: "format", Description: "Taken from the file name or content type when empty", Schema: openapi.Enum(bulk.FormatCSV, bulk.FormatXLSX)},
+			{Name: "mapping", Description: "A JSON object from column header to field path"},
+			{Name: "dryRun", Schema: openapi.Boolean()},
+		},
+		BodyContent: map[string]*openapi.Schema{
+			openapi.MediaMultipart: {
+				Type: openapi.Types{"object"},
+				Properties: openapi.Properties{
+					{Name: "file", Schema: &openapi.Schema{Type: openapi.Types{"string"}, Format: "binary"}},
+					{Name: "format", Schema: openapi.Enum(bulk.FormatCSV, bulk.FormatXLSX)},
+					{Name: "mapping", Schema: openapi.String()},
+					{Name: "dryRun", Schema: openapi.Boolean()},
+				},
+				Required: []string{"file"},
+			},
+			openapi.MediaCSV: openapi.String(),
+			"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": {Type: openapi.Types{"string"}, Format: "binary"},
+		},
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The report of a dry run", bulk.ImportReport{}),
+			{Status: http.StatusAccepted, Description: "The import started", Body: bulk.ImportReport{},
+				Headers: map[string]string{"Location": "Where to follow the import"}},
+			openapi.Error(http.StatusBadRequest, "The form, format, mapping or file is not valid"),
+			openapi.Error(http.StatusRequestEntityTooLarge, "The file is over 64 MiB"),
+			openapi.Error(http.StatusInternalServerError, "Failed to start the import"),
+		},
+	},
+	"GET /api/imports/{id}": {
+		ID: "getImport", Tag: "Bulk", Summary: "Follow an import",
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The progress of the import", bulk.ImportReport{}),
+			openapi.Error(http.StatusNotFound, "Import not found"),
+			openapi.Error(http.StatusInternalServerError, "Failed to retrieve the import"),
+		},
+	},
+	"GET /api/employees/export": {
+		ID: "exportEmployees", Tag: "Bulk", Summary: "Export employees",
+		Description: "Streams the employees matching the filters, in the format asked for or else the one the Accept header prefers. " +
+			"Personal data is masked unless the token was granted the PII role.",
+		Query:   append(append([]openapi.Param{}, employeeFilters...), exportParams...),
+		Replies: exportReplies,
+	},
+	"GET /api/jobs/export": {
+		ID: "exportJobs", Tag: "Bulk", Summary: "Export jobs",
+		Query:   exportParams,
+		Replies: exportReplies,
+	},
+
+	"POST /api/employees/{id}/actions/hire": {
+		ID: "hireEmployee", Tag: "Lifecycle", Summary: "Hire an employee",
+		Description: "Creates the employee with their first job, status and compensation. The employee must be complete and its id must match the path.",
+		Body:        hr.HireAction{},
+		Replies: append(lifecycleReplies(http.StatusCreated, "hire"),
+			openapi.Error(http.StatusConflict, "Employee already exists")),
+	},
+	"POST /api/employees/{id}/actions/transfer": {
+		ID: "transferEmployee", Tag: "Lifecycle", Summary: "Transfer an employee",
+		Description: "Moves the employee to another job, department, location or manager.",
+		Body:        hr.TransferAction{},
+		Replies:     lifecycleReplies(http.StatusOK, "transfer"),
+	},
+	"POST /api/employees/{id}/actions/promote": {
+		ID: "promoteEmployee", Tag: "Lifecycle", Summary: "Promote an employee",
+		Body:    hr.PromoteAction{},
+		Replies: lifecycleReplies(http.StatusOK, "promotion"),
+	},
+	"POST /api/employees/{id}/actions/terminate": {
+		ID: "terminateEmployee", Tag: "Lifecycle", Summary: "Terminate an employee",
+		Description: "Ends the employment after date, the last day worked.",
+		Body:        hr.TerminateAction{},
+		Replies:     lifecycleReplies(http.StatusOK, "termination"),
+	},
+	"POST /api/employees/{id}/actions/rehire": {
+		ID: "rehireEmployee", Tag: "Lifecycle", Summary: "Rehire an employee",
+		Description: "Brings back a terminated or retired employee.",
+		Body:        hr.RehireAction{},
+		Replies:     lifecycleReplies(http.StatusOK, "rehire"),
+	},
+
+	"GET /api/employees/{id}/reports": {
+		ID: "getReports", Tag: "Org", Summary: "List an employee's reports",
+		Query: []openapi.Param{asOfParam, {Name: "depth", Description: "Levels of reports to list, 0 for all; 1 when empty", Schema: depthSchema}},
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The reports", reportsResponse{}),
+			openapi.Error(http.StatusBadRequest, "asOf or depth is not valid"),
+			openapi.Error(http.StatusNotFound, "Employee not found in the org"),
+			openapi.Error(http.StatusInternalServerError, "Failed to load the org"),
+		},
+	},
+	"GET /api/employees/{id}/chain-of-command": {
+		ID: "getChainOfCommand", Tag: "Org", Summary: "List an employee's managers",
+		Query: []openapi.Param{asOfParam},
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The managers up to the top of the org", chainResponse{}),
+			openapi.Error(http.StatusBadRequest, "asOf is not a date"),
+			openapi.Error(http.StatusNotFound, "Employee not found in the org"),
+			openapi.Error(http.StatusInternalServerError, "Failed to load the org"),
+		},
+	},
+	"GET /api/org/stats": {
+		ID: "getOrgStats", Tag: "Org", Summary: "Summarize span of control",
+		Query: []openapi.Param{asOfParam},
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "Span of control across the org", hr.OrgStats{}),
+			openapi.Error(http.StatusBadRequest, "asOf is not a date"),
+			openapi.Error(http.StatusInternalServerError, "Failed to load the org"),
+		},
+	},
+	"GET /api/org/issues": {
+		ID: "getOrgIssues", Tag: "Org", Summary: "Find problems in the reporting lines",
+		Description: "Reporting cycles and employees whose manager is not active.",
+		Query:       []openapi.Param{asOfParam},
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The issues", []hr.OrgIssue{}),
+			openapi.Error(http.StatusBadRequest, "asOf is not a date"),
+			openapi.Error(http.StatusInternalServerError, "Failed to load the org"),
+		},
+	},
+	"GET /api/org/chart": {
+		ID: "getOrgChart", Tag: "Org", Summary: "Export the org chart",
+		Query: []openapi.Param{
+			asOfParam,
+			{Name: "root", Description: "Employee id to chart from; the top of the org when empty"},
+			{Name: "depth", Description: "Levels to chart, 0 for all", Schema: depthSchema},
+			{Name: "format", Description: "mermaid when empty and the request prefers text/plain, json otherwise", Schema: openapi.Enum("json", "mermaid")},
+		},
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The chart as a tree", []*hr.OrgChartNode{}),
+			{Status: http.StatusOK, Description: "or as a Mermaid flowchart", ContentType: openapi.MediaText, Schema: openapi.String()},
+			openapi.Error(http.StatusBadRequest, "asOf, depth or format is not valid"),
+			openapi.Error(http.StatusNotFound, "Employee not found in the org"),
+			openapi.Error(http.StatusInternalServerError, "Failed to load the org"),
+		},
+	},
+
+	"GET /api/jobs/{id}/positions": {
+		ID: "listSlots", Tag: "Positions", Summary: "List a job's position slots",
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The slots", []models.PositionSlot{}),
+			openapi.Error(http.StatusInternalServerError, "Failed to retrieve positions"),
+		},
+	},
+	"POST /api/jobs/{id}/positions": {
+		ID: "openSlots", Tag: "Positions", Summary: "Open position slots",
+		Body: openSlotsRequest{},
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusCreated, "The slots opened", []models.PositionSlot{}),
+			openapi.Error(http.StatusBadRequest, "Invalid request body"),
+			openapi.Error(http.StatusNotFound, "Job not found"),
+			openapi.Error(http.StatusConflict, "The job has no such position, or the count is not positive"),
+			openapi.Error(http.StatusInternalServerError, "Failed to update positions"),
+		},
+	},
+	"POST /api/jobs/{id}/headcount/recalculate": {
+		ID: "recalculateHeadcount", Tag: "Positions", Summary: "Rebuild a job's headcount from its employees",
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The job", models.Job{}),
+			openapi.Error(http.StatusNotFound, "Job not found"),
+			openapi.Error(http.StatusConflict, "The headcount cannot be rebuilt"),
+			openapi.Error(http.StatusInternalServerError, "Failed to update positions"),
+		},
+	},
+	"POST /api/positions/{slotId}/freeze": {
+		ID: "freezeSlot", Tag: "Positions", Summary: "Freeze an open slot",
+		Body: freezeRequest{},
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The slot", models.PositionSlot{}),
+			openapi.Error(http.StatusBadRequest, "Invalid request body"),
+			openapi.Error(http.StatusNotFound, "Position not found"),
+			openapi.Error(http.StatusConflict, "The slot is not open"),
+			openapi.Error(http.StatusInternalServerError, "Failed to update positions"),
+		},
+	},
+	"POST /api/positions/{slotId}/unfreeze": {
+		ID: "unfreezeSlot", Tag: "Positions", Summary: "Reopen a frozen slot",
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The slot", models.PositionSlot{}),
+			openapi.Error(http.StatusNotFound, "Position not found"),
+			openapi.Error(http.StatusConflict, "The slot is not frozen"),
+			openapi.Error(http.StatusInternalServerError, "Failed to update positions"),
+		},
+	},
+	"GET /api/positions/vacancies": {
+		ID: "getVacancies", Tag: "Positions", Summary: "Report open and frozen seats",
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The seats of every job", []hr.Vacancy{}),
+			openapi.Error(http.StatusInternalServerError, "Failed to build vacancy report"),
+		},
+	},
+	"GET /api/positions/consistency": {
+		ID: "checkHeadcount", Tag: "Positions", Summary: "Find jobs whose headcount disagrees with their employees or slots",
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The disagreements", []hr.HeadcountIssue{}),
+			openapi.Error(http.StatusInternalServerError, "Failed to check headcount"),
+		},
+	},
+
+	"GET /api/admin/status": {
+		ID: "getStatus", Tag: "Admin", Summary: "Describe the server and its dependencies",
+		Description: "Needs the admin role.",
+		Replies:     roleReplies(openapi.JSON(http.StatusOK, "The status", statusResponse{})),
+	},
+	"GET /api/tenant": {
+		ID: "getTenant", Tag: "Tenant", Summary: "Get the caller's tenant",
+		Description: "The tenant's assistant configuration and quotas, and today's usage.",
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The tenant", tenantResponse{}),
+			openapi.Error(http.StatusUnauthorized, "No access token, or one that is not valid"),
+			openapi.Error(http.StatusInternalServerError, "Failed to load tenant"),
+		},
+	},
+	"GET /api/changes": {
+		ID: "streamChanges", Tag: "Changes", Summary: "Stream changes",
+		Description: "Sends every employee and job change of the tenant as a server-sent change event until the client goes away. " +
+			"The stream ends when the client falls behind; the client should reload what it shows when it reconnects.",
+		Replies: []openapi.Reply{
+			{Status: http.StatusOK, Description: "The event stream", ContentType: openapi.MediaEvents, Schema: openapi.String()},
+			openapi.Error(http.StatusInternalServerError, "Streaming is not supported"),
+		},
+	},
+
+	"POST /api/webhooks": {
+		ID: "createSubscription", Tag: "Webhooks", Summary: "Subscribe a URL to events",
+		Body: subscribeRequest{},
+		Replies: roleReplies(
+			openapi.JSON(http.StatusCreated, "The subscription with its signing secret, which is only ever shown here", subscriptionCreated{}),
+			openapi.Error(http.StatusBadRequest, "The URL or event types are not valid, or the URL points at a private, loopback or link-local address"),
+			openapi.Error(http.StatusInternalServerError, "Failed to process webhook request")),
+	},
+	"GET /api/webhooks": {
+		ID: "listSubscriptions", Tag: "Webhooks", Summary: "List the active subscriptions",
+		Replies: roleReplies(
+			openapi.JSON(http.StatusOK, "The subscriptions", []models.WebhookSubscription{}),
+			openapi.Error(http.StatusInternalServerError, "Failed to process webhook request")),
+	},
+	"DELETE /api/webhooks/{id}": {
+		ID: "deleteSubscription", Tag: "Webhooks", Summary: "Stop deliveries to a subscription",
+		Replies: roleReplies(
+			openapi.Empty(http.StatusNoContent, "The subscription is no longer active"),
+			openapi.Error(http.StatusNotFound, "Subscription not found"),
+			openapi.Error(http.StatusInternalServerError, "Failed to process webhook request")),
+	},
+	"GET /api/webhooks/{id}/deliveries": {
+		ID: "listDeliveries", Tag: "Webhooks", Summary: "List a subscription's most recent deliveries",
+		Query: []openapi.Param{
+			{Name: "status", Description: "Only deliveries with this status; dead lists the dead letters",
+				Schema: openapi.Enum(models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead)},
+			{Name: "limit", Description: "100 when empty", Schema: &openapi.Schema{Type: openapi.Types{"integer"}, Minimum: ptr(1.0), Maximum: ptr(1000.0)}},
+		},
+		Replies: roleReplies(
+			openapi.JSON(http.StatusOK, "The deliveries, newest first", []models.WebhookDelivery{}),
+			openapi.Error(http.StatusBadRequest, "status or limit is not valid"),
+			openapi.Error(http.StatusNotFound, "Subscription not found"),
+			openapi.Error(http.StatusInternalServerError, "Failed to process webhook request")),
+	},
+	"POST /api/webhooks/{id}/replay": {
+		ID: "replayEvents", Tag: "Webhooks", Summary: "Deliver past events again",
+		Description: "Queues every event between since and until, now when empty, for delivery to the subscription.",
+		Body:        replayRequest{},
+		Replies: roleReplies(
+			openapi.JSON(http.StatusAccepted, "The deliveries queued", replayResponse{}),
+			openapi.Error(http.StatusBadRequest, "The body or time window is not valid"),
+			openapi.Error(http.StatusNotFound, "Subscription not found"),
+			openapi.Error(http.StatusInternalServerError, "Failed to process webhook request")),
+	},
+	"POST /api/webhooks/deliveries/{deliveryId}/redeliver": {
+		ID: "redeliver", Tag: "Webhooks", Summary: "Send a delivery again",
+		Description: "Gives the delivery a fresh set of attempts.",
+		Replies: roleReplies(
+			openapi.JSON(http.StatusAccepted, "The delivery", models.WebhookDelivery{}),
+			openapi.Error(http.StatusNotFound, "Delivery not found"),
+			openapi.Error(http.StatusInternalServerError, "Failed to process webhook request")),
+	},
+
+	"GET /scim/v2/Users": {
+		ID: "scimListUsers", Tag: "SCIM", Summary: "List employees as Users",
+		Query: scimQuery,
+		Replies: scimReplies(
+			scimReply(http.StatusOK, "A page of Users", "ScimListResponse"),
+			scimReply(http.StatusBadRequest, "The filter or paging is not valid", "ScimError")),
+	},
+	"POST /scim/v2/Users": {
+		ID: "scimCreateUser", Tag: "SCIM", Summary: "Not supported",
+		Description: "Users are hired, transferred and terminated through the lifecycle actions; only PATCH is supported.",
+		Replies:     scimReplies(scimReply(http.StatusNotImplemented, "Not supported", "ScimError")),
+	},
+	"GET /scim/v2/Users/{id}": {
+		ID: "scimGetUser", Tag: "SCIM", Summary: "Get an employee as a User",
+		Query: scimAttributes,
+		Replies: scimReplies(
+			scimReply(http.StatusOK, "The User", "ScimResource"),
+			scimReply(http.StatusBadRequest, "The attributes are not valid", "ScimError"),
+			scimReply(http.StatusNotFound, "User not found", "ScimError")),
+	},
+	"PATCH /scim/v2/Users/{id}": {
+		ID: "scimPatchUser", Tag: "SCIM", Summary: "Update the name, nickName, email or phone of an employee",
+		Query:    scimAttributes,
+		Body:     scim.PatchRequest{},
+		BodyType: openapi.MediaSCIM,
+		Replies: scimReplies(
+			scimReply(http.StatusOK, "The User", "ScimResource"),
+			scimReply(http.StatusBadRequest, "The patch is not valid", "ScimError"),
+			scimReply(http.StatusNotFound, "User not found", "ScimError")),
+	},
+	"PUT /scim/v2/Users/{id}": {
+		ID: "scimReplaceUser", Tag: "SCIM", Summary: "Not supported",
+		Replies: scimReplies(scimReply(http.StatusNotImplemented, "Not supported", "ScimError")),
+	},
+	"DELETE /scim/v2/Users/{id}": {
+		ID: "scimDeleteUser", Tag: "SCIM", Summary: "Not supported",
+		Replies: scimReplies(scimReply(http.StatusNotImplemented, "Not supported", "ScimError")),
+	},
+	"GET /scim/v2/Groups": {
+		ID: "scimListGroups", Tag: "SCIM", Summary: "List departments as Groups of their active employees",
+		Query: scimQuery,
+		Replies: scimReplies(
+			scimReply(http.StatusOK, "A page of Groups", "ScimListResponse"),
+			scimReply(http.StatusBadRequest, "The filter or paging is not valid", "ScimError")),
+	},
+	"POST /scim/v2/Groups": {
+		ID: "scimCreateGroup", Tag: "SCIM", Summary: "Not supported",
+		Description: "Groups are the departments of the employees' current jobs.",
+		Replies:     scimReplies(scimReply(http.StatusNotImplemented, "Not supported", "ScimError")),
+	},
+	"GET /scim/v2/Groups/{id}": {
+		ID: "scimGetGroup", Tag: "SCIM", Summary: "Get a department as a Group",
+		Query: scimAttributes,
+		Replies: scimReplies(
+			scimReply(http.StatusOK, "The Group", "ScimResource"),
+			scimReply(http.StatusBadRequest, "The attributes are not valid", "ScimError"),
+			scimReply(http.StatusNotFound, "Group not found", "ScimError")),
+	},
+	"PATCH /scim/v2/Groups/{id}": {
+		ID: "scimPatchGroup", Tag: "SCIM", Summary: "Not supported",
+		Replies: scimReplies(scimReply(http.StatusNotImplemented, "Not supported", "ScimError")),
+	},
+	"PUT /scim/v2/Groups/{id}": {
+		ID: "scimReplaceGroup", Tag: "SCIM", Summary: "Not supported",
+		Replies: scimReplies(scimReply(http.StatusNotImplemented, "Not supported", "ScimError")),
+	},
+	"DELETE /scim/v2/Groups/{id}": {
+		ID: "scimDeleteGroup", Tag: "SCIM", Summary: "Not supported",
+		Replies: scimReplies(scimReply(http.StatusNotImplemented, "Not supported", "ScimError")),
+	},
+	"GET /scim/v2/Schemas": {
+		ID: "scimListSchemas", Tag: "SCIM", Summary: "List the User, enterprise User and Group schemas", Public: true,
+		Replies: []openapi.Reply{
+			scimReply(http.StatusOK, "The schemas", "ScimListResponse"),
+			scimReply(http.StatusInternalServerError, "Internal server error", "ScimError"),
+		},
+	},
+	"GET /scim/v2/Schemas/{id}": {
+		ID: "scimGetSchema", Tag: "SCIM", Summary: "Get a schema by its URN", Public: true,
+		Replies: []openapi.Reply{
+			{Status: http.StatusOK, Description: "The schema", ContentType: openapi.MediaSCIM, Body: scim.Schema{}},
+			scimReply(http.StatusNotFound, "Schema not found", "ScimError"),
+		},
+	},
+	"GET /scim/v2/ResourceTypes": {
+		ID: "scimListResourceTypes", Tag: "SCIM", Summary: "List the User and Group resource types", Public: true,
+		Replies: []openapi.Reply{
+			scimReply(http.StatusOK, "The resource types", "ScimListResponse"),
+			scimReply(http.StatusInternalServerError, "Internal server error", "ScimError"),
+		},
+	},
+	"GET /scim/v2/ResourceTypes/{id}": {
+		ID: "scimGetResourceType", Tag: "SCIM", Summary: "Get a resource type by name", Public: true,
+		Replies: []openapi.Reply{
+			{Status: http.StatusOK, Description: "The resource type", ContentType: openapi.MediaSCIM, Body: scim.ResourceType{}},
+			scimReply(http.StatusNotFound, "Resource type not found", "ScimError"),
+		},
+	},
+	"GET /scim/v2/ServiceProviderConfig": {
+		ID: "scimGetServiceProviderConfig", Tag: "SCIM", Summary: "Describe the SCIM features supported", Public: true,
+		Replies: []openapi.Reply{
+			{Status: http.StatusOK, Description: "The service provider configuration", ContentType: openapi.MediaSCIM,
+				Schema: &openapi.Schema{Type: openapi.Types{"object"}, Required: []string{"schemas"}}},
+		},
+	},
+
+	"GET /api/exectionplan": {
+		ID: "generateExecutionPlan", Tag: "Assistant", Summary: "Plan the tools to answer a prompt",
+		Query: []openapi.Param{promptParam},
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The plan", ai.ExecutionPlan{}),
+			openapi.Error(http.StatusBadRequest, "Missing 'prompt' query parameter"),
+			openapi.Error(http.StatusBadGateway, "The model call failed"),
+		},
+	},
+	"GET /api/usetool": {
+		ID: "shouldUseTool", Tag: "Assistant", Summary: "Decide whether a conversation needs tools",
+		Body: []openai.ChatCompletionMessage{},
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The decision", ai.ToolResponse{}),
+			openapi.Error(http.StatusBadRequest, "The body is not a list of chat messages"),
+			openapi.Error(http.StatusBadGateway, "The model call failed"),
+		},
+	},
+	"GET /api/math": {
+		ID: "generateMath", Tag: "Assistant", Summary: "Write and evaluate a calculation for a prompt",
+		Query: []openapi.Param{promptParam},
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The calculation", ai.MathResponse{}),
+			openapi.Error(http.StatusBadRequest, "Missing 'prompt' query parameter"),
+			openapi.Error(http.StatusBadGateway, "The model call failed"),
+		},
+	},
+	"GET /api/displayhtml": {
+		ID: "generateDisplay", Tag: "Assistant", Summary: "Generate display markup for a prompt",
+		Query: []openapi.Param{promptParam},
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The sanitized markup", ai.DisplayResponse{}),
+			openapi.Error(http.StatusBadRequest, "Missing 'prompt' query parameter"),
+			openapi.Error(http.StatusBadGateway, "The model call failed"),
+		},
+	},
+	"GET /api/traces": {
+		ID: "listTraces", Tag: "Assistant", Summary: "List recent chat traces",
+		Description: "The most recent chat requests of the tenant, oldest first, with the prompt versions in use.",
+		Replies: []openapi.Reply{
+			openapi.JSON(http.StatusOK, "The traces", tracesResponse{}),
+		},
+	},
+
+	"GET /api/openapi.json": {
+		ID: "getOpenAPI", Tag: "API", Summary: "This OpenAPI document", Public: true,
+		Replies: []openapi.Reply{
+			{Status: http.StatusOK, Description: "The OpenAPI 3.1 document", Schema: &openapi.Schema{Type: openapi.Types{"object"}, Required: []string{"openapi", "info", "paths"}}},
+		},
+	},
+	"GET /api/docs": {
+		ID: "getDocs", Tag: "API", Summary: "A page to read this document", Public: true,
+		Replies: []openapi.Reply{
+			{Status: http.StatusOK, Description: "The API reference", ContentType: openapi.MediaHTML, Schema: openapi.String()},
+		},
+	},
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"net/http"
+
+	"hcmnext/auth"
+	"hcmnext/models"
+	"hcmnext/problem"
+	"hcmnext/tenant"
+)
+
+// TenantController shows callers the configuration of their own tenant
+type TenantController struct {
+	registry *tenant.Registry
+}
+
+// NewTenantController creates a new instance of TenantController
+func NewTenantController(registry *tenant.Registry) *TenantController {
+	return &TenantController{registry: registry}
+}
+
+// tenantResponse is a tenant with its assistant use today
+type tenantResponse struct {
+	*models.Tenant
+	Usage *models.TenantUsage `json:"usage"`
+}
+
+// GetTenant returns the caller's tenant, its assistant configuration and quotas, and today's usage
+func (c *TenantController) GetTenant(w http.ResponseWriter, r *http.Request) error {
+	if auth.FromContext(r.Context()).Subject == auth.Anonymous.Subject {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		return problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "Authentication required")
+	}
+
+	t, err := c.registry.FromContext(r.Context())
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error loading tenant", "handler", "GetTenant", "err", err)
+		return internalError("Failed to load tenant")
+	}
+	usage, err := c.registry.Usage(r.Context())
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error loading usage", "handler", "GetTenant", "err", err)
+		return internalError("Failed to load tenant")
+	}
+	writeJSON(w, "GetTenant", tenantResponse{Tenant: t, Usage: usage})
+	return nil
+}
@@ -2,10 +2,9 @@ package controller
 
 import (
 	"encoding/json"
-	"fmt"
 	openai "github.com/sashabaranov/go-openai"
 	"hcmnext/ai"
-	"log"
+	"hcmnext/problem"
 	"net/http"
 )
 
@@ -19,18 +18,13 @@ func NewTestController(aiClient *ai.Client) *TestController {
 	}
 }
 
-func (c *TestController) HandleGenerateExecutionPlan(w http.ResponseWriter, r *http.Request) {
-	log.Printf("HandleGenerateExecutionPlan called with method: %s", r.Method)
-
+func (c *TestController) HandleGenerateExecutionPlan(w http.ResponseWriter, r *http.Request) error {
 	// Extract the prompt from the query parameters
 	prompt := r.URL.Query().Get("prompt")
 	if prompt == "" {
-		http.Error(w, "Missing 'prompt' query parameter", http.StatusBadRequest)
-		return
+		return problem.InvalidParameter("prompt", "Missing 'prompt' query parameter")
 	}
 
-	log.Printf("Generating Execution Plan for: %s", prompt)
-
 	chatMessages := []openai.ChatCompletionMessage{
 		{
 			Role:    "user",
@@ -41,69 +35,42 @@ func (c *TestController) HandleGenerateExecutionPlan(w http.ResponseWriter, r *h
 	// Call the GenerateExecutionPlan function
 	result, err := c.aiClient.GenerateExecutionPlan(nil, chatMessages)
 	if err != nil {
-		log.Printf("Error Generating Execution Plan: %v", err)
-		http.Error(w, fmt.Sprintf("Error Generating Execution Plan: %v", err), http.StatusInternalServerError)
-		return
+		logger.ErrorContext(r.Context(), "Error generating execution plan", "handler", "HandleGenerateExecutionPlan", "err", err)
+		return problem.New(http.StatusBadGateway, problem.CodeModelFailed, "Failed to generate the execution plan")
 	}
 
-	// Set the content type to JSON
-	w.Header().Set("Content-Type", "application/json")
-
 	// Encode and send the response
-	if err := json.NewEncoder(w).Encode(result); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, fmt.Sprintf("Error encoding response: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	fmt.Println("here 1")
-
-	log.Printf("Successfully responded to execution plan request for: %s", prompt)
+	writeJSON(w, "HandleGenerateExecutionPlan", result)
+	return nil
 }
 
-func (c *TestController) HandleToolUse(w http.ResponseWriter, r *http.Request) {
-	log.Printf("HandleGenerateExecutionPlan called with method: %s", r.Method)
-
+func (c *TestController) HandleToolUse(w http.ResponseWriter, r *http.Request) error {
 	// extract the body json and marshal it into a []openai.ChatCompletionMessage
 	var messages []openai.ChatCompletionMessage
-	if err := json.NewDecoder(r.Body).Decode(&messages); err != nil {
-		log.Printf("Error decoding request body: %v", err)
-		http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
-		return
+	if err := decodeJSON(r, &messages); err != nil {
+		logger.WarnContext(r.Context(), "Error decoding request body", "handler", "HandleToolUse", "err", err)
+		return err
 	}
 
-	log.Printf("should use tool for: %v", messages)
-
 	// Call the GenerateExecutionPlan function
 	result, err := c.aiClient.ShouldUseTool(nil, messages)
 	if err != nil {
-		log.Printf("Error should use tool: %v", err)
-		http.Error(w, fmt.Sprintf("Error should use tool: %v", err), http.StatusInternalServerError)
-		return
+		logger.ErrorContext(r.Context(), "Error deciding whether to use tools", "handler", "HandleToolUse", "err", err)
+		return problem.New(http.StatusBadGateway, problem.CodeModelFailed, "Failed to decide whether to use tools")
 	}
 
 	// Encode and send the response
-	if err := json.NewEncoder(w).Encode(result); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, fmt.Sprintf("Error encoding response: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	log.Printf("Successfully responded to execution plan request for: %v", messages)
+	writeJSON(w, "HandleToolUse", result)
+	return nil
 }
 
-func (c *TestController) HandleGenerateMath(w http.ResponseWriter, r *http.Request) {
-	log.Printf("HandleGenerateExecutionPlan called with method: %s", r.Method)
-
+func (c *TestController) HandleGenerateMath(w http.ResponseWriter, r *http.Request) error {
 	// Extract the prompt from the query parameters
 	prompt := r.URL.Query().Get("prompt")
 	if prompt == "" {
-		http.Error(w, "Missing 'prompt' query parameter", http.StatusBadRequest)
-		return
+		return problem.InvalidParameter("prompt", "Missing 'prompt' query parameter")
 	}
 
-	fmt.Printf("Generating Math via Python for: %s", prompt)
-
 	chatMessages := []openai.ChatCompletionMessage{
 		{
 			Role:    "user",
@@ -114,36 +81,22 @@ func (c *TestController) HandleGenerateMath(w http.ResponseWriter, r *http.Reque
 	// Call the GenerateExecutionPlan function
 	result, err := c.aiClient.GenerateMath(nil, chatMessages)
 	if err != nil {
-		log.Printf("Error Generating Math via Python: %v", err)
-		http.Error(w, fmt.Sprintf("Error Generating Math via Python: %v", err), http.StatusInternalServerError)
-		return
+		logger.ErrorContext(r.Context(), "Error generating math", "handler", "HandleGenerateMath", "err", err)
+		return problem.New(http.StatusBadGateway, problem.CodeModelFailed, "Failed to generate the calculation")
 	}
 
-	// Set the content type to JSON
-	w.Header().Set("Content-Type", "application/json")
-
 	// Encode and send the response
-	if err := json.NewEncoder(w).Encode(result); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, fmt.Sprintf("Error encoding response: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	fmt.Printf("Successfully responded to execution plan request for: %s", prompt)
+	writeJSON(w, "HandleGenerateMath", result)
+	return nil
 }
 
-func (c *TestController) HandleGenerateDisplayHtml(w http.ResponseWriter, r *http.Request) {
-	log.Printf("HandleGenerateDisplayHtml called with method: %s", r.Method)
-
+func (c *TestController) HandleGenerateDisplayHtml(w http.ResponseWriter, r *http.Request) error {
 	// Extract the prompt from the query parameters
 	prompt := r.URL.Query().Get("prompt")
 	if prompt == "" {
-		http.Error(w, "Missing 'prompt' query parameter", http.StatusBadRequest)
-		return
+		return problem.InvalidParameter("prompt", "Missing 'prompt' query parameter")
 	}
 
-	fmt.Printf("Generating HTML for: %s", prompt)
-
 	chatMessages := []openai.ChatCompletionMessage{
 		{
 			Role:    "user",
@@ -154,20 +107,29 @@ func (c *TestController) HandleGenerateDisplayHtml(w http.ResponseWriter, r *htt
 	// Call the GenerateExecutionPlan function
 	result, err := c.aiClient.GenerateDisplayHtml(nil, chatMessages)
 	if err != nil {
-		log.Printf("Error HTML: %v", err)
-		http.Error(w, fmt.Sprintf("Error HTML: %v", err), http.StatusInternalServerError)
-		return
+		logger.ErrorContext(r.Context(), "Error generating display HTML", "handler", "HandleGenerateDisplayHtml", "err", err)
+		return problem.New(http.StatusBadGateway, problem.CodeModelFailed, "Failed to generate the display markup")
 	}
 
-	// Set the content type to JSON
-	w.Header().Set("Content-Type", "application/json")
-
 	// Encode and send the response
-	if err := json.NewEncoder(w).Encode(result); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, fmt.Sprintf("Error encoding response: %v", err), http.StatusInternalServerError)
-		return
-	}
+	writeJSON(w, "HandleGenerateDisplayHtml", result)
+	return nil
+}
+
+// tracesResponse is the prompt versions in use and the most recent traces
+type tracesResponse struct {
+	Prompts map[string][]string `json:"prompts"`
+	Traces  []*ai.Trace         `json:"traces"`
+}
 
-	log.Printf("Successfully responded to execution plan request for: %s", prompt)
+// HandleTraces lists the most recent chat request traces with the prompt versions they used
+func (c *TestController) HandleTraces(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tracesResponse{
+		Prompts: c.aiClient.Prompts().Versions(),
+		Traces:  c.aiClient.Traces(r.Context()),
+	}); err != nil {
+		logger.WarnContext(r.Context(), "Error encoding response", "handler", "HandleTraces", "err", err)
+	}
+	return nil
 }
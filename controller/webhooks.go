@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"hcmnext/auth"
+	"hcmnext/events"
+	"hcmnext/models"
+	"hcmnext/problem"
+)
+
+// WebhookController manages webhook subscriptions, their deliveries and replays
+type WebhookController struct {
+	webhooks *events.Webhooks
+}
+
+// NewWebhookController creates a new instance of WebhookController
+func NewWebhookController(webhooks *events.Webhooks) *WebhookController {
+	return &WebhookController{webhooks: webhooks}
+}
+
+// subscribeRequest is the body of CreateSubscription
+type subscribeRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+}
+
+// subscriptionCreated shows the signing secret, which is only ever returned on creation
+type subscriptionCreated struct {
+	*models.WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+// replayRequest is the body of Replay; until defaults to now
+type replayRequest struct {
+	Since      time.Time `json:"since"`
+	Until      time.Time `json:"until"`
+	EventTypes []string  `json:"eventTypes"`
+}
+
+// replayResponse counts the deliveries a replay queued
+type replayResponse struct {
+	Queued int `json:"queued"`
+}
+
+// CreateSubscription registers a URL to receive events
+func (c *WebhookController) CreateSubscription(w http.ResponseWriter, r *http.Request) error {
+	if err := requireRole(w, r, auth.RoleManageWebhooks); err != nil {
+		return err
+	}
+	var req subscribeRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+
+	sub, err := c.webhooks.Subscribe(r.Context(), req.URL, req.EventTypes)
+	if err != nil {
+		return c.webhookError(r, "CreateSubscription", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(subscriptionCreated{WebhookSubscription: sub, Secret: sub.Secret})
+	return nil
+}
+
+// GetSubscriptions lists the active subscriptions
+func (c *WebhookController) GetSubscriptions(w http.ResponseWriter, r *http.Request) error {
+	if err := requireRole(w, r, auth.RoleManageWebhooks); err != nil {
+		return err
+	}
+	subs, err := c.webhooks.Subscriptions(r.Context())
+	if err != nil {
+		return c.webhookError(r, "GetSubscriptions", err)
+	}
+	writeJSON(w, "GetSubscriptions", subs)
+	return nil
+}
+
+// DeleteSubscription stops deliveries to a subscription
+func (c *WebhookController) DeleteSubscription(w http.ResponseWriter, r *http.Request) error {
+	if err := requireRole(w, r, auth.RoleManageWebhooks); err != nil {
+		return err
+	}
+	err := c.webhooks.Unsubscribe(r.Context(), r.PathValue("id"))
+	if err != nil {
+		return c.webhookError(r, "DeleteSubscription", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// GetDeliveries lists a subscription's most recent deliveries; status=dead lists its dead letters
+func (c *WebhookController) GetDeliveries(w http.ResponseWriter, r *http.Request) error {
+	if err := requireRole(w, r, auth.RoleManageWebhooks); err != nil {
+		return err
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+	default:
+		return problem.InvalidParameter("status", "status must be pending, delivered or dead")
+	}
+	limit := int64(100)
+	if param := r.URL.Query().Get("limit"); param != "" {
+		parsed, err := strconv.ParseInt(param, 10, 64)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			return problem.InvalidParameter("limit", "limit must be between 1 and 1000")
+		}
+		limit = parsed
+	}
+
+	deliveries, err := c.webhooks.Deliveries(r.Context(), r.PathValue("id"), status, limit)
+	if err != nil {
+		return c.webhookError(r, "GetDeliveries", err)
+	}
+	writeJSON(w, "GetDeliveries", deliveries)
+	return nil
+}
+
+// Redeliver sends a delivery again with a fresh set of attempts
+func (c *WebhookController) Redeliver(w http.ResponseWriter, r *http.Request) error {
+	if err := requireRole(w, r, auth.RoleManageWebhooks); err != nil {
+		return err
+	}
+	delivery, err := c.webhooks.Redeliver(r.Context(), r.PathValue("deliveryId"))
+	if err != nil {
+		return c.webhookError(r, "Redeliver", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+	return nil
+}
+
+// Replay queues every event in a time window for delivery to a subscription again
+func (c *WebhookController) Replay(w http.ResponseWriter, r *http.Request) error {
+	if err := requireRole(w, r, auth.RoleManageWebhooks); err != nil {
+		return err
+	}
+	var req replayRequest
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+	if req.Since.IsZero() {
+		return problem.Invalid("since is required", problem.Pointer("since", "is required"))
+	}
+	if !req.Until.IsZero() && !req.Until.After(req.Since) {
+		return problem.Invalid("until must be after since", problem.Pointer("until", "must be after since"))
+	}
+
+	queued, err := c.webhooks.Replay(r.Context(), r.PathValue("id"), req.Since, req.Until, req.EventTypes)
+	if err != nil {
+		return c.webhookError(r, "Replay", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(replayResponse{Queued: queued})
+	return nil
+}
+
+// webhookError returns err for Handle to map to a status, logging failures of the server
+func (c *WebhookController) webhookError(r *http.Request, handler string, err error) error {
+	switch {
+	case errors.Is(err, events.ErrSubscriptionNotFound),
+		errors.Is(err, events.ErrDeliveryNotFound),
+		errors.Is(err, events.ErrInvalidSubscription):
+		return err
+	default:
+		logger.ErrorContext(r.Context(), "Error processing webhook request", "handler", handler, "err", err)
+		return internalError("Failed to process webhook request")
+	}
+}
+
+// requireRole rejects the request unless the caller was granted role
+func requireRole(w http.ResponseWriter, r *http.Request, role string) error {
+	principal := auth.FromContext(r.Context())
+	if principal.HasRole(role) {
+		return nil
+	}
+	if principal.Subject == auth.Anonymous.Subject {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		return problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "Authentication required")
+	}
+	return problem.New(http.StatusForbidden, problem.CodeForbidden, "The "+role+" role is required")
+}
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// tokenCollection stores the resume token of each named change stream
+const tokenCollection = "ChangeStreamToken"
+
+// ChangeEvent is a write to a watched collection. It carries no field values, only what
+// changed, so it is safe to hand to any client that may see the collection.
+type ChangeEvent struct {
+	// Tenant owns the changed document; it is "" when tenants are not isolated
+	Tenant     string `json:"-"`
+	Collection string `json:"collection"`
+	// Operation is insert, update, replace or delete
+	Operation string `json:"operation"`
+	// ID is the employeeId or jobId of the document; it is empty for deletes, whose document is gone
+	ID string    `json:"id,omitempty"`
+	At time.Time `json:"at"`
+}
+
+// changeDocument is the projected change stream document
+type changeDocument struct {
+	Operation string `bson:"operationType"`
+	Namespace struct {
+		DB   string `bson:"db"`
+		Coll string `bson:"coll"`
+	} `bson:"ns"`
+	ClusterTime primitive.Timestamp `bson:"clusterTime"`
+	Document    struct {
+		EmployeeID string `bson:"employeeId"`
+		JobID      string `bson:"jobId"`
+	} `bson:"fullDocument"`
+}
+
+// storedToken is the resume token of a change stream as persisted in tokenCollection
+type storedToken struct {
+	Name    string    `bson:"_id"`
+	Token   bson.Raw  `bson:"token"`
+	SavedAt time.Time `bson:"savedAt"`
+}
+
+// Change stream error codes after which the stored resume token cannot be used again
+const (
+	codeInvalidResumeToken = 260
+	codeHistoryLost        = 286
+)
+
+// tokenSaveInterval bounds how often the resume token is written while events are flowing
+const tokenSaveInterval = time.Second
+
+// WatchChanges calls handle for every insert, update, replace and delete in collections until ctx
+// is cancelled. The stream's resume token is saved under name, so after a restart watching picks
+// up where it stopped. When tenants are isolated the collections of every tenant database are
+// watched and each event names its tenant. Change streams require MongoDB to run as a replica set;
+// the stream is reopened with backoff after errors.
+func (d *Database) WatchChanges(ctx context.Context, name string, collections []string, handle func(ChangeEvent)) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := d.watch(ctx, name, collections, handle, func() { backoff = time.Second })
+		if ctx.Err() != nil {
+			return
+		}
+
+		var serverErr mongo.ServerError
+		if errors.As(err, &serverErr) && (serverErr.HasErrorCode(codeInvalidResumeToken) || serverErr.HasErrorCode(codeHistoryLost)) {
+			logger.Warn("Resume token can no longer be used, changes made while stopped are skipped", "stream", name, "err", err)
+			d.deleteToken(name)
+			continue
+		}
+		if err != nil {
+			logger.Error("Change stream failed", "stream", name, "retry_in", backoff.String(), "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+// watch runs one change stream until it fails or ctx is cancelled, calling opened once it is open
+func (d *Database) watch(ctx context.Context, name string, collections []string, handle func(ChangeEvent), opened func()) error {
+	match := bson.M{
+		"ns.coll":       bson.M{"$in": collections},
+		"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete", "invalidate"}},
+	}
+	if d.multiTenant {
+		match["ns.db"] = bson.M{"$regex": "^" + regexp.QuoteMeta(d.name) + "(_|$)"}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		// keep the ids only; the rest of the document never leaves the server
+		{{Key: "$project", Value: bson.M{
+			"operationType":           1,
+			"ns":                      1,
+			"clusterTime":             1,
+			"fullDocument.employeeId": 1,
+			"fullDocument.jobId":      1,
+		}}},
+	}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if token, err := d.loadToken(name); err != nil {
+		return err
+	} else if token != nil {
+		opts.SetStartAfter(token)
+	}
+
+	var stream *mongo.ChangeStream
+	var err error
+	if d.multiTenant {
+		stream, err = d.client.Watch(ctx, pipeline, opts)
+	} else {
+		stream, err = d.db.Watch(ctx, pipeline, opts)
+	}
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+	opened()
+
+	lastSaved := time.Now()
+	defer func() { d.saveToken(name, stream.ResumeToken()) }()
+
+	for stream.Next(ctx) {
+		var change changeDocument
+		if err := stream.Decode(&change); err != nil {
+			return err
+		}
+		if change.Operation == "invalidate" {
+			// the database was dropped or renamed; start again after the invalidation
+			return nil
+		}
+		tenant, ok := d.tenantOf(change.Namespace.DB)
+		if !ok {
+			continue
+		}
+
+		handle(ChangeEvent{
+			Tenant:     tenant,
+			Collection: change.Namespace.Coll,
+			Operation:  change.Operation,
+			ID:         change.Document.EmployeeID + change.Document.JobID,
+			At:         time.Unix(int64(change.ClusterTime.T), 0).UTC(),
+		})
+
+		if time.Since(lastSaved) >= tokenSaveInterval {
+			d.saveToken(name, stream.ResumeToken())
+			lastSaved = time.Now()
+		}
+	}
+	return stream.Err()
+}
+
+// loadToken returns the saved resume token of a change stream, or nil if there is none
+func (d *Database) loadToken(name string) (bson.Raw, error) {
+	var stored storedToken
+	err := d.FindOne(tokenCollection, bson.M{"_id": name}, &stored)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stored.Token, nil
+}
+
+// saveToken persists the resume token of a change stream
+func (d *Database) saveToken(name string, token bson.Raw) {
+	if token == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	coll, err := d.collection(tokenCollection)
+	if err == nil {
+		_, err = coll.ReplaceOne(ctx, bson.M{"_id": name}, storedToken{Name: name, Token: token, SavedAt: time.Now().UTC()}, options.Replace().SetUpsert(true))
+	}
+	if err != nil {
+		logger.Error("Error saving resume token", "stream", name, "err", err)
+	}
+}
+
+// deleteToken forgets the resume token of a change stream, so it starts from the current time
+func (d *Database) deleteToken(name string) {
+	if _, err := d.DeleteOne(tokenCollection, bson.M{"_id": name}); err != nil {
+		logger.Error("Error deleting resume token", "stream", name, "err", err)
+	}
+}
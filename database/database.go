@@ -4,14 +4,31 @@ import (
 	"context"
 	"time"
 
+	"hcmnext/logging"
+
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+var logger = logging.For("database")
+
 type Database struct {
 	client *mongo.Client
 	db     *mongo.Database
+	name   string
+
+	// tenant is the tenant the handle is bound to, see ForTenant
+	tenant      string
+	multiTenant bool
+	err         error
+
+	// ctx carries the trace of the handle's operations, see For
+	ctx context.Context
+
+	// pool counts the client's connections, see PoolStats
+	pool *poolMonitor
 }
 
 // NewDatabase creates a new Database instance
@@ -19,7 +36,11 @@ func NewDatabase(uri, dbName string) (*Database, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	pool := &poolMonitor{}
+	opts := options.Client().ApplyURI(uri).
+		SetMonitor(newCommandMonitor()).
+		SetPoolMonitor(&event.PoolMonitor{Event: pool.event})
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -27,6 +48,8 @@ func NewDatabase(uri, dbName string) (*Database, error) {
 	return &Database{
 		client: client,
 		db:     client.Database(dbName),
+		name:   dbName,
+		pool:   pool,
 	}, nil
 }
 
@@ -37,56 +60,157 @@ func (d *Database) Close() error {
 	return d.client.Disconnect(ctx)
 }
 
+// Drop deletes the database the handle is bound to and every collection in it
+func (d *Database) Drop(ctx context.Context) error {
+	if d.err != nil {
+		return d.err
+	}
+	return d.db.Drop(ctx)
+}
+
+// operationContext returns the context operations start from: the one given to For, without its
+// cancellation, so an operation is not cut short when the request that started it ends
+func (d *Database) operationContext() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return context.WithoutCancel(d.ctx)
+}
+
 // InsertOne inserts a single document into the specified collection
 func (d *Database) InsertOne(collection string, document interface{}) (*mongo.InsertOneResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(d.operationContext(), 5*time.Second)
 	defer cancel()
 
-	coll := d.db.Collection(collection)
+	coll, err := d.collection(collection)
+	if err != nil {
+		return nil, err
+	}
 	return coll.InsertOne(ctx, document)
 }
 
 // FindOne finds a single document in the specified collection
 func (d *Database) FindOne(collection string, filter bson.M, result interface{}) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(d.operationContext(), 5*time.Second)
 	defer cancel()
 
-	coll := d.db.Collection(collection)
+	coll, err := d.collection(collection)
+	if err != nil {
+		return err
+	}
 	return coll.FindOne(ctx, filter).Decode(result)
 }
 
 // FindMany finds multiple documents in the specified collection
 func (d *Database) FindMany(collection string, filter bson.M) (*mongo.Cursor, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(d.operationContext(), 5*time.Second)
 	defer cancel()
 
-	coll := d.db.Collection(collection)
+	coll, err := d.collection(collection)
+	if err != nil {
+		return nil, err
+	}
 	return coll.Find(ctx, filter)
 }
 
+// FindSorted finds up to limit documents in the specified collection in sort order; a limit of 0 means no limit
+func (d *Database) FindSorted(collection string, filter bson.M, sort bson.D, limit int64) (*mongo.Cursor, error) {
+	ctx, cancel := context.WithTimeout(d.operationContext(), 5*time.Second)
+	defer cancel()
+
+	coll, err := d.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	return coll.Find(ctx, filter, options.Find().SetSort(sort).SetLimit(limit))
+}
+
+// FindPage finds the documents matching filter in sort order, skipping the first skip of them and
+// returning at most limit; a limit of 0 means no limit
+func (d *Database) FindPage(collection string, filter bson.M, sort bson.D, skip, limit int64) (*mongo.Cursor, error) {
+	ctx, cancel := context.WithTimeout(d.operationContext(), 5*time.Second)
+	defer cancel()
+
+	coll, err := d.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	return coll.Find(ctx, filter, options.Find().SetSort(sort).SetSkip(skip).SetLimit(limit))
+}
+
+// FindOneAndUpdate updates the first document matching filter, in sort order if given, and decodes it, as updated, into result
+func (d *Database) FindOneAndUpdate(collection string, filter bson.M, sort bson.D, update bson.M, result interface{}) error {
+	ctx, cancel := context.WithTimeout(d.operationContext(), 5*time.Second)
+	defer cancel()
+
+	coll, err := d.collection(collection)
+	if err != nil {
+		return err
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if len(sort) > 0 {
+		opts.SetSort(sort)
+	}
+	return coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(result)
+}
+
+// FindOneAndUpsert updates the document matching filter, inserting it if there is none, and decodes it, as updated, into result
+func (d *Database) FindOneAndUpsert(collection string, filter bson.M, update bson.M, result interface{}) error {
+	ctx, cancel := context.WithTimeout(d.operationContext(), 5*time.Second)
+	defer cancel()
+
+	coll, err := d.collection(collection)
+	if err != nil {
+		return err
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetUpsert(true)
+	return coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(result)
+}
+
 // UpdateOne updates a single document in the specified collection
 func (d *Database) UpdateOne(collection string, filter bson.M, update bson.M) (*mongo.UpdateResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(d.operationContext(), 5*time.Second)
 	defer cancel()
 
-	coll := d.db.Collection(collection)
+	coll, err := d.collection(collection)
+	if err != nil {
+		return nil, err
+	}
 	return coll.UpdateOne(ctx, filter, update)
 }
 
 // DeleteOne deletes a single document from the specified collection
 func (d *Database) DeleteOne(collection string, filter bson.M) (*mongo.DeleteResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(d.operationContext(), 5*time.Second)
 	defer cancel()
 
-	coll := d.db.Collection(collection)
+	coll, err := d.collection(collection)
+	if err != nil {
+		return nil, err
+	}
 	return coll.DeleteOne(ctx, filter)
 }
 
 // CountDocuments counts the number of documents in the specified collection
 func (d *Database) CountDocuments(collection string, filter bson.M) (int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(d.operationContext(), 5*time.Second)
 	defer cancel()
 
-	coll := d.db.Collection(collection)
+	coll, err := d.collection(collection)
+	if err != nil {
+		return 0, err
+	}
 	return coll.CountDocuments(ctx, filter)
-}
\ No newline at end of file
+}
+
+// BulkWrite performs the write operations against the specified collection in one request
+func (d *Database) BulkWrite(collection string, operations []mongo.WriteModel) (*mongo.BulkWriteResult, error) {
+	ctx, cancel := context.WithTimeout(d.operationContext(), 30*time.Second)
+	defer cancel()
+
+	coll, err := d.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	return coll.BulkWrite(ctx, operations, options.BulkWrite().SetOrdered(false))
+}
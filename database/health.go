@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// PoolStats counts the connections of the client's pools, across all servers
+type PoolStats struct {
+	// Open connections, idle or in use
+	Open int64 `json:"open"`
+	// InUse connections, checked out by an operation
+	InUse int64 `json:"inUse"`
+	// Created and Closed connections since the client connected
+	Created int64 `json:"created"`
+	Closed  int64 `json:"closed"`
+	// CheckOutFailures are operations that could not get a connection
+	CheckOutFailures int64 `json:"checkOutFailures"`
+}
+
+// poolMonitor keeps the PoolStats of a client up to date from its pool events
+type poolMonitor struct {
+	open, inUse, created, closed, checkOutFailures atomic.Int64
+}
+
+func (m *poolMonitor) event(e *event.PoolEvent) {
+	switch e.Type {
+	case event.ConnectionCreated:
+		m.open.Add(1)
+		m.created.Add(1)
+	case event.ConnectionClosed:
+		m.open.Add(-1)
+		m.closed.Add(1)
+	case event.GetSucceeded:
+		m.inUse.Add(1)
+	case event.ConnectionReturned:
+		m.inUse.Add(-1)
+	case event.GetFailed:
+		m.checkOutFailures.Add(1)
+	}
+}
+
+func (m *poolMonitor) stats() PoolStats {
+	return PoolStats{
+		Open:             m.open.Load(),
+		InUse:            m.inUse.Load(),
+		Created:          m.created.Load(),
+		Closed:           m.closed.Load(),
+		CheckOutFailures: m.checkOutFailures.Load(),
+	}
+}
+
+// PoolStats returns the connection counts of the client's pools
+func (d *Database) PoolStats() PoolStats {
+	return d.pool.stats()
+}
+
+// Ping checks that the primary is reachable
+func (d *Database) Ping(ctx context.Context) error {
+	return d.client.Ping(ctx, readpref.Primary())
+}
+
+// ServerVersion returns the version of the MongoDB server
+func (d *Database) ServerVersion(ctx context.Context) (string, error) {
+	var info struct {
+		Version string `bson:"version"`
+	}
+	err := d.client.Database("admin").RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&info)
+	return info.Version, err
+}
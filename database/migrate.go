@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Validation actions for Migrate
+const (
+	// ValidationError rejects writes that break a collection's schema
+	ValidationError = "error"
+	// ValidationWarn logs writes that break a collection's schema and lets them through
+	ValidationWarn = "warn"
+)
+
+// collectionSpec is the validator and indexes of a collection
+type collectionSpec struct {
+	name   string
+	schema string
+	// key is the field identifying documents, indexed unique
+	key     string
+	indexes []mongo.IndexModel
+}
+
+// tenantSpecs are the collections of every tenant's database
+var tenantSpecs = []collectionSpec{
+	{name: "Employee", schema: EmployeSchema, key: "employeeId"},
+	{name: "Job", schema: JobSchema, key: "jobId"},
+	{name: "PositionSlot", schema: PositionSlotSchema, key: "slotId", indexes: []mongo.IndexModel{
+		{Keys: bson.D{{Key: "jobId", Value: 1}, {Key: "status", Value: 1}}},
+	}},
+	{name: "Outbox", schema: OutboxSchema, key: "eventId", indexes: []mongo.IndexModel{
+		{Keys: bson.D{{Key: "dispatchedAt", Value: 1}, {Key: "occurredAt", Value: 1}}},
+	}},
+	{name: "WebhookSubscription", schema: WebhookSubscriptionSchema, key: "subscriptionId", indexes: []mongo.IndexModel{
+		{Keys: bson.D{{Key: "active", Value: 1}, {Key: "createdAt", Value: 1}}},
+	}},
+	{name: "WebhookDelivery", schema: WebhookDeliverySchema, key: "deliveryId", indexes: []mongo.IndexModel{
+		{Keys: bson.D{{Key: "subscriptionId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+	}},
+	{name: "ImportJob", schema: ImportJobSchema, key: "importId", indexes: []mongo.IndexModel{
+		// reports are deleted once they expire
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}},
+}
+
+// sharedSpecs are the collections of the base database shared by all tenants
+var sharedSpecs = []collectionSpec{
+	{name: "Tenant", schema: TenantSchema, key: "tenantId", indexes: []mongo.IndexModel{
+		// a host name picks a single tenant
+		{Keys: bson.D{{Key: "hosts", Value: 1}}, Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"hosts": bson.M{"$exists": true}})},
+	}},
+}
+
+// KeyField returns the field identifying the documents of collection, or "" if it has none
+func KeyField(collection string) string {
+	for _, spec := range append(tenantSpecs, sharedSpecs...) {
+		if spec.name == collection {
+			return spec.key
+		}
+	}
+	return ""
+}
+
+// Migrate applies the JSON schema validators and creates the indexes of every collection: the shared
+// collections in the base database and the tenant collections in the database of each tenant, or
+// all in the base database when tenants are not isolated. Documents already stored are not checked;
+// validationAction is ValidationError or ValidationWarn. Migrate can run any number of times.
+func (d *Database) Migrate(ctx context.Context, validationAction string) error {
+	if validationAction != ValidationError && validationAction != ValidationWarn {
+		return fmt.Errorf("unknown validation action %q", validationAction)
+	}
+
+	base := d.client.Database(d.name)
+	if d.multiTenant {
+		if err := migrate(ctx, base, sharedSpecs, validationAction); err != nil {
+			return err
+		}
+		ids, err := d.Tenants(ctx)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := migrate(ctx, d.client.Database(d.databaseName(id)), tenantSpecs, validationAction); err != nil {
+				return fmt.Errorf("tenant %s: %w", id, err)
+			}
+		}
+		return nil
+	}
+	return migrate(ctx, base, append(tenantSpecs, sharedSpecs...), validationAction)
+}
+
+func migrate(ctx context.Context, db *mongo.Database, specs []collectionSpec, validationAction string) error {
+	for _, spec := range specs {
+		if err := applyValidator(ctx, db, spec, validationAction); err != nil {
+			return fmt.Errorf("%s validator: %w", spec.name, err)
+		}
+
+		indexes := append([]mongo.IndexModel{{
+			Keys:    bson.D{{Key: spec.key, Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}}, spec.indexes...)
+		names, err := db.Collection(spec.name).Indexes().CreateMany(ctx, indexes)
+		if err != nil {
+			return fmt.Errorf("%s indexes: %w", spec.name, err)
+		}
+		logger.InfoContext(ctx, "Collection migrated", "database", db.Name(), "collection", spec.name, "indexes", names)
+	}
+	return nil
+}
+
+// applyValidator creates the collection with its validator, or replaces the validator of an existing one
+func applyValidator(ctx context.Context, db *mongo.Database, spec collectionSpec, validationAction string) error {
+	var validator bson.M
+	if err := bson.UnmarshalExtJSON([]byte(spec.schema), false, &validator); err != nil {
+		return err
+	}
+
+	// moderate validation leaves updates of documents that were already invalid alone
+	err := db.CreateCollection(ctx, spec.name, options.CreateCollection().
+		SetValidator(validator).
+		SetValidationLevel("moderate").
+		SetValidationAction(validationAction))
+	var commandErr mongo.CommandError
+	if !errors.As(err, &commandErr) || commandErr.Name != "NamespaceExists" {
+		return err
+	}
+	return db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: spec.name},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: "moderate"},
+		{Key: "validationAction", Value: validationAction},
+	}).Err()
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"sync"
+
+	"hcmnext/metrics"
+	"hcmnext/tracing"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("database")
+
+// commandMonitor reports the latency and failures of every command sent to MongoDB, per collection,
+// including those run inside transactions and by change streams, and traces the commands made
+// within a traced operation
+type commandMonitor struct {
+	// inFlight maps the request ids of commands in flight to their command
+	inFlight sync.Map
+}
+
+// command is a command in flight
+type command struct {
+	collection string
+	span       trace.Span
+}
+
+func newCommandMonitor() *event.CommandMonitor {
+	m := &commandMonitor{}
+	return &event.CommandMonitor{
+		Started:   m.started,
+		Succeeded: m.succeeded,
+		Failed:    m.failed,
+	}
+}
+
+func (m *commandMonitor) started(ctx context.Context, e *event.CommandStartedEvent) {
+	// the command's first element names the collection, e.g. {find: "Employee", ...}, except for
+	// getMore, whose first element is the cursor id; security-sensitive commands arrive empty
+	var cmd command
+	if e.CommandName == "getMore" {
+		cmd.collection, _ = e.Command.Lookup("collection").StringValueOK()
+	} else if first, err := e.Command.IndexErr(0); err == nil {
+		cmd.collection, _ = first.Value().StringValueOK()
+	}
+
+	// commands outside any trace, such as the change stream's, would each start a trace of their own
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		name := e.CommandName
+		if cmd.collection != "" {
+			name += " " + cmd.collection
+		}
+		_, cmd.span = tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemMongoDB,
+				semconv.DBNamespace(e.DatabaseName),
+				semconv.DBCollectionName(cmd.collection),
+				semconv.DBOperationName(e.CommandName),
+			))
+	}
+	m.inFlight.Store(e.RequestID, cmd)
+}
+
+func (m *commandMonitor) succeeded(_ context.Context, e *event.CommandSucceededEvent) {
+	m.finish(e.CommandFinishedEvent, "")
+}
+
+func (m *commandMonitor) failed(_ context.Context, e *event.CommandFailedEvent) {
+	m.finish(e.CommandFinishedEvent, e.Failure)
+}
+
+// finish records a finished command; failure is empty when it succeeded
+func (m *commandMonitor) finish(e event.CommandFinishedEvent, failure string) {
+	value, _ := m.inFlight.LoadAndDelete(e.RequestID)
+	cmd, _ := value.(command)
+	metrics.ObserveDatabase(cmd.collection, e.CommandName, e.Duration, failure != "")
+
+	if cmd.span != nil {
+		if failure != "" {
+			cmd.span.SetStatus(codes.Error, failure)
+		}
+		cmd.span.End()
+	}
+}
@@ -271,27 +271,9 @@ var EmployeSchema = `{
               "description": "must be one of the predefined values and is required"
             },
             "bonuses": {
-              "bsonType": "array",
-              "items": {
-                "bsonType": "object",
-                "required": ["amount", "currency", "date"],
-                "properties": {
-                  "amount": {
-                    "bsonType": "number",
-                    "minimum": 0,
-                    "description": "must be a non-negative number and is required"
-                  },
-                  "currency": {
-                    "bsonType": "string",
-                    "enum": ["USD", "EUR", "GBP", "JPY", "AUD", "CAD"],
-                    "description": "must be one of the predefined values and is required"
-                  },
-                  "date": {
-                    "bsonType": "date",
-                    "description": "must be a valid date and is required"
-                  }
-                }
-              }
+              "bsonType": "int",
+              "minimum": 0,
+              "description": "must be a non-negative integer"
             }
           }
         }
@@ -590,3 +572,301 @@ var JobSchema = `{
     }
   }
 }`
+
+// Position slot schema
+var PositionSlotSchema = `{
+  "$jsonSchema": {
+    "bsonType": "object",
+    "required": ["slotId", "jobId", "positionTitle", "status", "openedDate"],
+    "properties": {
+      "slotId": {
+        "bsonType": "string",
+        "description": "must be a string and is required"
+      },
+      "jobId": {
+        "bsonType": "string",
+        "description": "must be a string and is required"
+      },
+      "positionTitle": {
+        "bsonType": "string",
+        "description": "must be a string and is required"
+      },
+      "status": {
+        "bsonType": "string",
+        "enum": ["Open", "Filled", "Frozen"],
+        "description": "must be one of the predefined values and is required"
+      },
+      "employeeId": {
+        "bsonType": "string",
+        "description": "must be a string if provided, set while the slot is filled"
+      },
+      "employeeName": {
+        "bsonType": "string",
+        "description": "must be a string if provided"
+      },
+      "openedDate": {
+        "bsonType": "date",
+        "description": "must be a valid date and is required"
+      },
+      "filledDate": {
+        "bsonType": "date",
+        "description": "must be a valid date if provided"
+      },
+      "frozenReason": {
+        "bsonType": "string",
+        "description": "must be a string if provided"
+      }
+    }
+  }
+}`
+
+// Outbox event schema
+var OutboxSchema = `{
+  "$jsonSchema": {
+    "bsonType": "object",
+    "required": ["eventId", "type", "subject", "occurredAt", "payload"],
+    "properties": {
+      "eventId": {
+        "bsonType": "string",
+        "description": "must be a string and is required"
+      },
+      "type": {
+        "bsonType": "string",
+        "enum": ["employee.created", "employee.updated", "employee.terminated", "employee.deleted", "compensation.changed", "job.updated"],
+        "description": "must be one of the predefined values and is required"
+      },
+      "subject": {
+        "bsonType": "string",
+        "description": "must be a string and is required, the id of the employee or job"
+      },
+      "actor": {
+        "bsonType": "string",
+        "description": "must be a string if provided"
+      },
+      "occurredAt": {
+        "bsonType": "date",
+        "description": "must be a valid date and is required"
+      },
+      "payload": {
+        "bsonType": "string",
+        "description": "must be a JSON string and is required"
+      },
+      "dispatchedAt": {
+        "bsonType": "date",
+        "description": "must be a valid date if provided, set once deliveries are created"
+      }
+    }
+  }
+}`
+
+// Webhook subscription schema
+var WebhookSubscriptionSchema = `{
+  "$jsonSchema": {
+    "bsonType": "object",
+    "required": ["subscriptionId", "url", "secret", "active", "createdAt"],
+    "properties": {
+      "subscriptionId": {
+        "bsonType": "string",
+        "description": "must be a string and is required"
+      },
+      "url": {
+        "bsonType": "string",
+        "pattern": "^https?://",
+        "description": "must be an http or https URL and is required"
+      },
+      "eventTypes": {
+        "bsonType": "array",
+        "items": {
+          "bsonType": "string"
+        },
+        "description": "must be an array of event types if provided"
+      },
+      "secret": {
+        "bsonType": "string",
+        "description": "must be a string and is required"
+      },
+      "active": {
+        "bsonType": "bool",
+        "description": "must be a boolean and is required"
+      },
+      "createdAt": {
+        "bsonType": "date",
+        "description": "must be a valid date and is required"
+      },
+      "createdBy": {
+        "bsonType": "string",
+        "description": "must be a string if provided"
+      }
+    }
+  }
+}`
+
+// Webhook delivery schema
+var WebhookDeliverySchema = `{
+  "$jsonSchema": {
+    "bsonType": "object",
+    "required": ["deliveryId", "subscriptionId", "eventId", "eventType", "status", "attempts", "nextAttemptAt", "createdAt"],
+    "properties": {
+      "deliveryId": {
+        "bsonType": "string",
+        "description": "must be a string and is required"
+      },
+      "subscriptionId": {
+        "bsonType": "string",
+        "description": "must be a string and is required"
+      },
+      "eventId": {
+        "bsonType": "string",
+        "description": "must be a string and is required"
+      },
+      "eventType": {
+        "bsonType": "string",
+        "description": "must be a string and is required"
+      },
+      "status": {
+        "bsonType": "string",
+        "enum": ["pending", "delivered", "dead"],
+        "description": "must be one of the predefined values and is required"
+      },
+      "attempts": {
+        "bsonType": "int",
+        "minimum": 0,
+        "description": "must be a non-negative integer and is required"
+      },
+      "nextAttemptAt": {
+        "bsonType": "date",
+        "description": "must be a valid date and is required"
+      },
+      "lockedUntil": {
+        "bsonType": "date",
+        "description": "must be a valid date if provided, set while a dispatcher holds the delivery"
+      },
+      "lastAttemptAt": {
+        "bsonType": "date",
+        "description": "must be a valid date if provided"
+      },
+      "lastStatusCode": {
+        "bsonType": "int",
+        "description": "must be an integer if provided"
+      },
+      "lastError": {
+        "bsonType": "string",
+        "description": "must be a string if provided"
+      },
+      "deliveredAt": {
+        "bsonType": "date",
+        "description": "must be a valid date if provided"
+      },
+      "createdAt": {
+        "bsonType": "date",
+        "description": "must be a valid date and is required"
+      }
+    }
+  }
+}`
+
+// Import job schema, the progress of a bulk employee import
+var ImportJobSchema = `{
+  "$jsonSchema": {
+    "bsonType": "object",
+    "required": ["importId", "status", "rows", "startedAt", "expiresAt"],
+    "properties": {
+      "importId": {
+        "bsonType": "string",
+        "description": "must be a string and is required"
+      },
+      "status": {
+        "bsonType": "string",
+        "enum": ["queued", "running", "completed", "failed"],
+        "description": "must be one of the predefined values and is required"
+      },
+      "rows": {
+        "bsonType": ["int", "long"],
+        "minimum": 0,
+        "description": "must be a non-negative integer and is required"
+      },
+      "errors": {
+        "bsonType": "array",
+        "items": {
+          "bsonType": "object",
+          "required": ["row", "problems"]
+        },
+        "description": "must be an array of row errors if provided"
+      },
+      "startedAt": {
+        "bsonType": "date",
+        "description": "must be a valid date and is required"
+      },
+      "finishedAt": {
+        "bsonType": "date",
+        "description": "must be a valid date if provided"
+      },
+      "expiresAt": {
+        "bsonType": "date",
+        "description": "must be a valid date and is required, when the report is deleted"
+      }
+    }
+  }
+}`
+
+// Tenant schema, kept in the base database
+var TenantSchema = `{
+  "$jsonSchema": {
+    "bsonType": "object",
+    "required": ["tenantId", "name", "createdAt"],
+    "properties": {
+      "tenantId": {
+        "bsonType": "string",
+        "pattern": "^[a-z0-9][a-z0-9-]{0,31}$",
+        "description": "must be a lowercase id of up to 32 letters, digits and hyphens and is required"
+      },
+      "name": {
+        "bsonType": "string",
+        "description": "must be a string and is required"
+      },
+      "hosts": {
+        "bsonType": "array",
+        "items": {
+          "bsonType": "string"
+        },
+        "description": "must be an array of host names if provided"
+      },
+      "ai": {
+        "bsonType": "object",
+        "properties": {
+          "model": {
+            "bsonType": "string",
+            "description": "must be a string if provided, overrides the chat model"
+          },
+          "disabled": {
+            "bsonType": "bool",
+            "description": "must be a boolean if provided"
+          }
+        }
+      },
+      "quota": {
+        "bsonType": "object",
+        "properties": {
+          "aiRequestsPerDay": {
+            "bsonType": "int",
+            "minimum": 0,
+            "description": "must be a non-negative integer if provided, 0 is unlimited"
+          },
+          "aiTokensPerDay": {
+            "bsonType": "int",
+            "minimum": 0,
+            "description": "must be a non-negative integer if provided, 0 is unlimited"
+          }
+        }
+      },
+      "disabled": {
+        "bsonType": "bool",
+        "description": "must be a boolean if provided"
+      },
+      "createdAt": {
+        "bsonType": "date",
+        "description": "must be a valid date and is required"
+      }
+    }
+  }
+}`
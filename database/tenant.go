@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"hcmnext/logging"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrNoTenant is returned for tenant data accessed without a tenant in a multi-tenant deployment
+	ErrNoTenant = errors.New("no tenant selected")
+	// ErrTenantMismatch is returned when a handle bound to one tenant is used for a request of another
+	ErrTenantMismatch = errors.New("database handle belongs to another tenant")
+)
+
+// sharedCollections live in the base database and hold no tenant data
+var sharedCollections = map[string]bool{
+	"Tenant":        true,
+	tokenCollection: true,
+}
+
+// tenantIDPattern keeps tenant ids usable as part of a database name
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// ValidTenantID reports whether id can name a tenant
+func ValidTenantID(id string) bool {
+	return tenantIDPattern.MatchString(id)
+}
+
+type tenantKey struct{}
+
+// WithTenant attaches the tenant id to ctx; every query made through For(ctx) is scoped to it
+// and every record logged with ctx carries it
+func WithTenant(ctx context.Context, id string) context.Context {
+	if id != "" {
+		ctx = logging.With(ctx, slog.String(logging.KeyTenant, id))
+	}
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// TenantFromContext returns the tenant id on ctx, or "" if there is none
+func TenantFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(tenantKey{}).(string)
+	return id
+}
+
+// SetMultiTenant makes tenant data reachable only through handles bound to a tenant. Each tenant
+// has its own database, named after the base database and the tenant id, so one tenant's queries
+// cannot match another's documents. Without it every request uses the base database.
+func (d *Database) SetMultiTenant(enabled bool) {
+	d.multiTenant = enabled
+}
+
+// MultiTenant reports whether the deployment isolates tenants
+func (d *Database) MultiTenant() bool {
+	return d.multiTenant
+}
+
+// Tenant returns the id of the tenant the handle is bound to, or "" for the base database
+func (d *Database) Tenant() string {
+	return d.tenant
+}
+
+// ForTenant returns a handle bound to the database of tenant id; "" is the base database
+func (d *Database) ForTenant(id string) *Database {
+	scoped := *d
+	scoped.tenant = id
+	scoped.db = d.client.Database(d.databaseName(id))
+	return &scoped
+}
+
+// For returns a handle bound to the tenant of ctx. A handle already bound to a tenant keeps
+// its tenant, unless ctx names another tenant, in which case every query fails. Operations made
+// through the handle are traced as part of ctx but are not cancelled with it.
+func (d *Database) For(ctx context.Context) *Database {
+	id := TenantFromContext(ctx)
+	scoped := *d
+	switch {
+	case d.tenant != "" && id != "" && id != d.tenant:
+		scoped.err = fmt.Errorf("%w: bound to %q, used for %q", ErrTenantMismatch, d.tenant, id)
+	case d.tenant == "" && id != "":
+		scoped = *d.ForTenant(id)
+	}
+	scoped.ctx = ctx
+	return &scoped
+}
+
+// Tenants lists the tenants that have a database, or just the base database when tenants are not isolated
+func (d *Database) Tenants(ctx context.Context) ([]string, error) {
+	if !d.multiTenant {
+		return []string{""}, nil
+	}
+	prefix := d.name + "_"
+	names, err := d.client.ListDatabaseNames(ctx, bson.M{"name": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}})
+	if err != nil {
+		return nil, err
+	}
+	tenants := make([]string, 0, len(names))
+	for _, name := range names {
+		if id := strings.TrimPrefix(name, prefix); ValidTenantID(id) {
+			tenants = append(tenants, id)
+		}
+	}
+	return tenants, nil
+}
+
+// databaseName is the name of the database holding the data of tenant id
+func (d *Database) databaseName(id string) string {
+	if id == "" {
+		return d.name
+	}
+	return d.name + "_" + id
+}
+
+// tenantOf returns the tenant owning the database called name, and whether it belongs to this deployment
+func (d *Database) tenantOf(name string) (string, bool) {
+	if name == d.name {
+		return "", true
+	}
+	id, ok := strings.CutPrefix(name, d.name+"_")
+	return id, ok && ValidTenantID(id)
+}
+
+// collection returns the named collection, refusing tenant data on an unbound handle when tenants are isolated
+func (d *Database) collection(name string) (*mongo.Collection, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if d.multiTenant && d.tenant == "" && !sharedCollections[name] {
+		return nil, fmt.Errorf("%w: %s holds tenant data", ErrNoTenant, name)
+	}
+	return d.db.Collection(name), nil
+}
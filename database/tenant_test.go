@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"hcmnext/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// newOfflineDatabase returns a multi-tenant handle whose client never connects, for checks
+// that must fail before any command is sent
+func newOfflineDatabase(t *testing.T) *Database {
+	t.Helper()
+
+	d, err := NewDatabase("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100", "hcm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	d.SetMultiTenant(true)
+	return d
+}
+
+func TestForWithoutTenant(t *testing.T) {
+	d := newOfflineDatabase(t)
+	scoped := d.For(context.Background())
+
+	var emp models.Employee
+	if err := scoped.FindOne("Employee", bson.M{}, &emp); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("FindOne: err = %v, want ErrNoTenant", err)
+	}
+	if _, err := scoped.FindMany("Employee", bson.M{}); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("FindMany: err = %v, want ErrNoTenant", err)
+	}
+	if _, err := scoped.InsertOne("Employee", &emp); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("InsertOne: err = %v, want ErrNoTenant", err)
+	}
+	if _, err := scoped.CountDocuments("Employee", bson.M{}); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("CountDocuments: err = %v, want ErrNoTenant", err)
+	}
+}
+
+func TestForTenant(t *testing.T) {
+	d := newOfflineDatabase(t)
+
+	acme := d.For(WithTenant(context.Background(), "acme"))
+	if acme.Tenant() != "acme" || acme.db.Name() != "hcm_acme" {
+		t.Errorf("For(acme) is bound to %q in %s, want acme in hcm_acme", acme.Tenant(), acme.db.Name())
+	}
+
+	// a handle bound to one tenant never serves another
+	other := acme.For(WithTenant(context.Background(), "globex"))
+	var emp models.Employee
+	if err := other.FindOne("Employee", bson.M{}, &emp); !errors.Is(err, ErrTenantMismatch) {
+		t.Errorf("err = %v, want ErrTenantMismatch", err)
+	}
+
+	// and keeps its tenant for contexts without one
+	if same := acme.For(context.Background()); same.Tenant() != "acme" || same.db.Name() != "hcm_acme" {
+		t.Errorf("For(no tenant) on acme is bound to %q in %s", same.Tenant(), same.db.Name())
+	}
+}
+
+// TestCrossTenantEmployeeRead writes an employee for one tenant and reads it back for another
+// through the same handle. It needs a MongoDB server, named by MONGO_URI.
+func TestCrossTenantEmployeeRead(t *testing.T) {
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		t.Skip("MONGO_URI is not set")
+	}
+
+	name := fmt.Sprintf("hcmtest%d", time.Now().UnixNano())
+	d, err := NewDatabase(uri, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetMultiTenant(true)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for _, id := range []string{"acme", "globex"} {
+			d.client.Database(d.databaseName(id)).Drop(ctx)
+		}
+		d.Close()
+	})
+
+	acmeCtx := WithTenant(context.Background(), "acme")
+	globexCtx := WithTenant(context.Background(), "globex")
+
+	emp := models.Employee{EmployeeID: "E1001", FirstName: "Ada", LastName: "Lovelace"}
+	if _, err := d.For(acmeCtx).InsertOne("Employee", &emp); err != nil {
+		t.Fatal(err)
+	}
+
+	var found models.Employee
+	if err := d.For(acmeCtx).FindOne("Employee", bson.M{"employeeId": "E1001"}, &found); err != nil {
+		t.Fatalf("acme cannot read its own employee: %v", err)
+	}
+
+	globex := d.For(globexCtx)
+	if err := globex.FindOne("Employee", bson.M{"employeeId": "E1001"}, &found); err != mongo.ErrNoDocuments {
+		t.Errorf("FindOne for globex: err = %v, want ErrNoDocuments", err)
+	}
+
+	cursor, err := globex.FindMany("Employee", bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []models.Employee
+	if err := cursor.All(context.Background(), &all); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 0 {
+		t.Errorf("FindMany for globex returned %d employees, want none", len(all))
+	}
+
+	count, err := globex.CountDocuments("Employee", bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("CountDocuments for globex = %d, want 0", count)
+	}
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Tx gives access to collections inside a multi-document transaction
+type Tx struct {
+	ctx   mongo.SessionContext
+	scope *Database
+}
+
+// WithTransaction runs fn in a multi-document transaction, committing if fn returns nil
+// and aborting otherwise. The transaction is scoped to the tenant of ctx, as with For.
+// Transactions require MongoDB to run as a replica set.
+func (d *Database) WithTransaction(ctx context.Context, fn func(tx *Tx) error) error {
+	scope := d.For(ctx)
+	if scope.err != nil {
+		return scope.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	session, err := d.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(&Tx{ctx: sc, scope: scope})
+	})
+	return err
+}
+
+// Context returns the context the transaction runs in
+func (t *Tx) Context() context.Context {
+	return t.ctx
+}
+
+// InsertOne inserts a single document into the specified collection
+func (t *Tx) InsertOne(collection string, document interface{}) (*mongo.InsertOneResult, error) {
+	coll, err := t.scope.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	return coll.InsertOne(t.ctx, document)
+}
+
+// FindOne finds a single document in the specified collection
+func (t *Tx) FindOne(collection string, filter bson.M, result interface{}) error {
+	coll, err := t.scope.collection(collection)
+	if err != nil {
+		return err
+	}
+	return coll.FindOne(t.ctx, filter).Decode(result)
+}
+
+// UpdateOne updates a single document in the specified collection
+func (t *Tx) UpdateOne(collection string, filter bson.M, update bson.M) (*mongo.UpdateResult, error) {
+	coll, err := t.scope.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	return coll.UpdateOne(t.ctx, filter, update)
+}
+
+// DeleteOne deletes a single document from the specified collection
+func (t *Tx) DeleteOne(collection string, filter bson.M) (*mongo.DeleteResult, error) {
+	coll, err := t.scope.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	return coll.DeleteOne(t.ctx, filter)
+}
+
+// CountDocuments counts the number of documents in the specified collection
+func (t *Tx) CountDocuments(collection string, filter bson.M) (int64, error) {
+	coll, err := t.scope.collection(collection)
+	if err != nil {
+		return 0, err
+	}
+	return coll.CountDocuments(t.ctx, filter)
+}
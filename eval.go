@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"hcmnext/ai"
+	"hcmnext/config"
+	"hcmnext/eval"
+)
+
+// evaluate runs a dataset of HR questions through the planner with two model or prompt
+// configurations and reports how they compare. The baseline uses the configured model.
+//
+//	hcmnext eval -dataset eval/datasets/hr_questions.yaml \
+//		-candidate-prompts generateExecutionPlan=v2 -out report.md
+func evaluate(args []string) {
+	var datasetPath, candidateModel, baselinePrompts, candidatePrompts, out, format string
+	cfg := loadConfig(config.Command{Name: "eval", AI: true, Flags: func(flags *flag.FlagSet) {
+		flags.StringVar(&datasetPath, "dataset", "eval/datasets/hr_questions.yaml", "YAML dataset of questions")
+		flags.StringVar(&candidateModel, "candidate-model", "", "model for the candidate run (defaults to the baseline model)")
+		flags.StringVar(&baselinePrompts, "baseline-prompts", "", "pinned prompt versions for the baseline, e.g. generateExecutionPlan=v1")
+		flags.StringVar(&candidatePrompts, "candidate-prompts", "", "pinned prompt versions for the candidate")
+		flags.StringVar(&out, "out", "", "report file (defaults to stdout)")
+		flags.StringVar(&format, "format", "markdown", "report format: markdown or json")
+	}}, args)
+
+	if format != "markdown" && format != "json" {
+		fatal("Invalid -format", fmt.Errorf("unknown format %q", format))
+	}
+	if candidateModel == "" {
+		candidateModel = cfg.AI.Model
+	}
+
+	dataset, err := eval.LoadDataset(datasetPath)
+	if err != nil {
+		fatal("Failed to load dataset", err)
+	}
+
+	baseline, err := newVariant(cfg, "baseline", cfg.AI.Model, baselinePrompts)
+	if err != nil {
+		fatal("Failed to configure baseline", err)
+	}
+	candidate, err := newVariant(cfg, "candidate", candidateModel, candidatePrompts)
+	if err != nil {
+		fatal("Failed to configure candidate", err)
+	}
+
+	// progress goes to stderr, so it stays out of a report written to stdout
+	ctx := context.Background()
+	comparison := eval.Compare(dataset,
+		eval.Summarize(dataset, baseline, eval.Run(ctx, dataset, baseline, os.Stderr)),
+		eval.Summarize(dataset, candidate, eval.Run(ctx, dataset, candidate, os.Stderr)),
+	)
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			fatal("Failed to create report", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if format == "json" {
+		err = comparison.WriteJSON(w)
+	} else {
+		err = comparison.WriteMarkdown(w)
+	}
+	if err != nil {
+		fatal("Failed to write report", err)
+	}
+}
+
+func newVariant(cfg *config.Config, name, model, prompts string) (eval.Variant, error) {
+	client, err := ai.NewClientFromConfig(ai.Config{
+		APIKey:    cfg.AI.APIKey,
+		BaseURL:   cfg.AI.BaseURL,
+		Model:     model,
+		PromptDir: cfg.AI.PromptDir,
+	})
+	if err != nil {
+		return eval.Variant{}, err
+	}
+
+	versions, err := parsePromptVersions(prompts)
+	if err != nil {
+		return eval.Variant{}, err
+	}
+	return eval.Variant{Name: name, Client: client, PromptVersions: versions}, nil
+}
+
+// parsePromptVersions parses "name=version,name=version"
+func parsePromptVersions(spec string) (map[string]string, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	versions := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		name, version, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" || version == "" {
+			return nil, fmt.Errorf("invalid prompt version %q, expected name=version", pair)
+		}
+		versions[name] = version
+	}
+	return versions, nil
+}
@@ -0,0 +1,69 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Dataset is a set of HR questions with the behaviour expected from the planner
+type Dataset struct {
+	Name  string `yaml:"name"`
+	Cases []Case `yaml:"cases"`
+}
+
+// Case is a single question. Every expectation is optional; only the ones set are scored.
+type Case struct {
+	ID string `yaml:"id"`
+	// Question is the user's message; History holds earlier turns of the conversation, if any
+	Question string    `yaml:"question"`
+	History  []Message `yaml:"history,omitempty"`
+
+	// UseTool is the expected ShouldUseTool decision
+	UseTool *bool `yaml:"useTool,omitempty"`
+	// ExpectedTools must all appear in the execution plan
+	ExpectedTools []string `yaml:"expectedTools,omitempty"`
+	// ForbiddenTools must not appear in the execution plan
+	ForbiddenTools []string `yaml:"forbiddenTools,omitempty"`
+	// MaxPlanLength is the longest acceptable plan
+	MaxPlanLength int `yaml:"maxPlanLength,omitempty"`
+}
+
+// Message is a prior turn of a conversation
+type Message struct {
+	Role    string `yaml:"role"`
+	Content string `yaml:"content"`
+}
+
+// LoadDataset reads a YAML dataset from path
+func LoadDataset(path string) (*Dataset, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var dataset Dataset
+	if err := yaml.Unmarshal(raw, &dataset); err != nil {
+		return nil, fmt.Errorf("error parsing dataset %s: %v", path, err)
+	}
+
+	seen := make(map[string]bool, len(dataset.Cases))
+	for i, c := range dataset.Cases {
+		if c.ID == "" {
+			return nil, fmt.Errorf("case %d in %s has no id", i+1, path)
+		}
+		if seen[c.ID] {
+			return nil, fmt.Errorf("case id %s appears more than once in %s", c.ID, path)
+		}
+		if c.Question == "" {
+			return nil, fmt.Errorf("case %s in %s has no question", c.ID, path)
+		}
+		seen[c.ID] = true
+	}
+	if dataset.Name == "" {
+		dataset.Name = path
+	}
+
+	return &dataset, nil
+}
@@ -0,0 +1,267 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary aggregates the results of one variant over a dataset
+type Summary struct {
+	Variant        string            `json:"variant"`
+	Model          string            `json:"model"`
+	PromptVersions map[string]string `json:"promptVersions,omitempty"`
+	Cases          int               `json:"cases"`
+
+	// ToolSelectionAccuracy is the share of cases with a useTool expectation that were decided correctly
+	ToolSelectionAccuracy float64 `json:"toolSelectionAccuracy"`
+	// ExpectedToolRecall is the share of expected tools that appeared in plans
+	ExpectedToolRecall float64 `json:"expectedToolRecall"`
+	// ForbiddenToolRate is the share of plans using a forbidden tool
+	ForbiddenToolRate float64 `json:"forbiddenToolRate"`
+	// DuplicateStepRate is the share of plans with the same tool twice in a row
+	DuplicateStepRate float64 `json:"duplicateStepRate"`
+	// OverlongPlanRate is the share of plans longer than the case allows
+	OverlongPlanRate float64 `json:"overlongPlanRate"`
+	// MeanPlanLength is the average number of steps over plans that were produced
+	MeanPlanLength float64 `json:"meanPlanLength"`
+	// JSONValidity is the share of model answers received that parsed as JSON
+	JSONValidity float64 `json:"jsonValidity"`
+	// ErrorRate is the share of cases where either call failed
+	ErrorRate float64 `json:"errorRate"`
+
+	MeanDuration time.Duration `json:"meanDuration"`
+	Results      []CaseResult  `json:"results"`
+}
+
+// Summarize scores results against the dataset they were produced from
+func Summarize(dataset *Dataset, variant Variant, results []CaseResult) Summary {
+	summary := Summary{
+		Variant:        variant.Name,
+		Model:          variant.Client.Model(),
+		PromptVersions: variant.PromptVersions,
+		Cases:          len(results),
+		Results:        results,
+	}
+	if len(results) == 0 {
+		return summary
+	}
+
+	cases := make(map[string]Case, len(dataset.Cases))
+	for _, c := range dataset.Cases {
+		cases[c.ID] = c
+	}
+
+	var decided, correct, expected, seen, plans, steps, forbidden, duplicates, overlong, answers, validJSON, failed int
+	var elapsed time.Duration
+	for _, r := range results {
+		c := cases[r.ID]
+		elapsed += r.Duration
+
+		if r.UseToolCorrect != nil {
+			decided++
+			if *r.UseToolCorrect {
+				correct++
+			}
+		} else if c.UseTool != nil {
+			// a failed call counts against accuracy
+			decided++
+		}
+
+		// transport failures produce no answer to judge
+		if r.UseToolError == "" || !r.UseToolJSON {
+			answers++
+			if r.UseToolJSON {
+				validJSON++
+			}
+		}
+		if r.PlanError == "" || !r.PlanJSON {
+			answers++
+			if r.PlanJSON {
+				validJSON++
+			}
+		}
+		if r.UseToolError != "" || r.PlanError != "" {
+			failed++
+		}
+
+		expected += len(c.ExpectedTools)
+		seen += r.ExpectedToolsSeen
+		if r.PlanError != "" {
+			continue
+		}
+		plans++
+		steps += len(r.Plan)
+		if len(r.ForbiddenTools) > 0 {
+			forbidden++
+		}
+		if len(r.DuplicateSteps) > 0 {
+			duplicates++
+		}
+		if r.PlanTooLong {
+			overlong++
+		}
+	}
+
+	summary.ToolSelectionAccuracy = ratio(correct, decided)
+	summary.ExpectedToolRecall = ratio(seen, expected)
+	summary.ForbiddenToolRate = ratio(forbidden, plans)
+	summary.DuplicateStepRate = ratio(duplicates, plans)
+	summary.OverlongPlanRate = ratio(overlong, plans)
+	summary.MeanPlanLength = ratio(steps, plans)
+	summary.JSONValidity = ratio(validJSON, answers)
+	summary.ErrorRate = ratio(failed, len(results))
+	summary.MeanDuration = elapsed / time.Duration(len(results))
+	return summary
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// Comparison is a candidate variant measured against a baseline on the same dataset
+type Comparison struct {
+	Dataset   string             `json:"dataset"`
+	Baseline  Summary            `json:"baseline"`
+	Candidate Summary            `json:"candidate"`
+	Deltas    map[string]float64 `json:"deltas"`
+	// Changed lists the cases whose plan or tool decision differs between the variants
+	Changed []string `json:"changed"`
+}
+
+// metric is a named accessor over Summary, in report order
+type metric struct {
+	name   string
+	value  func(Summary) float64
+	higher bool
+	format string
+}
+
+var metrics = []metric{
+	{"toolSelectionAccuracy", func(s Summary) float64 { return s.ToolSelectionAccuracy }, true, "%.1f%%"},
+	{"expectedToolRecall", func(s Summary) float64 { return s.ExpectedToolRecall }, true, "%.1f%%"},
+	{"jsonValidity", func(s Summary) float64 { return s.JSONValidity }, true, "%.1f%%"},
+	{"forbiddenToolRate", func(s Summary) float64 { return s.ForbiddenToolRate }, false, "%.1f%%"},
+	{"duplicateStepRate", func(s Summary) float64 { return s.DuplicateStepRate }, false, "%.1f%%"},
+	{"overlongPlanRate", func(s Summary) float64 { return s.OverlongPlanRate }, false, "%.1f%%"},
+	{"errorRate", func(s Summary) float64 { return s.ErrorRate }, false, "%.1f%%"},
+	{"meanPlanLength", func(s Summary) float64 { return s.MeanPlanLength }, false, "%.2f"},
+}
+
+// Compare builds the comparison of candidate against baseline
+func Compare(dataset *Dataset, baseline, candidate Summary) Comparison {
+	comparison := Comparison{
+		Dataset:   dataset.Name,
+		Baseline:  baseline,
+		Candidate: candidate,
+		Deltas:    make(map[string]float64, len(metrics)),
+	}
+	for _, m := range metrics {
+		comparison.Deltas[m.name] = m.value(candidate) - m.value(baseline)
+	}
+
+	byID := make(map[string]CaseResult, len(baseline.Results))
+	for _, r := range baseline.Results {
+		byID[r.ID] = r
+	}
+	for _, r := range candidate.Results {
+		before, ok := byID[r.ID]
+		if !ok || planKey(before) != planKey(r) {
+			comparison.Changed = append(comparison.Changed, r.ID)
+		}
+	}
+	return comparison
+}
+
+func planKey(r CaseResult) string {
+	decision := "error"
+	if r.UseTool != nil {
+		decision = fmt.Sprint(*r.UseTool)
+	}
+	return decision + "|" + strings.ToLower(strings.Join(r.Plan, ","))
+}
+
+// WriteJSON writes the comparison as indented JSON
+func (c Comparison) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c)
+}
+
+// WriteMarkdown writes the comparison as a markdown report
+func (c Comparison) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Evaluation: %s\n\n", c.Dataset)
+	fmt.Fprintf(&b, "| | Baseline (%s) | Candidate (%s) |\n|---|---|---|\n", c.Baseline.Variant, c.Candidate.Variant)
+	fmt.Fprintf(&b, "| Model | %s | %s |\n", c.Baseline.Model, c.Candidate.Model)
+	fmt.Fprintf(&b, "| Prompts | %s | %s |\n", formatVersions(c.Baseline.PromptVersions), formatVersions(c.Candidate.PromptVersions))
+	fmt.Fprintf(&b, "| Cases | %d | %d |\n\n", c.Baseline.Cases, c.Candidate.Cases)
+
+	b.WriteString("| Metric | Baseline | Candidate | Delta |\n|---|---|---|---|\n")
+	for _, m := range metrics {
+		base, cand := m.value(c.Baseline), m.value(c.Candidate)
+		scale := 1.0
+		if strings.HasSuffix(m.format, "%%") {
+			scale = 100
+		}
+		delta := fmt.Sprintf("%+.2f", (cand-base)*scale)
+		if cand != base {
+			if (cand > base) == m.higher {
+				delta += " better"
+			} else {
+				delta += " worse"
+			}
+		}
+		fmt.Fprintf(&b, "| %s | "+m.format+" | "+m.format+" | %s |\n", m.name, base*scale, cand*scale, delta)
+	}
+	fmt.Fprintf(&b, "| meanDuration | %s | %s | |\n", c.Baseline.MeanDuration.Round(time.Millisecond), c.Candidate.MeanDuration.Round(time.Millisecond))
+
+	if len(c.Changed) > 0 {
+		baseline := make(map[string]CaseResult, len(c.Baseline.Results))
+		for _, r := range c.Baseline.Results {
+			baseline[r.ID] = r
+		}
+
+		b.WriteString("\n## Changed cases\n\n| Case | Baseline plan | Candidate plan |\n|---|---|---|\n")
+		for _, r := range c.Candidate.Results {
+			before, ok := baseline[r.ID]
+			if !ok || planKey(before) == planKey(r) {
+				continue
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", r.ID, formatPlan(before), formatPlan(r))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatVersions(versions map[string]string) string {
+	if len(versions) == 0 {
+		return "assigned"
+	}
+	pairs := make([]string, 0, len(versions))
+	for name, version := range versions {
+		pairs = append(pairs, name+"="+version)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+func formatPlan(r CaseResult) string {
+	if r.PlanError != "" {
+		return "error: " + strings.ReplaceAll(r.PlanError, "|", "\\|")
+	}
+	useTool := "?"
+	if r.UseTool != nil {
+		useTool = fmt.Sprint(*r.UseTool)
+	}
+	return fmt.Sprintf("useTool=%s: %s", useTool, strings.Join(r.Plan, " → "))
+}
@@ -0,0 +1,143 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"hcmnext/ai"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Variant is one configuration under evaluation: a client (and so a model) plus pinned prompt versions
+type Variant struct {
+	Name           string
+	Client         *ai.Client
+	PromptVersions map[string]string
+}
+
+// CaseResult is the outcome of one case under one variant
+type CaseResult struct {
+	ID string `json:"id"`
+
+	UseTool        *bool  `json:"useTool,omitempty"`
+	UseToolCorrect *bool  `json:"useToolCorrect,omitempty"`
+	UseToolError   string `json:"useToolError,omitempty"`
+	UseToolJSON    bool   `json:"useToolValidJson"`
+
+	Plan              []string `json:"plan,omitempty"`
+	PlanError         string   `json:"planError,omitempty"`
+	PlanJSON          bool     `json:"planValidJson"`
+	MissingTools      []string `json:"missingTools,omitempty"`
+	ForbiddenTools    []string `json:"forbiddenTools,omitempty"`
+	DuplicateSteps    []string `json:"duplicateSteps,omitempty"`
+	PlanTooLong       bool     `json:"planTooLong,omitempty"`
+	ExpectedToolsSeen int      `json:"expectedToolsSeen"`
+
+	Duration time.Duration `json:"duration"`
+}
+
+// Run evaluates every case of dataset against variant, writing a line to progress, if not nil,
+// as each case starts
+func Run(ctx context.Context, dataset *Dataset, variant Variant, progress io.Writer) []CaseResult {
+	if len(variant.PromptVersions) > 0 {
+		ctx = ai.WithPromptVersions(ctx, variant.PromptVersions)
+	}
+
+	results := make([]CaseResult, 0, len(dataset.Cases))
+	for _, c := range dataset.Cases {
+		if progress != nil {
+			fmt.Fprintf(progress, "[%s] %s\n", variant.Name, c.ID)
+		}
+		results = append(results, runCase(ctx, variant.Client, c))
+	}
+	return results
+}
+
+func runCase(ctx context.Context, client *ai.Client, c Case) CaseResult {
+	start := time.Now()
+	result := CaseResult{ID: c.ID}
+
+	conversation := make([]openai.ChatCompletionMessage, 0, len(c.History)+1)
+	for _, m := range c.History {
+		conversation = append(conversation, openai.ChatCompletionMessage{Role: m.Role, Content: m.Content})
+	}
+	conversation = append(conversation, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: c.Question})
+
+	// tools receive the request context through cachedContext
+	ctx = ai.WithConversationID(ctx, c.ID)
+	values := ai.RequestValues(ctx)
+
+	toolResponse, err := client.ShouldUseTool(values, conversation)
+	result.UseToolJSON = !isJSONError(err)
+	if err != nil {
+		result.UseToolError = err.Error()
+	} else {
+		result.UseTool = &toolResponse.UseTool
+		if c.UseTool != nil {
+			correct := toolResponse.UseTool == *c.UseTool
+			result.UseToolCorrect = &correct
+		}
+	}
+
+	plan, err := client.GenerateExecutionPlan(values, conversation)
+	result.PlanJSON = !isJSONError(err)
+	if err != nil {
+		result.PlanError = err.Error()
+	} else {
+		result.Plan = plan.Tools
+		scorePlan(&result, c)
+	}
+
+	result.Duration = time.Since(start)
+	return result
+}
+
+// scorePlan checks the plan against the case's expectations and the planner's own rules
+func scorePlan(result *CaseResult, c Case) {
+	used := make(map[string]bool, len(result.Plan))
+	for _, tool := range result.Plan {
+		used[normalizeTool(tool)] = true
+	}
+
+	for _, tool := range c.ExpectedTools {
+		if used[normalizeTool(tool)] {
+			result.ExpectedToolsSeen++
+		} else {
+			result.MissingTools = append(result.MissingTools, tool)
+		}
+	}
+	for _, tool := range c.ForbiddenTools {
+		if used[normalizeTool(tool)] {
+			result.ForbiddenTools = append(result.ForbiddenTools, tool)
+		}
+	}
+
+	// the planner prompt forbids placing the same tool back to back
+	for i := 1; i < len(result.Plan); i++ {
+		if normalizeTool(result.Plan[i]) == normalizeTool(result.Plan[i-1]) {
+			result.DuplicateSteps = append(result.DuplicateSteps, result.Plan[i])
+		}
+	}
+
+	if c.MaxPlanLength > 0 && len(result.Plan) > c.MaxPlanLength {
+		result.PlanTooLong = true
+	}
+}
+
+// normalizeTool compares tool names the way HandleRequest dispatches them: ignoring the case of the first letter
+func normalizeTool(tool string) string {
+	return strings.ToLower(strings.TrimSpace(tool))
+}
+
+// isJSONError reports whether err came from decoding the model's JSON answer
+func isJSONError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return errors.As(err, &syntaxErr) || errors.As(err, &typeErr)
+}
@@ -0,0 +1,342 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"hcmnext/database"
+	"hcmnext/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Signature headers sent with every delivery. The signature is the hex HMAC-SHA256, keyed
+// with the subscription secret, of the timestamp header, a dot and the request body.
+const (
+	HeaderEvent     = "X-HCM-Event"
+	HeaderEventID   = "X-HCM-Event-Id"
+	HeaderDelivery  = "X-HCM-Delivery"
+	HeaderTimestamp = "X-HCM-Timestamp"
+	HeaderSignature = "X-HCM-Signature"
+)
+
+// DispatcherOptions tunes delivery
+type DispatcherOptions struct {
+	// PollInterval is how often the outbox and the delivery queue are checked
+	PollInterval time.Duration
+	// MaxAttempts is the number of attempts before a delivery is dead-lettered
+	MaxAttempts int
+	// BaseBackoff is the wait after the first failed attempt; it doubles with each attempt up to MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Timeout bounds a single delivery request
+	Timeout time.Duration
+	// Workers is the number of deliveries sent at once
+	Workers int
+}
+
+// DefaultDispatcherOptions retries for about a day before dead-lettering
+var DefaultDispatcherOptions = DispatcherOptions{
+	PollInterval: 2 * time.Second,
+	MaxAttempts:  12,
+	BaseBackoff:  30 * time.Second,
+	MaxBackoff:   4 * time.Hour,
+	Timeout:      10 * time.Second,
+	Workers:      4,
+}
+
+// batchSize is the number of outbox events and deliveries handled per poll
+const batchSize = 100
+
+// Dispatcher fans outbox events out to subscriptions and delivers them. Several instances
+// may run at once: events are claimed in a transaction and deliveries with a lease.
+type Dispatcher struct {
+	db     *database.Database
+	opts   DispatcherOptions
+	client *http.Client
+}
+
+// NewDispatcher creates a dispatcher with opts
+func NewDispatcher(db *database.Database, opts DispatcherOptions) *Dispatcher {
+	return &Dispatcher{
+		db:     db,
+		opts:   opts,
+		client: newDeliveryClient(opts.Timeout),
+	}
+}
+
+// Run dispatches the events of every tenant until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		tenants, err := d.db.Tenants(ctx)
+		if err != nil {
+			logger.ErrorContext(ctx, "Error listing tenants", "err", err)
+		}
+		for _, id := range tenants {
+			if ctx.Err() != nil {
+				break
+			}
+			tenantCtx := database.WithTenant(ctx, id)
+			if err := d.fanOut(tenantCtx); err != nil {
+				logger.ErrorContext(tenantCtx, "Error fanning out events", "err", err)
+			}
+			if err := d.deliverDue(tenantCtx); err != nil {
+				logger.ErrorContext(tenantCtx, "Error delivering events", "err", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// fanOut creates a delivery for every subscription that wants each undispatched event
+func (d *Dispatcher) fanOut(ctx context.Context) error {
+	cursor, err := d.db.For(ctx).FindSorted(outboxCollection, bson.M{"dispatchedAt": bson.M{"$exists": false}}, bson.D{{Key: "occurredAt", Value: 1}}, batchSize)
+	if err != nil {
+		return err
+	}
+	var pending []models.OutboxEvent
+	err = cursor.All(ctx, &pending)
+	cursor.Close(ctx)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	subs, err := NewWebhooks(d.db).Subscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range pending {
+		err := d.db.WithTransaction(ctx, func(tx *database.Tx) error {
+			// another dispatcher may have taken the event since it was listed
+			var current models.OutboxEvent
+			err := tx.FindOne(outboxCollection, bson.M{"eventId": event.EventID, "dispatchedAt": bson.M{"$exists": false}}, &current)
+			if err == mongo.ErrNoDocuments {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			now := time.Now().UTC()
+			for i := range subs {
+				if !subs[i].Wants(event.Type) {
+					continue
+				}
+				if _, err := tx.InsertOne(deliveryCollection, newDelivery(&subs[i], event, now)); err != nil {
+					return err
+				}
+			}
+			_, err = tx.UpdateOne(outboxCollection, bson.M{"eventId": event.EventID}, bson.M{"$set": bson.M{"dispatchedAt": now}})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliverDue sends the deliveries whose next attempt is due
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+	work := make(chan models.WebhookDelivery)
+	var wg sync.WaitGroup
+	for i := 0; i < max(d.opts.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range work {
+				d.attempt(ctx, delivery)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(work)
+
+	for i := 0; i < batchSize && ctx.Err() == nil; i++ {
+		delivery, err := d.claim(ctx)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		work <- delivery
+	}
+	return nil
+}
+
+// claim leases the next due delivery so no other dispatcher sends it at the same time
+func (d *Dispatcher) claim(ctx context.Context) (models.WebhookDelivery, error) {
+	now := time.Now().UTC()
+	lease := now.Add(d.opts.Timeout + 30*time.Second)
+
+	var delivery models.WebhookDelivery
+	err := d.db.For(ctx).FindOneAndUpdate(deliveryCollection, bson.M{
+		"status":        models.DeliveryPending,
+		"nextAttemptAt": bson.M{"$lte": now},
+		"$or": bson.A{
+			bson.M{"lockedUntil": bson.M{"$exists": false}},
+			bson.M{"lockedUntil": bson.M{"$lt": now}},
+		},
+	}, bson.D{{Key: "nextAttemptAt", Value: 1}}, bson.M{"$set": bson.M{"lockedUntil": lease}}, &delivery)
+	return delivery, err
+}
+
+// attempt sends a delivery once and records the outcome
+func (d *Dispatcher) attempt(ctx context.Context, delivery models.WebhookDelivery) {
+	var sub models.WebhookSubscription
+	err := d.db.For(ctx).FindOne(subscriptionCollection, bson.M{"subscriptionId": delivery.SubscriptionID}, &sub)
+	if err == mongo.ErrNoDocuments || (err == nil && !sub.Active) {
+		d.record(ctx, delivery, models.DeliveryDead, 0, "subscription was removed")
+		return
+	}
+	if err != nil {
+		// the lease runs out and the attempt is made again
+		logger.ErrorContext(ctx, "Error loading subscription", "subscription", delivery.SubscriptionID, "err", err)
+		return
+	}
+
+	var event models.OutboxEvent
+	err = d.db.For(ctx).FindOne(outboxCollection, bson.M{"eventId": delivery.EventID}, &event)
+	if err == mongo.ErrNoDocuments {
+		d.record(ctx, delivery, models.DeliveryDead, 0, "event not found")
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Error loading event", "event", delivery.EventID, "err", err)
+		return
+	}
+
+	statusCode, err := d.send(ctx, &sub, delivery, event)
+	if ctx.Err() != nil {
+		// shutting down; the lease runs out and the attempt is made again
+		return
+	}
+	if err == nil {
+		d.record(ctx, delivery, models.DeliveryDelivered, statusCode, "")
+		return
+	}
+	status := models.DeliveryPending
+	if delivery.Attempts+1 >= d.opts.MaxAttempts {
+		status = models.DeliveryDead
+		logger.WarnContext(ctx, "Delivery dead-lettered", "delivery", delivery.DeliveryID, "event", event.EventID, "url", sub.URL, "attempts", delivery.Attempts+1, "err", err)
+	}
+	d.record(ctx, delivery, status, statusCode, err.Error())
+}
+
+// send posts the event to the subscription, treating any 2xx response as delivered
+func (d *Dispatcher) send(ctx context.Context, sub *models.WebhookSubscription, delivery models.WebhookDelivery, event models.OutboxEvent) (int, error) {
+	body, err := json.Marshal(envelope{
+		ID:         event.EventID,
+		Type:       event.Type,
+		Subject:    event.Subject,
+		Actor:      event.Actor,
+		OccurredAt: event.OccurredAt,
+		Data:       json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, event.Type)
+	req.Header.Set(HeaderEventID, event.EventID)
+	req.Header.Set(HeaderDelivery, delivery.DeliveryID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(sub.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// record stores the outcome of an attempt and releases the lease
+func (d *Dispatcher) record(ctx context.Context, delivery models.WebhookDelivery, status string, statusCode int, lastError string) {
+	now := time.Now().UTC()
+	attempts := delivery.Attempts + 1
+	set := bson.M{
+		"status":         status,
+		"attempts":       attempts,
+		"lastAttemptAt":  now,
+		"lastStatusCode": statusCode,
+		"lastError":      lastError,
+	}
+	switch status {
+	case models.DeliveryDelivered:
+		set["deliveredAt"] = now
+	case models.DeliveryPending:
+		set["nextAttemptAt"] = now.Add(d.backoff(attempts))
+	}
+
+	_, err := d.db.For(ctx).UpdateOne(deliveryCollection, bson.M{"deliveryId": delivery.DeliveryID}, bson.M{
+		"$set":   set,
+		"$unset": bson.M{"lockedUntil": ""},
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "Error recording delivery", "delivery", delivery.DeliveryID, "err", err)
+	}
+}
+
+// backoff is the wait after the given number of failed attempts, doubling each time with up to 10% jitter
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.opts.BaseBackoff
+	for i := 1; i < attempts && wait < d.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+	wait = min(wait, d.opts.MaxBackoff)
+	return wait + time.Duration(rand.Int63n(int64(wait)/10+1))
+}
+
+// envelope is the body of a delivery
+type envelope struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Subject    string          `json:"subject"`
+	Actor      string          `json:"actor,omitempty"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Sign returns the hex HMAC-SHA256 of timestamp and body keyed with secret, as sent in HeaderSignature.
+// Receivers recompute it to check a delivery came from this server and reject stale timestamps.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,211 @@
+// Package events records HR domain events in a transactional outbox, written in the same
+// transaction as the change they describe, and delivers them to webhook subscriptions. Its Hub
+// fans database changes out to open browser sessions.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"hcmnext/auth"
+	"hcmnext/database"
+	"hcmnext/logging"
+	"hcmnext/models"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var logger = logging.For("events")
+
+// Collections used by the outbox and webhook delivery
+const (
+	outboxCollection       = "Outbox"
+	subscriptionCollection = "WebhookSubscription"
+	deliveryCollection     = "WebhookDelivery"
+)
+
+// New builds an event of eventType about subject, e.g. an employee or job id, attributed to the caller on ctx
+func New(ctx context.Context, eventType, subject string, data interface{}) (models.OutboxEvent, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return models.OutboxEvent{}, err
+	}
+
+	event := models.OutboxEvent{
+		EventID:    newID(),
+		Type:       eventType,
+		Subject:    subject,
+		OccurredAt: time.Now().UTC(),
+		Payload:    string(payload),
+	}
+	if principal := auth.FromContext(ctx); principal.Subject != auth.Anonymous.Subject {
+		event.Actor = principal.Subject
+	}
+	return event, nil
+}
+
+// Record writes events to the outbox inside tx, so they are published only if the change commits
+func Record(tx *database.Tx, events ...models.OutboxEvent) error {
+	for _, event := range events {
+		if _, err := tx.InsertOne(outboxCollection, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordBatch writes events to the outbox outside a transaction, for bulk writes that cannot run in one.
+// Events are lost if the process stops between the change and this call.
+func RecordBatch(db *database.Database, events []models.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	operations := make([]mongo.WriteModel, len(events))
+	for i, event := range events {
+		operations[i] = mongo.NewInsertOneModel().SetDocument(event)
+	}
+	_, err := db.BulkWrite(outboxCollection, operations)
+	return err
+}
+
+// EmployeeCreated returns the employee.created event for emp
+func EmployeeCreated(ctx context.Context, emp *models.Employee) (models.OutboxEvent, error) {
+	return New(ctx, models.EventEmployeeCreated, emp.EmployeeID, employeeData(emp))
+}
+
+// EmployeeUpdated returns the employee.updated event for emp as it now stands
+func EmployeeUpdated(ctx context.Context, emp *models.Employee) (models.OutboxEvent, error) {
+	return New(ctx, models.EventEmployeeUpdated, emp.EmployeeID, employeeData(emp))
+}
+
+// EmployeeChanges returns the events describing the change from before to after: employee.created when
+// before is nil, employee.updated otherwise, plus employee.terminated and compensation.changed when
+// the change adds a termination or pay entry.
+func EmployeeChanges(ctx context.Context, before, after *models.Employee) ([]models.OutboxEvent, error) {
+	if before == nil {
+		event, err := EmployeeCreated(ctx, after)
+		return []models.OutboxEvent{event}, err
+	}
+
+	updated, err := EmployeeUpdated(ctx, after)
+	if err != nil {
+		return nil, err
+	}
+	events := []models.OutboxEvent{updated}
+	add := func(eventType string, data interface{}) error {
+		event, err := New(ctx, eventType, after.EmployeeID, data)
+		events = append(events, event)
+		return err
+	}
+
+	for _, status := range after.StatusHistory {
+		if (status.Status != "Terminated" && status.Status != "Retired") || hasStatus(before.StatusHistory, status) {
+			continue
+		}
+		err := add(models.EventEmployeeTerminated, terminationData{
+			EmployeeID:    after.EmployeeID,
+			Status:        status.Status,
+			EffectiveDate: status.Date,
+			Reason:        status.Reason,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var added []models.CompensationDetails
+	for _, comp := range after.CompensationDetails {
+		if !hasCompensation(before.CompensationDetails, comp) {
+			added = append(added, comp)
+		}
+	}
+	if len(added) > 0 {
+		if err := add(models.EventCompensationChanged, compensationData{EmployeeID: after.EmployeeID, Compensation: added}); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// EmployeeDeleted returns the employee.deleted event for an employee id
+func EmployeeDeleted(ctx context.Context, employeeID string) (models.OutboxEvent, error) {
+	return New(ctx, models.EventEmployeeDeleted, employeeID, map[string]string{"employeeId": employeeID})
+}
+
+// JobUpdated returns the job.updated event for job as it now stands
+func JobUpdated(ctx context.Context, job *models.Job) (models.OutboxEvent, error) {
+	return New(ctx, models.EventJobUpdated, job.JobID, jobData{
+		JobID:     job.JobID,
+		JobName:   job.JobName,
+		Headcount: job.Headcount,
+	})
+}
+
+// employeePayload is the employee in event payloads, without personal data
+type employeePayload struct {
+	EmployeeID    string             `json:"employeeId"`
+	FirstName     string             `json:"firstName"`
+	LastName      string             `json:"lastName"`
+	PreferredName string             `json:"preferredName,omitempty"`
+	Current       models.CurrentView `json:"current"`
+}
+
+type terminationData struct {
+	EmployeeID    string    `json:"employeeId"`
+	Status        string    `json:"status"`
+	EffectiveDate time.Time `json:"effectiveDate"`
+	Reason        string    `json:"reason,omitempty"`
+}
+
+type compensationData struct {
+	EmployeeID   string                       `json:"employeeId"`
+	Compensation []models.CompensationDetails `json:"compensation"`
+}
+
+type jobData struct {
+	JobID     string           `json:"jobId"`
+	JobName   string           `json:"jobName"`
+	Headcount models.Headcount `json:"headcount"`
+}
+
+func employeeData(emp *models.Employee) employeePayload {
+	current := emp.CurrentAsOf(time.Now())
+	if current.Manager != nil {
+		// the manager's email is personal data
+		current.Manager = &models.Manager{Name: current.Manager.Name, EmployeeID: current.Manager.EmployeeID}
+	}
+	return employeePayload{
+		EmployeeID:    emp.EmployeeID,
+		FirstName:     emp.FirstName,
+		LastName:      emp.LastName,
+		PreferredName: emp.PreferredName,
+		Current:       current,
+	}
+}
+
+func hasStatus(history []models.StatusHistory, status models.StatusHistory) bool {
+	for _, s := range history {
+		if s.Status == status.Status && s.Date.Equal(status.Date) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasCompensation(history []models.CompensationDetails, comp models.CompensationDetails) bool {
+	for _, c := range history {
+		if c.EffectiveDate.Equal(comp.EffectiveDate) && c.Salary == comp.Salary && c.Currency == comp.Currency && c.PayFrequency == comp.PayFrequency {
+			return true
+		}
+	}
+	return false
+}
+
+func newID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
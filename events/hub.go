@@ -0,0 +1,84 @@
+package events
+
+import (
+	"sync"
+
+	"hcmnext/database"
+)
+
+// subscriberBuffer is the number of changes a subscriber may fall behind before it is dropped
+const subscriberBuffer = 64
+
+// Hub fans database changes out to the subscribers of each tenant, such as open browser tabs.
+// A subscriber that falls too far behind is dropped rather than slowing the others down; its
+// channel is closed so the client reconnects and reloads what it shows.
+type Hub struct {
+	mu     sync.Mutex
+	topics map[string]map[chan database.ChangeEvent]struct{}
+	closed bool
+}
+
+// NewHub creates a new instance of Hub
+func NewHub() *Hub {
+	return &Hub{topics: make(map[string]map[chan database.ChangeEvent]struct{})}
+}
+
+// Subscribe returns a channel receiving the changes of tenant and a function that ends the subscription.
+// The channel is closed when the subscription ends, the subscriber falls behind or the hub is closed.
+func (h *Hub) Subscribe(tenant string) (<-chan database.ChangeEvent, func()) {
+	ch := make(chan database.ChangeEvent, subscriberBuffer)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if h.topics[tenant] == nil {
+		h.topics[tenant] = make(map[chan database.ChangeEvent]struct{})
+	}
+	h.topics[tenant][ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.remove(tenant, ch)
+	}
+}
+
+// Publish sends change to every subscriber of tenant without blocking
+func (h *Hub) Publish(tenant string, change database.ChangeEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.topics[tenant] {
+		select {
+		case ch <- change:
+		default:
+			h.remove(tenant, ch)
+		}
+	}
+}
+
+// Close ends every subscription; later subscriptions end straight away
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for tenant, subscribers := range h.topics {
+		for ch := range subscribers {
+			h.remove(tenant, ch)
+		}
+	}
+	h.closed = true
+}
+
+// remove ends a subscription; h.mu must be held
+func (h *Hub) remove(tenant string, ch chan database.ChangeEvent) {
+	if _, ok := h.topics[tenant][ch]; !ok {
+		return
+	}
+	delete(h.topics[tenant], ch)
+	if len(h.topics[tenant]) == 0 {
+		delete(h.topics, tenant)
+	}
+	close(ch)
+}
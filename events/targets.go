@@ -0,0 +1,80 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// errInternalTarget rejects subscriptions to addresses inside the network
+var errInternalTarget = fmt.Errorf("%w: url must not point at a private, loopback or link-local address", ErrInvalidSubscription)
+
+// sharedAddressSpace is the carrier-grade NAT range, which net/netip does not count as private
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// internalAddress reports whether addr is one webhooks must not reach: loopback, private,
+// link-local (such as cloud metadata at 169.254.169.254), multicast or unspecified
+func internalAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() || sharedAddressSpace.Contains(addr)
+}
+
+// checkHost rejects a target host that is, or resolves to, an internal address. A host that
+// does not resolve yet is accepted; the dispatcher checks the address again when it connects.
+func checkHost(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if internalAddress(addr) {
+			return errInternalTarget
+		}
+		return nil
+	}
+	if host == "localhost" {
+		return errInternalTarget
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if internalAddress(addr) {
+			return errInternalTarget
+		}
+	}
+	return nil
+}
+
+// refuseInternal is a dialer control refusing connections to internal addresses, checked
+// after DNS resolution so a public name cannot point deliveries into the network
+func refuseInternal(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if internalAddress(addrPort.Addr()) {
+		return fmt.Errorf("refusing to deliver to internal address %s", addrPort.Addr())
+	}
+	return nil
+}
+
+// newDeliveryClient returns a client for webhook deliveries. It connects directly, never
+// through a proxy, only to public addresses, and does not follow redirects; a redirect is
+// reported like any other non-2xx response.
+func newDeliveryClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: refuseInternal}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
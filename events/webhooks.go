@@ -0,0 +1,221 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"hcmnext/auth"
+	"hcmnext/database"
+	"hcmnext/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+	ErrInvalidSubscription  = errors.New("invalid webhook subscription")
+)
+
+// maxReplay bounds the events one replay request can queue
+const maxReplay = 10000
+
+// Webhooks manages webhook subscriptions and their deliveries
+type Webhooks struct {
+	db *database.Database
+}
+
+// NewWebhooks creates a new instance of Webhooks
+func NewWebhooks(db *database.Database) *Webhooks {
+	return &Webhooks{db: db}
+}
+
+// Subscribe registers url for the given event types, or every type when none are given.
+// URLs pointing at private, loopback or link-local addresses are refused. The returned
+// subscription carries the signing secret, which is not shown again.
+func (wh *Webhooks) Subscribe(ctx context.Context, target string, eventTypes []string) (*models.WebhookSubscription, error) {
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidSubscription)
+	}
+	if err := checkHost(ctx, parsed.Hostname()); err != nil {
+		return nil, err
+	}
+	for _, eventType := range eventTypes {
+		if !knownEventType(eventType) {
+			return nil, fmt.Errorf("%w: unknown event type %q", ErrInvalidSubscription, eventType)
+		}
+	}
+
+	sub := &models.WebhookSubscription{
+		SubscriptionID: newID(),
+		URL:            target,
+		EventTypes:     eventTypes,
+		Secret:         "whsec_" + newID() + newID(),
+		Active:         true,
+		CreatedAt:      time.Now().UTC(),
+		CreatedBy:      auth.FromContext(ctx).Subject,
+	}
+	if _, err := wh.db.For(ctx).InsertOne(subscriptionCollection, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Subscriptions lists every active subscription
+func (wh *Webhooks) Subscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	cursor, err := wh.db.For(ctx).FindSorted(subscriptionCollection, bson.M{"active": true}, bson.D{{Key: "createdAt", Value: 1}}, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	subs := []models.WebhookSubscription{}
+	if err := cursor.All(ctx, &subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// Unsubscribe deactivates a subscription; its pending deliveries are dead-lettered by the dispatcher
+func (wh *Webhooks) Unsubscribe(ctx context.Context, subscriptionID string) error {
+	result, err := wh.db.For(ctx).UpdateOne(subscriptionCollection, bson.M{"subscriptionId": subscriptionID, "active": true}, bson.M{
+		"$set": bson.M{"active": false},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// Deliveries lists the most recent deliveries of a subscription, optionally only those with status
+func (wh *Webhooks) Deliveries(ctx context.Context, subscriptionID, status string, limit int64) ([]models.WebhookDelivery, error) {
+	if _, err := wh.subscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"subscriptionId": subscriptionID}
+	if status != "" {
+		filter["status"] = status
+	}
+	cursor, err := wh.db.For(ctx).FindSorted(deliveryCollection, filter, bson.D{{Key: "createdAt", Value: -1}}, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []models.WebhookDelivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Redeliver queues a delivery to be sent again straight away, with a fresh set of attempts.
+// It is how dead-lettered deliveries are retried once the receiver is fixed.
+func (wh *Webhooks) Redeliver(ctx context.Context, deliveryID string) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := wh.db.For(ctx).FindOneAndUpdate(deliveryCollection, bson.M{"deliveryId": deliveryID}, nil, bson.M{
+		"$set":   bson.M{"status": models.DeliveryPending, "attempts": 0, "nextAttemptAt": time.Now().UTC()},
+		"$unset": bson.M{"lockedUntil": "", "deliveredAt": ""},
+	}, &delivery)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// Replay queues new deliveries to a subscription for every outbox event that occurred in [since, until)
+// and matches both the subscription and eventTypes, if given. It returns the number queued.
+func (wh *Webhooks) Replay(ctx context.Context, subscriptionID string, since, until time.Time, eventTypes []string) (int, error) {
+	sub, err := wh.subscription(ctx, subscriptionID)
+	if err != nil {
+		return 0, err
+	}
+	if !sub.Active {
+		return 0, fmt.Errorf("%w: subscription is not active", ErrInvalidSubscription)
+	}
+
+	occurred := bson.M{"$gte": since}
+	if !until.IsZero() {
+		occurred["$lt"] = until
+	}
+	filter := bson.M{"occurredAt": occurred}
+	if len(eventTypes) > 0 {
+		filter["type"] = bson.M{"$in": eventTypes}
+	}
+
+	cursor, err := wh.db.For(ctx).FindSorted(outboxCollection, filter, bson.D{{Key: "occurredAt", Value: 1}}, maxReplay)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var operations []mongo.WriteModel
+	now := time.Now().UTC()
+	for cursor.Next(ctx) {
+		var event models.OutboxEvent
+		if err := cursor.Decode(&event); err != nil {
+			return 0, err
+		}
+		if !sub.Wants(event.Type) {
+			continue
+		}
+		operations = append(operations, mongo.NewInsertOneModel().SetDocument(newDelivery(sub, event, now)))
+	}
+	if err := cursor.Err(); err != nil {
+		return 0, err
+	}
+	if len(operations) == 0 {
+		return 0, nil
+	}
+
+	result, err := wh.db.For(ctx).BulkWrite(deliveryCollection, operations)
+	if err != nil {
+		return 0, err
+	}
+	return int(result.InsertedCount), nil
+}
+
+func (wh *Webhooks) subscription(ctx context.Context, subscriptionID string) (*models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	err := wh.db.For(ctx).FindOne(subscriptionCollection, bson.M{"subscriptionId": subscriptionID}, &sub)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func newDelivery(sub *models.WebhookSubscription, event models.OutboxEvent, now time.Time) models.WebhookDelivery {
+	return models.WebhookDelivery{
+		DeliveryID:     newID(),
+		SubscriptionID: sub.SubscriptionID,
+		EventID:        event.EventID,
+		EventType:      event.Type,
+		Status:         models.DeliveryPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+	}
+}
+
+func knownEventType(eventType string) bool {
+	for _, t := range models.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
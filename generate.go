@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log/slog"
+	"time"
+
+	"hcmnext/config"
+	"hcmnext/synth"
+)
+
+// generate creates a synthetic company and writes it to MongoDB, or as NDJSON fixtures into
+// the directory named by -out
+func generate(args []string) {
+	opts := synth.DefaultOptions
+	var tenant, asOf, out string
+	// fixtures need no database, so its settings are checked only when storing
+	cfg := loadConfig(config.Command{Name: "generate", Flags: func(flags *flag.FlagSet) {
+		tenantsFlag(flags, &tenant)
+		flags.IntVar(&opts.Employees, "employees", opts.Employees, "number of employee records, current and former")
+		flags.Int64Var(&opts.Seed, "seed", opts.Seed, "random seed; the same flags generate the same data")
+		flags.Float64Var(&opts.Attrition, "attrition", opts.Attrition, "share of the employees who have left")
+		flags.StringVar(&asOf, "as-of", "", "day the data describes, as YYYY-MM-DD; today when empty")
+		flags.StringVar(&out, "out", "", "directory to write Employee.ndjson and Job.ndjson into instead of the database")
+	}}, args)
+
+	opts.AsOf = time.Now()
+	if asOf != "" {
+		day, err := time.Parse(time.DateOnly, asOf)
+		if err != nil {
+			fatal("Invalid -as-of", err)
+		}
+		opts.AsOf = day
+	}
+
+	dataset, err := synth.Generate(opts)
+	if err != nil {
+		fatal("Failed to generate data", err)
+	}
+	if out != "" {
+		if err := dataset.WriteFixtures(out); err != nil {
+			fatal("Failed to write fixtures", err)
+		}
+		slog.Info("Fixtures written", "dir", out, "employees", len(dataset.Employees), "jobs", len(dataset.Jobs))
+		return
+	}
+
+	if cfg.Database.URI == "" || cfg.Database.Name == "" {
+		fatal("Nowhere to store generated data", errors.New("set the database URI and name, or -out to write fixtures"))
+	}
+	db := openDatabase(cfg)
+	defer db.Close()
+	if db.MultiTenant() && tenant == "" {
+		db.Close()
+		fatal("Nowhere to store generated data", errors.New("-tenant is required when tenants are isolated"))
+	}
+	if err := dataset.Store(operatorContext(tenant), db); err != nil {
+		db.Close()
+		fatal("Failed to store generated data", err)
+	}
+	slog.Info("Generated data stored", "employees", len(dataset.Employees), "jobs", len(dataset.Jobs))
+}
@@ -0,0 +1,147 @@
+// Package health checks the dependencies of the server for the liveness and readiness probes
+// and the admin status page.
+//
+// Liveness only runs the checks marked Live, which depend on nothing but the process and its
+// host, such as the static assets and the Node.js evaluator: a database outage makes the server
+// unready, so it gets no traffic, but must not get it restarted. Readiness runs every check.
+// A failing Optional check, such as the LLM provider's, reports the server as degraded but ready.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Status is the outcome of a check or of a whole report
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusDegraded Status = "degraded"
+	StatusFailed   Status = "failed"
+)
+
+// Check is a named dependency check
+type Check struct {
+	Name string
+	// Run returns nil when the dependency is usable
+	Run func(ctx context.Context) error
+	// Optional checks degrade the report instead of failing it
+	Optional bool
+	// Live checks decide liveness too
+	Live bool
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Status     Status `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+}
+
+// Report is the outcome of a set of checks
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker runs the registered checks concurrently, each within the timeout
+type Checker struct {
+	mu      sync.RWMutex
+	checks  []Check
+	timeout time.Duration
+}
+
+// NewChecker creates a checker that gives each check timeout to complete
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers check
+func (c *Checker) Add(check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check)
+}
+
+// Live runs the checks that decide liveness
+func (c *Checker) Live(ctx context.Context) Report {
+	return c.run(ctx, func(check Check) bool { return check.Live })
+}
+
+// Ready runs every check
+func (c *Checker) Ready(ctx context.Context) Report {
+	return c.run(ctx, func(Check) bool { return true })
+}
+
+func (c *Checker) run(ctx context.Context, include func(Check) bool) Report {
+	c.mu.RLock()
+	var checks []Check
+	for _, check := range c.checks {
+		if include(check) {
+			checks = append(checks, check)
+		}
+	}
+	c.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = c.runOne(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	for i, check := range checks {
+		report.Checks[check.Name] = results[i]
+		switch {
+		case results[i].Status == StatusOK:
+		case check.Optional:
+			if report.Status == StatusOK {
+				report.Status = StatusDegraded
+			}
+		default:
+			report.Status = StatusFailed
+		}
+	}
+	return report
+}
+
+func (c *Checker) runOne(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Run(ctx)
+	result := Result{Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Cached returns a check that runs run at most once per ttl and otherwise repeats its last
+// outcome, for dependencies that are slow or costly to check on every probe
+func Cached(ttl time.Duration, run func(ctx context.Context) error) func(ctx context.Context) error {
+	var (
+		mu      sync.Mutex
+		checked time.Time
+		last    error
+	)
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !checked.IsZero() && time.Since(checked) < ttl {
+			return last
+		}
+		last = run(ctx)
+		checked = time.Now()
+		return last
+	}
+}
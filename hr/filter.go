@@ -0,0 +1,105 @@
+package hr
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"hcmnext/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EmployeeFilter selects employees by their current view on AsOf. Every set field must
+// match, ignoring case.
+type EmployeeFilter struct {
+	AsOf           time.Time
+	Department     string
+	Location       string
+	JobID          string
+	Title          string
+	EmploymentType string
+	ManagerID      string
+	Status         string
+}
+
+// ParseEmployeeFilter reads the filter from query parameters of the same names, in camel case,
+// with asOf given as YYYY-MM-DD and defaulting to today
+func ParseEmployeeFilter(query url.Values) (EmployeeFilter, error) {
+	filter := EmployeeFilter{
+		AsOf:           time.Now(),
+		Department:     query.Get("department"),
+		Location:       query.Get("location"),
+		JobID:          query.Get("jobId"),
+		Title:          query.Get("title"),
+		EmploymentType: query.Get("employmentType"),
+		ManagerID:      query.Get("managerId"),
+		Status:         query.Get("status"),
+	}
+	if param := query.Get("asOf"); param != "" {
+		asOf, err := time.Parse("2006-01-02", param)
+		if err != nil {
+			return filter, &ActionError{Action: "filter employees", Reason: "asOf must be a date in the form YYYY-MM-DD"}
+		}
+		filter.AsOf = asOf
+	}
+	return filter, nil
+}
+
+// Query returns a Mongo filter matching at least every employee the filter selects.
+// History entries other than the current one can match it too, so results still go through Matches.
+func (f EmployeeFilter) Query() bson.M {
+	query := bson.M{}
+
+	job := bson.M{}
+	for field, value := range map[string]string{
+		"department":         f.Department,
+		"location":           f.Location,
+		"jobId":              f.JobID,
+		"title":              f.Title,
+		"employmentType":     f.EmploymentType,
+		"manager.employeeId": f.ManagerID,
+	} {
+		if value != "" {
+			job[field] = equalFold(value)
+		}
+	}
+	if len(job) > 0 {
+		query["jobHistory"] = bson.M{"$elemMatch": job}
+	}
+	if f.Status != "" {
+		query["statusHistory.status"] = equalFold(f.Status)
+	}
+	return query
+}
+
+// Matches reports whether the employee's current view on AsOf satisfies the filter
+func (f EmployeeFilter) Matches(emp *models.Employee) bool {
+	view := emp.CurrentAsOf(f.AsOf)
+
+	managerID := ""
+	if view.Manager != nil {
+		managerID = view.Manager.EmployeeID
+	}
+	for _, c := range []struct{ want, got string }{
+		{f.Department, view.Department},
+		{f.Location, view.Location},
+		{f.JobID, view.JobID},
+		{f.Title, view.Title},
+		{f.EmploymentType, view.EmploymentType},
+		{f.ManagerID, managerID},
+		{f.Status, view.Status},
+	} {
+		if c.want != "" && !strings.EqualFold(c.want, c.got) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalFold matches a string field equal to value, ignoring case
+func equalFold(value string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(value) + "$", Options: "i"}
+}
@@ -0,0 +1,512 @@
+// Package hr implements HR operations that span several records, such as
+// lifecycle actions that change an employee's history and the jobs they fill.
+package hr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"hcmnext/database"
+	"hcmnext/events"
+	"hcmnext/logging"
+	"hcmnext/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var logger = logging.For("hr")
+
+var (
+	// ErrEmployeeNotFound is returned when the employee an action targets does not exist
+	ErrEmployeeNotFound = errors.New("employee not found")
+	// ErrEmployeeExists is returned when hiring an employee id that is already taken
+	ErrEmployeeExists = errors.New("employee already exists")
+	// ErrJobNotFound is returned when an action references a job that does not exist
+	ErrJobNotFound = errors.New("job not found")
+)
+
+// ActionError is returned when an action is not valid for the employee's current state
+type ActionError struct {
+	Action string
+	Reason string
+}
+
+func (e *ActionError) Error() string {
+	return fmt.Sprintf("cannot %s: %s", e.Action, e.Reason)
+}
+
+// JobChange describes the job an employee moves into. Empty fields carry over from the current job.
+type JobChange struct {
+	JobID            string          `json:"jobId"`
+	Title            string          `json:"title"`
+	Department       string          `json:"department"`
+	Location         string          `json:"location"`
+	EmploymentType   string          `json:"employmentType"`
+	Manager          *models.Manager `json:"manager,omitempty"`
+	Responsibilities []string        `json:"responsibilities,omitempty"`
+}
+
+// CompensationChange is new pay taking effect with an action
+type CompensationChange struct {
+	Salary       float64            `json:"salary"`
+	Currency     string             `json:"currency"`
+	PayFrequency string             `json:"payFrequency"`
+	Bonuses      int                `json:"bonuses"`
+	Allowances   []models.Allowance `json:"allowances,omitempty"`
+}
+
+// HireAction creates an employee with their first job, status and pay
+type HireAction struct {
+	Employee     models.Employee    `json:"employee"`
+	Date         time.Time          `json:"date"`
+	Job          JobChange          `json:"job"`
+	Compensation CompensationChange `json:"compensation"`
+}
+
+// TransferAction moves an employee to another department, location, manager or job
+type TransferAction struct {
+	Date         time.Time           `json:"date"`
+	Job          JobChange           `json:"job"`
+	Compensation *CompensationChange `json:"compensation,omitempty"`
+	Reason       string              `json:"reason,omitempty"`
+}
+
+// PromoteAction moves an employee into a more senior job with new pay
+type PromoteAction struct {
+	Date         time.Time          `json:"date"`
+	Job          JobChange          `json:"job"`
+	Compensation CompensationChange `json:"compensation"`
+	Reason       string             `json:"reason,omitempty"`
+}
+
+// TerminateAction ends employment; Date is the last day worked
+type TerminateAction struct {
+	Date   time.Time `json:"date"`
+	Status string    `json:"status,omitempty"`
+	Reason string    `json:"reason"`
+}
+
+// RehireAction brings a terminated or retired employee back
+type RehireAction struct {
+	Date         time.Time          `json:"date"`
+	Job          JobChange          `json:"job"`
+	Compensation CompensationChange `json:"compensation"`
+	Reason       string             `json:"reason,omitempty"`
+}
+
+// Lifecycle applies lifecycle actions. Each action updates the employee and the
+// headcount of the affected jobs in one transaction, so a failure leaves both untouched.
+type Lifecycle struct {
+	db *database.Database
+}
+
+// NewLifecycle creates a lifecycle service
+func NewLifecycle(db *database.Database) *Lifecycle {
+	return &Lifecycle{db: db}
+}
+
+// Hire creates the employee described by action
+func (l *Lifecycle) Hire(ctx context.Context, action HireAction) (*models.Employee, error) {
+	emp := action.Employee
+	if emp.EmployeeID == "" {
+		return nil, &ActionError{Action: "hire", Reason: "employee.employeeId is required"}
+	}
+	if err := requireDate("hire", action.Date); err != nil {
+		return nil, err
+	}
+
+	job, err := newJobEntry("hire", nil, action.Job, action.Date)
+	if err != nil {
+		return nil, err
+	}
+	emp.JobHistory = []models.JobHistory{job}
+	emp.StatusHistory = []models.StatusHistory{{Status: "Active", Date: action.Date, Reason: "Hired"}}
+	emp.CompensationDetails = []models.CompensationDetails{compensationEntry(action.Compensation, action.Date)}
+	if err := emp.Validate(); err != nil {
+		return nil, err
+	}
+
+	err = l.db.WithTransaction(ctx, func(tx *database.Tx) error {
+		var existing models.Employee
+		err := tx.FindOne("Employee", bson.M{"employeeId": emp.EmployeeID}, &existing)
+		if err == nil {
+			return ErrEmployeeExists
+		}
+		if err != mongo.ErrNoDocuments {
+			return err
+		}
+
+		if _, err := tx.InsertOne("Employee", emp); err != nil {
+			return err
+		}
+		if err := fillPosition(tx, &emp, job); err != nil {
+			return err
+		}
+		if err := recordEmployeeChanges(ctx, tx, nil, &emp); err != nil {
+			return err
+		}
+		return recordJobUpdated(ctx, tx, job.JobID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &emp, nil
+}
+
+// Transfer closes the employee's open job the day before action.Date and opens the new one
+func (l *Lifecycle) Transfer(ctx context.Context, employeeID string, action TransferAction) (*models.Employee, error) {
+	return l.apply(ctx, employeeID, "transfer", action.Date, func(emp *models.Employee) error {
+		if err := requireActive("transfer", emp, action.Date); err != nil {
+			return err
+		}
+		if err := changeJob("transfer", emp, action.Job, action.Date); err != nil {
+			return err
+		}
+		if action.Compensation != nil {
+			emp.CompensationDetails = append(emp.CompensationDetails, compensationEntry(*action.Compensation, action.Date))
+		}
+		return nil
+	})
+}
+
+// Promote moves the employee into a new job with new pay from action.Date
+func (l *Lifecycle) Promote(ctx context.Context, employeeID string, action PromoteAction) (*models.Employee, error) {
+	return l.apply(ctx, employeeID, "promote", action.Date, func(emp *models.Employee) error {
+		if err := requireActive("promote", emp, action.Date); err != nil {
+			return err
+		}
+		if action.Job.Title == "" {
+			return &ActionError{Action: "promote", Reason: "job.title is required"}
+		}
+		if err := changeJob("promote", emp, action.Job, action.Date); err != nil {
+			return err
+		}
+		emp.CompensationDetails = append(emp.CompensationDetails, compensationEntry(action.Compensation, action.Date))
+		return nil
+	})
+}
+
+// Terminate closes the employee's open job on action.Date and records the termination the day after
+func (l *Lifecycle) Terminate(ctx context.Context, employeeID string, action TerminateAction) (*models.Employee, error) {
+	status := action.Status
+	if status == "" {
+		status = "Terminated"
+	}
+	if status != "Terminated" && status != "Retired" {
+		return nil, &ActionError{Action: "terminate", Reason: "status must be Terminated or Retired"}
+	}
+
+	return l.apply(ctx, employeeID, "terminate", action.Date, func(emp *models.Employee) error {
+		if err := requireActive("terminate", emp, action.Date); err != nil {
+			return err
+		}
+		open := openJob(emp)
+		if open == nil {
+			return &ActionError{Action: "terminate", Reason: "employee has no open job"}
+		}
+		end := action.Date
+		open.EndDate = &end
+		emp.StatusHistory = append(emp.StatusHistory, models.StatusHistory{Status: status, Date: action.Date.AddDate(0, 0, 1), Reason: action.Reason})
+		return nil
+	})
+}
+
+// Rehire returns a terminated or retired employee to work in a new job from action.Date
+func (l *Lifecycle) Rehire(ctx context.Context, employeeID string, action RehireAction) (*models.Employee, error) {
+	return l.apply(ctx, employeeID, "rehire", action.Date, func(emp *models.Employee) error {
+		status := emp.StatusAsOf(action.Date)
+		if status == nil || (status.Status != "Terminated" && status.Status != "Retired") {
+			return &ActionError{Action: "rehire", Reason: "employee is not terminated or retired"}
+		}
+		if openJob(emp) != nil {
+			return &ActionError{Action: "rehire", Reason: "employee still has an open job"}
+		}
+
+		// the previous job's details are a sensible default for a returning employee
+		var previous *models.JobHistory
+		if n := len(emp.JobHistory); n > 0 {
+			previous = &emp.JobHistory[n-1]
+		}
+		job, err := newJobEntry("rehire", previous, action.Job, action.Date)
+		if err != nil {
+			return err
+		}
+		emp.JobHistory = append(emp.JobHistory, job)
+		emp.StatusHistory = append(emp.StatusHistory, models.StatusHistory{Status: "Active", Date: action.Date, Reason: reasonOr(action.Reason, "Rehired")})
+		emp.CompensationDetails = append(emp.CompensationDetails, compensationEntry(action.Compensation, action.Date))
+		return nil
+	})
+}
+
+// apply loads the employee, lets change modify them, validates the resulting history and
+// saves the employee together with the headcount of every job they left or joined
+func (l *Lifecycle) apply(ctx context.Context, employeeID, action string, date time.Time, change func(emp *models.Employee) error) (*models.Employee, error) {
+	if err := requireDate(action, date); err != nil {
+		return nil, err
+	}
+
+	var emp models.Employee
+	err := l.db.WithTransaction(ctx, func(tx *database.Tx) error {
+		// the transaction may be retried, so start from a fresh read every time
+		emp = models.Employee{}
+		if err := tx.FindOne("Employee", bson.M{"employeeId": employeeID}, &emp); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return ErrEmployeeNotFound
+			}
+			return err
+		}
+
+		// change may append to the histories and close the open job but must not alter what the
+		// events are compared against
+		before := emp
+		before.JobHistory = append([]models.JobHistory(nil), emp.JobHistory...)
+		for i, job := range before.JobHistory {
+			if job.EndDate != nil {
+				end := *job.EndDate
+				before.JobHistory[i].EndDate = &end
+			}
+		}
+		before.StatusHistory = append([]models.StatusHistory(nil), emp.StatusHistory...)
+		before.CompensationDetails = append([]models.CompensationDetails(nil), emp.CompensationDetails...)
+
+		var left *models.JobHistory
+		if open := openJob(&emp); open != nil {
+			copied := *open
+			left = &copied
+		}
+
+		if err := change(&emp); err != nil {
+			return err
+		}
+		if err := emp.ValidateHistory(); err != nil {
+			return err
+		}
+
+		if _, err := tx.UpdateOne("Employee", bson.M{"employeeId": employeeID}, bson.M{"$set": bson.M{
+			"jobHistory":          emp.JobHistory,
+			"statusHistory":       emp.StatusHistory,
+			"compensationDetails": emp.CompensationDetails,
+		}}); err != nil {
+			return err
+		}
+
+		if err := recordEmployeeChanges(ctx, tx, &before, &emp); err != nil {
+			return err
+		}
+
+		joined := openJob(&emp)
+		if left != nil && (joined == nil || left.JobID != joined.JobID) {
+			if err := vacatePosition(tx, &emp, *left); err != nil {
+				return err
+			}
+			if err := recordJobUpdated(ctx, tx, left.JobID); err != nil {
+				return err
+			}
+		}
+		if joined != nil && (left == nil || left.JobID != joined.JobID) {
+			if err := fillPosition(tx, &emp, *joined); err != nil {
+				return err
+			}
+			return recordJobUpdated(ctx, tx, joined.JobID)
+		}
+		if joined != nil && left != nil && joined.Title != left.Title {
+			if err := retitlePosition(tx, &emp, *joined); err != nil {
+				return err
+			}
+			return recordJobUpdated(ctx, tx, joined.JobID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &emp, nil
+}
+
+// changeJob closes the open job the day before date and opens the changed one on date
+func changeJob(action string, emp *models.Employee, change JobChange, date time.Time) error {
+	open := openJob(emp)
+	if open == nil {
+		return &ActionError{Action: action, Reason: "employee has no open job"}
+	}
+
+	job, err := newJobEntry(action, open, change, date)
+	if err != nil {
+		return err
+	}
+	end := date.AddDate(0, 0, -1)
+	open.EndDate = &end
+	emp.JobHistory = append(emp.JobHistory, job)
+	return nil
+}
+
+// newJobEntry builds a job history entry starting on date, taking unset fields from previous
+func newJobEntry(action string, previous *models.JobHistory, change JobChange, date time.Time) (models.JobHistory, error) {
+	job := models.JobHistory{
+		JobID:            change.JobID,
+		Title:            change.Title,
+		Department:       change.Department,
+		Location:         change.Location,
+		EmploymentType:   change.EmploymentType,
+		Manager:          change.Manager,
+		Responsibilities: change.Responsibilities,
+		StartDate:        date,
+	}
+	if previous != nil {
+		if job.JobID == "" {
+			job.JobID = previous.JobID
+		}
+		if job.Title == "" {
+			job.Title = previous.Title
+		}
+		if job.Department == "" {
+			job.Department = previous.Department
+		}
+		if job.Location == "" {
+			job.Location = previous.Location
+		}
+		if job.EmploymentType == "" {
+			job.EmploymentType = previous.EmploymentType
+		}
+		if job.Manager == nil {
+			job.Manager = previous.Manager
+		}
+		if job.Responsibilities == nil && job.Title == previous.Title {
+			job.Responsibilities = previous.Responsibilities
+		}
+	}
+
+	if job.JobID == "" || job.Title == "" || job.Department == "" || job.Location == "" || job.EmploymentType == "" {
+		return job, &ActionError{Action: action, Reason: "job needs a jobId, title, department, location and employmentType"}
+	}
+	return job, nil
+}
+
+func compensationEntry(change CompensationChange, date time.Time) models.CompensationDetails {
+	allowances := change.Allowances
+	if allowances == nil {
+		allowances = []models.Allowance{}
+	}
+	return models.CompensationDetails{
+		EffectiveDate: date,
+		Salary:        change.Salary,
+		Currency:      change.Currency,
+		PayFrequency:  change.PayFrequency,
+		Bonuses:       change.Bonuses,
+		Allowances:    allowances,
+	}
+}
+
+// openJob returns the employee's job entry without an end date, if any
+func openJob(emp *models.Employee) *models.JobHistory {
+	for i := len(emp.JobHistory) - 1; i >= 0; i-- {
+		if emp.JobHistory[i].EndDate == nil {
+			return &emp.JobHistory[i]
+		}
+	}
+	return nil
+}
+
+func requireDate(action string, date time.Time) error {
+	if date.IsZero() {
+		return &ActionError{Action: action, Reason: "date is required"}
+	}
+	return nil
+}
+
+func requireActive(action string, emp *models.Employee, date time.Time) error {
+	status := emp.StatusAsOf(date)
+	if status == nil {
+		return &ActionError{Action: action, Reason: "employee has no status on " + date.Format("2006-01-02")}
+	}
+	if status.Status == "Terminated" || status.Status == "Retired" {
+		return &ActionError{Action: action, Reason: "employee is " + status.Status}
+	}
+	return nil
+}
+
+func reasonOr(reason, fallback string) string {
+	if reason == "" {
+		return fallback
+	}
+	return reason
+}
+
+// fillPosition records the employee as filling a position of job, claims a slot for them
+// and recalculates the job's headcount
+func fillPosition(tx *database.Tx, emp *models.Employee, job models.JobHistory) error {
+	result, err := tx.UpdateOne("Job", bson.M{"jobId": job.JobID}, bson.M{
+		"$push": bson.M{"headcount.positionsFilled": models.PositionFilled{
+			PositionTitle: job.Title,
+			EmployeeID:    emp.EmployeeID,
+			EmployeeName:  emp.FirstName + " " + emp.LastName,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, job.JobID)
+	}
+	if err := claimSlot(tx, emp, job); err != nil {
+		return err
+	}
+	return syncHeadcount(tx, job.JobID)
+}
+
+// vacatePosition removes the employee from the positions filled for job, reopens their slot
+// and recalculates the job's headcount
+func vacatePosition(tx *database.Tx, emp *models.Employee, job models.JobHistory) error {
+	result, err := tx.UpdateOne("Job", bson.M{"jobId": job.JobID, "headcount.positionsFilled.employeeId": emp.EmployeeID}, bson.M{
+		"$pull": bson.M{"headcount.positionsFilled": bson.M{"employeeId": emp.EmployeeID}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		// jobs filled before lifecycle actions existed may not list the employee
+		logger.WarnContext(tx.Context(), "Employee was not listed on job", "employee", emp.EmployeeID, "job", job.JobID)
+	}
+	if err := releaseSlot(tx, emp, job); err != nil {
+		return err
+	}
+	return syncHeadcount(tx, job.JobID)
+}
+
+// retitlePosition updates the position title the employee holds within the same job
+func retitlePosition(tx *database.Tx, emp *models.Employee, job models.JobHistory) error {
+	if _, err := tx.UpdateOne("Job", bson.M{"jobId": job.JobID, "headcount.positionsFilled.employeeId": emp.EmployeeID}, bson.M{
+		"$set": bson.M{"headcount.positionsFilled.$.positionTitle": job.Title},
+	}); err != nil {
+		return err
+	}
+	_, err := tx.UpdateOne("PositionSlot", bson.M{"jobId": job.JobID, "employeeId": emp.EmployeeID, "status": models.SlotFilled}, bson.M{
+		"$set": bson.M{"positionTitle": job.Title},
+	})
+	return err
+}
+
+// recordEmployeeChanges writes the events describing the change from before to after to the outbox
+func recordEmployeeChanges(ctx context.Context, tx *database.Tx, before, after *models.Employee) error {
+	changes, err := events.EmployeeChanges(ctx, before, after)
+	if err != nil {
+		return err
+	}
+	return events.Record(tx, changes...)
+}
+
+// recordJobUpdated writes a job.updated event with the job as it stands in tx to the outbox
+func recordJobUpdated(ctx context.Context, tx *database.Tx, jobID string) error {
+	var job models.Job
+	if err := tx.FindOne("Job", bson.M{"jobId": jobID}, &job); err != nil {
+		return err
+	}
+	event, err := events.JobUpdated(ctx, &job)
+	if err != nil {
+		return err
+	}
+	return events.Record(tx, event)
+}
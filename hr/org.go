@@ -0,0 +1,432 @@
+package hr
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"hcmnext/database"
+	"hcmnext/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// OrgNode is an employee's place in the organization on a given day
+type OrgNode struct {
+	EmployeeID string `json:"employeeId"`
+	Name       string `json:"name"`
+	Title      string `json:"title"`
+	Department string `json:"department"`
+	// ManagerID is the manager named on the employee's current job, if any
+	ManagerID string `json:"managerId,omitempty"`
+
+	Manager *OrgNode   `json:"-"`
+	Reports []*OrgNode `json:"-"`
+}
+
+// Org is the reporting hierarchy derived from the managers on every active employee's current job
+type Org struct {
+	AsOf  time.Time
+	nodes map[string]*OrgNode
+	order []string
+}
+
+// OrgIssue is a problem in the reporting lines
+type OrgIssue struct {
+	// Kind is "cycle" or "orphan"
+	Kind string `json:"kind"`
+	// EmployeeIDs lists the employees involved; for a cycle, in reporting order
+	EmployeeIDs []string `json:"employeeIds"`
+	Detail      string   `json:"detail"`
+}
+
+// Report is an employee reporting to another, directly (depth 1) or indirectly
+type Report struct {
+	*OrgNode
+	Depth int `json:"depth"`
+}
+
+// SpanOfControl summarizes the reports of one manager
+type SpanOfControl struct {
+	EmployeeID    string `json:"employeeId"`
+	Name          string `json:"name"`
+	DirectReports int    `json:"directReports"`
+	TotalReports  int    `json:"totalReports"`
+	// Layers is the number of management levels below the employee
+	Layers int `json:"layers"`
+}
+
+// OrgStats summarizes span of control across the organization
+type OrgStats struct {
+	AsOf            time.Time       `json:"asOf"`
+	Employees       int             `json:"employees"`
+	Managers        int             `json:"managers"`
+	Roots           []string        `json:"roots"`
+	MeanSpan        float64         `json:"meanSpan"`
+	MedianSpan      float64         `json:"medianSpan"`
+	MaxSpan         int             `json:"maxSpan"`
+	Layers          int             `json:"layers"`
+	WidestManagers  []SpanOfControl `json:"widestManagers"`
+	SingleReportMgr int             `json:"singleReportManagers"`
+}
+
+// OrgChartNode is a node of the JSON org chart export
+type OrgChartNode struct {
+	*OrgNode
+	Reports []*OrgChartNode `json:"reports,omitempty"`
+}
+
+// OrgService builds org hierarchies from the employee collection
+type OrgService struct {
+	db *database.Database
+}
+
+// NewOrgService creates an org service
+func NewOrgService(db *database.Database) *OrgService {
+	return &OrgService{db: db}
+}
+
+// Load builds the org as of the day of asOf
+func (s *OrgService) Load(ctx context.Context, asOf time.Time) (*Org, error) {
+	cursor, err := s.db.For(ctx).FindMany("Employee", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var employees []models.Employee
+	if err := cursor.All(ctx, &employees); err != nil {
+		return nil, err
+	}
+	return BuildOrg(employees, asOf), nil
+}
+
+// BuildOrg links every employee who is employed on asOf to the manager on their current job.
+// Managers that are missing or not employed on asOf leave the employee as an orphan.
+func BuildOrg(employees []models.Employee, asOf time.Time) *Org {
+	org := &Org{AsOf: asOf, nodes: make(map[string]*OrgNode, len(employees))}
+
+	for i := range employees {
+		emp := &employees[i]
+		job := employedJob(emp, asOf)
+		if job == nil {
+			continue
+		}
+
+		name := emp.FirstName
+		if emp.PreferredName != "" {
+			name = emp.PreferredName
+		}
+		node := &OrgNode{
+			EmployeeID: emp.EmployeeID,
+			Name:       name + " " + emp.LastName,
+			Title:      job.Title,
+			Department: job.Department,
+		}
+		if job.Manager != nil && job.Manager.EmployeeID != emp.EmployeeID {
+			node.ManagerID = job.Manager.EmployeeID
+		}
+		org.nodes[emp.EmployeeID] = node
+		org.order = append(org.order, emp.EmployeeID)
+	}
+	sort.Strings(org.order)
+
+	for _, id := range org.order {
+		node := org.nodes[id]
+		if manager, ok := org.nodes[node.ManagerID]; ok {
+			node.Manager = manager
+			manager.Reports = append(manager.Reports, node)
+		}
+	}
+	for _, node := range org.nodes {
+		sort.Slice(node.Reports, func(i, j int) bool { return node.Reports[i].Name < node.Reports[j].Name })
+	}
+
+	return org
+}
+
+// Node returns the employee's node, or nil when they are not in the org
+func (o *Org) Node(employeeID string) *OrgNode {
+	return o.nodes[employeeID]
+}
+
+// Find returns the employees whose id or name matches query, ignoring case
+func (o *Org) Find(query string) []*OrgNode {
+	query = strings.ToLower(strings.TrimSpace(query))
+	var found []*OrgNode
+	for _, id := range o.order {
+		node := o.nodes[id]
+		if strings.ToLower(node.EmployeeID) == query || strings.ToLower(node.Name) == query {
+			found = append(found, node)
+		}
+	}
+	return found
+}
+
+// Nodes returns every employee in the org ordered by id
+func (o *Org) Nodes() []*OrgNode {
+	nodes := make([]*OrgNode, len(o.order))
+	for i, id := range o.order {
+		nodes[i] = o.nodes[id]
+	}
+	return nodes
+}
+
+// Reports returns the employees below employeeID down to depth levels, breadth first.
+// A depth of 0 or less returns every level.
+func (o *Org) Reports(employeeID string, depth int) []Report {
+	root := o.nodes[employeeID]
+	if root == nil {
+		return nil
+	}
+
+	var reports []Report
+	seen := map[*OrgNode]bool{root: true}
+	level := []*OrgNode{root}
+	for d := 1; len(level) > 0 && (depth <= 0 || d <= depth); d++ {
+		var next []*OrgNode
+		for _, node := range level {
+			for _, report := range node.Reports {
+				// reporting cycles would otherwise loop forever
+				if seen[report] {
+					continue
+				}
+				seen[report] = true
+				reports = append(reports, Report{OrgNode: report, Depth: d})
+				next = append(next, report)
+			}
+		}
+		level = next
+	}
+	return reports
+}
+
+// ChainOfCommand returns the managers above employeeID, nearest first
+func (o *Org) ChainOfCommand(employeeID string) []*OrgNode {
+	node := o.nodes[employeeID]
+	if node == nil {
+		return nil
+	}
+
+	var chain []*OrgNode
+	seen := map[*OrgNode]bool{node: true}
+	for manager := node.Manager; manager != nil && !seen[manager]; manager = manager.Manager {
+		seen[manager] = true
+		chain = append(chain, manager)
+	}
+	return chain
+}
+
+// Span returns the span of control of employeeID
+func (o *Org) Span(employeeID string) SpanOfControl {
+	node := o.nodes[employeeID]
+	if node == nil {
+		return SpanOfControl{EmployeeID: employeeID}
+	}
+
+	reports := o.Reports(employeeID, 0)
+	span := SpanOfControl{
+		EmployeeID:    node.EmployeeID,
+		Name:          node.Name,
+		DirectReports: len(node.Reports),
+		TotalReports:  len(reports),
+	}
+	for _, report := range reports {
+		span.Layers = max(span.Layers, report.Depth)
+	}
+	return span
+}
+
+// Roots returns the employees without a manager in the org, including orphans
+func (o *Org) Roots() []*OrgNode {
+	var roots []*OrgNode
+	for _, id := range o.order {
+		if node := o.nodes[id]; node.Manager == nil {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
+
+// Stats summarizes span of control across the org
+func (o *Org) Stats() OrgStats {
+	stats := OrgStats{AsOf: o.AsOf, Employees: len(o.nodes), Roots: []string{}}
+
+	var spans []SpanOfControl
+	for _, id := range o.order {
+		node := o.nodes[id]
+		if node.Manager == nil {
+			stats.Roots = append(stats.Roots, node.EmployeeID)
+			stats.Layers = max(stats.Layers, o.Span(id).Layers+1)
+		}
+		if len(node.Reports) == 0 {
+			continue
+		}
+		span := o.Span(id)
+		spans = append(spans, span)
+		if span.DirectReports == 1 {
+			stats.SingleReportMgr++
+		}
+	}
+
+	stats.Managers = len(spans)
+	if len(spans) == 0 {
+		return stats
+	}
+
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].DirectReports != spans[j].DirectReports {
+			return spans[i].DirectReports > spans[j].DirectReports
+		}
+		return spans[i].EmployeeID < spans[j].EmployeeID
+	})
+
+	total := 0
+	for _, span := range spans {
+		total += span.DirectReports
+	}
+	stats.MeanSpan = float64(total) / float64(len(spans))
+	stats.MaxSpan = spans[0].DirectReports
+	if n := len(spans); n%2 == 1 {
+		stats.MedianSpan = float64(spans[n/2].DirectReports)
+	} else {
+		stats.MedianSpan = float64(spans[n/2-1].DirectReports+spans[n/2].DirectReports) / 2
+	}
+	stats.WidestManagers = spans[:min(len(spans), 10)]
+	return stats
+}
+
+// Issues reports reporting cycles and employees whose manager is not in the org
+func (o *Org) Issues() []OrgIssue {
+	issues := []OrgIssue{}
+
+	for _, id := range o.order {
+		node := o.nodes[id]
+		if node.ManagerID != "" && node.Manager == nil {
+			issues = append(issues, OrgIssue{
+				Kind:        "orphan",
+				EmployeeIDs: []string{node.EmployeeID},
+				Detail:      fmt.Sprintf("manager %s is not an active employee", node.ManagerID),
+			})
+		}
+	}
+
+	// walk up from every employee; a walk that returns to a node on the current path is a cycle
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make(map[*OrgNode]int, len(o.nodes))
+	for _, id := range o.order {
+		var path []*OrgNode
+		node := o.nodes[id]
+		for node != nil && state[node] == unvisited {
+			state[node] = onPath
+			path = append(path, node)
+			node = node.Manager
+		}
+		if node != nil && state[node] == onPath {
+			var cycle []string
+			for i := len(path) - 1; i >= 0; i-- {
+				cycle = append([]string{path[i].EmployeeID}, cycle...)
+				if path[i] == node {
+					break
+				}
+			}
+			issues = append(issues, OrgIssue{
+				Kind:        "cycle",
+				EmployeeIDs: cycle,
+				Detail:      strings.Join(cycle, " reports to ") + " reports to " + cycle[0],
+			})
+		}
+		for _, n := range path {
+			state[n] = done
+		}
+	}
+
+	return issues
+}
+
+// Chart returns the org chart below rootID down to depth levels, or below every root when
+// rootID is empty. Employees in a reporting cycle have a manager and so are no root; the
+// first employee of each cycle, as listed by Issues, is charted as an extra root so that the
+// chart covers everyone. A depth of 0 or less includes every level.
+func (o *Org) Chart(rootID string, depth int) []*OrgChartNode {
+	roots := o.Roots()
+	if rootID != "" {
+		roots = nil
+		if node := o.nodes[rootID]; node != nil {
+			roots = []*OrgNode{node}
+		}
+	} else {
+		for _, issue := range o.Issues() {
+			if issue.Kind == "cycle" {
+				roots = append(roots, o.nodes[issue.EmployeeIDs[0]])
+			}
+		}
+	}
+
+	chart := make([]*OrgChartNode, 0, len(roots))
+	seen := map[*OrgNode]bool{}
+	for _, root := range roots {
+		if !seen[root] {
+			chart = append(chart, chartNode(root, depth, seen))
+		}
+	}
+	return chart
+}
+
+func chartNode(node *OrgNode, depth int, seen map[*OrgNode]bool) *OrgChartNode {
+	seen[node] = true
+	chart := &OrgChartNode{OrgNode: node}
+	if depth == 1 {
+		return chart
+	}
+	for _, report := range node.Reports {
+		if !seen[report] {
+			chart.Reports = append(chart.Reports, chartNode(report, depth-1, seen))
+		}
+	}
+	return chart
+}
+
+// Mermaid renders the org chart below rootID as a Mermaid flowchart
+func (o *Org) Mermaid(rootID string, depth int) string {
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+
+	var write func(node *OrgChartNode)
+	write = func(node *OrgChartNode) {
+		fmt.Fprintf(&b, "    %s[\"%s<br/>%s\"]\n", mermaidID(node.EmployeeID), mermaidText(node.Name), mermaidText(node.Title))
+		for _, report := range node.Reports {
+			write(report)
+			fmt.Fprintf(&b, "    %s --> %s\n", mermaidID(node.EmployeeID), mermaidID(report.EmployeeID))
+		}
+	}
+	for _, root := range o.Chart(rootID, depth) {
+		write(root)
+	}
+	return b.String()
+}
+
+// mermaidID turns an employee id into a Mermaid node id, which may only hold word characters
+func mermaidID(employeeID string) string {
+	var b strings.Builder
+	b.WriteString("e_")
+	for _, r := range employeeID {
+		if r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			b.WriteRune(r)
+		} else {
+			fmt.Fprintf(&b, "_%x_", r)
+		}
+	}
+	return b.String()
+}
+
+// mermaidText escapes a label for use inside a quoted Mermaid node
+func mermaidText(text string) string {
+	return strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(text)
+}
@@ -0,0 +1,474 @@
+package hr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"hcmnext/database"
+	"hcmnext/events"
+	"hcmnext/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrSlotNotFound is returned when a position slot does not exist
+var ErrSlotNotFound = errors.New("position slot not found")
+
+// Vacancy summarizes the seats of one job
+type Vacancy struct {
+	JobID   string `json:"jobId"`
+	JobName string `json:"jobName"`
+	Target  int    `json:"targetHeadcount"`
+	Current int    `json:"currentHeadcount"`
+	Open    int    `json:"open"`
+	Frozen  int    `json:"frozen"`
+	// OpenSlots lists the open seats; jobs without slots report Target - Current as Open instead
+	OpenSlots []models.PositionSlot `json:"openSlots,omitempty"`
+}
+
+// HeadcountIssue is a job whose headcount figures disagree with its employees or slots
+type HeadcountIssue struct {
+	JobID string `json:"jobId"`
+	// Kind is one of currentHeadcount, unlistedEmployee, staleListing, slotOccupant, targetHeadcount or overfilled
+	Kind       string `json:"kind"`
+	EmployeeID string `json:"employeeId,omitempty"`
+	SlotID     string `json:"slotId,omitempty"`
+	Detail     string `json:"detail"`
+}
+
+// PositionService manages position slots and keeps job headcount in line with employees
+type PositionService struct {
+	db *database.Database
+}
+
+// NewPositionService creates a position service
+func NewPositionService(db *database.Database) *PositionService {
+	return &PositionService{db: db}
+}
+
+// Slots returns the slots of jobID
+func (s *PositionService) Slots(ctx context.Context, jobID string) ([]models.PositionSlot, error) {
+	return s.findSlots(ctx, bson.M{"jobId": jobID})
+}
+
+// OpenSlots adds count open slots for positionTitle to jobID and raises its target headcount to match
+func (s *PositionService) OpenSlots(ctx context.Context, jobID, positionTitle string, count int) ([]models.PositionSlot, error) {
+	if count <= 0 {
+		return nil, &ActionError{Action: "open positions", Reason: "count must be positive"}
+	}
+
+	var opened []models.PositionSlot
+	err := s.db.WithTransaction(ctx, func(tx *database.Tx) error {
+		opened = nil
+
+		var job models.Job
+		if err := tx.FindOne("Job", bson.M{"jobId": jobID}, &job); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return fmt.Errorf("%w: %s", ErrJobNotFound, jobID)
+			}
+			return err
+		}
+		if !hasPosition(job, positionTitle) {
+			return &ActionError{Action: "open positions", Reason: fmt.Sprintf("job %s has no position titled %q", jobID, positionTitle)}
+		}
+
+		existing, err := tx.CountDocuments("PositionSlot", bson.M{"jobId": jobID})
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		for i := 1; i <= count; i++ {
+			slot := models.PositionSlot{
+				SlotID:        fmt.Sprintf("%s-%03d", jobID, int(existing)+i),
+				JobID:         jobID,
+				PositionTitle: positionTitle,
+				Status:        models.SlotOpen,
+				OpenedDate:    now,
+			}
+			if _, err := tx.InsertOne("PositionSlot", slot); err != nil {
+				return err
+			}
+			opened = append(opened, slot)
+		}
+		if err := syncTarget(tx, jobID); err != nil {
+			return err
+		}
+		return recordJobUpdated(ctx, tx, jobID)
+	})
+	return opened, err
+}
+
+// Freeze stops an open slot from being filled and removes it from the target headcount
+func (s *PositionService) Freeze(ctx context.Context, slotID, reason string) (*models.PositionSlot, error) {
+	return s.setSlotStatus(ctx, slotID, "freeze", models.SlotOpen, bson.M{"status": models.SlotFrozen, "frozenReason": reason})
+}
+
+// Unfreeze reopens a frozen slot
+func (s *PositionService) Unfreeze(ctx context.Context, slotID string) (*models.PositionSlot, error) {
+	return s.setSlotStatus(ctx, slotID, "unfreeze", models.SlotFrozen, bson.M{"status": models.SlotOpen, "frozenReason": ""})
+}
+
+func (s *PositionService) setSlotStatus(ctx context.Context, slotID, action, from string, set bson.M) (*models.PositionSlot, error) {
+	var slot models.PositionSlot
+	err := s.db.WithTransaction(ctx, func(tx *database.Tx) error {
+		slot = models.PositionSlot{}
+		if err := tx.FindOne("PositionSlot", bson.M{"slotId": slotID}, &slot); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return ErrSlotNotFound
+			}
+			return err
+		}
+		if slot.Status != from {
+			return &ActionError{Action: action + " position", Reason: fmt.Sprintf("slot %s is %s", slotID, slot.Status)}
+		}
+
+		if _, err := tx.UpdateOne("PositionSlot", bson.M{"slotId": slotID}, bson.M{"$set": set}); err != nil {
+			return err
+		}
+		slot.Status = set["status"].(string)
+		slot.FrozenReason = set["frozenReason"].(string)
+		if err := syncTarget(tx, slot.JobID); err != nil {
+			return err
+		}
+		return recordJobUpdated(ctx, tx, slot.JobID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &slot, nil
+}
+
+// Vacancies reports open and frozen seats for every job, most open first
+func (s *PositionService) Vacancies(ctx context.Context) ([]Vacancy, error) {
+	jobs, err := s.findJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slots, err := s.findSlots(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	byJob := make(map[string][]models.PositionSlot)
+	for _, slot := range slots {
+		byJob[slot.JobID] = append(byJob[slot.JobID], slot)
+	}
+
+	vacancies := []Vacancy{}
+	for _, job := range jobs {
+		vacancy := Vacancy{
+			JobID:   job.JobID,
+			JobName: job.JobName,
+			Target:  job.Headcount.TargetHeadcount,
+			Current: job.Headcount.CurrentHeadcount,
+		}
+		if jobSlots, ok := byJob[job.JobID]; ok {
+			for _, slot := range jobSlots {
+				switch slot.Status {
+				case models.SlotOpen:
+					vacancy.Open++
+					vacancy.OpenSlots = append(vacancy.OpenSlots, slot)
+				case models.SlotFrozen:
+					vacancy.Frozen++
+				}
+			}
+		} else {
+			vacancy.Open = max(job.Headcount.TargetHeadcount-job.Headcount.CurrentHeadcount, 0)
+		}
+		if vacancy.Open > 0 || vacancy.Frozen > 0 {
+			vacancies = append(vacancies, vacancy)
+		}
+	}
+
+	sort.SliceStable(vacancies, func(i, j int) bool { return vacancies[i].Open > vacancies[j].Open })
+	return vacancies, nil
+}
+
+// Check compares every job's headcount figures and slots with the employees currently in the job
+func (s *PositionService) Check(ctx context.Context) ([]HeadcountIssue, error) {
+	jobs, err := s.findJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slots, err := s.findSlots(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	occupants, err := s.occupants(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	slotsByJob := make(map[string][]models.PositionSlot)
+	for _, slot := range slots {
+		slotsByJob[slot.JobID] = append(slotsByJob[slot.JobID], slot)
+	}
+
+	issues := []HeadcountIssue{}
+	for _, job := range jobs {
+		actual := occupants[job.JobID]
+
+		if job.Headcount.CurrentHeadcount != len(actual) {
+			issues = append(issues, HeadcountIssue{
+				JobID:  job.JobID,
+				Kind:   "currentHeadcount",
+				Detail: fmt.Sprintf("currentHeadcount is %d but %d employees hold the job", job.Headcount.CurrentHeadcount, len(actual)),
+			})
+		}
+
+		listed := make(map[string]bool, len(job.Headcount.PositionsFilled))
+		for _, filled := range job.Headcount.PositionsFilled {
+			listed[filled.EmployeeID] = true
+			if _, ok := actual[filled.EmployeeID]; !ok {
+				issues = append(issues, HeadcountIssue{
+					JobID:      job.JobID,
+					Kind:       "staleListing",
+					EmployeeID: filled.EmployeeID,
+					Detail:     fmt.Sprintf("%s is listed in positionsFilled but does not hold the job", filled.EmployeeName),
+				})
+			}
+		}
+		for _, id := range sortedKeys(actual) {
+			if !listed[id] {
+				issues = append(issues, HeadcountIssue{
+					JobID:      job.JobID,
+					Kind:       "unlistedEmployee",
+					EmployeeID: id,
+					Detail:     fmt.Sprintf("%s holds the job but is missing from positionsFilled", actual[id]),
+				})
+			}
+		}
+
+		if jobSlots, ok := slotsByJob[job.JobID]; ok {
+			active := 0
+			for _, slot := range jobSlots {
+				if slot.Status != models.SlotFrozen {
+					active++
+				}
+				if slot.Status != models.SlotFilled {
+					continue
+				}
+				if _, ok := actual[slot.EmployeeID]; !ok {
+					issues = append(issues, HeadcountIssue{
+						JobID:      job.JobID,
+						Kind:       "slotOccupant",
+						SlotID:     slot.SlotID,
+						EmployeeID: slot.EmployeeID,
+						Detail:     fmt.Sprintf("slot %s is filled by %s who does not hold the job", slot.SlotID, slot.EmployeeID),
+					})
+				}
+			}
+			if job.Headcount.TargetHeadcount != active {
+				issues = append(issues, HeadcountIssue{
+					JobID:  job.JobID,
+					Kind:   "targetHeadcount",
+					Detail: fmt.Sprintf("targetHeadcount is %d but the job has %d open or filled slots", job.Headcount.TargetHeadcount, active),
+				})
+			}
+		}
+
+		if len(actual) > job.Headcount.TargetHeadcount {
+			issues = append(issues, HeadcountIssue{
+				JobID:  job.JobID,
+				Kind:   "overfilled",
+				Detail: fmt.Sprintf("%d employees hold the job against a target of %d", len(actual), job.Headcount.TargetHeadcount),
+			})
+		}
+	}
+	return issues, nil
+}
+
+// Recalculate rewrites positionsFilled and currentHeadcount of jobID from the employees currently in the job
+func (s *PositionService) Recalculate(ctx context.Context, jobID string) (*models.Job, error) {
+	occupants, err := s.occupants(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var job models.Job
+	err = s.db.WithTransaction(ctx, func(tx *database.Tx) error {
+		job = models.Job{}
+		if err := tx.FindOne("Job", bson.M{"jobId": jobID}, &job); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return fmt.Errorf("%w: %s", ErrJobNotFound, jobID)
+			}
+			return err
+		}
+
+		titles := make(map[string]string, len(job.Headcount.PositionsFilled))
+		for _, filled := range job.Headcount.PositionsFilled {
+			titles[filled.EmployeeID] = filled.PositionTitle
+		}
+
+		actual := occupants[jobID]
+		filled := make([]models.PositionFilled, 0, len(actual))
+		for _, id := range sortedKeys(actual) {
+			filled = append(filled, models.PositionFilled{PositionTitle: titles[id], EmployeeID: id, EmployeeName: actual[id]})
+		}
+		job.Headcount.PositionsFilled = filled
+		job.Headcount.CurrentHeadcount = len(filled)
+
+		if _, err := tx.UpdateOne("Job", bson.M{"jobId": jobID}, bson.M{"$set": bson.M{
+			"headcount.positionsFilled":  filled,
+			"headcount.currentHeadcount": len(filled),
+		}}); err != nil {
+			return err
+		}
+		event, err := events.JobUpdated(ctx, &job)
+		if err != nil {
+			return err
+		}
+		return events.Record(tx, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// occupants maps every job id to the employees (id to name) holding it on asOf
+func (s *PositionService) occupants(ctx context.Context, asOf time.Time) (map[string]map[string]string, error) {
+	cursor, err := s.db.For(ctx).FindMany("Employee", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	occupants := make(map[string]map[string]string)
+	for cursor.Next(ctx) {
+		var emp models.Employee
+		if err := cursor.Decode(&emp); err != nil {
+			return nil, err
+		}
+		job := employedJob(&emp, asOf)
+		if job == nil {
+			continue
+		}
+		if occupants[job.JobID] == nil {
+			occupants[job.JobID] = make(map[string]string)
+		}
+		occupants[job.JobID][emp.EmployeeID] = emp.FirstName + " " + emp.LastName
+	}
+	return occupants, cursor.Err()
+}
+
+func (s *PositionService) findJobs(ctx context.Context) ([]models.Job, error) {
+	cursor, err := s.db.For(ctx).FindMany("Job", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var jobs []models.Job
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (s *PositionService) findSlots(ctx context.Context, filter bson.M) ([]models.PositionSlot, error) {
+	cursor, err := s.db.For(ctx).FindMany("PositionSlot", filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	slots := []models.PositionSlot{}
+	if err := cursor.All(ctx, &slots); err != nil {
+		return nil, err
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].SlotID < slots[j].SlotID })
+	return slots, nil
+}
+
+// employedJob returns the employee's job on asOf, or nil when they are not employed that day
+func employedJob(emp *models.Employee, asOf time.Time) *models.JobHistory {
+	if status := emp.StatusAsOf(asOf); status != nil && (status.Status == "Terminated" || status.Status == "Retired") {
+		return nil
+	}
+	return emp.JobAsOf(asOf)
+}
+
+func hasPosition(job models.Job, title string) bool {
+	for _, position := range job.Positions {
+		if position.Title == title {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// claimSlot marks an open slot of job as filled by the employee, preferring one for the same title.
+// Jobs that have no slots at all are not managed by position and need none.
+func claimSlot(tx *database.Tx, emp *models.Employee, job models.JobHistory) error {
+	total, err := tx.CountDocuments("PositionSlot", bson.M{"jobId": job.JobID})
+	if err != nil || total == 0 {
+		return err
+	}
+
+	var slot models.PositionSlot
+	err = tx.FindOne("PositionSlot", bson.M{"jobId": job.JobID, "status": models.SlotOpen, "positionTitle": job.Title}, &slot)
+	if err == mongo.ErrNoDocuments {
+		err = tx.FindOne("PositionSlot", bson.M{"jobId": job.JobID, "status": models.SlotOpen}, &slot)
+	}
+	if err == mongo.ErrNoDocuments {
+		return &ActionError{Action: "fill position", Reason: fmt.Sprintf("job %s has no open position", job.JobID)}
+	}
+	if err != nil {
+		return err
+	}
+
+	filled := job.StartDate
+	_, err = tx.UpdateOne("PositionSlot", bson.M{"slotId": slot.SlotID}, bson.M{"$set": bson.M{
+		"status":       models.SlotFilled,
+		"employeeId":   emp.EmployeeID,
+		"employeeName": emp.FirstName + " " + emp.LastName,
+		"filledDate":   filled,
+	}})
+	return err
+}
+
+// releaseSlot reopens the slot of job held by the employee, if any
+func releaseSlot(tx *database.Tx, emp *models.Employee, job models.JobHistory) error {
+	_, err := tx.UpdateOne("PositionSlot", bson.M{"jobId": job.JobID, "employeeId": emp.EmployeeID, "status": models.SlotFilled}, bson.M{
+		"$set":   bson.M{"status": models.SlotOpen, "openedDate": time.Now().UTC()},
+		"$unset": bson.M{"employeeId": "", "employeeName": "", "filledDate": ""},
+	})
+	return err
+}
+
+// syncHeadcount sets currentHeadcount of jobID to the number of positions filled
+func syncHeadcount(tx *database.Tx, jobID string) error {
+	var job models.Job
+	if err := tx.FindOne("Job", bson.M{"jobId": jobID}, &job); err != nil {
+		return err
+	}
+	_, err := tx.UpdateOne("Job", bson.M{"jobId": jobID}, bson.M{"$set": bson.M{
+		"headcount.currentHeadcount": len(job.Headcount.PositionsFilled),
+	}})
+	return err
+}
+
+// syncTarget sets targetHeadcount of jobID to the number of its slots that are not frozen
+func syncTarget(tx *database.Tx, jobID string) error {
+	active, err := tx.CountDocuments("PositionSlot", bson.M{"jobId": jobID, "status": bson.M{"$ne": models.SlotFrozen}})
+	if err != nil {
+		return err
+	}
+	_, err = tx.UpdateOne("Job", bson.M{"jobId": jobID}, bson.M{"$set": bson.M{
+		"headcount.targetHeadcount": int(active),
+	}})
+	return err
+}
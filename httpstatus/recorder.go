@@ -0,0 +1,44 @@
+// Package httpstatus records the status code of HTTP responses for middleware that reports it.
+package httpstatus
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// Recorder is a http.ResponseWriter that remembers the status code written through it. It passes
+// flushes and connection hijacks through, so server-sent events and WebSockets keep working.
+type Recorder struct {
+	http.ResponseWriter
+	// Status is the status code written, 200 if none was written explicitly
+	Status int
+}
+
+// NewRecorder wraps w
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (rec *Recorder) WriteHeader(status int) {
+	rec.Status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets streaming handlers such as the change feed flush through the recorder
+func (rec *Recorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets the chat WebSocket take over the connection
+func (rec *Recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	rec.Status = http.StatusSwitchingProtocols
+	return http.NewResponseController(rec.ResponseWriter).Hijack()
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer
+func (rec *Recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
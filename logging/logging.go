@@ -0,0 +1,176 @@
+// Package logging writes structured JSON logs through log/slog.
+//
+// Every package logs through its own logger from For, so levels can be set per package.
+// Request-scoped fields such as the request id, tenant, user, conversation and tool step are
+// attached to the context with With and added to every record logged with that context, as are
+// the ids of the OpenTelemetry trace and span the context belongs to.
+// Struct values are logged with the fields tagged pii in models redacted.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Keys of the request-scoped fields
+const (
+	KeyRequestID    = "request_id"
+	KeyTenant       = "tenant"
+	KeyUser         = "user"
+	KeyConversation = "conversation"
+	KeyStep         = "step"
+	KeyTraceID      = "trace_id"
+	KeySpanID       = "span_id"
+)
+
+var (
+	// root writes every record; loggers from For filter by package before it
+	root slog.Handler = newJSONHandler(os.Stderr)
+
+	mu       sync.RWMutex
+	level    = new(slog.LevelVar)
+	packages = make(map[string]slog.Level)
+)
+
+func newJSONHandler(w io.Writer) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{
+		// levels are checked per package by handler.Enabled
+		Level:       slog.Level(-8),
+		ReplaceAttr: redactAttr,
+	})
+}
+
+// Setup makes the JSON handler the default for slog and the standard library's log package,
+// logging as package "main", and applies the levels, see SetLevels
+func Setup(defaultLevel string, packageLevels []string) error {
+	if err := SetLevels(defaultLevel, packageLevels); err != nil {
+		return err
+	}
+	slog.SetDefault(For("main"))
+	return nil
+}
+
+// SetLevels sets the minimum level of every package to defaultLevel, except those listed in
+// packageLevels as package=level, e.g. "ai=debug". Levels are debug, info, warn or error.
+func SetLevels(defaultLevel string, packageLevels []string) error {
+	def, overrides, err := ParseLevels(defaultLevel, packageLevels)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	level.Set(def)
+	packages = overrides
+	return nil
+}
+
+// ParseLevels parses the arguments of SetLevels
+func ParseLevels(defaultLevel string, packageLevels []string) (slog.Level, map[string]slog.Level, error) {
+	var def slog.Level
+	if defaultLevel != "" {
+		if err := def.UnmarshalText([]byte(defaultLevel)); err != nil {
+			return 0, nil, err
+		}
+	}
+	overrides := make(map[string]slog.Level, len(packageLevels))
+	for _, entry := range packageLevels {
+		pkg, name, ok := strings.Cut(entry, "=")
+		if !ok || pkg == "" {
+			return 0, nil, fmt.Errorf("invalid package level %q, expected package=level", entry)
+		}
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(name)); err != nil {
+			return 0, nil, fmt.Errorf("package %s: %w", pkg, err)
+		}
+		overrides[pkg] = l
+	}
+	return def, overrides, nil
+}
+
+// levelOf returns the minimum level logged for pkg
+func levelOf(pkg string) slog.Level {
+	mu.RLock()
+	defer mu.RUnlock()
+	if l, ok := packages[pkg]; ok {
+		return l
+	}
+	return level.Level()
+}
+
+// For returns the logger of package pkg; records carry pkg as the "pkg" field
+func For(pkg string) *slog.Logger {
+	return slog.New(&handler{pkg: pkg, next: root.WithAttrs([]slog.Attr{slog.String("pkg", pkg)})})
+}
+
+// handler filters records by the level of its package and adds the request-scoped fields
+type handler struct {
+	pkg  string
+	next slog.Handler
+}
+
+func (h *handler) Enabled(ctx context.Context, l slog.Level) bool {
+	return l >= levelOf(h.pkg)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	fields := fromContext(ctx)
+	// records logged inside a traced operation can be found from the trace and back
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		fields = append(fields[:len(fields):len(fields)],
+			slog.String(KeyTraceID, span.TraceID().String()),
+			slog.String(KeySpanID, span.SpanID().String()))
+	}
+	if len(fields) > 0 {
+		r = r.Clone()
+		r.AddAttrs(fields...)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{pkg: h.pkg, next: h.next.WithAttrs(attrs)}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{pkg: h.pkg, next: h.next.WithGroup(name)}
+}
+
+type contextKey struct{}
+
+// With returns a copy of ctx whose log records carry attrs; a later field replaces an earlier
+// one with the same key
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	current := fromContext(ctx)
+	fields := make([]slog.Attr, 0, len(current)+len(attrs))
+	for _, f := range current {
+		if !hasKey(attrs, f.Key) {
+			fields = append(fields, f)
+		}
+	}
+	fields = append(fields, attrs...)
+	return context.WithValue(ctx, contextKey{}, fields)
+}
+
+func fromContext(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	return fields
+}
+
+func hasKey(attrs []slog.Attr, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
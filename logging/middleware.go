@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"hcmnext/httpstatus"
+)
+
+// requestIDPattern limits the request ids accepted from clients and proxies
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+var httpLogger = For("http")
+
+// probePaths are polled by Kubernetes; they are only logged at debug level unless they fail
+var probePaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+// Middleware gives every request an id, taken from a valid X-Request-ID header or generated,
+// returns it in the X-Request-ID response header, attaches it to the request's context and logs
+// the request once it is served
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		ctx := With(r.Context(), slog.String(KeyRequestID, id))
+
+		start := time.Now()
+		rec := httpstatus.NewRecorder(w)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		l := slog.LevelInfo
+		switch {
+		case rec.Status >= http.StatusInternalServerError:
+			l = slog.LevelError
+		case probePaths[r.URL.Path]:
+			// probes come every few seconds
+			l = slog.LevelDebug
+		}
+		httpLogger.Log(ctx, l, "Request served",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.Status,
+			"duration_ms", time.Since(start).Milliseconds())
+	})
+}
+
+func newRequestID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
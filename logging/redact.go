@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"encoding"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// redacted stands in for a field tagged pii
+const redacted = "[REDACTED]"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// redactAttr replaces struct values, including those in slices and maps, with JSON-shaped copies
+// in which every field tagged pii is redacted
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() != slog.KindAny {
+		return a
+	}
+	switch v := a.Value.Any().(type) {
+	case error, fmt.Stringer, encoding.TextMarshaler:
+		return a
+	default:
+		rv := reflect.ValueOf(v)
+		if !rv.IsValid() || !containsStruct(rv.Type()) {
+			return a
+		}
+		a.Value = slog.AnyValue(redactValue(rv))
+		return a
+	}
+}
+
+// containsStruct reports whether values of t may hold struct fields
+func containsStruct(t reflect.Type) bool {
+	for {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array:
+			t = t.Elem()
+		case reflect.Map:
+			t = t.Elem()
+		case reflect.Interface:
+			return true
+		case reflect.Struct:
+			return t != timeType
+		default:
+			return false
+		}
+	}
+}
+
+func redactValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem())
+
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface()
+		}
+		out := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if field.Tag.Get("pii") != "" {
+				if !v.Field(i).IsZero() {
+					out[name] = redacted
+				}
+				continue
+			}
+			out[name] = redactValue(v.Field(i))
+		}
+		return out
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = redactValue(v.Index(i))
+		}
+		return out
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = redactValue(iter.Value())
+		}
+		return out
+
+	default:
+		return v.Interface()
+	}
+}
@@ -1,127 +1,170 @@
+// Command hcmnext runs the HCM server and the operational tasks around it:
+//
+//	hcmnext serve               run the server (the default)
+//	hcmnext migrate             apply collection validators and indexes
+//	hcmnext seed FILE...        load NDJSON fixtures
+//	hcmnext generate            generate a synthetic company
+//	hcmnext import FILE         import an employee spreadsheet
+//	hcmnext export employees    export employees or jobs
+//	hcmnext reindex             rebuild the vector store
+//	hcmnext audit verify        check records, reporting lines and headcount
+//	hcmnext ask PROMPT          answer one chat prompt
+//	hcmnext eval                compare model or prompt configurations
+//
+// Every command reads the configuration from the same file, environment and flags as the server;
+// run hcmnext COMMAND -h for its flags.
 package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"net/http"
+	"log/slog"
 	"os"
-	"os/signal"
-	"path/filepath"
-	"syscall"
-	"time"
+	"strings"
 
 	"hcmnext/ai"
+	"hcmnext/auth"
+	"hcmnext/config"
 	"hcmnext/database"
-	"hcmnext/controller"
-	"hcmnext/router"
-
-	"github.com/joho/godotenv"
-	"go.mongodb.org/mongo-driver/bson"
+	"hcmnext/logging"
 )
 
-func init() {
-	// Load environment variables from .env file
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
-	}
+// command is a subcommand of hcmnext
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+var commands = []command{
+	{"serve", "run the server (the default)", serve},
+	{"migrate", "apply collection validators and indexes", migrate},
+	{"seed", "load NDJSON fixtures", seed},
+	{"generate", "generate a synthetic company into the database or NDJSON fixtures", generate},
+	{"import", "import an employee spreadsheet", importEmployees},
+	{"export", "export employees or jobs", export},
+	{"reindex", "rebuild the vector store", reindex},
+	{"audit", "audit verify: check records, reporting lines and headcount", audit},
+	{"ask", "answer one chat prompt through the assistant", ask},
+	{"eval", "compare model or prompt configurations on a dataset", evaluate},
 }
 
 func main() {
-	// Initialize AI client
-	aiClient, err := ai.NewClient()
-	if err != nil {
-		log.Fatalf("Failed to initialize AI client: %v", err)
-	}
-	fmt.Println("AI client initialized")
+	args := os.Args[1:]
 
-	// Database initialization
-	mongoURI := os.Getenv("MONGO_URI")
-	if mongoURI == "" {
-		log.Fatal("MONGO_URI not set in environment variables")
+	// flags without a command run the server, as before there were commands
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
-	dbName := os.Getenv("DB_NAME")
-	if dbName == "" {
-		log.Fatal("DB_NAME not set in environment variables")
-	}
-
-	db, err := database.NewDatabase(mongoURI, dbName)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+	if name == "help" {
+		fmt.Print(usage())
+		return
 	}
-	defer db.Close()
-
-	fmt.Println("Connected to MongoDB")
-
-	// Check for collections and count their contents
-	collections := []string{"Employee", "Job"}
-	for _, collName := range collections {
-		count, err := db.CountDocuments(collName, bson.M{})
-		if err != nil {
-			log.Printf("Error counting documents in %s collection: %v", collName, err)
-		} else {
-			fmt.Printf("Collection %s exists and contains %d documents\n", collName, count)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			cmd.run(args)
+			return
 		}
 	}
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", name, usage())
+	os.Exit(2)
+}
 
-	// Initialize the controller
-	ctrl := controller.NewController(aiClient, db)
+// usage lists the commands
+func usage() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Usage: %s COMMAND [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(&b, "\nRun %s COMMAND -h for the flags of a command.\n", os.Args[0])
+	return b.String()
+}
 
-	// Initialize the home controller
-	staticDir := filepath.Join(".", "static")
-	homeCtrl := controller.NewHomeController(staticDir)
+// loadConfig loads the configuration for cmd and sets up logging, exiting on problems or after
+// printing the usage when asked for help
+func loadConfig(cmd config.Command, args []string) *config.Config {
+	cfg, err := config.LoadCommand(cmd, args)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Printf("Usage of %s %s:\n%s", os.Args[0], cmd.Name, config.UsageFor(cmd))
+		os.Exit(0)
+	}
+	var invalid *config.Error
+	if errors.As(err, &invalid) {
+		log.Fatalf("Invalid configuration:\n%s", invalid.Error())
+	}
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
 
-	// Initialize the Employee API
-	employeeAPI := controller.NewAPI(db)
+	// Log as JSON from here on, at the configured levels
+	if err := logging.Setup(cfg.Log.Level, cfg.Log.Packages); err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	return cfg
+}
 
-	// test fn calling
-	testCtrl := controller.NewTestController(aiClient)
+// newAIClient creates the AI client from the configuration
+func newAIClient(cfg *config.Config) *ai.Client {
+	aiClient, err := ai.NewClientFromConfig(ai.Config{
+		APIKey:    cfg.AI.APIKey,
+		BaseURL:   cfg.AI.BaseURL,
+		Model:     cfg.AI.Model,
+		PromptDir: cfg.AI.PromptDir,
+	})
+	if err != nil {
+		fatal("Failed to initialize AI client", err)
+	}
+	slog.Info("AI client initialized", "model", aiClient.Model())
+	return aiClient
+}
 
-	// Initialize the router with all controllers
-	r := router.NewRouter(ctrl, homeCtrl, employeeAPI, testCtrl)
+// openDatabase connects to MongoDB. When tenants are isolated every tenant has a database of
+// its own, named after the database name and the tenant id.
+func openDatabase(cfg *config.Config) *database.Database {
+	db, err := database.NewDatabase(cfg.Database.URI, cfg.Database.Name)
+	if err != nil {
+		fatal("Failed to connect to database", err)
+	}
+	slog.Info("Connected to MongoDB", "database", cfg.Database.Name)
 
-	// Set up the routes
-	r.SetupRoutes()
+	db.SetMultiTenant(cfg.Tenancy.MultiTenant)
+	return db
+}
 
-	// Create a new server
-	srv := &http.Server{
-		Addr:    ":8080",
-		Handler: nil, // Use default ServeMux
-	}
+// tenantsFlag declares -tenant, which picks the tenants a command works on
+func tenantsFlag(flags *flag.FlagSet, target *string) {
+	flags.StringVar(target, "tenant", "", "tenant to work on when tenants are isolated; all tenants when empty, where the command allows it")
+}
 
-	// Channel to listen for errors coming from the listener.
-	serverErrors := make(chan error, 1)
-
-	// Start the server
-	go func() {
-		fmt.Println("WebSocket AI server and Employee API starting on :8080")
-		serverErrors <- srv.ListenAndServe()
-	}()
-
-	// Channel to listen for an interrupt or terminate signal from the OS.
-	shutdown := make(chan os.Signal, 1)
-	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
-
-	// Blocking main and waiting for shutdown.
-	select {
-	case err := <-serverErrors:
-		log.Fatalf("Error starting server: %v", err)
-
-	case <-shutdown:
-		fmt.Println("Starting shutdown...")
-
-		// Give outstanding requests a deadline for completion.
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
-		// Asking listener to shut down and shed load.
-		if err := srv.Shutdown(ctx); err != nil {
-			log.Fatalf("Graceful shutdown did not complete in 5s: %v", err)
-			if err := srv.Close(); err != nil {
-				log.Fatalf("Error killing server: %v", err)
-			}
+// tenantIDs returns the tenant named by -tenant, or every tenant
+func tenantIDs(db *database.Database, tenant string) []string {
+	if tenant != "" {
+		if !db.MultiTenant() {
+			fatal("Invalid -tenant", errors.New("tenants are not isolated in this deployment"))
 		}
+		return []string{tenant}
+	}
+	ids, err := db.Tenants(context.Background())
+	if err != nil {
+		fatal("Failed to list tenants", err)
 	}
+	return ids
+}
+
+// operatorContext is the context commands work in for tenant; the events they cause name the
+// command line as their actor
+func operatorContext(tenant string, roles ...string) context.Context {
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{Subject: "cli", Roles: roles})
+	return database.WithTenant(ctx, tenant)
+}
 
-	fmt.Println("Server gracefully stopped")
-}
\ No newline at end of file
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
@@ -0,0 +1,134 @@
+// Package metrics exposes Prometheus metrics for HTTP requests, chat WebSocket connections,
+// MongoDB operations and LLM calls at /metrics.
+//
+// Labels are kept to bounded sets: route patterns rather than paths, collection and command
+// names, and tool and model names. Tenants are not a label.
+package metrics
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "hcm"
+
+// registry holds every metric of the server, plus the Go runtime and process collectors
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests served, by route pattern, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time to serve HTTP requests, by route pattern and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	websocketConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "connections",
+		Help:      "Open chat WebSocket connections.",
+	})
+
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "mongo",
+		Name:      "operation_duration_seconds",
+		Help:      "Time of MongoDB commands, by collection and command.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"collection", "operation"})
+
+	dbErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "mongo",
+		Name:      "operation_errors_total",
+		Help:      "Failed MongoDB commands, by collection and command.",
+	}, []string{"collection", "operation"})
+
+	llmDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "call_duration_seconds",
+		Help:      "Time of LLM calls, by tool and model.",
+		Buckets:   []float64{.25, .5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{"tool", "model"})
+
+	llmTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "tokens_total",
+		Help:      "Tokens used by LLM calls, by tool, model and kind (prompt or completion).",
+	}, []string{"tool", "model", "kind"})
+
+	llmFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "call_failures_total",
+		Help:      "Failed LLM calls, by tool and model.",
+	}, []string{"tool", "model"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		websocketConnections,
+		dbDuration, dbErrors,
+		llmDuration, llmTokens, llmFailures,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// openWebSockets mirrors websocketConnections for OpenWebSockets, as gauges cannot be read back
+var openWebSockets atomic.Int64
+
+// WebSocketOpened counts an open chat WebSocket connection; call the returned function when it closes
+func WebSocketOpened() (closed func()) {
+	websocketConnections.Inc()
+	openWebSockets.Add(1)
+	return func() {
+		websocketConnections.Dec()
+		openWebSockets.Add(-1)
+	}
+}
+
+// OpenWebSockets returns the number of open chat WebSocket connections
+func OpenWebSockets() int64 {
+	return openWebSockets.Load()
+}
+
+// ObserveDatabase records a MongoDB command on collection, "" for commands on no collection
+func ObserveDatabase(collection, operation string, duration time.Duration, failed bool) {
+	dbDuration.WithLabelValues(collection, operation).Observe(duration.Seconds())
+	if failed {
+		dbErrors.WithLabelValues(collection, operation).Inc()
+	}
+}
+
+// ObserveLLM records an LLM call made for tool
+func ObserveLLM(tool, model string, duration time.Duration, promptTokens, completionTokens int, failed bool) {
+	llmDuration.WithLabelValues(tool, model).Observe(duration.Seconds())
+	llmTokens.WithLabelValues(tool, model, "prompt").Add(float64(promptTokens))
+	llmTokens.WithLabelValues(tool, model, "completion").Add(float64(completionTokens))
+	if failed {
+		llmFailures.WithLabelValues(tool, model).Inc()
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+
+	"hcmnext/config"
+	"hcmnext/database"
+)
+
+// migrate applies the collection validators and creates the indexes in every database
+func migrate(args []string) {
+	var validationAction string
+	cfg := loadConfig(config.Command{Name: "migrate", Database: true, Flags: func(flags *flag.FlagSet) {
+		flags.StringVar(&validationAction, "validation-action", database.ValidationError, "what the validators do with invalid writes: error rejects them, warn only logs them")
+	}}, args)
+
+	db := openDatabase(cfg)
+	defer db.Close()
+
+	if err := db.Migrate(context.Background(), validationAction); err != nil {
+		db.Close()
+		fatal("Failed to migrate database", err)
+	}
+	slog.Info("Database migrated", "validationAction", validationAction)
+}
@@ -4,7 +4,8 @@ import (
 	"time"
 )
 
-// Employee represents the structure of an employee record
+// Employee represents the structure of an employee record.
+// Fields tagged with pii hold personal data; the tag value names the kind of data.
 type Employee struct {
 	EmployeeID           string                `bson:"employeeId" json:"employeeId"`
 	PreferredName        string                `bson:"preferredName,omitempty" json:"preferredName,omitempty"`
@@ -12,9 +13,9 @@ type Employee struct {
 	MiddleName           string                `bson:"middleName,omitempty" json:"middleName,omitempty"`
 	LastName             string                `bson:"lastName" json:"lastName"`
 	Suffix               string                `bson:"suffix,omitempty" json:"suffix,omitempty"`
-	Email                string                `bson:"email" json:"email"`
-	Phone                string                `bson:"phone,omitempty" json:"phone,omitempty"`
-	SocialSecurityNumber string                `bson:"socialSecurityNumber" json:"socialSecurityNumber"`
+	Email                string                `bson:"email" json:"email" pii:"email"`
+	Phone                string                `bson:"phone,omitempty" json:"phone,omitempty" pii:"phone"`
+	SocialSecurityNumber string                `bson:"socialSecurityNumber" json:"socialSecurityNumber" pii:"ssn"`
 	PersonalDetails      PersonalDetails       `bson:"personalDetails" json:"personalDetails"`
 	JobHistory           []JobHistory          `bson:"jobHistory" json:"jobHistory"`
 	StatusHistory        []StatusHistory       `bson:"statusHistory" json:"statusHistory"`
@@ -23,12 +24,12 @@ type Employee struct {
 
 type PersonalDetails struct {
 	PreferredGender   string             `bson:"preferredGender,omitempty" json:"preferredGender,omitempty"`
-	DateOfBirth       time.Time          `bson:"dateOfBirth" json:"dateOfBirth"`
+	DateOfBirth       time.Time          `bson:"dateOfBirth" json:"dateOfBirth" pii:"dob"`
 	Gender            string             `bson:"gender" json:"gender"`
 	MaritalStatus     string             `bson:"maritalStatus" json:"maritalStatus"`
 	Nationality       string             `bson:"nationality,omitempty" json:"nationality,omitempty"`
-	PlaceOfBirth      string             `bson:"placeOfBirth,omitempty" json:"placeOfBirth,omitempty"`
-	Address           Address            `bson:"address" json:"address"`
+	PlaceOfBirth      string             `bson:"placeOfBirth,omitempty" json:"placeOfBirth,omitempty" pii:"birthplace"`
+	Address           Address            `bson:"address" json:"address" pii:"address"`
 	EmergencyContacts []EmergencyContact `bson:"emergencyContacts" json:"emergencyContacts"`
 }
 
@@ -43,9 +44,9 @@ type Address struct {
 type EmergencyContact struct {
 	Name     string   `bson:"name" json:"name"`
 	Relation string   `bson:"relation" json:"relation"`
-	Phone    string   `bson:"phone" json:"phone"`
-	Email    string   `bson:"email,omitempty" json:"email,omitempty"`
-	Address  *Address `bson:"address,omitempty" json:"address,omitempty"`
+	Phone    string   `bson:"phone" json:"phone" pii:"phone"`
+	Email    string   `bson:"email,omitempty" json:"email,omitempty" pii:"email"`
+	Address  *Address `bson:"address,omitempty" json:"address,omitempty" pii:"address"`
 }
 
 type JobHistory struct {
@@ -63,7 +64,7 @@ type JobHistory struct {
 type Manager struct {
 	Name       string `bson:"name,omitempty" json:"name,omitempty"`
 	EmployeeID string `bson:"employeeId,omitempty" json:"employeeId,omitempty"`
-	Email      string `bson:"email,omitempty" json:"email,omitempty"`
+	Email      string `bson:"email,omitempty" json:"email,omitempty" pii:"email"`
 }
 
 type StatusHistory struct {
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The patterns and allowed values below mirror EmployeSchema in the database package
+var (
+	emailPattern = regexp.MustCompile(`^.+@.+\..+$`)
+	phonePattern = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
+	ssnPattern   = regexp.MustCompile(`^(\d{3}-\d{2}-\d{4})$`)
+
+	genders         = []string{"Male", "Female", "Other"}
+	preferredGender = []string{"He/Him", "She/Her", "They/Them", "Other"}
+	maritalStatuses = []string{"Single", "Married", "Divorced", "Widowed"}
+	employmentTypes = []string{"Full-time", "Part-time", "Contract", "Temporary"}
+	statuses        = []string{"Active", "Leave of Absence", "Terminated", "Retired", "Remote Work"}
+	currencies      = []string{"USD", "EUR", "GBP", "JPY", "AUD", "CAD"}
+	payFrequencies  = []string{"Weekly", "Bi-weekly", "Monthly", "Annually"}
+)
+
+// ValidationError lists every field of a record that breaks the schema
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid record: " + strings.Join(e.Messages(), "; ")
+}
+
+// Messages returns the message of every problem
+func (e *ValidationError) Messages() []string {
+	return messages(e.Problems)
+}
+
+// Validate checks the employee against the rules of the employee schema and the history rules
+// of ValidateHistory, returning a *ValidationError describing every problem found.
+func (e *Employee) Validate() error {
+	v := &validator{}
+
+	v.required("employeeId", e.EmployeeID)
+	v.required("firstName", e.FirstName)
+	v.required("lastName", e.LastName)
+	v.required("email", e.Email)
+	v.pattern("email", e.Email, emailPattern, "a valid email address")
+	v.pattern("phone", e.Phone, phonePattern, "an E.164 phone number")
+	v.required("socialSecurityNumber", e.SocialSecurityNumber)
+	v.pattern("socialSecurityNumber", e.SocialSecurityNumber, ssnPattern, "formatted XXX-XX-XXXX")
+
+	pd := e.PersonalDetails
+	if pd.DateOfBirth.IsZero() {
+		v.add("personalDetails.dateOfBirth", "is required")
+	}
+	v.required("personalDetails.gender", pd.Gender)
+	v.oneOf("personalDetails.gender", pd.Gender, genders)
+	v.oneOf("personalDetails.preferredGender", pd.PreferredGender, preferredGender)
+	v.required("personalDetails.maritalStatus", pd.MaritalStatus)
+	v.oneOf("personalDetails.maritalStatus", pd.MaritalStatus, maritalStatuses)
+	v.required("personalDetails.address.street", pd.Address.Street)
+	v.required("personalDetails.address.city", pd.Address.City)
+	v.required("personalDetails.address.state", pd.Address.State)
+	v.required("personalDetails.address.zipCode", pd.Address.ZipCode)
+	v.required("personalDetails.address.country", pd.Address.Country)
+	for i, contact := range pd.EmergencyContacts {
+		field := fmt.Sprintf("personalDetails.emergencyContacts.%d", i)
+		v.required(field+".name", contact.Name)
+		v.required(field+".relation", contact.Relation)
+		v.required(field+".phone", contact.Phone)
+		v.pattern(field+".phone", contact.Phone, phonePattern, "an E.164 phone number")
+		v.pattern(field+".email", contact.Email, emailPattern, "a valid email address")
+	}
+
+	if len(e.JobHistory) == 0 {
+		v.add("jobHistory", "needs at least one entry")
+	}
+	for i, job := range e.JobHistory {
+		field := fmt.Sprintf("jobHistory.%d", i)
+		v.required(field+".jobId", job.JobID)
+		v.required(field+".title", job.Title)
+		v.required(field+".department", job.Department)
+		v.required(field+".location", job.Location)
+		v.required(field+".employmentType", job.EmploymentType)
+		v.oneOf(field+".employmentType", job.EmploymentType, employmentTypes)
+		if job.Manager != nil {
+			v.pattern(field+".manager.email", job.Manager.Email, emailPattern, "a valid email address")
+		}
+	}
+
+	if len(e.StatusHistory) == 0 {
+		v.add("statusHistory", "needs at least one entry")
+	}
+	for i, status := range e.StatusHistory {
+		field := fmt.Sprintf("statusHistory.%d", i)
+		v.required(field+".status", status.Status)
+		v.oneOf(field+".status", status.Status, statuses)
+		if status.Date.IsZero() {
+			v.add(field+".date", "is required")
+		}
+	}
+
+	if len(e.CompensationDetails) == 0 {
+		v.add("compensationDetails", "needs at least one entry")
+	}
+	for i, comp := range e.CompensationDetails {
+		field := fmt.Sprintf("compensationDetails.%d", i)
+		if comp.EffectiveDate.IsZero() {
+			v.add(field+".effectiveDate", "is required")
+		}
+		if comp.Salary < 0 {
+			v.add(field+".salary", "must not be negative")
+		}
+		v.required(field+".currency", comp.Currency)
+		v.oneOf(field+".currency", comp.Currency, currencies)
+		v.required(field+".payFrequency", comp.PayFrequency)
+		v.oneOf(field+".payFrequency", comp.PayFrequency, payFrequencies)
+	}
+
+	var historyErr *HistoryError
+	if err := e.ValidateHistory(); errors.As(err, &historyErr) {
+		v.problems = append(v.problems, historyErr.Problems...)
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+type validator struct {
+	problems []Problem
+}
+
+// add records that field breaks a rule; the message starts with the field
+func (v *validator) add(field, problem string) {
+	v.problems = append(v.problems, Problem{Field: field, Message: field + " " + problem})
+}
+
+func (v *validator) required(field, value string) {
+	if value == "" {
+		v.add(field, "is required")
+	}
+}
+
+// pattern checks value against re when it is set
+func (v *validator) pattern(field, value string, re *regexp.Regexp, want string) {
+	if value != "" && !re.MatchString(value) {
+		v.add(field, "must be "+want)
+	}
+}
+
+// oneOf checks value is one of allowed when it is set
+func (v *validator) oneOf(field, value string, allowed []string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add(field, "must be one of "+strings.Join(allowed, ", "))
+}
@@ -0,0 +1,209 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CurrentView is an employee's position, status and pay in effect on a given day,
+// derived from the job, status and compensation histories.
+type CurrentView struct {
+	AsOf time.Time `json:"asOf"`
+
+	JobID          string     `json:"jobId,omitempty"`
+	Title          string     `json:"title,omitempty"`
+	Department     string     `json:"department,omitempty"`
+	Location       string     `json:"location,omitempty"`
+	EmploymentType string     `json:"employmentType,omitempty"`
+	Manager        *Manager   `json:"manager,omitempty"`
+	JobStartDate   *time.Time `json:"jobStartDate,omitempty"`
+
+	Status      string     `json:"status,omitempty"`
+	StatusSince *time.Time `json:"statusSince,omitempty"`
+
+	Salary                float64     `json:"salary,omitempty"`
+	Currency              string      `json:"currency,omitempty"`
+	PayFrequency          string      `json:"payFrequency,omitempty"`
+	CompensationEffective *time.Time  `json:"compensationEffective,omitempty"`
+	Allowances            []Allowance `json:"allowances,omitempty"`
+}
+
+// CurrentAsOf returns the view of the employee in effect on the day of asOf.
+// Dates are compared by calendar day in UTC; a job's end date is its last working day.
+// Fields are left empty when no history entry is in effect, e.g. before the hire date.
+func (e *Employee) CurrentAsOf(asOf time.Time) CurrentView {
+	day := truncateDay(asOf)
+	view := CurrentView{AsOf: day}
+
+	if job := e.JobAsOf(day); job != nil {
+		start := job.StartDate
+		view.JobID = job.JobID
+		view.Title = job.Title
+		view.Department = job.Department
+		view.Location = job.Location
+		view.EmploymentType = job.EmploymentType
+		view.Manager = job.Manager
+		view.JobStartDate = &start
+	}
+
+	if status := e.StatusAsOf(day); status != nil {
+		since := status.Date
+		view.Status = status.Status
+		view.StatusSince = &since
+	}
+
+	if comp := e.CompensationAsOf(day); comp != nil {
+		effective := comp.EffectiveDate
+		view.Salary = comp.Salary
+		view.Currency = comp.Currency
+		view.PayFrequency = comp.PayFrequency
+		view.CompensationEffective = &effective
+		view.Allowances = comp.Allowances
+	}
+
+	return view
+}
+
+// JobAsOf returns the job history entry covering the day of asOf, or nil
+func (e *Employee) JobAsOf(asOf time.Time) *JobHistory {
+	day := truncateDay(asOf)
+
+	var current *JobHistory
+	for i := range e.JobHistory {
+		job := &e.JobHistory[i]
+		if truncateDay(job.StartDate).After(day) {
+			continue
+		}
+		if job.EndDate != nil && truncateDay(*job.EndDate).Before(day) {
+			continue
+		}
+		// with valid history only one entry matches; prefer the latest start otherwise
+		if current == nil || job.StartDate.After(current.StartDate) {
+			current = job
+		}
+	}
+	return current
+}
+
+// StatusAsOf returns the latest status change on or before the day of asOf, or nil
+func (e *Employee) StatusAsOf(asOf time.Time) *StatusHistory {
+	day := truncateDay(asOf)
+
+	var current *StatusHistory
+	for i := range e.StatusHistory {
+		status := &e.StatusHistory[i]
+		if truncateDay(status.Date).After(day) {
+			continue
+		}
+		if current == nil || !status.Date.Before(current.Date) {
+			current = status
+		}
+	}
+	return current
+}
+
+// CompensationAsOf returns the latest compensation effective on or before the day of asOf, or nil
+func (e *Employee) CompensationAsOf(asOf time.Time) *CompensationDetails {
+	day := truncateDay(asOf)
+
+	var current *CompensationDetails
+	for i := range e.CompensationDetails {
+		comp := &e.CompensationDetails[i]
+		if truncateDay(comp.EffectiveDate).After(day) {
+			continue
+		}
+		if current == nil || !comp.EffectiveDate.Before(current.EffectiveDate) {
+			current = comp
+		}
+	}
+	return current
+}
+
+// Problem is a rule a record breaks. Field is the path of the field breaking it in the record's
+// JSON, with dots between names and array indexes, such as jobHistory.0.startDate.
+type Problem struct {
+	Field   string
+	Message string
+}
+
+// messages returns the messages of problems
+func messages(problems []Problem) []string {
+	out := make([]string, len(problems))
+	for i, p := range problems {
+		out[i] = p.Message
+	}
+	return out
+}
+
+// HistoryError lists every rule the employee's histories break
+type HistoryError struct {
+	Problems []Problem
+}
+
+func (e *HistoryError) Error() string {
+	return "invalid employee history: " + strings.Join(messages(e.Problems), "; ")
+}
+
+// ValidateHistory checks that the histories give a single answer for every day:
+// entries are in chronological order with distinct dates, jobs end after they start,
+// job entries do not overlap and only the latest job is open-ended.
+// It returns a *HistoryError describing every problem found.
+func (e *Employee) ValidateHistory() error {
+	var problems []Problem
+	add := func(field string, format string, args ...interface{}) {
+		problems = append(problems, Problem{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for i, job := range e.JobHistory {
+		if job.StartDate.IsZero() {
+			add(fmt.Sprintf("jobHistory.%d.startDate", i), "jobHistory[%d] has no start date", i)
+			continue
+		}
+		if job.EndDate != nil && truncateDay(*job.EndDate).Before(truncateDay(job.StartDate)) {
+			add(fmt.Sprintf("jobHistory.%d.endDate", i), "jobHistory[%d] ends %s before it starts %s", i, formatDay(*job.EndDate), formatDay(job.StartDate))
+		}
+		if i == 0 {
+			continue
+		}
+
+		prev := e.JobHistory[i-1]
+		if !truncateDay(prev.StartDate).Before(truncateDay(job.StartDate)) {
+			add(fmt.Sprintf("jobHistory.%d.startDate", i), "jobHistory[%d] starts %s, not after jobHistory[%d] which starts %s", i, formatDay(job.StartDate), i-1, formatDay(prev.StartDate))
+			continue
+		}
+		if prev.EndDate == nil {
+			add(fmt.Sprintf("jobHistory.%d.endDate", i-1), "jobHistory[%d] has no end date but is followed by jobHistory[%d]", i-1, i)
+		} else if !truncateDay(*prev.EndDate).Before(truncateDay(job.StartDate)) {
+			add(fmt.Sprintf("jobHistory.%d.startDate", i), "jobHistory[%d] starting %s overlaps jobHistory[%d] ending %s", i, formatDay(job.StartDate), i-1, formatDay(*prev.EndDate))
+		}
+	}
+
+	for i := 1; i < len(e.StatusHistory); i++ {
+		prev, status := e.StatusHistory[i-1], e.StatusHistory[i]
+		if !truncateDay(prev.Date).Before(truncateDay(status.Date)) {
+			add(fmt.Sprintf("statusHistory.%d.date", i), "statusHistory[%d] dated %s is not after statusHistory[%d] dated %s", i, formatDay(status.Date), i-1, formatDay(prev.Date))
+		}
+	}
+
+	for i := 1; i < len(e.CompensationDetails); i++ {
+		prev, comp := e.CompensationDetails[i-1], e.CompensationDetails[i]
+		if !truncateDay(prev.EffectiveDate).Before(truncateDay(comp.EffectiveDate)) {
+			add(fmt.Sprintf("compensationDetails.%d.effectiveDate", i), "compensationDetails[%d] effective %s is not after compensationDetails[%d] effective %s", i, formatDay(comp.EffectiveDate), i-1, formatDay(prev.EffectiveDate))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &HistoryError{Problems: problems}
+	}
+	return nil
+}
+
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func formatDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
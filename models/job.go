@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+)
+
+// Job represents the structure of a job record
+type Job struct {
+	JobID             string             `bson:"jobId" json:"jobId"`
+	JobName           string             `bson:"jobName" json:"jobName"`
+	JobDescription    string             `bson:"jobDescription,omitempty" json:"jobDescription,omitempty"`
+	Positions         []Position         `bson:"positions" json:"positions"`
+	Locations         []JobLocation      `bson:"locations" json:"locations"`
+	Budget            Budget             `bson:"budget" json:"budget"`
+	Headcount         Headcount          `bson:"headcount" json:"headcount"`
+	JobPostingDetails *JobPostingDetails `bson:"jobPostingDetails,omitempty" json:"jobPostingDetails,omitempty"`
+	JobRequirements   *JobRequirements   `bson:"jobRequirements,omitempty" json:"jobRequirements,omitempty"`
+	CreationDate      time.Time          `bson:"creationDate" json:"creationDate"`
+	LastModifiedDate  *time.Time         `bson:"lastModifiedDate,omitempty" json:"lastModifiedDate,omitempty"`
+	Notes             string             `bson:"notes,omitempty" json:"notes,omitempty"`
+}
+
+type Position struct {
+	Title              string   `bson:"title" json:"title"`
+	Role               string   `bson:"role" json:"role"`
+	Level              string   `bson:"level" json:"level"`
+	EmploymentType     string   `bson:"employmentType" json:"employmentType"`
+	SkillsRequired     []string `bson:"skillsRequired" json:"skillsRequired"`
+	ExperienceRequired string   `bson:"experienceRequired" json:"experienceRequired"`
+	Certifications     []string `bson:"certifications,omitempty" json:"certifications,omitempty"`
+}
+
+type JobLocation struct {
+	OfficeName     string  `bson:"officeName" json:"officeName"`
+	Address        Address `bson:"address" json:"address"`
+	RemoteEligible bool    `bson:"remoteEligible" json:"remoteEligible"`
+	TimeZone       string  `bson:"timeZone" json:"timeZone"`
+}
+
+type Budget struct {
+	TotalBudget float64          `bson:"totalBudget" json:"totalBudget"`
+	Currency    string           `bson:"currency" json:"currency"`
+	Allocation  BudgetAllocation `bson:"allocation" json:"allocation"`
+	BudgetNotes string           `bson:"budgetNotes,omitempty" json:"budgetNotes,omitempty"`
+}
+
+type BudgetAllocation struct {
+	Salary    float64 `bson:"salary" json:"salary"`
+	Benefits  float64 `bson:"benefits" json:"benefits"`
+	Equipment float64 `bson:"equipment" json:"equipment"`
+}
+
+type Headcount struct {
+	CurrentHeadcount int              `bson:"currentHeadcount" json:"currentHeadcount"`
+	TargetHeadcount  int              `bson:"targetHeadcount" json:"targetHeadcount"`
+	PositionsFilled  []PositionFilled `bson:"positionsFilled,omitempty" json:"positionsFilled,omitempty"`
+}
+
+type PositionFilled struct {
+	PositionTitle string `bson:"positionTitle" json:"positionTitle"`
+	EmployeeID    string `bson:"employeeId" json:"employeeId"`
+	EmployeeName  string `bson:"employeeName" json:"employeeName"`
+}
+
+type JobPostingDetails struct {
+	PostedDate          *time.Time `bson:"postedDate,omitempty" json:"postedDate,omitempty"`
+	PostingStatus       string     `bson:"postingStatus,omitempty" json:"postingStatus,omitempty"`
+	ClosingDate         *time.Time `bson:"closingDate,omitempty" json:"closingDate,omitempty"`
+	ApplicationDeadline *time.Time `bson:"applicationDeadline,omitempty" json:"applicationDeadline,omitempty"`
+	JobBoards           []string   `bson:"jobBoards,omitempty" json:"jobBoards,omitempty"`
+	Recruiter           *Recruiter `bson:"recruiter,omitempty" json:"recruiter,omitempty"`
+}
+
+type Recruiter struct {
+	RecruiterName  string `bson:"recruiterName" json:"recruiterName"`
+	RecruiterEmail string `bson:"recruiterEmail" json:"recruiterEmail" pii:"email"`
+	RecruiterPhone string `bson:"recruiterPhone,omitempty" json:"recruiterPhone,omitempty" pii:"phone"`
+}
+
+type JobRequirements struct {
+	EducationLevel     string   `bson:"educationLevel,omitempty" json:"educationLevel,omitempty"`
+	LanguagesRequired  []string `bson:"languagesRequired,omitempty" json:"languagesRequired,omitempty"`
+	TravelRequirements string   `bson:"travelRequirements,omitempty" json:"travelRequirements,omitempty"`
+	ClearanceLevel     string   `bson:"clearanceLevel,omitempty" json:"clearanceLevel,omitempty"`
+}
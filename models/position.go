@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Position slot statuses
+const (
+	SlotOpen   = "Open"
+	SlotFilled = "Filled"
+	SlotFrozen = "Frozen"
+)
+
+// PositionSlot is one budgeted seat for a position of a job. A job's target headcount
+// is the number of its slots that are not frozen.
+type PositionSlot struct {
+	SlotID        string     `bson:"slotId" json:"slotId"`
+	JobID         string     `bson:"jobId" json:"jobId"`
+	PositionTitle string     `bson:"positionTitle" json:"positionTitle"`
+	Status        string     `bson:"status" json:"status"`
+	EmployeeID    string     `bson:"employeeId,omitempty" json:"employeeId,omitempty"`
+	EmployeeName  string     `bson:"employeeName,omitempty" json:"employeeName,omitempty"`
+	OpenedDate    time.Time  `bson:"openedDate" json:"openedDate"`
+	FilledDate    *time.Time `bson:"filledDate,omitempty" json:"filledDate,omitempty"`
+	FrozenReason  string     `bson:"frozenReason,omitempty" json:"frozenReason,omitempty"`
+}
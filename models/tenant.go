@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// Tenant is a customer organisation. Its HR data lives in a database of its own; the tenant
+// record itself is kept in the shared Tenant collection of the base database.
+type Tenant struct {
+	TenantID string `bson:"tenantId" json:"tenantId"`
+	Name     string `bson:"name" json:"name"`
+	// Hosts are the host names, besides the tenant subdomain, that resolve to the tenant
+	Hosts     []string    `bson:"hosts,omitempty" json:"hosts,omitempty"`
+	AI        TenantAI    `bson:"ai" json:"ai"`
+	Quota     TenantQuota `bson:"quota" json:"quota"`
+	Disabled  bool        `bson:"disabled,omitempty" json:"disabled,omitempty"`
+	CreatedAt time.Time   `bson:"createdAt" json:"createdAt"`
+}
+
+// TenantAI configures the assistant for a tenant
+type TenantAI struct {
+	// Model overrides the deployment's chat model when set
+	Model string `bson:"model,omitempty" json:"model,omitempty"`
+	// Disabled turns the assistant off for the tenant
+	Disabled bool `bson:"disabled,omitempty" json:"disabled,omitempty"`
+}
+
+// TenantQuota limits a tenant's daily assistant use; zero means unlimited
+type TenantQuota struct {
+	AIRequestsPerDay int `bson:"aiRequestsPerDay,omitempty" json:"aiRequestsPerDay,omitempty"`
+	AITokensPerDay   int `bson:"aiTokensPerDay,omitempty" json:"aiTokensPerDay,omitempty"`
+}
+
+// TenantUsage counts a tenant's assistant use on one UTC day
+type TenantUsage struct {
+	Day        string `bson:"_id" json:"day"`
+	AIRequests int    `bson:"aiRequests" json:"aiRequests"`
+	AITokens   int    `bson:"aiTokens" json:"aiTokens"`
+}
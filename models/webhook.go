@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+)
+
+// Domain event types
+const (
+	EventEmployeeCreated     = "employee.created"
+	EventEmployeeUpdated     = "employee.updated"
+	EventEmployeeTerminated  = "employee.terminated"
+	EventEmployeeDeleted     = "employee.deleted"
+	EventCompensationChanged = "compensation.changed"
+	EventJobUpdated          = "job.updated"
+)
+
+// EventTypes lists every domain event type that can be subscribed to
+var EventTypes = []string{
+	EventEmployeeCreated,
+	EventEmployeeUpdated,
+	EventEmployeeTerminated,
+	EventEmployeeDeleted,
+	EventCompensationChanged,
+	EventJobUpdated,
+}
+
+// OutboxEvent is a domain event written in the same transaction as the change it describes.
+// The dispatcher fans it out to the matching subscriptions and then stamps DispatchedAt.
+type OutboxEvent struct {
+	EventID      string     `bson:"eventId" json:"id"`
+	Type         string     `bson:"type" json:"type"`
+	Subject      string     `bson:"subject" json:"subject"`
+	Actor        string     `bson:"actor,omitempty" json:"actor,omitempty"`
+	OccurredAt   time.Time  `bson:"occurredAt" json:"occurredAt"`
+	Payload      string     `bson:"payload" json:"-"`
+	DispatchedAt *time.Time `bson:"dispatchedAt,omitempty" json:"dispatchedAt,omitempty"`
+}
+
+// WebhookSubscription registers a URL to receive events of the listed types, or every type when empty.
+// Deliveries are signed with Secret.
+type WebhookSubscription struct {
+	SubscriptionID string    `bson:"subscriptionId" json:"id"`
+	URL            string    `bson:"url" json:"url"`
+	EventTypes     []string  `bson:"eventTypes,omitempty" json:"eventTypes,omitempty"`
+	Secret         string    `bson:"secret" json:"-"`
+	Active         bool      `bson:"active" json:"active"`
+	CreatedAt      time.Time `bson:"createdAt" json:"createdAt"`
+	CreatedBy      string    `bson:"createdBy,omitempty" json:"createdBy,omitempty"`
+}
+
+// Wants reports whether the subscription receives events of eventType
+func (s *WebhookSubscription) Wants(eventType string) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// WebhookDelivery is one event on its way to one subscription. Failed attempts are retried
+// with backoff until the attempts run out, when the delivery is dead-lettered.
+type WebhookDelivery struct {
+	DeliveryID     string     `bson:"deliveryId" json:"id"`
+	SubscriptionID string     `bson:"subscriptionId" json:"subscriptionId"`
+	EventID        string     `bson:"eventId" json:"eventId"`
+	EventType      string     `bson:"eventType" json:"eventType"`
+	Status         string     `bson:"status" json:"status"`
+	Attempts       int        `bson:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time  `bson:"nextAttemptAt" json:"nextAttemptAt"`
+	LockedUntil    *time.Time `bson:"lockedUntil,omitempty" json:"-"`
+	LastAttemptAt  *time.Time `bson:"lastAttemptAt,omitempty" json:"lastAttemptAt,omitempty"`
+	LastStatusCode int        `bson:"lastStatusCode,omitempty" json:"lastStatusCode,omitempty"`
+	LastError      string     `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	DeliveredAt    *time.Time `bson:"deliveredAt,omitempty" json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time  `bson:"createdAt" json:"createdAt"`
+}
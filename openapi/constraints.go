@@ -0,0 +1,140 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+)
+
+// rule holds the constraints a MongoDB validator puts on one field of a struct type
+type rule struct {
+	enum     []string
+	pattern  string
+	minimum  *float64
+	minItems *int
+	// notNull is set when the validator requires the field
+	notNull bool
+	// items constrains the elements of an array of strings or numbers
+	items *rule
+	// conflict is set when validators of different collections disagree on the field
+	conflict bool
+}
+
+// apply returns s with the constraints of r. Constraints are written to copies, as schemas of
+// fields are not shared.
+func (r *rule) apply(s *Schema) *Schema {
+	if s.Ref != "" {
+		return s
+	}
+	c := *s
+	c.Enum = r.enum
+	c.Pattern = r.pattern
+	c.Minimum = r.minimum
+	c.MinItems = r.minItems
+	if r.items != nil && c.Items != nil {
+		c.Items = r.items.apply(c.Items)
+	}
+	return &c
+}
+
+func (r *rule) empty() bool {
+	return r.enum == nil && r.pattern == "" && r.minimum == nil && r.minItems == nil && !r.notNull && r.items == nil
+}
+
+// constrain walks struct type t along node, the validator schema documents of t are checked
+// against, recording the rules of every field of t and of the structs it contains
+func (g *generator) constrain(t reflect.Type, node map[string]interface{}) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return
+	}
+	properties, _ := node["properties"].(map[string]interface{})
+	required := map[string]bool{}
+	if names, ok := node["required"].([]interface{}); ok {
+		for _, name := range names {
+			if name, ok := name.(string); ok {
+				required[name] = true
+			}
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		stored, _, _ := strings.Cut(sf.Tag.Get("bson"), ",")
+		if name == "-" || stored == "-" || !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if stored == "" {
+			stored = strings.ToLower(sf.Name)
+		}
+		prop, _ := properties[stored].(map[string]interface{})
+		if prop == nil {
+			continue
+		}
+
+		r := ruleOf(prop)
+		r.notNull = required[stored]
+		g.record(t, name, r)
+
+		// descend into embedded documents and arrays of them
+		ft := sf.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Slice {
+			if items, ok := prop["items"].(map[string]interface{}); ok {
+				g.constrain(ft.Elem(), items)
+			}
+		} else {
+			g.constrain(ft, prop)
+		}
+	}
+}
+
+// record keeps r for the field name of t, or marks the field conflicting when another
+// validator put other constraints on it
+func (g *generator) record(t reflect.Type, name string, r *rule) {
+	if g.rules[t] == nil {
+		g.rules[t] = map[string]*rule{}
+	}
+	previous, ok := g.rules[t][name]
+	switch {
+	case !ok:
+		g.rules[t][name] = r
+	case !reflect.DeepEqual(previous, r):
+		previous.conflict = true
+	}
+}
+
+// ruleOf reads the constraints of a validator property
+func ruleOf(prop map[string]interface{}) *rule {
+	r := &rule{}
+	if values, ok := prop["enum"].([]interface{}); ok {
+		for _, v := range values {
+			if s, ok := v.(string); ok {
+				r.enum = append(r.enum, s)
+			}
+		}
+	}
+	r.pattern, _ = prop["pattern"].(string)
+	if minimum, ok := prop["minimum"].(float64); ok {
+		r.minimum = &minimum
+	}
+	if minItems, ok := prop["minItems"].(float64); ok {
+		n := int(minItems)
+		r.minItems = &n
+	}
+	if items, ok := prop["items"].(map[string]interface{}); ok {
+		if _, object := items["properties"]; !object {
+			if item := ruleOf(items); !item.empty() {
+				r.items = item
+			}
+		}
+	}
+	return r
+}
@@ -0,0 +1,125 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hcmnext/httpstatus"
+)
+
+// maxCheckedBody bounds the size of the response bodies a contract buffers to check
+const maxCheckedBody = 4 << 20
+
+// Contract checks the responses of handlers against the operations documented for their routes.
+// Responses with a status the operation does not list, a media type it does not list for the
+// status or a JSON body that does not validate are logged as violations; they are sent unchanged.
+type Contract struct {
+	doc *Document
+	// report is told about every violation besides it being logged, see OnViolation
+	report func(r *http.Request, v Violation)
+}
+
+// Violation is a response that does not match the operation documented for its route
+type Violation struct {
+	Route    string
+	Status   int
+	Problems []string
+}
+
+// NewContract checks responses against doc
+func NewContract(doc *Document) *Contract {
+	return &Contract{doc: doc}
+}
+
+// OnViolation calls fn with every response that does not match the description, besides logging
+// it. Set it before the contract checks any response.
+func (c *Contract) OnViolation(fn func(r *http.Request, v Violation)) {
+	c.report = fn
+}
+
+// Handler returns h checking its responses against the operation of pattern, or h itself when
+// pattern is not documented
+func (c *Contract) Handler(pattern string, h http.Handler) http.Handler {
+	op := c.doc.Operation(pattern)
+	if op == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capture := &captureWriter{Recorder: httpstatus.NewRecorder(w)}
+		h.ServeHTTP(capture, r)
+
+		problems := c.check(op, capture)
+		if len(problems) > 0 {
+			logger.WarnContext(r.Context(), "Response does not match the API description",
+				"route", pattern, "status", capture.Status, "problems", problems)
+			if c.report != nil {
+				c.report(r, Violation{Route: pattern, Status: capture.Status, Problems: problems})
+			}
+		}
+	})
+}
+
+// check returns the ways the captured response breaks op
+func (c *Contract) check(op *Operation, capture *captureWriter) []string {
+	response := op.Responses[strconv.Itoa(capture.Status)]
+	if response == nil {
+		return []string{"status " + strconv.Itoa(capture.Status) + " is not documented"}
+	}
+	if len(response.Content) == 0 {
+		if capture.size > 0 {
+			return []string{"the response has a body, but none is documented"}
+		}
+		return nil
+	}
+
+	contentType := capture.Header().Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return []string{"the content type " + strconv.Quote(contentType) + " is not valid"}
+	}
+	content, ok := response.Content[mediaType]
+	if !ok {
+		return []string{"the content type " + mediaType + " is not documented"}
+	}
+	if content.Schema == nil || !isJSON(mediaType) || capture.truncated {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(capture.body.Bytes()))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return []string{"the body is not JSON: " + err.Error()}
+	}
+	return c.doc.Validate(content.Schema, value)
+}
+
+// isJSON reports whether mediaType is JSON, such as application/json or application/scim+json
+func isJSON(mediaType string) bool {
+	return mediaType == MediaJSON || strings.HasSuffix(mediaType, "+json")
+}
+
+// captureWriter keeps a copy of the body written through it, up to maxCheckedBody. Flushes and
+// hijacks pass through the recorder, so streams are not held back.
+type captureWriter struct {
+	*httpstatus.Recorder
+	body      bytes.Buffer
+	size      int
+	truncated bool
+}
+
+func (cw *captureWriter) Write(p []byte) (int, error) {
+	cw.size += len(p)
+	if cw.body.Len()+len(p) > maxCheckedBody {
+		cw.truncated = true
+		cw.body.Reset()
+	}
+	if !cw.truncated {
+		cw.body.Write(p)
+	}
+	return cw.Recorder.Write(p)
+}
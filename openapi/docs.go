@@ -0,0 +1,19 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// docsPage renders the document served next to it at openapi.json, without any external assets
+//
+//go:embed docs.html
+var docsPage []byte
+
+// DocsHandler serves the API reference page
+func DocsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(docsPage)
+	})
+}
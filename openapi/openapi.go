@@ -0,0 +1,390 @@
+// Package openapi describes the REST API as an OpenAPI 3.1 document. Schemas are derived from the
+// Go types handlers encode, following encoding/json, with the enums, patterns and minimums of the
+// MongoDB validators of the collections those types are stored in. The operations are those of the
+// routes the router registered, documented by a Route each.
+//
+// A Contract checks the responses handlers actually write against the document.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"hcmnext/logging"
+	"hcmnext/problem"
+)
+
+var logger = logging.For("openapi")
+
+// Version is the OpenAPI version of the documents built here
+const Version = "3.1.0"
+
+// Media types of request and response bodies
+const (
+	MediaJSON      = "application/json"
+	MediaSCIM      = "application/scim+json"
+	MediaText      = "text/plain"
+	MediaHTML      = "text/html"
+	MediaCSV       = "text/csv"
+	MediaNDJSON    = "application/x-ndjson"
+	MediaParquet   = "application/vnd.apache.parquet"
+	MediaEvents    = "text/event-stream"
+	MediaMultipart = "multipart/form-data"
+	MediaProblem   = problem.MediaType
+)
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+
+	// operations maps the route patterns to the operations serving them, for contracts
+	operations map[string]*Operation
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag groups operations
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path by lower case method
+type PathItem map[string]*Operation
+
+// Components holds the schemas operations refer to
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is a way of authenticating
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Operation is a method on a path
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the body an operation accepts
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// Response is a response of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]*Header   `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header is a response header
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType is the schema of a body in one media type
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Route documents the operation served at a route pattern
+type Route struct {
+	// ID is the operationId, unique across the API
+	ID          string
+	Tag         string
+	Summary     string
+	Description string
+	Query       []Param
+	// Body is a value of the type of the request body, nil if there is none
+	Body interface{}
+	// BodyType is the media type of Body, MediaJSON if empty
+	BodyType string
+	// BodyContent replaces Body for bodies that are not Go values, by media type
+	BodyContent map[string]*Schema
+	Replies     []Reply
+	// Public operations need no token
+	Public bool
+}
+
+// Param is a query parameter
+type Param struct {
+	Name        string
+	Description string
+	Schema      *Schema
+	Required    bool
+}
+
+// Reply is a response an operation may give
+type Reply struct {
+	Status      int
+	Description string
+	// ContentType is the media type of the body, MediaJSON if empty when there is a Body or Schema
+	ContentType string
+	// Body is a value of the type of the body; Schema replaces it for bodies that are not Go values
+	Body    interface{}
+	Schema  *Schema
+	Headers map[string]string
+}
+
+// JSON is a reply with a JSON body of the type of body
+func JSON(status int, description string, body interface{}) Reply {
+	return Reply{Status: status, Description: description, Body: body}
+}
+
+// Empty is a reply without a body
+func Empty(status int, description string) Reply {
+	return Reply{Status: status, Description: description}
+}
+
+// Error is an error reply, a problem described by the Problem component
+func Error(status int, description string) Reply {
+	return Reply{Status: status, Description: description, ContentType: MediaProblem, Schema: Ref("Problem")}
+}
+
+// Builder builds a Document from route patterns and their Routes
+type Builder struct {
+	doc    *Document
+	schema *generator
+}
+
+// NewBuilder starts a document described by info
+func NewBuilder(info Info) *Builder {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]*SecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer", Description: "An API token; requests without one are anonymous"},
+			},
+		},
+		// a token is optional until a handler asks for a role
+		Security:   []map[string][]string{{}, {"bearer": {}}},
+		operations: map[string]*Operation{},
+	}
+	doc.Components.Schemas["Problem"] = problemSchema()
+	return &Builder{doc: doc, schema: newGenerator(doc.Components.Schemas)}
+}
+
+// problemSchema describes the problem details of errors
+func problemSchema() *Schema {
+	return &Schema{
+		Type:        Types{"object"},
+		Description: "An RFC 9457 problem. Tell problems apart by code, which also ends the type URI.",
+		Properties: Properties{
+			{Name: "type", Schema: String()},
+			{Name: "title", Schema: String()},
+			{Name: "status", Schema: Integer()},
+			{Name: "detail", Schema: String()},
+			{Name: "instance", Schema: String().Describe("The path of the request")},
+			{Name: "code", Schema: Enum(problem.Codes...)},
+			{Name: "requestId", Schema: String().Describe("The X-Request-ID of the request")},
+			{Name: "errors", Schema: Array(&Schema{
+				Type: Types{"object"},
+				Properties: Properties{
+					{Name: "detail", Schema: String()},
+					{Name: "pointer", Schema: String().Describe("A JSON pointer to the member of the body at fault")},
+					{Name: "parameter", Schema: String().Describe("The query parameter at fault")},
+				},
+				Required: []string{"detail"},
+			}).Describe("The request fields at fault")},
+		},
+		Required: []string{"type", "title", "status", "code"},
+	}
+}
+
+// Constrain adds the constraints of the MongoDB $jsonSchema validator of the collection model is
+// stored in to the schemas of model's type and the types it contains. Constraints only apply to a
+// field when every validator it is stored under agrees on them. Constrain before adding routes.
+func (b *Builder) Constrain(model interface{}, validator string) error {
+	var doc struct {
+		Schema map[string]interface{} `json:"$jsonSchema"`
+	}
+	if err := json.Unmarshal([]byte(validator), &doc); err != nil {
+		return fmt.Errorf("parse validator of %T: %w", model, err)
+	}
+	b.schema.constrain(reflect.TypeOf(model), doc.Schema)
+	return nil
+}
+
+// Tag describes a tag the routes use
+func (b *Builder) Tag(name, description string) {
+	b.doc.Tags = append(b.doc.Tags, Tag{Name: name, Description: description})
+}
+
+// Add documents the operation registered for pattern, a http.ServeMux pattern with a method
+func (b *Builder) Add(pattern string, route Route) error {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		return fmt.Errorf("pattern %q has no method", pattern)
+	}
+	if route.ID == "" {
+		return fmt.Errorf("route %q has no operation id", pattern)
+	}
+
+	op := &Operation{
+		OperationID: route.ID,
+		Summary:     route.Summary,
+		Description: route.Description,
+		Responses:   map[string]*Response{},
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+	if route.Public {
+		op.Security = []map[string][]string{{}}
+	}
+
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			name := strings.TrimSuffix(strings.Trim(segment, "{}"), "...")
+			op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: String()})
+		}
+	}
+	path = strings.ReplaceAll(path, "...}", "}")
+	for _, q := range route.Query {
+		schema := q.Schema
+		if schema == nil {
+			schema = String()
+		}
+		op.Parameters = append(op.Parameters, &Parameter{Name: q.Name, In: "query", Description: q.Description, Required: q.Required, Schema: schema})
+	}
+
+	switch {
+	case route.BodyContent != nil:
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{}}
+		for mediaType, schema := range route.BodyContent {
+			op.RequestBody.Content[mediaType] = MediaType{Schema: schema}
+		}
+	case route.Body != nil:
+		mediaType := route.BodyType
+		if mediaType == "" {
+			mediaType = MediaJSON
+		}
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			mediaType: {Schema: b.schema.of(reflect.TypeOf(route.Body))},
+		}}
+	}
+
+	for _, reply := range route.Replies {
+		status := strconv.Itoa(reply.Status)
+		response := op.Responses[status]
+		if response == nil {
+			response = &Response{Description: reply.Description}
+			op.Responses[status] = response
+		} else {
+			// several replies with one status are alternatives, such as errors of one kind
+			response.Description += "; " + reply.Description
+		}
+		for name, description := range reply.Headers {
+			if response.Headers == nil {
+				response.Headers = map[string]*Header{}
+			}
+			response.Headers[name] = &Header{Description: description, Schema: String()}
+		}
+
+		schema := reply.Schema
+		if schema == nil && reply.Body != nil {
+			schema = b.schema.of(reflect.TypeOf(reply.Body))
+		}
+		if schema == nil {
+			continue
+		}
+		mediaType := reply.ContentType
+		if mediaType == "" {
+			mediaType = MediaJSON
+		}
+		if response.Content == nil {
+			response.Content = map[string]MediaType{}
+		}
+		response.Content[mediaType] = MediaType{Schema: schema}
+	}
+	if len(op.Responses) == 0 {
+		return fmt.Errorf("route %q documents no responses", pattern)
+	}
+	// the authenticator rejects unknown tokens before any handler runs
+	if _, ok := op.Responses["401"]; !ok {
+		op.Responses["401"] = &Response{Description: "The access token is not valid", Content: map[string]MediaType{MediaProblem: {Schema: Ref("Problem")}}}
+	}
+
+	item := b.doc.Paths[path]
+	if item == nil {
+		item = PathItem{}
+		b.doc.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+	b.doc.operations[pattern] = op
+	return nil
+}
+
+// Schema returns the schema of the type of v, adding the components it refers to
+func (b *Builder) Schema(v interface{}) *Schema {
+	return b.schema.of(reflect.TypeOf(v))
+}
+
+// Component adds a schema written by hand as a component and returns a reference to it
+func (b *Builder) Component(name string, schema *Schema) *Schema {
+	b.doc.Components.Schemas[name] = schema
+	return Ref(name)
+}
+
+// Document returns the document built, with its tags sorted by name
+func (b *Builder) Document() *Document {
+	sort.Slice(b.doc.Tags, func(i, j int) bool { return b.doc.Tags[i].Name < b.doc.Tags[j].Name })
+	return b.doc
+}
+
+// Operation returns the operation documented for pattern, or nil
+func (d *Document) Operation(pattern string) *Operation {
+	return d.operations[pattern]
+}
+
+// ServeHTTP serves the document as JSON
+func (d *Document) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", MediaJSON)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(d); err != nil {
+		logger.WarnContext(r.Context(), "Error encoding the API description", "err", err)
+	}
+}
@@ -0,0 +1,308 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Schema is a JSON Schema (draft 2020-12), limited to the keywords the API needs
+type Schema struct {
+	Ref                  string     `json:"$ref,omitempty"`
+	Type                 Types      `json:"type,omitempty"`
+	Format               string     `json:"format,omitempty"`
+	Description          string     `json:"description,omitempty"`
+	Properties           Properties `json:"properties,omitempty"`
+	Required             []string   `json:"required,omitempty"`
+	Items                *Schema    `json:"items,omitempty"`
+	AdditionalProperties *Schema    `json:"additionalProperties,omitempty"`
+	AnyOf                []*Schema  `json:"anyOf,omitempty"`
+	Enum                 []string   `json:"enum,omitempty"`
+	Pattern              string     `json:"pattern,omitempty"`
+	Minimum              *float64   `json:"minimum,omitempty"`
+	Maximum              *float64   `json:"maximum,omitempty"`
+	MinItems             *int       `json:"minItems,omitempty"`
+}
+
+// Types are the JSON types a schema allows, written as a single string when there is one
+type Types []string
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// Properties are the properties of an object schema, written in order
+type Properties []Property
+
+// Property is a named property of an object schema
+type Property struct {
+	Name   string
+	Schema *Schema
+}
+
+func (p Properties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, prop := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(prop.Name)
+		schema, err := json.Marshal(prop.Schema)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(schema)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Get returns the schema of the property name, or nil
+func (p Properties) Get(name string) *Schema {
+	for _, prop := range p {
+		if prop.Name == name {
+			return prop.Schema
+		}
+	}
+	return nil
+}
+
+// String is a string schema
+func String() *Schema {
+	return &Schema{Type: Types{"string"}}
+}
+
+// Integer is an integer schema
+func Integer() *Schema {
+	return &Schema{Type: Types{"integer"}}
+}
+
+// Boolean is a boolean schema
+func Boolean() *Schema {
+	return &Schema{Type: Types{"boolean"}}
+}
+
+// Enum is a string schema allowing values
+func Enum(values ...string) *Schema {
+	return &Schema{Type: Types{"string"}, Enum: values}
+}
+
+// Date is a date in the form YYYY-MM-DD
+func Date() *Schema {
+	return &Schema{Type: Types{"string"}, Format: "date"}
+}
+
+// Array is an array of items
+func Array(items *Schema) *Schema {
+	return &Schema{Type: Types{"array"}, Items: items}
+}
+
+// Ref refers to the component schema name
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// Describe sets the description of s and returns it
+func (s *Schema) Describe(description string) *Schema {
+	s.Description = description
+	return s
+}
+
+// nullable allows null besides the values s allows
+func nullable(s *Schema) *Schema {
+	if s.Ref != "" || len(s.Type) == 0 {
+		if s.Ref == "" {
+			// an empty schema already allows null
+			return s
+		}
+		return &Schema{AnyOf: []*Schema{s, {Type: Types{"null"}}}}
+	}
+	s.Type = append(s.Type, "null")
+	return s
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	durationType  = reflect.TypeOf(time.Duration(0))
+	rawType       = reflect.TypeOf(json.RawMessage(nil))
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// generator derives schemas from Go types as encoding/json encodes them. Named struct types become
+// components named after the type, prefixed by their package when two packages use one name.
+type generator struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+	rules      map[reflect.Type]map[string]*rule
+}
+
+func newGenerator(components map[string]*Schema) *generator {
+	return &generator{
+		components: components,
+		names:      map[reflect.Type]string{},
+		rules:      map[reflect.Type]map[string]*rule{},
+	}
+}
+
+// of returns the schema of t, which is not null for pointers, slices and maps: fields that may be
+// null say so themselves
+func (g *generator) of(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: Types{"string"}, Format: "date-time"}
+	case t == durationType:
+		return &Schema{Type: Types{"integer"}, Format: "int64", Description: "nanoseconds"}
+	case t == rawType:
+		return &Schema{}
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		// the type writes its own JSON, so its fields say nothing
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Boolean()
+	case reflect.String:
+		return String()
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: Types{"integer"}, Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: Types{"integer"}, Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: Types{"number"}, Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: Types{"number"}, Format: "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: Types{"string"}, Format: "byte"}
+		}
+		return Array(g.of(t.Elem()))
+	case reflect.Map:
+		return &Schema{Type: Types{"object"}, AdditionalProperties: g.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return g.component(t)
+	}
+	// interfaces and anything else hold any value
+	return &Schema{}
+}
+
+// component returns a reference to the component of the named struct type t, adding it first
+func (g *generator) component(t reflect.Type) *Schema {
+	if name, ok := g.names[t]; ok {
+		return Ref(name)
+	}
+	name := exported(t.Name())
+	if _, taken := g.components[name]; taken {
+		pkg := t.PkgPath()
+		name = exported(pkg[strings.LastIndex(pkg, "/")+1:]) + name
+	}
+	g.names[t] = name
+	// recursive types find the name taken while their fields are described
+	g.components[name] = &Schema{}
+	*g.components[name] = *g.object(t)
+	return Ref(name)
+}
+
+// object describes the fields of struct type t, with those of embedded structs promoted
+func (g *generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: Types{"object"}}
+	for _, f := range g.fields(t) {
+		s.Properties = append(s.Properties, Property{Name: f.name, Schema: f.schema})
+		if f.required {
+			s.Required = append(s.Required, f.name)
+		}
+	}
+	return s
+}
+
+type field struct {
+	name     string
+	schema   *Schema
+	required bool
+	promoted bool
+}
+
+// fields lists the JSON fields of t in order; a field of t hides promoted fields of the same name
+func (g *generator) fields(t reflect.Type) []field {
+	var all []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		ft := sf.Type
+		if sf.Anonymous && name == "" {
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for _, f := range g.fields(ft) {
+					f.promoted = true
+					all = append(all, f)
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		omitEmpty := strings.Contains(","+options+",", ",omitempty,")
+		schema := g.of(sf.Type)
+		r := g.rules[t][name]
+		if r != nil && !r.conflict {
+			schema = r.apply(schema)
+		}
+		switch sf.Type.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+			// nil encodes as null unless omitted; stored values the validator requires are never nil
+			if !omitEmpty && (r == nil || r.conflict || !r.notNull) {
+				schema = nullable(schema)
+			}
+		}
+		all = append(all, field{name: name, schema: schema, required: !omitEmpty})
+	}
+
+	fields := all[:0]
+	for _, f := range all {
+		hidden := false
+		for _, other := range all {
+			hidden = hidden || f.promoted && !other.promoted && other.name == f.name
+		}
+		if !hidden {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// exported capitalizes the first letter of name
+func exported(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
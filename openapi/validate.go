@@ -0,0 +1,192 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxProblems bounds the problems reported for one value
+const maxProblems = 20
+
+// patterns caches the compiled patterns of schemas
+var patterns sync.Map
+
+// Validate checks value, as decoded from JSON with json.Decoder.UseNumber, against schema, which
+// may refer to the components of d. It returns the problems found, each prefixed with the JSON
+// pointer of the offending value, or nil if the value is valid.
+func (d *Document) Validate(schema *Schema, value interface{}) []string {
+	v := &validation{doc: d}
+	v.check(schema, value, "")
+	return v.problems
+}
+
+type validation struct {
+	doc      *Document
+	problems []string
+}
+
+func (v *validation) fail(path, format string, args ...interface{}) {
+	if len(v.problems) < maxProblems {
+		if path == "" {
+			path = "/"
+		}
+		v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *validation) check(s *Schema, value interface{}, path string) {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		target := v.doc.Components.Schemas[name]
+		if target == nil {
+			v.fail(path, "unknown schema %s", s.Ref)
+			return
+		}
+		v.check(target, value, path)
+		return
+	}
+
+	if len(s.AnyOf) > 0 {
+		var first []string
+		for i, option := range s.AnyOf {
+			alternative := &validation{doc: v.doc}
+			alternative.check(option, value, path)
+			if len(alternative.problems) == 0 {
+				return
+			}
+			if i == 0 {
+				first = alternative.problems
+			}
+		}
+		// the first alternative is the one that is not null, so its problems say most
+		for _, problem := range first {
+			if len(v.problems) < maxProblems {
+				v.problems = append(v.problems, problem)
+			}
+		}
+		return
+	}
+
+	if len(s.Type) > 0 && !hasType(s.Type, value) {
+		v.fail(path, "expected %s, got %s", strings.Join(s.Type, " or "), typeOf(value))
+		return
+	}
+
+	switch value := value.(type) {
+	case string:
+		if len(s.Enum) > 0 && !contains(s.Enum, value) {
+			v.fail(path, "%q is not one of %s", value, strings.Join(s.Enum, ", "))
+		}
+		if s.Pattern != "" && !compile(s.Pattern).MatchString(value) {
+			v.fail(path, "%q does not match %s", value, s.Pattern)
+		}
+		switch s.Format {
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+				v.fail(path, "%q is not a date-time", value)
+			}
+		case "date":
+			if _, err := time.Parse(time.DateOnly, value); err != nil {
+				v.fail(path, "%q is not a date", value)
+			}
+		}
+
+	case json.Number:
+		if s.Minimum != nil {
+			if n, err := value.Float64(); err == nil && n < *s.Minimum {
+				v.fail(path, "%s is less than %v", value, *s.Minimum)
+			}
+		}
+		if s.Maximum != nil {
+			if n, err := value.Float64(); err == nil && n > *s.Maximum {
+				v.fail(path, "%s is greater than %v", value, *s.Maximum)
+			}
+		}
+
+	case []interface{}:
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			v.fail(path, "has %d items, fewer than %d", len(value), *s.MinItems)
+		}
+		if s.Items != nil {
+			for i, item := range value {
+				v.check(s.Items, item, fmt.Sprintf("%s/%d", path, i))
+			}
+		}
+
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				v.fail(path, "%s is required", name)
+			}
+		}
+		for name, item := range value {
+			itemPath := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+			if prop := s.Properties.Get(name); prop != nil {
+				v.check(prop, item, itemPath)
+			} else if s.AdditionalProperties != nil {
+				v.check(s.AdditionalProperties, item, itemPath)
+			}
+		}
+	}
+}
+
+func hasType(types Types, value interface{}) bool {
+	actual := typeOf(value)
+	for _, t := range types {
+		if t == actual || t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf names the JSON type of value
+func typeOf(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return "integer"
+		}
+		if f, err := value.Float64(); err == nil && f == float64(int64(f)) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func compile(pattern string) *regexp.Regexp {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		// a pattern Go cannot compile checks nothing
+		re = regexp.MustCompile("")
+	}
+	patterns.Store(pattern, re)
+	return re
+}
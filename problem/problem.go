@@ -0,0 +1,165 @@
+// Package problem writes the errors of the REST API as RFC 9457 problem details, in
+// application/problem+json.
+//
+// Every problem has a code, which is also the end of its type URI, for clients to tell problems
+// apart; codes do not change, while titles and details are for people and may. Problems with
+// request fields list them in errors, and every problem carries the id of the request it answers.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"hcmnext/logging"
+)
+
+var logger = logging.For("problem")
+
+// MediaType is the media type of problem details
+const MediaType = "application/problem+json"
+
+// TypePrefix starts the type URI of every problem, which ends with its code
+const TypePrefix = "urn:hcmnext:problem:"
+
+// Codes of the problems the API answers with
+const (
+	// CodeInvalidBody is a request body that cannot be read, such as malformed JSON
+	CodeInvalidBody = "invalid_body"
+	// CodeValidationFailed is a request body whose fields break the rules of the record
+	CodeValidationFailed = "validation_failed"
+	// CodeInvalidParameter is a query parameter that is not valid
+	CodeInvalidParameter = "invalid_parameter"
+	// CodeBodyTooLarge is a request body over the size allowed
+	CodeBodyTooLarge = "body_too_large"
+	// CodeUnsupportedMediaType is a request body in a media type the operation does not read
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	// CodeNotAcceptable is a request accepting none of the media types the operation answers in
+	CodeNotAcceptable = "not_acceptable"
+	// CodeRouteNotFound is a method and path no operation of the API serves
+	CodeRouteNotFound = "route_not_found"
+	// CodeUnauthorized is a request without the access token the operation needs
+	CodeUnauthorized = "unauthorized"
+	// CodeInvalidToken is an access token that is not valid
+	CodeInvalidToken = "invalid_token"
+	// CodeForbidden is a caller without the role the operation needs
+	CodeForbidden = "forbidden"
+	// CodeTenantRequired is a request naming no tenant when tenants are isolated
+	CodeTenantRequired = "tenant_required"
+	// CodeTenantMismatch is a request whose host and token name different tenants
+	CodeTenantMismatch = "tenant_mismatch"
+	// CodeTenantNotFound is a request naming an unknown tenant
+	CodeTenantNotFound = "tenant_not_found"
+	// CodeEmployeeNotFound is an employee id no employee has
+	CodeEmployeeNotFound = "employee_not_found"
+	// CodeJobNotFound is a job id no job has
+	CodeJobNotFound = "job_not_found"
+	// CodePositionNotFound is a position slot id no slot has
+	CodePositionNotFound = "position_not_found"
+	// CodeImportNotFound is an import id no import has
+	CodeImportNotFound = "import_not_found"
+	// CodeSubscriptionNotFound is a webhook subscription id no active subscription has
+	CodeSubscriptionNotFound = "subscription_not_found"
+	// CodeDeliveryNotFound is a webhook delivery id no delivery has
+	CodeDeliveryNotFound = "delivery_not_found"
+	// CodeDisplayNotFound is a display id no stored display has
+	CodeDisplayNotFound = "display_not_found"
+	// CodeEmployeeExists is a hire of an employee id already taken
+	CodeEmployeeExists = "employee_exists"
+	// CodeActionNotAllowed is a change the current state of the record does not allow
+	CodeActionNotAllowed = "action_not_allowed"
+	// CodeTimeout is a request that took too long to serve
+	CodeTimeout = "timeout"
+	// CodeShuttingDown is a request arriving while the server drains before shutting down
+	CodeShuttingDown = "shutting_down"
+	// CodeModelFailed is a failed call to the language model
+	CodeModelFailed = "model_failed"
+	// CodeInternal is a failure of the server
+	CodeInternal = "internal"
+)
+
+// Codes lists every code
+var Codes = []string{
+	CodeInvalidBody, CodeValidationFailed, CodeInvalidParameter, CodeBodyTooLarge,
+	CodeUnsupportedMediaType, CodeNotAcceptable, CodeRouteNotFound, CodeUnauthorized, CodeInvalidToken, CodeForbidden,
+	CodeTenantRequired, CodeTenantMismatch, CodeTenantNotFound,
+	CodeEmployeeNotFound, CodeJobNotFound, CodePositionNotFound, CodeImportNotFound,
+	CodeSubscriptionNotFound, CodeDeliveryNotFound, CodeDisplayNotFound, CodeEmployeeExists, CodeActionNotAllowed,
+	CodeTimeout, CodeShuttingDown, CodeModelFailed, CodeInternal,
+}
+
+// Details describes a problem. It is an error, so handlers can return it as it is.
+type Details struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"requestId,omitempty"`
+	// Errors are the request fields at fault
+	Errors []Field `json:"errors,omitempty"`
+}
+
+// Field is a request field at fault: a member of the body named by a JSON pointer, or a query
+// parameter
+type Field struct {
+	Detail    string `json:"detail"`
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+}
+
+// New describes a problem answered with status
+func New(status int, code, detail string) *Details {
+	return &Details{
+		Type:   TypePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Invalid describes a request body whose fields break the rules of the record
+func Invalid(detail string, fields ...Field) *Details {
+	d := New(http.StatusBadRequest, CodeValidationFailed, detail)
+	d.Errors = fields
+	return d
+}
+
+// InvalidParameter describes a query parameter that is not valid
+func InvalidParameter(name, detail string) *Details {
+	d := New(http.StatusBadRequest, CodeInvalidParameter, detail)
+	d.Errors = []Field{{Detail: detail, Parameter: name}}
+	return d
+}
+
+// Pointer names the member of the body at path, whose names and array indexes are separated by
+// dots, such as jobHistory.0.startDate
+func Pointer(path, detail string) Field {
+	return Field{Detail: detail, Pointer: "#/" + strings.ReplaceAll(path, ".", "/")}
+}
+
+func (d *Details) Error() string {
+	return d.Detail
+}
+
+// Write answers r with the problem, taking the request id from the X-Request-ID response header
+func Write(w http.ResponseWriter, r *http.Request, d *Details) {
+	answer := *d
+	answer.Instance = r.URL.Path
+	answer.RequestID = w.Header().Get("X-Request-ID")
+
+	w.Header().Set("Content-Type", MediaType)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(d.Status)
+	if err := json.NewEncoder(w).Encode(answer); err != nil {
+		logger.WarnContext(r.Context(), "Error encoding problem", "code", d.Code, "err", err)
+	}
+}
+
+// Error answers r with a new problem, like http.Error
+func Error(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	Write(w, r, New(status, code, detail))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"hcmnext/ai"
+	"hcmnext/config"
+	"hcmnext/database"
+	"hcmnext/vectorstore"
+)
+
+// reindex embeds every employee and job into the vector store again. The in-process index is
+// rebuilt from scratch, so records deleted since the last index are dropped; Milvus records are
+// upserted.
+func reindex(args []string) {
+	cfg := loadConfig(config.Command{Name: "reindex", Database: true, AI: true}, args)
+
+	aiClient := newAIClient(cfg)
+	db := openDatabase(cfg)
+	defer db.Close()
+
+	var store vectorstore.Store
+	switch cfg.VectorStore.Kind {
+	case "hnsw":
+		store = vectorstore.NewHNSW(vectorstore.DefaultHNSWOptions)
+	case "none":
+		slog.Info("No vector store configured, nothing to index")
+		return
+	default:
+		var err error
+		if store, err = newVectorStore(cfg.VectorStore); err != nil {
+			fatal("Failed to initialize vector store", err)
+		}
+	}
+	aiClient.SetVectorStore(store)
+
+	if err := indexRecords(aiClient, db, store, cfg.VectorStore.HNSWIndexPath); err != nil {
+		db.Close()
+		fatal("Failed to index HR records", err)
+	}
+}
+
+// indexRecords embeds every employee and job of every tenant into the vector store and saves
+// an in-process index to indexPath, if set
+func indexRecords(aiClient *ai.Client, db *database.Database, store vectorstore.Store, indexPath string) error {
+	ids, err := db.Tenants(context.Background())
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, id := range ids {
+		ctx := database.WithTenant(context.Background(), id)
+		indexed, err := aiClient.IndexDatabase(ctx, db)
+		count += indexed
+		if err != nil {
+			return err
+		}
+	}
+	slog.Info("Indexed HR records for retrieval", "records", count)
+
+	if index, ok := store.(*vectorstore.HNSW); ok && indexPath != "" {
+		return index.SaveFile(indexPath)
+	}
+	return nil
+}
@@ -2,44 +2,216 @@ package router
 
 import (
 	"net/http"
+	"sync/atomic"
 
 	"hcmnext/controller"
+	"hcmnext/metrics"
+	"hcmnext/openapi"
+	"hcmnext/tracing"
 )
 
 type Router struct {
-	controller     *controller.Controller
-	homeController *controller.HomeController
-	employeeAPI    *controller.API
-	testController *controller.TestController
+	controller        *controller.Controller
+	homeController    *controller.HomeController
+	employeeAPI       *controller.API
+	testController    *controller.TestController
+	displayController *controller.DisplayController
+	lifecycle         *controller.LifecycleController
+	org               *controller.OrgController
+	positions         *controller.PositionController
+	imports           *controller.ImportController
+	exports           *controller.ExportController
+	scim              *controller.SCIMController
+	webhooks          *controller.WebhookController
+	changes           *controller.ChangeFeedController
+	tenant            *controller.TenantController
+	health            *controller.HealthController
+	openapi           *controller.OpenAPIController
+	doc               *openapi.Document
+
+	// mux serves the routes registered by SetupRoutes
+	mux *http.ServeMux
+
+	// routes are the patterns registered through handle, which the API description must cover
+	routes []string
+
+	// contract checks responses against the API description while response checks are enabled
+	contract atomic.Pointer[openapi.Contract]
 }
 
-func NewRouter(ctrl *controller.Controller, homeCtrl *controller.HomeController, empAPI *controller.API, testAPI *controller.TestController) *Router {
+func NewRouter(ctrl *controller.Controller, homeCtrl *controller.HomeController, empAPI *controller.API, testAPI *controller.TestController, displayCtrl *controller.DisplayController, lifecycleCtrl *controller.LifecycleController, orgCtrl *controller.OrgController, positionCtrl *controller.PositionController, importCtrl *controller.ImportController, exportCtrl *controller.ExportController, scimCtrl *controller.SCIMController, webhookCtrl *controller.WebhookController, changeCtrl *controller.ChangeFeedController, tenantCtrl *controller.TenantController, healthCtrl *controller.HealthController, openapiCtrl *controller.OpenAPIController) *Router {
 	return &Router{
-		controller:     ctrl,
-		homeController: homeCtrl,
-		employeeAPI:    empAPI,
-		testController: testAPI,
+		controller:        ctrl,
+		homeController:    homeCtrl,
+		employeeAPI:       empAPI,
+		testController:    testAPI,
+		displayController: displayCtrl,
+		lifecycle:         lifecycleCtrl,
+		org:               orgCtrl,
+		positions:         positionCtrl,
+		imports:           importCtrl,
+		exports:           exportCtrl,
+		scim:              scimCtrl,
+		webhooks:          webhookCtrl,
+		changes:           changeCtrl,
+		tenant:            tenantCtrl,
+		health:            healthCtrl,
+		openapi:           openapiCtrl,
 	}
 }
 
-func (r *Router) SetupRoutes() {
+// SetupRoutes registers the routes and describes the API they serve. It fails when a route of the
+// REST or SCIM API and its description disagree.
+func (r *Router) SetupRoutes() error {
+	r.mux = http.NewServeMux()
+	r.routes = nil
+
+	// Prometheus metrics
+	r.mux.Handle("GET /metrics", metrics.Handler())
+
+	// Kubernetes probes, left out of the request metrics and traces like the metrics endpoint
+	r.mux.HandleFunc("GET /healthz", r.health.Live)
+	r.mux.HandleFunc("GET /readyz", r.health.Ready)
+
 	// handle static files
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	r.handle("/static/", http.StripPrefix("/static/", r.homeController.StaticFiles()))
 
 	// Existing routes
-	http.HandleFunc("/", r.homeController.ServeHome)
-	http.HandleFunc("/ws", r.controller.HandleWebSocket)
+	r.handleFunc("/", r.homeController.ServeHome)
+	r.handleFunc("/ws", r.controller.HandleWebSocket)
+
+	// sandboxed AI display markup
+	r.handleFunc("GET /display/{id}", r.displayController.ServeDisplay)
 
 	// Employee API routes
-	http.HandleFunc("POST /api/employees", r.employeeAPI.CreateEmployee)
-	http.HandleFunc("GET /api/employees", r.employeeAPI.GetEmployees)
-	http.HandleFunc("GET /api/employees/{id}", r.employeeAPI.GetEmployee)
-	http.HandleFunc("PUT /api/employees/{id}", r.employeeAPI.UpdateEmployee)
-	http.HandleFunc("DELETE /api/employees/{id}", r.employeeAPI.DeleteEmployee)
+	r.handleAPI("POST /api/employees", r.employeeAPI.CreateEmployee)
+	r.handleAPI("GET /api/employees", r.employeeAPI.GetEmployees)
+	r.handleAPI("GET /api/employees/{id}", r.employeeAPI.GetEmployee)
+	r.handleAPI("PUT /api/employees/{id}", r.employeeAPI.UpdateEmployee)
+	r.handleAPI("DELETE /api/employees/{id}", r.employeeAPI.DeleteEmployee)
+
+	// Bulk employee import
+	r.handleAPI("POST /api/employees/import", r.imports.ImportEmployees)
+	r.handleAPI("GET /api/imports/{id}", r.imports.GetImport)
+
+	// Bulk exports
+	r.handleAPI("GET /api/employees/export", r.exports.ExportEmployees, "text/csv", "application/x-ndjson", "application/vnd.apache.parquet")
+	r.handleAPI("GET /api/jobs/export", r.exports.ExportJobs, "text/csv", "application/x-ndjson", "application/vnd.apache.parquet")
+
+	// Employee lifecycle actions
+	r.handleAPI("POST /api/employees/{id}/actions/hire", r.lifecycle.Hire)
+	r.handleAPI("POST /api/employees/{id}/actions/transfer", r.lifecycle.Transfer)
+	r.handleAPI("POST /api/employees/{id}/actions/promote", r.lifecycle.Promote)
+	r.handleAPI("POST /api/employees/{id}/actions/terminate", r.lifecycle.Terminate)
+	r.handleAPI("POST /api/employees/{id}/actions/rehire", r.lifecycle.Rehire)
+
+	// Org chart and reporting lines
+	r.handleAPI("GET /api/employees/{id}/reports", r.org.GetReports)
+	r.handleAPI("GET /api/employees/{id}/chain-of-command", r.org.GetChainOfCommand)
+	r.handleAPI("GET /api/org/stats", r.org.GetStats)
+	r.handleAPI("GET /api/org/issues", r.org.GetIssues)
+	r.handleAPI("GET /api/org/chart", r.org.GetChart, "application/json", "text/plain")
+
+	// Position slots and headcount
+	r.handleAPI("GET /api/jobs/{id}/positions", r.positions.GetSlots)
+	r.handleAPI("POST /api/jobs/{id}/positions", r.positions.OpenSlots)
+	r.handleAPI("POST /api/jobs/{id}/headcount/recalculate", r.positions.RecalculateHeadcount)
+	r.handleAPI("POST /api/positions/{slotId}/freeze", r.positions.FreezeSlot)
+	r.handleAPI("POST /api/positions/{slotId}/unfreeze", r.positions.UnfreezeSlot)
+	r.handleAPI("GET /api/positions/vacancies", r.positions.GetVacancies)
+	r.handleAPI("GET /api/positions/consistency", r.positions.GetConsistency)
+
+	// Server status for administrators
+	r.handleAPI("GET /api/admin/status", r.health.GetStatus)
+
+	// The caller's tenant, its assistant configuration and quotas
+	r.handleAPI("GET /api/tenant", r.tenant.GetTenant)
+
+	// Live employee and job changes for open dashboards
+	r.handleAPI("GET /api/changes", r.changes.StreamChanges, "text/event-stream")
+
+	// Webhook subscriptions for domain events
+	r.handleAPI("POST /api/webhooks", r.webhooks.CreateSubscription)
+	r.handleAPI("GET /api/webhooks", r.webhooks.GetSubscriptions)
+	r.handleAPI("DELETE /api/webhooks/{id}", r.webhooks.DeleteSubscription)
+	r.handleAPI("GET /api/webhooks/{id}/deliveries", r.webhooks.GetDeliveries)
+	r.handleAPI("POST /api/webhooks/{id}/replay", r.webhooks.Replay)
+	r.handleAPI("POST /api/webhooks/deliveries/{deliveryId}/redeliver", r.webhooks.Redeliver)
+
+	// SCIM 2.0 provisioning
+	r.handleFunc("GET /scim/v2/Users", r.scim.ListUsers)
+	r.handleFunc("POST /scim/v2/Users", r.scim.UnsupportedUserWrite)
+	r.handleFunc("GET /scim/v2/Users/{id}", r.scim.GetUser)
+	r.handleFunc("PATCH /scim/v2/Users/{id}", r.scim.PatchUser)
+	r.handleFunc("PUT /scim/v2/Users/{id}", r.scim.UnsupportedUserWrite)
+	r.handleFunc("DELETE /scim/v2/Users/{id}", r.scim.UnsupportedUserWrite)
+	r.handleFunc("GET /scim/v2/Groups", r.scim.ListGroups)
+	r.handleFunc("POST /scim/v2/Groups", r.scim.UnsupportedGroupWrite)
+	r.handleFunc("GET /scim/v2/Groups/{id}", r.scim.GetGroup)
+	r.handleFunc("PATCH /scim/v2/Groups/{id}", r.scim.UnsupportedGroupWrite)
+	r.handleFunc("PUT /scim/v2/Groups/{id}", r.scim.UnsupportedGroupWrite)
+	r.handleFunc("DELETE /scim/v2/Groups/{id}", r.scim.UnsupportedGroupWrite)
+	r.handleFunc("GET /scim/v2/Schemas", r.scim.GetSchemas)
+	r.handleFunc("GET /scim/v2/Schemas/{id}", r.scim.GetSchema)
+	r.handleFunc("GET /scim/v2/ResourceTypes", r.scim.GetResourceTypes)
+	r.handleFunc("GET /scim/v2/ResourceTypes/{id}", r.scim.GetResourceType)
+	r.handleFunc("GET /scim/v2/ServiceProviderConfig", r.scim.GetServiceProviderConfig)
 
 	// test routes
-	http.HandleFunc("GET /api/exectionplan", r.testController.HandleGenerateExecutionPlan)
-	http.HandleFunc("GET /api/usetool", r.testController.HandleToolUse)
-	http.HandleFunc("GET /api/math", r.testController.HandleGenerateMath)
-	http.HandleFunc("GET /api/displayhtml", r.testController.HandleGenerateDisplayHtml)
-}
\ No newline at end of file
+	r.handleAPI("GET /api/exectionplan", r.testController.HandleGenerateExecutionPlan)
+	r.handleAPI("GET /api/usetool", r.testController.HandleToolUse)
+	r.handleAPI("GET /api/math", r.testController.HandleGenerateMath)
+	r.handleAPI("GET /api/displayhtml", r.testController.HandleGenerateDisplayHtml)
+	r.handleAPI("GET /api/traces", r.testController.HandleTraces)
+
+	// OpenAPI description and reference page
+	r.handleAPI("GET /api/openapi.json", r.openapi.GetSpec)
+	r.handleAPI("GET /api/docs", r.openapi.GetDocs, "text/html")
+
+	doc, err := r.openapi.Describe(r.routes)
+	if err != nil {
+		return err
+	}
+	r.doc = doc
+	return nil
+}
+
+// CheckResponses turns checking every response against the API description on or off; responses
+// that do not match are logged and sent unchanged
+func (r *Router) CheckResponses(enabled bool) {
+	if enabled {
+		r.contract.Store(openapi.NewContract(r.doc))
+	} else {
+		r.contract.Store(nil)
+	}
+}
+
+// Handler serves the routes registered by SetupRoutes
+func (r *Router) Handler() http.Handler {
+	return r.mux
+}
+
+// handle registers h for pattern, counting, timing and tracing its requests and checking its
+// responses while response checks are enabled
+func (r *Router) handle(pattern string, h http.Handler) {
+	r.routes = append(r.routes, pattern)
+	checked := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if c := r.contract.Load(); c != nil {
+			c.Handler(pattern, h).ServeHTTP(w, req)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+	r.mux.Handle(pattern, metrics.Route(pattern, tracing.Route(pattern, checked)))
+}
+
+// handleFunc registers fn for pattern, counting, timing and tracing its requests
+func (r *Router) handleFunc(pattern string, fn http.HandlerFunc) {
+	r.handle(pattern, fn)
+}
+
+// handleAPI registers the REST API handler fn for pattern, answering in one of offers,
+// application/json when there are none, and with problems on errors
+func (r *Router) handleAPI(pattern string, fn controller.HandlerFunc, offers ...string) {
+	r.handle(pattern, controller.Handle(fn, offers...))
+}
@@ -0,0 +1,252 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"hcmnext/ai"
+	"hcmnext/auth"
+	"hcmnext/bulk"
+	"hcmnext/controller"
+	"hcmnext/database"
+	"hcmnext/events"
+	"hcmnext/health"
+	"hcmnext/hr"
+	"hcmnext/models"
+	"hcmnext/openapi"
+	"hcmnext/scim"
+	"hcmnext/tenant"
+)
+
+// sampleRequest is a request made to a route to check its response
+type sampleRequest struct {
+	path        string
+	contentType string
+	accept      string
+	body        string
+}
+
+// pathValues fill in the wildcards of the patterns
+var pathValues = strings.NewReplacer(
+	"/scim/v2/Schemas/{id}", "/scim/v2/Schemas/urn:ietf:params:scim:schemas:core:2.0:User",
+	"/scim/v2/ResourceTypes/{id}", "/scim/v2/ResourceTypes/User",
+	"{id}", "E9001",
+	"{slotId}", "S9001",
+	"{deliveryId}", "D9001",
+)
+
+// sampleEmployee is a complete, valid employee in its JSON form
+func sampleEmployee(id, lastName string) string {
+	return `{"employeeId":"` + id + `","firstName":"Ada","lastName":"` + lastName + `","email":"ada@example.com",` +
+		`"socialSecurityNumber":"123-45-6789",` +
+		`"personalDetails":{"dateOfBirth":"1985-12-10T00:00:00Z","gender":"Female","maritalStatus":"Married",` +
+		`"address":{"street":"12 St James's Square","city":"London","state":"London","zipCode":"SW1Y 4JH","country":"UK"}},` +
+		`"jobHistory":[{"jobId":"J9001","title":"Analyst","department":"Engineering","location":"London",` +
+		`"employmentType":"Full-time","startDate":"2024-01-15T00:00:00Z"}],` +
+		`"statusHistory":[{"status":"Active","date":"2024-01-15T00:00:00Z"}],` +
+		`"compensationDetails":[{"effectiveDate":"2024-01-15T00:00:00Z","salary":120000,"currency":"GBP","payFrequency":"Annually"}]}`
+}
+
+// sampleBodies are the bodies sent to routes that take one; the rest get an empty JSON object.
+// Routes with an {id} address E9001, which is stored before the requests are made; the employee
+// created is another one.
+var sampleBodies = map[string]sampleRequest{
+	"POST /api/employees":     {body: sampleEmployee("E9002", "Lovelace")},
+	"PUT /api/employees/{id}": {body: sampleEmployee("E9001", "King")},
+	"POST /api/employees/import": {
+		path:        "/api/employees/import?dryRun=true",
+		contentType: "text/csv",
+		body:        "employeeId,firstName,lastName\nE9002,Grace,Hopper\n",
+	},
+	"POST /api/webhooks":                {body: `{"url":"https://example.com/hooks","events":["employee.created"]}`},
+	"PATCH /scim/v2/Users/{id}":         {contentType: "application/scim+json", body: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"active","value":false}]}`},
+	"GET /api/usetool":                  {body: ""},
+	"GET /api/changes":                  {accept: "text/event-stream"},
+	"GET /api/org/chart":                {path: "/api/org/chart?format=mermaid"},
+	"GET /api/employees/export":         {path: "/api/employees/export?format=ndjson"},
+	"GET /api/jobs/export":              {path: "/api/jobs/export?format=csv"},
+	"POST /scim/v2/Users":               {contentType: "application/scim+json"},
+	"PUT /scim/v2/Users/{id}":           {contentType: "application/scim+json"},
+	"POST /scim/v2/Groups":              {contentType: "application/scim+json"},
+	"PATCH /scim/v2/Groups/{id}":        {contentType: "application/scim+json"},
+	"PUT /scim/v2/Groups/{id}":          {contentType: "application/scim+json"},
+	"GET /api/employees/{id}/reports":   {path: "/api/employees/E9001/reports?depth=2"},
+	"GET /api/webhooks/{id}/deliveries": {path: "/api/webhooks/W9001/deliveries"},
+}
+
+// providerRoutes call the model provider, which the test never reaches, so they only ever fail
+var providerRoutes = map[string]bool{
+	"GET /api/exectionplan": true,
+	"GET /api/usetool":      true,
+	"GET /api/math":         true,
+	"GET /api/displayhtml":  true,
+}
+
+// newTestRouter wires every controller the way serve does. The database is a fresh one on the
+// server MONGO_URI names, holding the sample employee E9001, or else an unreachable server isolating
+// tenants, which the requests name none of, so that every route answers with its errors without
+// waiting on the server; only a real database lets routes answer with their successes. The model
+// provider is never reached.
+func newTestRouter(t *testing.T) (*Router, bool) {
+	t.Helper()
+
+	uri := os.Getenv("MONGO_URI")
+	offline := uri == ""
+	if offline {
+		uri = "mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100"
+	}
+	db, err := database.NewDatabase(uri, fmt.Sprintf("hcmcontract%d", time.Now().UnixNano()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if !offline {
+			db.Drop(context.Background())
+		}
+		db.Close()
+	})
+	db.SetMultiTenant(offline)
+	if !offline {
+		var emp models.Employee
+		if err := json.Unmarshal([]byte(sampleEmployee("E9001", "Lovelace")), &emp); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.InsertOne("Employee", &emp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	aiClient, err := ai.NewClientFromConfig(ai.Config{BaseURL: "http://127.0.0.1:1/v1", PromptDir: "../ai/prompts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenants := tenant.NewRegistry(db)
+	aiClient.SetTenants(tenants)
+	orgService := hr.NewOrgService(db)
+	aiClient.SetOrgService(orgService)
+
+	r := NewRouter(
+		controller.NewController(aiClient, db),
+		controller.NewHomeController("../static"),
+		controller.NewAPI(db),
+		controller.NewTestController(aiClient),
+		controller.NewDisplayController(aiClient.Displays()),
+		controller.NewLifecycleController(hr.NewLifecycle(db)),
+		controller.NewOrgController(orgService),
+		controller.NewPositionController(hr.NewPositionService(db)),
+		controller.NewImportController(bulk.NewImporter(db)),
+		controller.NewExportController(bulk.NewExporter(db)),
+		controller.NewSCIMController(scim.NewService(db)),
+		controller.NewWebhookController(events.NewWebhooks(db)),
+		controller.NewChangeFeedController(events.NewHub()),
+		controller.NewTenantController(tenants),
+		controller.NewHealthController(health.NewChecker(time.Second), aiClient, db),
+		controller.NewOpenAPIController(),
+	)
+	return r, !offline
+}
+
+// TestResponsesMatchDescription sends every documented route a request, and REST API routes one
+// accepting nothing they answer with, and fails on each response the API description does not
+// allow. Deletes go last, so the other routes find the sample employee. With a real database it
+// also fails on server errors, which mean a sample request reached no success or client error.
+func TestResponsesMatchDescription(t *testing.T) {
+	r, online := newTestRouter(t)
+	if err := r.SetupRoutes(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := openapi.NewContract(r.doc)
+	var violations []openapi.Violation
+	c.OnViolation(func(_ *http.Request, v openapi.Violation) {
+		violations = append(violations, v)
+	})
+	r.contract.Store(c)
+	t.Cleanup(func() { r.contract.Store(nil) })
+
+	caller := auth.Principal{
+		Subject: "contract-test",
+		Roles:   []string{auth.RoleAdmin, auth.RoleViewPII, auth.RoleProvision, auth.RoleManageWebhooks},
+	}
+
+	// deletes last
+	patterns := make([]string, 0, len(r.routes))
+	var deletes []string
+	for _, pattern := range r.routes {
+		if strings.HasPrefix(pattern, http.MethodDelete+" ") {
+			deletes = append(deletes, pattern)
+		} else {
+			patterns = append(patterns, pattern)
+		}
+	}
+	patterns = append(patterns, deletes...)
+
+	checked := 0
+	for _, pattern := range patterns {
+		if r.doc.Operation(pattern) == nil {
+			continue
+		}
+		method, path, _ := strings.Cut(pattern, " ")
+
+		sample := sampleBodies[pattern]
+		if sample.path == "" {
+			sample.path = pathValues.Replace(path)
+		}
+		requests := []sampleRequest{sample}
+		if strings.HasPrefix(path, "/api/") {
+			requests = append(requests, sampleRequest{path: sample.path, accept: "application/x-unknown"})
+		}
+
+		for _, req := range requests {
+			var body io.Reader
+			if method != http.MethodGet && method != http.MethodDelete || req.body != "" {
+				if req.body == "" && req.contentType == "" {
+					req.body = "{}"
+				}
+				body = strings.NewReader(req.body)
+			}
+
+			// the change stream only ends with its request
+			timeout := 5 * time.Second
+			if req.accept == "text/event-stream" {
+				timeout = 100 * time.Millisecond
+			}
+			ctx, cancel := context.WithTimeout(auth.WithPrincipal(context.Background(), caller), timeout)
+			httpReq := httptest.NewRequest(method, req.path, body).WithContext(ctx)
+			if body != nil {
+				contentType := req.contentType
+				if contentType == "" {
+					contentType = "application/json"
+				}
+				httpReq.Header.Set("Content-Type", contentType)
+			}
+			if req.accept != "" {
+				httpReq.Header.Set("Accept", req.accept)
+			}
+
+			w := httptest.NewRecorder()
+			r.Handler().ServeHTTP(w, httpReq)
+			cancel()
+			checked++
+
+			if online && w.Code >= http.StatusInternalServerError && !providerRoutes[pattern] && req.accept != "text/event-stream" {
+				t.Errorf("%s %s answered %d: %s", method, req.path, w.Code, w.Body.String())
+			}
+		}
+	}
+
+	if checked == 0 {
+		t.Fatal("no documented route was checked")
+	}
+	for _, v := range violations {
+		t.Errorf("%s answered %d: %s", v.Route, v.Status, strings.Join(v.Problems, "; "))
+	}
+}
@@ -0,0 +1,426 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Filter is a parsed SCIM filter expression (RFC 7644 section 3.4.2.2)
+type Filter interface {
+	// Matches evaluates the filter against a resource in its JSON form
+	Matches(resource map[string]interface{}) bool
+}
+
+type logicalFilter struct {
+	and         bool
+	left, right Filter
+}
+
+func (f logicalFilter) Matches(r map[string]interface{}) bool {
+	if f.and {
+		return f.left.Matches(r) && f.right.Matches(r)
+	}
+	return f.left.Matches(r) || f.right.Matches(r)
+}
+
+type notFilter struct {
+	inner Filter
+}
+
+func (f notFilter) Matches(r map[string]interface{}) bool {
+	return !f.inner.Matches(r)
+}
+
+// compareFilter is attrPath op value, or attrPath pr when op is "pr"
+type compareFilter struct {
+	path  string
+	op    string
+	value interface{}
+}
+
+func (f compareFilter) Matches(r map[string]interface{}) bool {
+	values := lookup(r, f.path)
+	if f.op == "pr" {
+		for _, v := range values {
+			if present(v) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, v := range values {
+		if compare(v, f.op, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// valuePathFilter is attr[filter], matching when any element of the multi-valued attr matches
+type valuePathFilter struct {
+	path  string
+	inner Filter
+}
+
+func (f valuePathFilter) Matches(r map[string]interface{}) bool {
+	for _, v := range lookupElements(r, f.path) {
+		if element, ok := v.(map[string]interface{}); ok && f.inner.Matches(element) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseFilter parses a SCIM filter expression
+func ParseFilter(expr string) (Filter, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+type tokenKind int
+
+const (
+	wordToken tokenKind = iota
+	stringToken
+	punctToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			tokens = append(tokens, token{punctToken, string(c)})
+			i++
+		case c == '"':
+			// strings are JSON strings, escapes included
+			j := i + 1
+			for j < len(expr) && expr[j] != '"' {
+				if expr[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(expr) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			var s string
+			if err := json.Unmarshal([]byte(expr[i:j+1]), &s); err != nil {
+				return nil, fmt.Errorf("invalid string %s", expr[i:j+1])
+			}
+			tokens = append(tokens, token{stringToken, s})
+			i = j + 1
+		default:
+			j := i
+			for j < len(expr) && !strings.ContainsRune(" \t\n()[]\"", rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, token{wordToken, expr[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *filterParser) peekWord(words ...string) bool {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != wordToken {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(p.tokens[p.pos].text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *filterParser) peekPunct(punct string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == punctToken && p.tokens[p.pos].text == punct
+}
+
+func (p *filterParser) expectPunct(punct string) error {
+	if !p.peekPunct(punct) {
+		return fmt.Errorf("expected %q", punct)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *filterParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekWord("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (Filter, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekWord("and") {
+		p.pos++
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalFilter{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseFactor() (Filter, error) {
+	if p.peekWord("not") {
+		p.pos++
+		if err := p.expectPunct("("); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return notFilter{inner}, p.expectPunct(")")
+	}
+	if p.peekPunct("(") {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expectPunct(")")
+	}
+
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != wordToken {
+		return nil, fmt.Errorf("expected an attribute")
+	}
+	path := p.tokens[p.pos].text
+	if !validAttrPath(path) {
+		return nil, fmt.Errorf("invalid attribute %q", path)
+	}
+	p.pos++
+
+	if p.peekPunct("[") {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct("]"); err != nil {
+			return nil, err
+		}
+		return valuePathFilter{path: path, inner: inner}, nil
+	}
+
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != wordToken {
+		return nil, fmt.Errorf("expected an operator after %s", path)
+	}
+	op := strings.ToLower(p.tokens[p.pos].text)
+	p.pos++
+	switch op {
+	case "pr":
+		return compareFilter{path: path, op: op}, nil
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, fmt.Errorf("unknown operator %q", op)
+	}
+
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind == punctToken {
+		return nil, fmt.Errorf("expected a value after %s %s", path, op)
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	if tok.kind == stringToken {
+		return compareFilter{path: path, op: op, value: tok.text}, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(tok.text), &value); err != nil {
+		return nil, fmt.Errorf("invalid value %q", tok.text)
+	}
+	if _, isString := value.(string); isString {
+		return nil, fmt.Errorf("invalid value %q", tok.text)
+	}
+	return compareFilter{path: path, op: op, value: value}, nil
+}
+
+func validAttrPath(path string) bool {
+	for _, r := range path {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(".:$-_", r) {
+			return false
+		}
+	}
+	return path != ""
+}
+
+// lookup returns every value at path in r, flattening multi-valued attributes.
+// Names match without regard to case and may be prefixed with a schema URN.
+func lookup(r map[string]interface{}, path string) []interface{} {
+	values := lookupElements(r, path)
+
+	// a multi-valued attribute of objects compares by its value sub-attribute
+	for i, v := range values {
+		if obj, ok := v.(map[string]interface{}); ok {
+			if value, ok := obj["value"]; ok {
+				values[i] = value
+			}
+		}
+	}
+	return values
+}
+
+// lookupElements is lookup without reducing objects to their value sub-attribute
+func lookupElements(r map[string]interface{}, path string) []interface{} {
+	values := []interface{}{r}
+	for _, segment := range splitPath(path) {
+		var next []interface{}
+		for _, v := range values {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for key, child := range obj {
+				if !strings.EqualFold(key, segment) {
+					continue
+				}
+				if list, ok := child.([]interface{}); ok {
+					next = append(next, list...)
+				} else {
+					next = append(next, child)
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// splitPath splits an attribute path into its segments. A path prefixed with an extension URN,
+// like urn:...:enterprise:2.0:User:manager.value, keeps the URN as its first segment.
+func splitPath(path string) []string {
+	if !strings.HasPrefix(strings.ToLower(path), "urn:") {
+		return strings.Split(path, ".")
+	}
+	i := strings.LastIndex(path, ":")
+	segments := strings.Split(path[i+1:], ".")
+	if urn := path[:i]; strings.EqualFold(urn, UserSchema) || strings.EqualFold(urn, GroupSchema) {
+		// attributes of the core schemas live at the top level
+		return segments
+	}
+	return append([]string{path[:i]}, segments...)
+}
+
+func present(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+// compare applies op to an attribute value and a filter value. Strings compare without regard to case.
+func compare(attr interface{}, op string, value interface{}) bool {
+	switch a := attr.(type) {
+	case string:
+		v, ok := value.(string)
+		if !ok {
+			return op == "ne"
+		}
+		a, v = strings.ToLower(a), strings.ToLower(v)
+		switch op {
+		case "eq":
+			return a == v
+		case "ne":
+			return a != v
+		case "co":
+			return strings.Contains(a, v)
+		case "sw":
+			return strings.HasPrefix(a, v)
+		case "ew":
+			return strings.HasSuffix(a, v)
+		case "gt":
+			return a > v
+		case "ge":
+			return a >= v
+		case "lt":
+			return a < v
+		case "le":
+			return a <= v
+		}
+	case float64:
+		v, ok := value.(float64)
+		if !ok {
+			return op == "ne"
+		}
+		switch op {
+		case "eq":
+			return a == v
+		case "ne":
+			return a != v
+		case "gt":
+			return a > v
+		case "ge":
+			return a >= v
+		case "lt":
+			return a < v
+		case "le":
+			return a <= v
+		}
+	case bool:
+		v, ok := value.(bool)
+		switch op {
+		case "eq":
+			return ok && a == v
+		case "ne":
+			return !ok || a != v
+		}
+	case nil:
+		switch op {
+		case "eq":
+			return value == nil
+		case "ne":
+			return value != nil
+		}
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+package scim
+
+import (
+	"encoding/json"
+	"strings"
+
+	"hcmnext/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// PatchRequest is a PATCH body of RFC 7644 section 3.5.2
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation adds, replaces or removes the value at path
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// userField is an employee field a User attribute writes to
+type userField struct {
+	field    string
+	required bool
+	get      func(*models.Employee) *string
+}
+
+// userFields lists the attributes PATCH may change, by lower-cased path
+var userFields = map[string]userField{
+	"username":             {"email", true, func(e *models.Employee) *string { return &e.Email }},
+	"emails.value":         {"email", true, func(e *models.Employee) *string { return &e.Email }},
+	"phonenumbers.value":   {"phone", false, func(e *models.Employee) *string { return &e.Phone }},
+	"nickname":             {"preferredName", false, func(e *models.Employee) *string { return &e.PreferredName }},
+	"name.givenname":       {"firstName", true, func(e *models.Employee) *string { return &e.FirstName }},
+	"name.familyname":      {"lastName", true, func(e *models.Employee) *string { return &e.LastName }},
+	"name.middlename":      {"middleName", false, func(e *models.Employee) *string { return &e.MiddleName }},
+	"name.honorificsuffix": {"suffix", false, func(e *models.Employee) *string { return &e.Suffix }},
+}
+
+// readOnlyAttributes are derived from the job and status histories or assigned by the server
+var readOnlyAttributes = []string{"id", "meta", "displayname", "name.formatted", "title", "usertype", "active", "groups"}
+
+// changes collects the employee fields a patch sets and clears
+type changes struct {
+	set   bson.M
+	unset bson.M
+}
+
+func (c changes) update() bson.M {
+	update := bson.M{}
+	if len(c.set) > 0 {
+		update["$set"] = c.set
+	}
+	if len(c.unset) > 0 {
+		update["$unset"] = c.unset
+	}
+	return update
+}
+
+// applyPatch applies every operation to emp, returning the database update that stores the result
+func applyPatch(emp *models.Employee, patch PatchRequest) (bson.M, error) {
+	if !hasSchema(patch.Schemas, PatchOpSchema) {
+		return nil, badRequest("invalidSyntax", "PATCH requests must use the %s schema", PatchOpSchema)
+	}
+	if len(patch.Operations) == 0 {
+		return nil, badRequest("invalidSyntax", "PATCH requests need at least one operation")
+	}
+
+	c := changes{set: bson.M{}, unset: bson.M{}}
+	for _, op := range patch.Operations {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if op.Path != "" {
+				if err := c.apply(emp, op.Path, op.Value, false); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			// without a path the value is an object of attributes to set
+			var values map[string]json.RawMessage
+			if err := json.Unmarshal(op.Value, &values); err != nil {
+				return nil, badRequest("invalidValue", "%s without a path needs an object value", op.Op)
+			}
+			for attr, value := range values {
+				if strings.EqualFold(attr, "schemas") {
+					continue
+				}
+				if err := c.apply(emp, attr, value, false); err != nil {
+					return nil, err
+				}
+			}
+		case "remove":
+			if op.Path == "" {
+				return nil, badRequest("noTarget", "remove needs a path")
+			}
+			if err := c.apply(emp, op.Path, nil, true); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, badRequest("invalidSyntax", "unknown operation %q", op.Op)
+		}
+	}
+	return c.update(), nil
+}
+
+// apply sets or removes the attribute at path
+func (c changes) apply(emp *models.Employee, path string, value json.RawMessage, remove bool) error {
+	path, err := resolveValueFilter(emp, path)
+	if err != nil {
+		return err
+	}
+
+	segments := splitPath(path)
+	if strings.HasPrefix(strings.ToLower(segments[0]), "urn:") {
+		if strings.EqualFold(segments[0], EnterpriseUserSchema) {
+			return badRequest("mutability", "%s is read-only; it follows the employee's job history", path)
+		}
+		return badRequest("invalidPath", "unknown attribute %s", path)
+	}
+	key := strings.ToLower(strings.Join(segments, "."))
+
+	for _, readOnly := range readOnlyAttributes {
+		if key == readOnly || strings.HasPrefix(key, readOnly+".") {
+			return badRequest("mutability", "%s is read-only; job and status changes go through the HR lifecycle actions", path)
+		}
+	}
+
+	switch key {
+	case "name":
+		if remove {
+			return badRequest("invalidValue", "name.givenName and name.familyName are required")
+		}
+		var parts map[string]json.RawMessage
+		if err := json.Unmarshal(value, &parts); err != nil {
+			return badRequest("invalidValue", "name must be an object")
+		}
+		for sub, subValue := range parts {
+			if err := c.apply(emp, "name."+sub, subValue, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "emails", "phonenumbers":
+		// a single work email and phone are kept, so the primary entry (or the first) wins
+		if !remove {
+			var entries []MultiValue
+			if err := json.Unmarshal(value, &entries); err != nil {
+				return badRequest("invalidValue", "%s must be an array", path)
+			}
+			if len(entries) > 0 {
+				entry := entries[0]
+				for _, e := range entries {
+					if e.Primary {
+						entry = e
+						break
+					}
+				}
+				value, _ = json.Marshal(entry.Value)
+			} else {
+				remove = true
+			}
+		}
+		key += ".value"
+	}
+
+	field, ok := userFields[key]
+	if !ok {
+		return badRequest("invalidPath", "unknown attribute %s", path)
+	}
+	dst := field.get(emp)
+
+	if remove {
+		if field.required {
+			return badRequest("invalidValue", "%s is required", path)
+		}
+		*dst = ""
+		delete(c.set, field.field)
+		c.unset[field.field] = ""
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		// emails[type eq "work"] may be replaced with a whole entry
+		var entry MultiValue
+		if !strings.HasSuffix(key, ".value") || json.Unmarshal(value, &entry) != nil {
+			return badRequest("invalidValue", "%s must be a string", path)
+		}
+		s = entry.Value
+	}
+	if s == "" && field.required {
+		return badRequest("invalidValue", "%s is required", path)
+	}
+	*dst = s
+	delete(c.unset, field.field)
+	c.set[field.field] = s
+	return nil
+}
+
+// resolveValueFilter checks a path like emails[type eq "work"].value against the employee's
+// single work email or phone, returning the path without the filter.
+func resolveValueFilter(emp *models.Employee, path string) (string, error) {
+	open := strings.Index(path, "[")
+	if open < 0 {
+		return path, nil
+	}
+	end := strings.LastIndex(path, "]")
+	if end < open {
+		return "", badRequest("invalidPath", "unbalanced brackets in %s", path)
+	}
+	filter, err := ParseFilter(path[open+1 : end])
+	if err != nil {
+		return "", badRequest("invalidFilter", "invalid filter in %s: %v", path, err)
+	}
+
+	attr := path[:open]
+	var current string
+	switch strings.ToLower(attr) {
+	case "emails":
+		current = emp.Email
+	case "phonenumbers":
+		current = emp.Phone
+	default:
+		return "", badRequest("invalidPath", "%s does not support value filters", attr)
+	}
+	if current == "" || !filter.Matches(map[string]interface{}{"value": current, "type": "work", "primary": attr == "emails"}) {
+		return "", badRequest("noTarget", "no value of %s matches the filter", attr)
+	}
+
+	rest := path[end+1:]
+	if rest == "" {
+		rest = ".value"
+	}
+	return attr + rest, nil
+}
+
+func hasSchema(schemas []string, schema string) bool {
+	for _, s := range schemas {
+		if strings.EqualFold(s, schema) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+// Package scim serves employees as SCIM 2.0 (RFC 7643, RFC 7644) Users and departments as Groups,
+// so identity providers can provision accounts from HR as the source of truth.
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"hcmnext/models"
+)
+
+// Schema and message URNs
+const (
+	UserSchema             = "urn:ietf:params:scim:schemas:core:2.0:User"
+	EnterpriseUserSchema   = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	GroupSchema            = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ListResponseSchema     = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema          = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema            = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaSchema           = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+	ResourceTypeSchema     = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	ServiceProviderSchema  = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	defaultCount, maxCount = 100, 200
+)
+
+// Meta describes a resource
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location,omitempty"`
+}
+
+// Name is the components of a user's name
+type Name struct {
+	Formatted       string `json:"formatted,omitempty"`
+	FamilyName      string `json:"familyName,omitempty"`
+	GivenName       string `json:"givenName,omitempty"`
+	MiddleName      string `json:"middleName,omitempty"`
+	HonorificSuffix string `json:"honorificSuffix,omitempty"`
+}
+
+// MultiValue is an entry of a multi-valued attribute such as emails or members
+type MultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// ManagerRef is the manager attribute of the enterprise extension
+type ManagerRef struct {
+	Value       string `json:"value,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+// EnterpriseUser is the enterprise extension of a User
+type EnterpriseUser struct {
+	EmployeeNumber string      `json:"employeeNumber"`
+	Department     string      `json:"department,omitempty"`
+	Manager        *ManagerRef `json:"manager,omitempty"`
+}
+
+// User is an employee as a SCIM User
+type User struct {
+	Schemas      []string        `json:"schemas"`
+	ID           string          `json:"id"`
+	UserName     string          `json:"userName"`
+	Name         Name            `json:"name"`
+	DisplayName  string          `json:"displayName,omitempty"`
+	NickName     string          `json:"nickName,omitempty"`
+	Title        string          `json:"title,omitempty"`
+	UserType     string          `json:"userType,omitempty"`
+	Active       bool            `json:"active"`
+	Emails       []MultiValue    `json:"emails,omitempty"`
+	PhoneNumbers []MultiValue    `json:"phoneNumbers,omitempty"`
+	Groups       []MultiValue    `json:"groups,omitempty"`
+	Enterprise   *EnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+	Meta         Meta            `json:"meta"`
+}
+
+// Group is a department as a SCIM Group
+type Group struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id"`
+	DisplayName string       `json:"displayName"`
+	Members     []MultiValue `json:"members"`
+	Meta        Meta         `json:"meta"`
+}
+
+// ListResponse is a page of query results
+type ListResponse struct {
+	Schemas      []string                 `json:"schemas"`
+	TotalResults int                      `json:"totalResults"`
+	StartIndex   int                      `json:"startIndex"`
+	ItemsPerPage int                      `json:"itemsPerPage"`
+	Resources    []map[string]interface{} `json:"Resources"`
+}
+
+// Error is a SCIM error response; it is also returned as the error of failed operations
+type Error struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+// MarshalJSON renders the error in the SCIM error schema, which carries the status as a string
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Schemas  []string `json:"schemas"`
+		Status   string   `json:"status"`
+		ScimType string   `json:"scimType,omitempty"`
+		Detail   string   `json:"detail"`
+	}{[]string{ErrorSchema}, strconv.Itoa(e.Status), e.ScimType, e.Detail})
+}
+
+func badRequest(scimType, format string, args ...interface{}) *Error {
+	return &Error{Status: http.StatusBadRequest, ScimType: scimType, Detail: fmt.Sprintf(format, args...)}
+}
+
+func notFound(format string, args ...interface{}) *Error {
+	return &Error{Status: http.StatusNotFound, Detail: fmt.Sprintf(format, args...)}
+}
+
+// NewUser maps an employee to a User as of asOf. Department, title and manager come from the job
+// in effect that day; the user is active while employed. baseURL is the root of the SCIM API.
+func NewUser(emp *models.Employee, asOf time.Time, baseURL string) User {
+	user := User{
+		Schemas:  []string{UserSchema, EnterpriseUserSchema},
+		ID:       emp.EmployeeID,
+		UserName: emp.Email,
+		Name: Name{
+			Formatted:       formattedName(emp),
+			FamilyName:      emp.LastName,
+			GivenName:       emp.FirstName,
+			MiddleName:      emp.MiddleName,
+			HonorificSuffix: emp.Suffix,
+		},
+		DisplayName: displayName(emp),
+		NickName:    emp.PreferredName,
+		Enterprise:  &EnterpriseUser{EmployeeNumber: emp.EmployeeID},
+		Meta:        Meta{ResourceType: "User", Location: baseURL + "/Users/" + emp.EmployeeID},
+	}
+	if emp.Email != "" {
+		user.Emails = []MultiValue{{Value: emp.Email, Type: "work", Primary: true}}
+	}
+	if emp.Phone != "" {
+		user.PhoneNumbers = []MultiValue{{Value: emp.Phone, Type: "work"}}
+	}
+
+	job := emp.JobAsOf(asOf)
+	if job == nil {
+		return user
+	}
+	status := emp.StatusAsOf(asOf)
+	user.Active = status == nil || (status.Status != "Terminated" && status.Status != "Retired")
+	user.Title = job.Title
+	user.UserType = job.EmploymentType
+	user.Enterprise.Department = job.Department
+	if job.Department != "" {
+		id := GroupID(job.Department)
+		user.Groups = []MultiValue{{Value: id, Display: job.Department, Type: "direct", Ref: baseURL + "/Groups/" + id}}
+	}
+	if job.Manager != nil && job.Manager.EmployeeID != "" && job.Manager.EmployeeID != emp.EmployeeID {
+		user.Enterprise.Manager = &ManagerRef{
+			Value:       job.Manager.EmployeeID,
+			Ref:         baseURL + "/Users/" + job.Manager.EmployeeID,
+			DisplayName: job.Manager.Name,
+		}
+	}
+	return user
+}
+
+// GroupID derives the id of a department's group from its name
+func GroupID(department string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(department)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// NewGroups builds a group for every department with active users, listing those users as members
+func NewGroups(users []User, baseURL string) []Group {
+	groups := make(map[string]*Group)
+	var order []string
+	for _, user := range users {
+		if !user.Active || user.Enterprise.Department == "" {
+			continue
+		}
+		department := user.Enterprise.Department
+		id := GroupID(department)
+		group, ok := groups[id]
+		if !ok {
+			group = &Group{
+				Schemas:     []string{GroupSchema},
+				ID:          id,
+				DisplayName: department,
+				Members:     []MultiValue{},
+				Meta:        Meta{ResourceType: "Group", Location: baseURL + "/Groups/" + id},
+			}
+			groups[id] = group
+			order = append(order, id)
+		}
+		group.Members = append(group.Members, MultiValue{
+			Value:   user.ID,
+			Display: user.DisplayName,
+			Type:    "User",
+			Ref:     user.Meta.Location,
+		})
+	}
+
+	result := make([]Group, len(order))
+	for i, id := range order {
+		result[i] = *groups[id]
+	}
+	return result
+}
+
+func displayName(emp *models.Employee) string {
+	first := emp.FirstName
+	if emp.PreferredName != "" {
+		first = emp.PreferredName
+	}
+	return strings.TrimSpace(first + " " + emp.LastName)
+}
+
+func formattedName(emp *models.Employee) string {
+	parts := []string{emp.FirstName, emp.MiddleName, emp.LastName, emp.Suffix}
+	var nonEmpty []string
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, " ")
+}
@@ -0,0 +1,205 @@
+package scim
+
+import "hcmnext/auth"
+
+// Attribute describes an attribute of a schema (RFC 7643 section 7)
+type Attribute struct {
+	Name           string      `json:"name"`
+	Type           string      `json:"type"`
+	MultiValued    bool        `json:"multiValued"`
+	Description    string      `json:"description,omitempty"`
+	Required       bool        `json:"required"`
+	CaseExact      bool        `json:"caseExact"`
+	Mutability     string      `json:"mutability"`
+	Returned       string      `json:"returned"`
+	Uniqueness     string      `json:"uniqueness"`
+	ReferenceTypes []string    `json:"referenceTypes,omitempty"`
+	SubAttributes  []Attribute `json:"subAttributes,omitempty"`
+}
+
+// Schema is a resource schema served from /Schemas
+type Schema struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Attributes  []Attribute `json:"attributes"`
+	Meta        Meta        `json:"meta"`
+}
+
+// ResourceType is a resource endpoint served from /ResourceTypes
+type ResourceType struct {
+	Schemas          []string          `json:"schemas"`
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	Endpoint         string            `json:"endpoint"`
+	Description      string            `json:"description"`
+	Schema           string            `json:"schema"`
+	SchemaExtensions []SchemaExtension `json:"schemaExtensions,omitempty"`
+	Meta             Meta              `json:"meta"`
+}
+
+// SchemaExtension names an extension schema of a resource type
+type SchemaExtension struct {
+	Schema   string `json:"schema"`
+	Required bool   `json:"required"`
+}
+
+// attr builds a single-valued, case-insensitive, non-unique attribute
+func attr(name, typ, mutability, description string) Attribute {
+	return Attribute{Name: name, Type: typ, Description: description, Mutability: mutability, Returned: "default", Uniqueness: "none"}
+}
+
+func required(a Attribute) Attribute {
+	a.Required = true
+	return a
+}
+
+func multiValued(a Attribute, subAttributes ...Attribute) Attribute {
+	a.MultiValued = true
+	a.SubAttributes = subAttributes
+	return a
+}
+
+func complexAttr(a Attribute, subAttributes ...Attribute) Attribute {
+	a.SubAttributes = subAttributes
+	return a
+}
+
+func reference(a Attribute, types ...string) Attribute {
+	a.ReferenceTypes = types
+	return a
+}
+
+// Schemas returns the User, enterprise User and Group schemas with the mutability this server enforces.
+// baseURL is the root of the SCIM API.
+func Schemas(baseURL string) []Schema {
+	userName := required(attr("userName", "string", "readWrite", "The employee's work email"))
+	userName.Uniqueness = "server"
+	id := attr("id", "string", "readOnly", "The employee id")
+	id.CaseExact = true
+	id.Returned = "always"
+	groupID := id
+	groupID.Description = "Derived from the department name"
+
+	user := Schema{
+		ID:          UserSchema,
+		Name:        "User",
+		Description: "An employee",
+		Attributes: []Attribute{
+			id,
+			userName,
+			complexAttr(attr("name", "complex", "readWrite", "The components of the employee's name"),
+				attr("formatted", "string", "readOnly", "The full name"),
+				required(attr("familyName", "string", "readWrite", "")),
+				required(attr("givenName", "string", "readWrite", "")),
+				attr("middleName", "string", "readWrite", ""),
+				attr("honorificSuffix", "string", "readWrite", ""),
+			),
+			attr("displayName", "string", "readOnly", "The preferred or first name with the last name"),
+			attr("nickName", "string", "readWrite", "The employee's preferred name"),
+			attr("title", "string", "readOnly", "The title of the current job"),
+			attr("userType", "string", "readOnly", "The employment type of the current job"),
+			attr("active", "boolean", "readOnly", "Whether the employee is employed today"),
+			multiValued(attr("emails", "complex", "readWrite", "The work email"),
+				attr("value", "string", "readWrite", ""),
+				attr("type", "string", "readOnly", ""),
+				attr("primary", "boolean", "readOnly", ""),
+			),
+			multiValued(attr("phoneNumbers", "complex", "readWrite", "The work phone, in E.164 format"),
+				attr("value", "string", "readWrite", ""),
+				attr("type", "string", "readOnly", ""),
+			),
+			multiValued(attr("groups", "complex", "readOnly", "The department group of the current job"),
+				attr("value", "string", "readOnly", ""),
+				reference(attr("$ref", "reference", "readOnly", ""), "Group"),
+				attr("display", "string", "readOnly", ""),
+				attr("type", "string", "readOnly", ""),
+			),
+		},
+	}
+
+	enterprise := Schema{
+		ID:          EnterpriseUserSchema,
+		Name:        "EnterpriseUser",
+		Description: "Enterprise attributes of an employee, taken from the current job",
+		Attributes: []Attribute{
+			attr("employeeNumber", "string", "readOnly", "The employee id"),
+			attr("department", "string", "readOnly", "The department of the current job"),
+			complexAttr(attr("manager", "complex", "readOnly", "The manager of the current job"),
+				attr("value", "string", "readOnly", ""),
+				reference(attr("$ref", "reference", "readOnly", ""), "User"),
+				attr("displayName", "string", "readOnly", ""),
+			),
+		},
+	}
+
+	group := Schema{
+		ID:          GroupSchema,
+		Name:        "Group",
+		Description: "A department and the employees currently working in it",
+		Attributes: []Attribute{
+			groupID,
+			required(attr("displayName", "string", "readOnly", "The department name")),
+			multiValued(attr("members", "complex", "readOnly", "The active employees of the department"),
+				attr("value", "string", "readOnly", ""),
+				reference(attr("$ref", "reference", "readOnly", ""), "User"),
+				attr("display", "string", "readOnly", ""),
+				attr("type", "string", "readOnly", ""),
+			),
+		},
+	}
+
+	schemas := []Schema{user, enterprise, group}
+	for i := range schemas {
+		schemas[i].Schemas = []string{SchemaSchema}
+		schemas[i].Meta = Meta{ResourceType: "Schema", Location: baseURL + "/Schemas/" + schemas[i].ID}
+	}
+	return schemas
+}
+
+// ResourceTypes returns the User and Group resource types
+func ResourceTypes(baseURL string) []ResourceType {
+	return []ResourceType{
+		{
+			Schemas:          []string{ResourceTypeSchema},
+			ID:               "User",
+			Name:             "User",
+			Endpoint:         "/Users",
+			Description:      "Employees",
+			Schema:           UserSchema,
+			SchemaExtensions: []SchemaExtension{{Schema: EnterpriseUserSchema, Required: true}},
+			Meta:             Meta{ResourceType: "ResourceType", Location: baseURL + "/ResourceTypes/User"},
+		},
+		{
+			Schemas:     []string{ResourceTypeSchema},
+			ID:          "Group",
+			Name:        "Group",
+			Endpoint:    "/Groups",
+			Description: "Departments",
+			Schema:      GroupSchema,
+			Meta:        Meta{ResourceType: "ResourceType", Location: baseURL + "/ResourceTypes/Group"},
+		},
+	}
+}
+
+// ServiceProviderConfig describes the features this server supports
+func ServiceProviderConfig(baseURL string) map[string]interface{} {
+	return map[string]interface{}{
+		"schemas":          []string{ServiceProviderSchema},
+		"documentationUri": "https://datatracker.ietf.org/doc/html/rfc7644",
+		"patch":            map[string]bool{"supported": true},
+		"bulk":             map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":           map[string]interface{}{"supported": true, "maxResults": maxCount},
+		"changePassword":   map[string]bool{"supported": false},
+		"sort":             map[string]bool{"supported": false},
+		"etag":             map[string]bool{"supported": false},
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "A bearer token granted the " + auth.RoleProvision + " role",
+			"primary":     true,
+		}},
+		"meta": Meta{ResourceType: "ServiceProviderConfig", Location: baseURL + "/ServiceProviderConfig"},
+	}
+}
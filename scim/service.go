@@ -0,0 +1,472 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"hcmnext/database"
+	"hcmnext/events"
+	"hcmnext/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Query holds the list parameters of RFC 7644 section 3.4.2
+type Query struct {
+	Filter             Filter
+	StartIndex         int
+	Count              int
+	Attributes         []string
+	ExcludedAttributes []string
+}
+
+// ParseQuery reads filter, startIndex, count, attributes and excludedAttributes from query parameters
+func ParseQuery(values url.Values) (Query, error) {
+	q := Query{
+		StartIndex:         1,
+		Count:              defaultCount,
+		Attributes:         splitList(values.Get("attributes")),
+		ExcludedAttributes: splitList(values.Get("excludedAttributes")),
+	}
+
+	if expr := values.Get("filter"); expr != "" {
+		filter, err := ParseFilter(expr)
+		if err != nil {
+			return q, badRequest("invalidFilter", "invalid filter: %v", err)
+		}
+		q.Filter = filter
+	}
+
+	// out of range values are clamped rather than rejected, as the RFC asks
+	if param := values.Get("startIndex"); param != "" {
+		index, err := strconv.Atoi(param)
+		if err != nil {
+			return q, badRequest("invalidValue", "startIndex must be an integer")
+		}
+		q.StartIndex = max(index, 1)
+	}
+	if param := values.Get("count"); param != "" {
+		count, err := strconv.Atoi(param)
+		if err != nil {
+			return q, badRequest("invalidValue", "count must be an integer")
+		}
+		q.Count = min(max(count, 0), maxCount)
+	}
+	return q, nil
+}
+
+func splitList(param string) []string {
+	var list []string
+	for _, item := range strings.Split(param, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Service reads and updates employees through the SCIM resource model
+type Service struct {
+	db *database.Database
+}
+
+// NewService creates a new instance of Service
+func NewService(db *database.Database) *Service {
+	return &Service{db: db}
+}
+
+// ListUsers returns the page of users matching q. Filters on userName, id, externalId and active
+// are run by the database, which also pages the result; other filters are checked on every user.
+func (s *Service) ListUsers(ctx context.Context, q Query, baseURL string) (ListResponse, error) {
+	now := time.Now()
+	query, ok := userQuery(q.Filter, now)
+	if !ok {
+		users, err := s.users(ctx, bson.M{}, baseURL)
+		if err != nil {
+			return ListResponse{}, err
+		}
+		resources := make([]interface{}, len(users))
+		for i := range users {
+			resources[i] = users[i]
+		}
+		return NewListResponse(resources, q)
+	}
+
+	db := s.db.For(ctx)
+	total, err := db.CountDocuments("Employee", query)
+	if err != nil {
+		return ListResponse{}, err
+	}
+	var page []map[string]interface{}
+	if q.Count > 0 && int64(q.StartIndex) <= total {
+		cursor, err := db.FindPage("Employee", query, bson.D{{Key: "employeeId", Value: 1}}, int64(q.StartIndex-1), int64(q.Count))
+		if err != nil {
+			return ListResponse{}, err
+		}
+		defer cursor.Close(ctx)
+
+		var employees []models.Employee
+		if err := cursor.All(ctx, &employees); err != nil {
+			return ListResponse{}, err
+		}
+		for i := range employees {
+			doc, err := toMap(NewUser(&employees[i], now, baseURL))
+			if err != nil {
+				return ListResponse{}, err
+			}
+			page = append(page, doc)
+		}
+	}
+	return listPage(int(total), page, q), nil
+}
+
+// GetUser returns the user for an employee id
+func (s *Service) GetUser(ctx context.Context, id, baseURL string) (User, error) {
+	emp, err := s.employee(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+	return NewUser(&emp, time.Now(), baseURL), nil
+}
+
+// ListGroups returns the page of department groups matching q
+func (s *Service) ListGroups(ctx context.Context, q Query, baseURL string) (ListResponse, error) {
+	users, err := s.users(ctx, activeQuery(time.Now(), true), baseURL)
+	if err != nil {
+		return ListResponse{}, err
+	}
+	groups := NewGroups(users, baseURL)
+	resources := make([]interface{}, len(groups))
+	for i := range groups {
+		resources[i] = groups[i]
+	}
+	return NewListResponse(resources, q)
+}
+
+// GetGroup returns the group of a department by its id
+func (s *Service) GetGroup(ctx context.Context, id, baseURL string) (Group, error) {
+	users, err := s.users(ctx, activeQuery(time.Now(), true), baseURL)
+	if err != nil {
+		return Group{}, err
+	}
+	for _, group := range NewGroups(users, baseURL) {
+		if group.ID == id {
+			return group, nil
+		}
+	}
+	return Group{}, notFound("Group %s not found", id)
+}
+
+// PatchUser applies a PATCH request to an employee and returns the updated user.
+// Only the name, nickName, email and phone can change; job and status data is read-only
+// here and changes through the lifecycle actions.
+func (s *Service) PatchUser(ctx context.Context, id string, patch PatchRequest, baseURL string) (User, error) {
+	var emp models.Employee
+	err := s.db.WithTransaction(ctx, func(tx *database.Tx) error {
+		emp = models.Employee{}
+		if err := tx.FindOne("Employee", bson.M{"employeeId": id}, &emp); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return notFound("User %s not found", id)
+			}
+			return err
+		}
+		before := emp
+
+		update, err := applyPatch(&emp, patch)
+		if err != nil || len(update) == 0 {
+			return err
+		}
+		if err := emp.Validate(); err != nil {
+			return badRequest("invalidValue", "%v", err)
+		}
+		if _, err := tx.UpdateOne("Employee", bson.M{"employeeId": id}, update); err != nil {
+			return err
+		}
+		changes, err := events.EmployeeChanges(ctx, &before, &emp)
+		if err != nil {
+			return err
+		}
+		return events.Record(tx, changes...)
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return NewUser(&emp, time.Now(), baseURL), nil
+}
+
+func (s *Service) employee(ctx context.Context, id string) (models.Employee, error) {
+	var emp models.Employee
+	err := s.db.For(ctx).FindOne("Employee", bson.M{"employeeId": id}, &emp)
+	if err == mongo.ErrNoDocuments {
+		return emp, notFound("User %s not found", id)
+	}
+	return emp, err
+}
+
+// users maps the employees matching query to users as of today, ordered by id
+func (s *Service) users(ctx context.Context, query bson.M, baseURL string) ([]User, error) {
+	cursor, err := s.db.For(ctx).FindSorted("Employee", query, bson.D{{Key: "employeeId", Value: 1}}, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var employees []models.Employee
+	if err := cursor.All(ctx, &employees); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	users := make([]User, len(employees))
+	for i := range employees {
+		users[i] = NewUser(&employees[i], now, baseURL)
+	}
+	return users, nil
+}
+
+// matchNone is a query no document matches
+var matchNone = bson.M{"$expr": false}
+
+// userQuery translates a filter on the userName, id, externalId and active attributes of users,
+// combined with and, or and not, into a query on employees as of asOf. It reports false for
+// filters on any other attribute or with any other operator.
+func userQuery(f Filter, asOf time.Time) (bson.M, bool) {
+	switch f := f.(type) {
+	case nil:
+		return bson.M{}, true
+	case logicalFilter:
+		left, ok := userQuery(f.left, asOf)
+		if !ok {
+			return nil, false
+		}
+		right, ok := userQuery(f.right, asOf)
+		if !ok {
+			return nil, false
+		}
+		if f.and {
+			return bson.M{"$and": bson.A{left, right}}, true
+		}
+		return bson.M{"$or": bson.A{left, right}}, true
+	case notFilter:
+		inner, ok := userQuery(f.inner, asOf)
+		if !ok {
+			return nil, false
+		}
+		return bson.M{"$nor": bson.A{inner}}, true
+	case compareFilter:
+		if f.op != "eq" {
+			return nil, false
+		}
+		switch strings.ToLower(f.path) {
+		case "username", "id":
+			field := "email"
+			if strings.EqualFold(f.path, "id") {
+				field = "employeeId"
+			}
+			value, ok := f.value.(string)
+			if !ok {
+				return matchNone, true
+			}
+			// string attributes compare ignoring case
+			return bson.M{field: primitive.Regex{Pattern: "^" + regexp.QuoteMeta(value) + "$", Options: "i"}}, true
+		case "externalid":
+			// users carry no externalId
+			return matchNone, true
+		case "active":
+			value, ok := f.value.(bool)
+			if !ok {
+				return matchNone, true
+			}
+			return activeQuery(asOf, value), true
+		}
+	}
+	return nil, false
+}
+
+// activeQuery matches the employees whose user is active, or inactive, on the day of asOf: those
+// with a job that day whose latest status is neither Terminated nor Retired, as NewUser decides
+func activeQuery(asOf time.Time, active bool) bson.M {
+	y, m, d := asOf.UTC().Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	next := day.AddDate(0, 0, 1)
+
+	hasJob := bson.M{"$gt": bson.A{bson.M{"$size": bson.M{"$filter": bson.M{
+		"input": bson.M{"$ifNull": bson.A{"$jobHistory", bson.A{}}},
+		"cond": bson.M{"$and": bson.A{
+			bson.M{"$lt": bson.A{"$$this.startDate", next}},
+			bson.M{"$or": bson.A{
+				bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$$this.endDate", nil}}, nil}},
+				bson.M{"$gte": bson.A{"$$this.endDate", day}},
+			}},
+		}},
+	}}}, 0}}
+
+	// the last of the latest status changes on or before the day, as in Employee.StatusAsOf
+	latest := bson.M{"$reduce": bson.M{
+		"input": bson.M{"$filter": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$statusHistory", bson.A{}}},
+			"cond":  bson.M{"$lt": bson.A{"$$this.date", next}},
+		}},
+		"initialValue": nil,
+		"in": bson.M{"$cond": bson.A{
+			bson.M{"$or": bson.A{bson.M{"$eq": bson.A{"$$value", nil}}, bson.M{"$gte": bson.A{"$$this.date", "$$value.date"}}}},
+			"$$this",
+			"$$value",
+		}},
+	}}
+	notLeft := bson.M{"$not": bson.A{bson.M{"$in": bson.A{
+		bson.M{"$let": bson.M{"vars": bson.M{"latest": latest}, "in": bson.M{"$ifNull": bson.A{"$$latest.status", ""}}}},
+		bson.A{"Terminated", "Retired"},
+	}}}}
+
+	expr := bson.M{"$and": bson.A{hasJob, notLeft}}
+	if !active {
+		expr = bson.M{"$not": bson.A{expr}}
+	}
+	return bson.M{"$expr": expr}
+}
+
+// NewListResponse filters resources, then projects the page q asks for
+func NewListResponse(resources []interface{}, q Query) (ListResponse, error) {
+	var matched []map[string]interface{}
+	for _, resource := range resources {
+		doc, err := toMap(resource)
+		if err != nil {
+			return ListResponse{}, err
+		}
+		if q.Filter == nil || q.Filter.Matches(doc) {
+			matched = append(matched, doc)
+		}
+	}
+
+	start := min(q.StartIndex-1, len(matched))
+	end := min(start+q.Count, len(matched))
+	return listPage(len(matched), matched[start:end], q), nil
+}
+
+// listPage answers q with the page of a result of total resources, projecting each
+func listPage(total int, page []map[string]interface{}, q Query) ListResponse {
+	list := ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: total,
+		StartIndex:   q.StartIndex,
+		Resources:    []map[string]interface{}{},
+	}
+	for _, doc := range page {
+		list.Resources = append(list.Resources, project(doc, q.Attributes, q.ExcludedAttributes))
+	}
+	list.ItemsPerPage = len(list.Resources)
+	return list
+}
+
+// Project renders a resource with only the requested attributes, or without the excluded ones.
+// The schemas, id and meta attributes are always returned.
+func Project(resource interface{}, attributes, excluded []string) (map[string]interface{}, error) {
+	doc, err := toMap(resource)
+	if err != nil {
+		return nil, err
+	}
+	return project(doc, attributes, excluded), nil
+}
+
+func project(doc map[string]interface{}, attributes, excluded []string) map[string]interface{} {
+	if len(attributes) > 0 {
+		kept := map[string]interface{}{"schemas": doc["schemas"], "id": doc["id"], "meta": doc["meta"]}
+		for _, attr := range attributes {
+			copyPath(doc, kept, splitPath(attr))
+		}
+		doc = kept
+	}
+	for _, attr := range excluded {
+		segments := splitPath(attr)
+		if len(segments) == 1 && (strings.EqualFold(attr, "schemas") || strings.EqualFold(attr, "id") || strings.EqualFold(attr, "meta")) {
+			continue
+		}
+		removePath(doc, segments)
+	}
+	return doc
+}
+
+func copyPath(src, dst map[string]interface{}, segments []string) {
+	key, ok := findKey(src, segments[0])
+	if !ok {
+		return
+	}
+	if len(segments) == 1 {
+		dst[key] = src[key]
+		return
+	}
+
+	switch child := src[key].(type) {
+	case map[string]interface{}:
+		sub, _ := dst[key].(map[string]interface{})
+		if sub == nil {
+			sub = map[string]interface{}{}
+			dst[key] = sub
+		}
+		copyPath(child, sub, segments[1:])
+	case []interface{}:
+		// sub-attributes of a multi-valued attribute are kept in every element
+		subs, _ := dst[key].([]interface{})
+		if subs == nil {
+			subs = make([]interface{}, len(child))
+			for i := range subs {
+				subs[i] = map[string]interface{}{}
+			}
+			dst[key] = subs
+		}
+		for i, element := range child {
+			if obj, ok := element.(map[string]interface{}); ok {
+				copyPath(obj, subs[i].(map[string]interface{}), segments[1:])
+			}
+		}
+	}
+}
+
+func removePath(doc map[string]interface{}, segments []string) {
+	key, ok := findKey(doc, segments[0])
+	if !ok {
+		return
+	}
+	if len(segments) == 1 {
+		delete(doc, key)
+		return
+	}
+	switch child := doc[key].(type) {
+	case map[string]interface{}:
+		removePath(child, segments[1:])
+	case []interface{}:
+		for _, element := range child {
+			if obj, ok := element.(map[string]interface{}); ok {
+				removePath(obj, segments[1:])
+			}
+		}
+	}
+}
+
+// findKey returns the key of obj matching name without regard to case
+func findKey(obj map[string]interface{}, name string) (string, bool) {
+	for key := range obj {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+func toMap(resource interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	err = json.Unmarshal(raw, &doc)
+	return doc, err
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"hcmnext/config"
+	"hcmnext/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// seedBatchSize is the number of documents written per bulk write
+const seedBatchSize = 500
+
+// maxFixtureLine bounds the size of one fixture document
+const maxFixtureLine = 16 << 20
+
+// seed loads NDJSON fixture files, one document per line in MongoDB extended JSON, into the
+// collection named by each file, e.g. Employee.ndjson into Employee. Documents replace those
+// with the same key, such as employeeId, so seeding again updates rather than duplicates.
+func seed(args []string) {
+	var tenant string
+	cfg := loadConfig(config.Command{Name: "seed", Database: true, Flags: func(flags *flag.FlagSet) {
+		tenantsFlag(flags, &tenant)
+	}}, args)
+	if len(cfg.Args) == 0 {
+		fatal("Nothing to seed", errors.New("name the NDJSON fixture files to load"))
+	}
+
+	db := openDatabase(cfg)
+	defer db.Close()
+	if db.MultiTenant() && tenant == "" {
+		db.Close()
+		fatal("Nothing to seed", errors.New("-tenant is required when tenants are isolated"))
+	}
+
+	ctx := operatorContext(tenant)
+	for _, path := range cfg.Args {
+		count, err := seedFile(ctx, db, path)
+		if err != nil {
+			db.Close()
+			fatal("Failed to seed "+path, err)
+		}
+		slog.Info("Fixtures loaded", "file", path, "documents", count)
+	}
+}
+
+// seedFile loads the documents of the fixture file at path
+func seedFile(ctx context.Context, db *database.Database, path string) (int, error) {
+	collection := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	key := database.KeyField(collection)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	count := 0
+	var batch []mongo.WriteModel
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		_, err := db.For(ctx).BulkWrite(collection, batch)
+		batch = batch[:0]
+		return err
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxFixtureLine)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var doc bson.M
+		if err := bson.UnmarshalExtJSON(scanner.Bytes(), false, &doc); err != nil {
+			return count, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if id, ok := doc[key]; ok && key != "" {
+			batch = append(batch, mongo.NewReplaceOneModel().SetFilter(bson.M{key: id}).SetReplacement(doc).SetUpsert(true))
+		} else {
+			batch = append(batch, mongo.NewInsertOneModel().SetDocument(doc))
+		}
+		count++
+		if len(batch) == seedBatchSize {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+	return count, flush()
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"hcmnext/ai"
+	"hcmnext/auth"
+	"hcmnext/bulk"
+	"hcmnext/config"
+	"hcmnext/controller"
+	"hcmnext/database"
+	"hcmnext/events"
+	"hcmnext/health"
+	"hcmnext/hr"
+	"hcmnext/logging"
+	"hcmnext/router"
+	"hcmnext/scim"
+	"hcmnext/tenant"
+	"hcmnext/tracing"
+	"hcmnext/vectorstore"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// serve runs the server until it receives SIGINT or SIGTERM
+func serve(args []string) {
+	cfg := loadConfig(config.Serve, args)
+
+	// Trace requests, model calls and database commands
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+
+	aiClient := newAIClient(cfg)
+
+	db := openDatabase(cfg)
+	defer db.Close()
+
+	tenants := tenant.NewRegistry(db)
+	aiClient.SetTenants(tenants)
+
+	// Check for collections and count their contents
+	if db.MultiTenant() {
+		ids, err := db.Tenants(context.Background())
+		if err != nil {
+			slog.Error("Error listing tenants", "err", err)
+		} else {
+			slog.Info("Serving tenants", "tenants", len(ids))
+		}
+	} else {
+		for _, collName := range cfg.Database.Collections {
+			count, err := db.CountDocuments(collName, bson.M{})
+			if err != nil {
+				slog.Error("Error counting documents", "collection", collName, "err", err)
+			} else {
+				slog.Info("Collection ready", "collection", collName, "documents", count)
+			}
+		}
+	}
+
+	// Initialize the vector store used for retrieval
+	vectorStore, err := newVectorStore(cfg.VectorStore)
+	if err != nil {
+		fatal("Failed to initialize vector store", err)
+	}
+	if vectorStore != nil {
+		aiClient.SetVectorStore(vectorStore)
+		go func() {
+			if err := indexRecords(aiClient, db, vectorStore, cfg.VectorStore.HNSWIndexPath); err != nil {
+				slog.Error("Error indexing HR records", "err", err)
+			}
+		}()
+	}
+
+	// Reporting lines for the org chart API and the queryOrgChart tool
+	orgService := hr.NewOrgService(db)
+	aiClient.SetOrgService(orgService)
+
+	// Initialize the controller
+	ctrl := controller.NewController(aiClient, db)
+	ctrl.SetAllowedOrigins(cfg.Server.AllowedOrigins)
+
+	// Initialize the home controller
+	homeCtrl := controller.NewHomeController(cfg.Server.StaticDir)
+
+	// Initialize the Employee API
+	employeeAPI := controller.NewAPI(db)
+	employeeAPI.SetIndex(aiClient)
+
+	// test fn calling
+	testCtrl := controller.NewTestController(aiClient)
+
+	// Initialize the display controller for sanitized AI markup
+	displayCtrl := controller.NewDisplayController(aiClient.Displays())
+
+	// Initialize the lifecycle actions (hire, transfer, promote, terminate, rehire)
+	lifecycleCtrl := controller.NewLifecycleController(hr.NewLifecycle(db))
+
+	// Initialize the org chart controller
+	orgCtrl := controller.NewOrgController(orgService)
+
+	// Initialize position slots and headcount reporting
+	positionCtrl := controller.NewPositionController(hr.NewPositionService(db))
+
+	// Initialize bulk employee imports
+	importCtrl := controller.NewImportController(bulk.NewImporter(db))
+
+	// Initialize bulk exports
+	exportCtrl := controller.NewExportController(bulk.NewExporter(db))
+
+	// Initialize SCIM provisioning for identity providers
+	scimCtrl := controller.NewSCIMController(scim.NewService(db))
+
+	// Initialize webhook subscriptions and deliver domain events from the outbox
+	webhookCtrl := controller.NewWebhookController(events.NewWebhooks(db))
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go events.NewDispatcher(db, events.DefaultDispatcherOptions).Run(backgroundCtx)
+
+	// Push changes to the configured collections to open browser sessions
+	changeHub := events.NewHub()
+	changeCtrl := controller.NewChangeFeedController(changeHub)
+	go db.WatchChanges(backgroundCtx, "browser", cfg.Database.Collections, func(change database.ChangeEvent) {
+		changeHub.Publish(change.Tenant, change)
+	})
+
+	// Keep the records used for retrieval in step with employee and job writes
+	if vectorStore != nil {
+		go db.WatchChanges(backgroundCtx, "retrieval", []string{"Employee", "Job"}, func(change database.ChangeEvent) {
+			ctx := database.WithTenant(backgroundCtx, change.Tenant)
+			if err := aiClient.IndexChange(ctx, db, change); err != nil {
+				slog.Error("Error indexing change for retrieval", "collection", change.Collection, "id", change.ID, "err", err)
+			}
+		})
+	}
+
+	// Initialize the tenant controller
+	tenantCtrl := controller.NewTenantController(tenants)
+
+	// Check the database, the static assets, the Node.js evaluator and optionally the LLM provider
+	// for the health probes; spawning node and calling the provider are cached between probes
+	checker := health.NewChecker(cfg.Health.Timeout)
+	checker.Add(health.Check{Name: "mongo", Run: db.Ping})
+	checker.Add(health.Check{Name: "chat", Run: ctrl.CheckAccepting})
+	checker.Add(health.Check{Name: "static", Run: homeCtrl.CheckAssets, Live: true})
+	checker.Add(health.Check{Name: "evaluator", Live: true, Run: health.Cached(time.Minute, func(ctx context.Context) error {
+		_, err := ai.EvaluatorVersion(ctx)
+		return err
+	})})
+	if cfg.Health.CheckLLM {
+		checker.Add(health.Check{Name: "llm", Run: health.Cached(cfg.Health.LLMInterval, aiClient.Ping), Optional: true})
+	}
+	healthCtrl := controller.NewHealthController(checker, aiClient, db)
+
+	// Initialize the OpenAPI controller, which describes the routes once they are set up
+	openapiCtrl := controller.NewOpenAPIController()
+
+	// Initialize the router with all controllers
+	r := router.NewRouter(ctrl, homeCtrl, employeeAPI, testCtrl, displayCtrl, lifecycleCtrl, orgCtrl, positionCtrl, importCtrl, exportCtrl, scimCtrl, webhookCtrl, changeCtrl, tenantCtrl, healthCtrl, openapiCtrl)
+
+	// Set up the routes
+	if err := r.SetupRoutes(); err != nil {
+		fatal("Failed to describe the API", err)
+	}
+	r.CheckResponses(cfg.Server.CheckResponses)
+
+	// Resolve callers from the configured bearer tokens
+	authenticator, err := auth.NewAuthenticator(cfg.Auth.Tokens)
+	if err != nil {
+		fatal("Failed to parse API tokens", err)
+	}
+
+	// Resolve each request's tenant from its token or from subdomains of the tenancy domain
+	resolver := tenant.NewResolver(tenants, cfg.Tenancy.Domain)
+
+	// Apply reloadable settings when the config file changes or on SIGHUP
+	var shutdownTimeout, drainTimeout atomic.Int64
+	shutdownTimeout.Store(int64(cfg.Server.ShutdownTimeout))
+	drainTimeout.Store(int64(cfg.Server.DrainTimeout))
+	go cfg.Watch(backgroundCtx, func(next *config.Config) {
+		if err := authenticator.SetTokens(next.Auth.Tokens); err != nil {
+			slog.Error("Error applying API tokens", "err", err)
+		}
+		if err := logging.SetLevels(next.Log.Level, next.Log.Packages); err != nil {
+			slog.Error("Error applying log levels", "err", err)
+		}
+		ctrl.SetAllowedOrigins(next.Server.AllowedOrigins)
+		r.CheckResponses(next.Server.CheckResponses)
+		aiClient.SetModel(next.AI.Model)
+		shutdownTimeout.Store(int64(next.Server.ShutdownTimeout))
+		drainTimeout.Store(int64(next.Server.DrainTimeout))
+	})
+
+	// Create a new server
+	srv := &http.Server{
+		Addr:    cfg.Server.Addr,
+		Handler: logging.Middleware(authenticator.Middleware(resolver.Middleware(r.Handler()))),
+	}
+	// end the change feeds so they do not hold up shutdown
+	srv.RegisterOnShutdown(changeHub.Close)
+
+	// Channel to listen for errors coming from the listener.
+	serverErrors := make(chan error, 1)
+
+	// Start the server
+	go func() {
+		slog.Info("WebSocket AI server and Employee API starting", "addr", cfg.Server.Addr)
+		serverErrors <- srv.ListenAndServe()
+	}()
+
+	// Channel to listen for an interrupt or terminate signal from the OS.
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+
+	// Blocking main and waiting for shutdown.
+	select {
+	case err := <-serverErrors:
+		fatal("Error starting server", err)
+
+	case <-shutdown:
+		slog.Info("Starting shutdown")
+		stopBackground()
+
+		// Shutdown does not wait for WebSockets: let the chat answers in progress finish first
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), time.Duration(drainTimeout.Load()))
+		ctrl.Drain(drainCtx)
+		cancelDrain()
+
+		// Give outstanding requests a deadline for completion.
+		timeout := time.Duration(shutdownTimeout.Load())
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		// Asking listener to shut down and shed load.
+		if err := srv.Shutdown(ctx); err != nil {
+			slog.Error("Graceful shutdown did not complete", "timeout", timeout.String(), "err", err)
+			if err := srv.Close(); err != nil {
+				fatal("Error killing server", err)
+			}
+		}
+
+		// Export the spans still buffered
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Error flushing traces", "err", err)
+		}
+	}
+
+	slog.Info("Server gracefully stopped")
+}
+
+// newVectorStore builds the vector store selected by cfg.Kind (hnsw, milvus or none)
+func newVectorStore(cfg config.VectorStore) (vectorstore.Store, error) {
+	switch cfg.Kind {
+	case "hnsw":
+		if cfg.HNSWIndexPath != "" {
+			if index, err := vectorstore.LoadHNSWFile(cfg.HNSWIndexPath); err == nil {
+				slog.Info("Loaded vector index", "path", cfg.HNSWIndexPath, "records", index.Len())
+				return index, nil
+			} else if !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+		return vectorstore.NewHNSW(vectorstore.DefaultHNSWOptions), nil
+
+	case "milvus":
+		milvus := vectorstore.NewMilvus(cfg.Milvus.Address, cfg.Milvus.Token, cfg.Milvus.Database, cfg.Milvus.Collection)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := milvus.EnsureCollection(ctx, ai.EmbeddingDimensions); err != nil {
+			return nil, err
+		}
+		return milvus, nil
+
+	case "none":
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unknown vector store %q", cfg.Kind)
+	}
+}
@@ -2,152 +2,6 @@ let socket;
 let reconnectAttempts = 0;
 const MAX_RECONNECT_ATTEMPTS = 3;
 
-const iframeChrome = (content) => `
-    <!DOCTYPE html>
-    <html lang="en">
-    <head>
-    <meta charset='UTF-8' />
-    <meta name='viewport' content='width=device-width, initial-scale=1.0' />
-    <title>UKG Panel Webpage with Chat</title>
-    <script src='https://cdn.tailwindcss.com'></script>
-    <script src='https://cdn.jsdelivr.net/npm/marked/marked.min.js'></script>
-    <script src='https://cdn.jsdelivr.net/npm/toastify-js'></script>
-    <script src='https://cdn.jsdelivr.net/npm/chart.js'></script>
-    <script src='https://cdnjs.cloudflare.com/ajax/libs/react/18.2.0/umd/react.development.js'></script>
-    <script src='https://cdnjs.cloudflare.com/ajax/libs/react-dom/18.2.0/umd/react-dom.development.js'></script>
-    <script src='https://cdnjs.cloudflare.com/ajax/libs/htm/3.1.1/htm.min.js'></script>
-    <script src="https://cdnjs.cloudflare.com/ajax/libs/three.js/r128/three.min.js"></script>
-
-    <script
-      defer
-      src='https://cdn.jsdelivr.net/npm/mermaid/dist/mermaid.min.js'
-    >
-      const config = {
-        startOnLoad: true,
-        theme: 'default',
-        flowchart: {
-          curve: 'linear',
-          useMaxWidth: true,
-          htmlLabels: true,
-        },
-      };
-      mermaid.initialize(config);
-    </script>
-    <link
-      rel='stylesheet'
-      href='https://cdn.jsdelivr.net/npm/highlight.js/styles/default.min.css'
-    />
-    <link
-      rel='stylesheet'
-      type='text/css'
-      href='https://cdn.jsdelivr.net/npm/toastify-js/src/toastify.min.css'
-    />
-    <script>
-      tailwind.config = {
-        theme: {
-          extend: {
-            colors: {
-              ukg: {
-                blue: '#0066cc',
-                green: '#00a650',
-                orange: '#ff6a39',
-              },
-            },
-          },
-        },
-      };
-    </script>
-    <style>
-      /* Existing styles */
-      @keyframes fadeIn {
-        from {
-          opacity: 0;
-        }
-        to {
-          opacity: 1;
-        }
-      }
-      @keyframes slideIn {
-        from {
-          transform: translateY(20px);
-          opacity: 0;
-        }
-        to {
-          transform: translateY(0);
-          opacity: 1;
-        }
-      }
-      .fade-in {
-        animation: fadeIn 0.5s ease-in-out;
-      }
-      .slide-in {
-        animation: slideIn 0.5s ease-in-out;
-      }
-      #app {
-        display: flex;
-        height: 100vh;
-        overflow: hidden;
-      }
-      #leftPanel {
-        width: 50%;
-        display: flex;
-        flex-direction: column;
-      }
-      #divider {
-        width: 10px;
-        cursor: col-resize;
-        background-color: #d1d5db;
-        transition: background-color 0.3s;
-      }
-      #divider:hover {
-        background-color: #ff6a39;
-      }
-      #rightPanel {
-        flex-grow: 1;
-        border: none;
-      }
-
-      /* New styles for improved UI */
-      .chat-message {
-        margin-bottom: 1rem;
-        padding: 1rem;
-        border-radius: 0.5rem;
-        box-shadow: 0 1px 3px rgba(0, 0, 0, 0.1);
-      }
-      .user-message {
-        border-left: 4px solid #0066cc;
-        background-color: #e6f2ff;
-      }
-      .ai-message {
-        border-left: 4px solid #00a650;
-        background-color: #e6fff2;
-      }
-      .system-message {
-        border-left: 4px solid #ff6a39;
-        background-color: #fff2e6;
-      }
-
-      /* Custom scrollbar styles */
-      .custom-scrollbar::-webkit-scrollbar {
-        width: 8px;
-      }
-      .custom-scrollbar::-webkit-scrollbar-track {
-        background: #f1f1f1;
-      }
-      .custom-scrollbar::-webkit-scrollbar-thumb {
-        background: #888;
-        border-radius: 4px;
-      }
-      .custom-scrollbar::-webkit-scrollbar-thumb:hover {
-        background: #555;
-      }
-    </style>
-  </head>
-  <body class="bg-gray-100 font-sans">
-  ${content}
-  </body>
-</html>`;
-
 // const injectDependencies = (iframe) => {
 //   const iframeDocument =
 //     iframe.contentDocument || iframe.contentWindow.document;
@@ -250,7 +104,9 @@ const iframeChrome = (content) => `
 // Function to connect to WebSocket
 const connectWebSocket = () => {
   console.log("Attempting to connect to WebSocket");
-  socket = new WebSocket("ws://localhost:8080/ws");
+  // the host picks the tenant, so the socket goes back to the host the page came from
+  const scheme = window.location.protocol === "https:" ? "wss" : "ws";
+  socket = new WebSocket(`${scheme}://${window.location.host}/ws`);
 
   socket.onopen = () => {
     console.log("WebSocket connection established");
@@ -270,6 +126,14 @@ const connectWebSocket = () => {
       : "WebSocket connection died";
     console.log(message);
 
+    // 1001 Going Away: the server is restarting and another one takes over, reconnect right away,
+    // spread out so the clients of one server do not all arrive at once
+    if (event.code === 1001) {
+      addChatMessage("System", "Server restarting. Reconnecting...");
+      setTimeout(connectWebSocket, 500 + Math.random() * 1500);
+      return;
+    }
+
     if (reconnectAttempts < MAX_RECONNECT_ATTEMPTS) {
       showToast(
         "Disconnected from server. Attempting to reconnect...",
@@ -299,6 +163,72 @@ const connectWebSocket = () => {
   };
 };
 
+// Changes are gathered for a moment so a bulk import refreshes views once, not per row
+const CHANGE_BATCH_MS = 1000;
+let changeFeed;
+let pendingChanges = [];
+let changeBatchTimer;
+let changeFeedLost = false;
+
+// Function to follow employee and job changes made by other users
+const connectChangeFeed = () => {
+  changeFeed = new EventSource("/api/changes");
+
+  changeFeed.onopen = () => {
+    console.log("Change feed connected");
+    if (changeFeedLost) {
+      // changes may have been missed while disconnected
+      changeFeedLost = false;
+      refreshViews({ resync: true, changes: [] });
+    }
+  };
+
+  changeFeed.addEventListener("change", (event) => {
+    pendingChanges.push(JSON.parse(event.data));
+    if (!changeBatchTimer) {
+      changeBatchTimer = setTimeout(flushChanges, CHANGE_BATCH_MS);
+    }
+  });
+
+  // EventSource reconnects on its own
+  changeFeed.onerror = () => {
+    console.log("Change feed disconnected");
+    changeFeedLost = true;
+  };
+};
+
+const flushChanges = () => {
+  const changes = pendingChanges;
+  pendingChanges = [];
+  changeBatchTimer = null;
+  refreshViews({ resync: false, changes });
+};
+
+// Function to tell the open views which records changed
+const refreshViews = (detail) => {
+  // other scripts on the page listen for hcm:change
+  window.dispatchEvent(new CustomEvent("hcm:change", { detail }));
+
+  // display markup cannot fetch, so it is told and the user is offered a refresh
+  const iframe = document.getElementById("rightPanel");
+  if (!iframe.src || !iframe.contentWindow) {
+    return;
+  }
+  iframe.contentWindow.postMessage({ type: "hcm:change", ...detail }, "*");
+
+  const employees = detail.changes.filter((c) => c.collection === "Employee");
+  const jobs = detail.changes.filter((c) => c.collection === "Job");
+  const parts = [];
+  if (employees.length) {
+    parts.push(`${employees.length} employee change${employees.length > 1 ? "s" : ""}`);
+  }
+  if (jobs.length) {
+    parts.push(`${jobs.length} job change${jobs.length > 1 ? "s" : ""}`);
+  }
+  const summary = detail.resync ? "Records may have changed" : parts.join(" and ");
+  showToast(`${summary} since this view was shown. Ask again to refresh it.`, "info");
+};
+
 // Function to send a message
 const sendMessage = () => {
   // add the message to the chat history
@@ -384,15 +314,18 @@ const extractContentFromMarkdown = (markdown, type) => {
 
 // Function to display content in the right panel
 const displayContentInRightPanel = (content) => {
+  // the server only ever sends a /display/{id} url, the markup itself is
+  // served by the backend under a strict CSP inside a sandboxed iframe
+  const url = content.trim();
+  if (!/^\/display\/[a-f0-9]+$/.test(url)) {
+    if (url) {
+      console.warn("Ignoring display content that is not a display url");
+    }
+    return;
+  }
+
   const iframe = document.getElementById("rightPanel");
-  iframe.src = "about:blank"; // Set src to trigger the load event
-  iframe.onload = function () {
-    console.log("Right panel iframe loaded");
-    const doc = iframe.contentDocument;
-    doc.open();
-    doc.write(iframeChrome(content));
-    doc.close();
-  };
+  iframe.src = url;
 };
 
 // Function to initialize resizable divider
@@ -488,6 +421,7 @@ const showToast = (message, type = "info") => {
 // Initialize the application
 document.addEventListener("DOMContentLoaded", () => {
   connectWebSocket();
+  connectChangeFeed();
   initResizableDivider();
 
   // Event listeners
@@ -0,0 +1,217 @@
+package synth
+
+// department is a part of the company and the career ladders within it
+type department struct {
+	name string
+	// weight is the department's share of the workforce
+	weight int
+	// ladder holds the individual contributor titles from Junior to Lead
+	ladder [4]string
+	// manager, director and head are the management titles, from the first line up
+	manager, director, head string
+	// salary is the base salary of the most junior title, in USD
+	salary   float64
+	skills   []string
+	duties   []string
+	remote   bool
+	degree   string
+	certs    []string
+	language string
+}
+
+// executive is the department of the chief executive, who heads the company
+var executive = department{
+	name:     "Executive",
+	ladder:   [4]string{"Chief Executive Officer", "Chief Executive Officer", "Chief Executive Officer", "Chief Executive Officer"},
+	head:     "Chief Executive Officer",
+	salary:   250000,
+	skills:   []string{"Strategy", "Leadership", "Fundraising", "Board relations"},
+	duties:   []string{"Setting company strategy", "Reporting to the board", "Leading the executive team"},
+	degree:   "Master's degree",
+	language: "English",
+}
+
+var departments = []department{
+	{
+		name: "Engineering", weight: 30,
+		ladder:  [4]string{"Associate Software Engineer", "Software Engineer", "Senior Software Engineer", "Staff Software Engineer"},
+		manager: "Engineering Manager", director: "Director of Engineering", head: "VP of Engineering",
+		salary: 95000,
+		skills: []string{"Go", "TypeScript", "Kubernetes", "MongoDB", "AWS", "System design", "CI/CD", "Observability"},
+		duties: []string{"Building backend services", "Reviewing code", "Running on-call rotations", "Designing APIs",
+			"Improving test coverage", "Mentoring engineers", "Migrating infrastructure"},
+		remote: true, degree: "Bachelor's degree", certs: []string{"AWS Certified Developer", "CKA"}, language: "English",
+	},
+	{
+		name: "Sales", weight: 16,
+		ladder:  [4]string{"Sales Development Representative", "Account Executive", "Senior Account Executive", "Principal Account Executive"},
+		manager: "Sales Manager", director: "Director of Sales", head: "VP of Sales",
+		salary: 60000,
+		skills: []string{"Prospecting", "Negotiation", "Salesforce", "Forecasting", "Account planning"},
+		duties: []string{"Managing the sales pipeline", "Closing enterprise deals", "Vendor negotiations",
+			"Running product demos", "Forecasting quarterly revenue"},
+		degree: "Bachelor's degree", language: "English",
+	},
+	{
+		name: "Customer Support", weight: 12,
+		ladder:  [4]string{"Support Associate", "Support Specialist", "Senior Support Specialist", "Support Team Lead"},
+		manager: "Support Manager", director: "Director of Customer Support", head: "VP of Customer Experience",
+		salary: 48000,
+		skills: []string{"Zendesk", "Troubleshooting", "Customer communication", "SQL", "Knowledge base writing"},
+		duties: []string{"Resolving customer tickets", "Writing help center articles", "Escalating product defects",
+			"Onboarding new customers"},
+		remote: true, degree: "High school diploma", language: "English",
+	},
+	{
+		name: "Marketing", weight: 8,
+		ladder:  [4]string{"Marketing Coordinator", "Marketing Specialist", "Senior Marketing Manager", "Principal Product Marketer"},
+		manager: "Marketing Manager", director: "Director of Marketing", head: "VP of Marketing",
+		salary: 62000,
+		skills: []string{"Content strategy", "SEO", "HubSpot", "Analytics", "Brand management", "Copywriting"},
+		duties: []string{"Running demand generation campaigns", "Managing the website", "Planning product launches",
+			"Vendor negotiations", "Organizing events"},
+		remote: true, degree: "Bachelor's degree", language: "English",
+	},
+	{
+		name: "Product", weight: 6,
+		ladder:  [4]string{"Associate Product Manager", "Product Manager", "Senior Product Manager", "Group Product Manager"},
+		manager: "Product Lead", director: "Director of Product", head: "VP of Product",
+		salary: 90000,
+		skills: []string{"Roadmapping", "User research", "Analytics", "Prioritization", "Jira"},
+		duties: []string{"Owning the product roadmap", "Writing requirements", "Interviewing customers",
+			"Prioritizing the backlog"},
+		remote: true, degree: "Bachelor's degree", language: "English",
+	},
+	{
+		name: "Operations", weight: 9,
+		ladder:  [4]string{"Operations Associate", "Operations Analyst", "Senior Operations Analyst", "Operations Lead"},
+		manager: "Operations Manager", director: "Director of Operations", head: "VP of Operations",
+		salary: 55000,
+		skills: []string{"Process improvement", "Excel", "Procurement", "Logistics", "Vendor management"},
+		duties: []string{"Managing facilities", "Vendor negotiations", "Tracking operating metrics",
+			"Coordinating office moves"},
+		degree: "Bachelor's degree", certs: []string{"Lean Six Sigma Green Belt"}, language: "English",
+	},
+	{
+		name: "Finance", weight: 6,
+		ladder:  [4]string{"Junior Accountant", "Financial Analyst", "Senior Financial Analyst", "Finance Lead"},
+		manager: "Finance Manager", director: "Controller", head: "Chief Financial Officer",
+		salary: 65000,
+		skills: []string{"Financial modeling", "GAAP", "NetSuite", "Budgeting", "Excel"},
+		duties: []string{"Closing the books", "Preparing budgets", "Managing payroll", "Auditing expenses"},
+		degree: "Bachelor's degree", certs: []string{"CPA", "CFA"}, language: "English",
+	},
+	{
+		name: "Human Resources", weight: 5,
+		ladder:  [4]string{"HR Coordinator", "HR Generalist", "Senior HR Business Partner", "HR Lead"},
+		manager: "HR Manager", director: "Director of People", head: "Chief People Officer",
+		salary: 55000,
+		skills: []string{"Recruiting", "Employee relations", "HRIS", "Benefits administration", "Compensation"},
+		duties: []string{"Recruiting candidates", "Running performance reviews", "Administering benefits",
+			"Handling employee relations"},
+		degree: "Bachelor's degree", certs: []string{"SHRM-CP", "PHR"}, language: "English",
+	},
+	{
+		name: "Legal", weight: 2,
+		ladder:  [4]string{"Paralegal", "Corporate Counsel", "Senior Corporate Counsel", "Lead Counsel"},
+		manager: "Legal Manager", director: "Deputy General Counsel", head: "General Counsel",
+		salary: 70000,
+		skills: []string{"Contract law", "Privacy", "Compliance", "Negotiation", "Employment law"},
+		duties: []string{"Reviewing contracts", "Managing compliance", "Vendor negotiations", "Advising on privacy"},
+		degree: "Juris Doctor", language: "English",
+	},
+	{
+		name: "Data", weight: 6,
+		ladder:  [4]string{"Data Analyst", "Data Scientist", "Senior Data Scientist", "Staff Data Scientist"},
+		manager: "Data Science Manager", director: "Director of Data", head: "VP of Data",
+		salary: 85000,
+		skills: []string{"Python", "SQL", "Machine learning", "Statistics", "dbt", "Spark"},
+		duties: []string{"Building dashboards", "Training forecasting models", "Maintaining the data warehouse",
+			"Running experiments"},
+		remote: true, degree: "Master's degree", language: "English",
+	},
+}
+
+// levels are the position levels of the four ladder titles
+var levels = [4]string{"Junior", "Mid-level", "Senior", "Lead"}
+
+// experience is the experience required at each level
+var experience = [4]string{"0-2 years", "2-5 years", "5-8 years", "8+ years"}
+
+// office is a company office; employees live near theirs
+type office struct {
+	name      string
+	street    string
+	city      string
+	state     string
+	zipCode   string
+	timeZone  string
+	areaCodes []string
+	// suburbs are nearby cities employees live in
+	suburbs []string
+}
+
+var offices = []office{
+	{"New York HQ", "350 Fifth Avenue", "New York", "NY", "10118", "America/New_York", []string{"212", "646", "718"}, []string{"New York", "Brooklyn", "Jersey City", "Hoboken"}},
+	{"San Francisco", "101 California Street", "San Francisco", "CA", "94111", "America/Los_Angeles", []string{"415", "510", "650"}, []string{"San Francisco", "Oakland", "Daly City", "San Mateo"}},
+	{"Austin", "500 West 2nd Street", "Austin", "TX", "78701", "America/Chicago", []string{"512", "737"}, []string{"Austin", "Round Rock", "Cedar Park", "Pflugerville"}},
+	{"Chicago", "233 South Wacker Drive", "Chicago", "IL", "60606", "America/Chicago", []string{"312", "773"}, []string{"Chicago", "Evanston", "Oak Park", "Naperville"}},
+	{"Denver", "1144 Fifteenth Street", "Denver", "CO", "80202", "America/Denver", []string{"303", "720"}, []string{"Denver", "Aurora", "Lakewood", "Boulder"}},
+	{"Atlanta", "1180 Peachtree Street", "Atlanta", "GA", "30309", "America/New_York", []string{"404", "678"}, []string{"Atlanta", "Decatur", "Marietta", "Sandy Springs"}},
+}
+
+var maleNames = []string{
+	"James", "Robert", "John", "Michael", "David", "William", "Richard", "Joseph", "Thomas", "Charles",
+	"Daniel", "Matthew", "Anthony", "Mark", "Steven", "Andrew", "Joshua", "Kevin", "Brian", "Ryan",
+	"Jacob", "Nicholas", "Eric", "Jonathan", "Samuel", "Benjamin", "Carlos", "Luis", "Wei", "Arjun",
+	"Omar", "Hiroshi", "Diego", "Kwame", "Mateo", "Ethan", "Noah", "Liam", "Elijah", "Aaron",
+}
+
+var femaleNames = []string{
+	"Mary", "Patricia", "Jennifer", "Linda", "Elizabeth", "Barbara", "Susan", "Jessica", "Sarah", "Karen",
+	"Lisa", "Nancy", "Emily", "Michelle", "Laura", "Rebecca", "Sophia", "Olivia", "Emma", "Ava",
+	"Maria", "Ana", "Priya", "Mei", "Fatima", "Aisha", "Yuki", "Camila", "Grace", "Hannah",
+	"Chloe", "Zoe", "Isabella", "Amara", "Leila", "Nora", "Rachel", "Julia", "Natalie", "Jane",
+}
+
+var neutralNames = []string{
+	"Alex", "Jordan", "Taylor", "Morgan", "Casey", "Riley", "Jamie", "Avery", "Quinn", "Rowan",
+	"Skyler", "Sam", "Charlie", "Dakota", "Emerson", "Finley", "Hayden", "Parker", "Reese", "Sage",
+}
+
+var lastNames = []string{
+	"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez",
+	"Hernandez", "Lopez", "Gonzalez", "Wilson", "Anderson", "Thomas", "Taylor", "Moore", "Jackson", "Martin",
+	"Lee", "Perez", "Thompson", "White", "Harris", "Sanchez", "Clark", "Ramirez", "Lewis", "Robinson",
+	"Walker", "Young", "Allen", "King", "Wright", "Scott", "Torres", "Nguyen", "Hill", "Flores",
+	"Green", "Adams", "Nelson", "Baker", "Hall", "Rivera", "Campbell", "Mitchell", "Carter", "Roberts",
+	"Patel", "Chen", "Kim", "Singh", "Okafor", "Tanaka", "Kowalski", "Novak", "Haddad", "Schmidt",
+}
+
+var streetNames = []string{
+	"Main", "Oak", "Pine", "Maple", "Cedar", "Elm", "Washington", "Lake", "Hill", "Park",
+	"Walnut", "Sunset", "Highland", "Lincoln", "Jackson", "Church", "River", "Spring", "Willow", "Franklin",
+}
+
+var streetSuffixes = []string{"Street", "Avenue", "Road", "Lane", "Drive", "Court", "Boulevard", "Place"}
+
+// nationalities are weighted towards the country of the offices
+var nationalities = []struct {
+	nationality string
+	birthplaces []string
+}{
+	{"American", []string{"New York, USA", "Los Angeles, USA", "Chicago, USA", "Houston, USA", "Seattle, USA", "Boston, USA", "Miami, USA", "Denver, USA"}},
+	{"Canadian", []string{"Toronto, Canada", "Vancouver, Canada", "Montreal, Canada"}},
+	{"Mexican", []string{"Mexico City, Mexico", "Guadalajara, Mexico", "Monterrey, Mexico"}},
+	{"Indian", []string{"Bengaluru, India", "Mumbai, India", "Delhi, India"}},
+	{"Chinese", []string{"Shanghai, China", "Beijing, China", "Shenzhen, China"}},
+	{"British", []string{"London, UK", "Manchester, UK", "Edinburgh, UK"}},
+	{"Nigerian", []string{"Lagos, Nigeria", "Abuja, Nigeria"}},
+	{"Brazilian", []string{"São Paulo, Brazil", "Rio de Janeiro, Brazil"}},
+}
+
+var leaveReasons = []string{"Parental leave", "Medical leave", "Family care leave", "Sabbatical"}
+
+var terminationReasons = []string{"Resigned", "Resigned", "Resigned", "Laid off", "Dismissed", "Contract ended"}
+
+var jobBoards = []string{"LinkedIn", "Indeed", "Glassdoor", "Company careers page"}
@@ -0,0 +1,136 @@
+package synth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"hcmnext/models"
+)
+
+// job builds the record of spec. Its headcount lists the current holders, and some jobs are
+// posted with an opening or two on top.
+func (g *generator) job(spec *jobSpec) models.Job {
+	sort.Slice(spec.holders, func(i, j int) bool { return spec.holders[i].id < spec.holders[j].id })
+
+	filled := make([]models.PositionFilled, 0, len(spec.holders))
+	salaries := 0.0
+	lastModified := spec.created
+	for _, p := range spec.holders {
+		filled = append(filled, models.PositionFilled{
+			PositionTitle: spec.title,
+			EmployeeID:    p.id,
+			EmployeeName:  p.emp.FirstName + " " + p.emp.LastName,
+		})
+		salaries += p.emp.CompensationDetails[len(p.emp.CompensationDetails)-1].Salary
+		if start := p.steps[len(p.steps)-1].start; start.After(lastModified) {
+			lastModified = start
+		}
+	}
+
+	// a department has a single head
+	openings := 0
+	if spec.title != spec.dept.head && spec.title != spec.dept.director && g.r.Float64() < 0.15 {
+		openings = 1 + g.r.Intn(2)
+	}
+	target := len(spec.holders) + openings
+
+	salaryBudget := roundTo(salaries+float64(openings)*spec.dept.salary*spec.band, 100)
+	benefits := roundTo(salaryBudget*0.25, 100)
+	equipment := float64(2500 * target)
+
+	position := models.Position{
+		Title:              spec.title,
+		Role:               spec.dept.name,
+		Level:              levels[spec.level],
+		EmploymentType:     "Full-time",
+		SkillsRequired:     sample(g, spec.dept.skills, 3),
+		ExperienceRequired: experience[spec.level],
+	}
+	if len(spec.dept.certs) > 0 && spec.level >= 2 {
+		position.Certifications = sample(g, spec.dept.certs, 1)
+	}
+
+	var locations []models.JobLocation
+	for i := range offices {
+		o := &offices[i]
+		if !spec.offices[o] {
+			continue
+		}
+		locations = append(locations, models.JobLocation{
+			OfficeName:     o.name,
+			Address:        models.Address{Street: o.street, City: o.city, State: o.state, ZipCode: o.zipCode, Country: "USA"},
+			RemoteEligible: spec.dept.remote,
+			TimeZone:       o.timeZone,
+		})
+	}
+
+	travel := "None"
+	if spec.dept.name == "Sales" || spec.manages {
+		travel = "Up to 25%"
+	}
+
+	job := models.Job{
+		JobID:          spec.id,
+		JobName:        spec.title,
+		JobDescription: fmt.Sprintf("%s in %s, responsible for %s.", spec.title, spec.dept.name, strings.ToLower(strings.Join(sample(g, spec.dept.duties, 2), " and "))),
+		Positions:      []models.Position{position},
+		Locations:      locations,
+		Budget: models.Budget{
+			TotalBudget: salaryBudget + benefits + equipment,
+			Currency:    "USD",
+			Allocation:  models.BudgetAllocation{Salary: salaryBudget, Benefits: benefits, Equipment: equipment},
+		},
+		Headcount: models.Headcount{
+			CurrentHeadcount: len(spec.holders),
+			TargetHeadcount:  target,
+			PositionsFilled:  filled,
+		},
+		JobRequirements: &models.JobRequirements{
+			EducationLevel:     spec.dept.degree,
+			LanguagesRequired:  []string{spec.dept.language},
+			TravelRequirements: travel,
+		},
+		CreationDate: spec.created,
+	}
+	if lastModified.After(spec.created) {
+		job.LastModifiedDate = &lastModified
+	}
+
+	if openings > 0 {
+		posted := g.asOf.AddDate(0, 0, -g.r.Intn(60))
+		deadline := posted.AddDate(0, 0, 45)
+		job.JobPostingDetails = &models.JobPostingDetails{
+			PostedDate:          &posted,
+			PostingStatus:       "Active",
+			ApplicationDeadline: &deadline,
+			JobBoards:           sample(g, jobBoards, 2),
+			Recruiter:           g.recruiter(),
+		}
+		if posted.After(lastModified) {
+			job.LastModifiedDate = &posted
+		}
+	}
+	return job
+}
+
+// recruiter returns a current Human Resources employee, or nil when there are none
+func (g *generator) recruiter() *models.Recruiter {
+	if g.recruiters == nil {
+		g.recruiters = []*person{}
+		for _, p := range g.people {
+			if p.left.IsZero() && p.dept.name == "Human Resources" {
+				g.recruiters = append(g.recruiters, p)
+			}
+		}
+	}
+	if len(g.recruiters) == 0 {
+		return nil
+	}
+	p := pick(g.r, g.recruiters)
+	return &models.Recruiter{
+		RecruiterName:  p.emp.FirstName + " " + p.emp.LastName,
+		RecruiterEmail: p.emp.Email,
+		RecruiterPhone: p.emp.Phone,
+	}
+}
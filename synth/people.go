@@ -0,0 +1,348 @@
+package synth
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"hcmnext/models"
+)
+
+// employee builds the record of p. Managers are hired before their reports, so their records
+// are already built when they are named in a job history.
+func (g *generator) employee(p *person) models.Employee {
+	end := g.asOf
+	if !p.left.IsZero() {
+		end = p.left
+	}
+
+	gender, pronouns, names := "Male", "He/Him", maleNames
+	switch g.weighted([]int{47, 47, 6}) {
+	case 1:
+		gender, pronouns, names = "Female", "She/Her", femaleNames
+	case 2:
+		gender, pronouns, names = "Other", "They/Them", neutralNames
+		if g.r.Float64() < 0.2 {
+			pronouns = "Other"
+		}
+	}
+	if g.r.Float64() < 0.3 {
+		pronouns = ""
+	}
+
+	emp := models.Employee{
+		EmployeeID: p.id,
+		FirstName:  pick(g.r, names),
+		LastName:   pick(g.r, lastNames),
+	}
+	if g.r.Float64() < 0.4 {
+		emp.MiddleName = pick(g.r, names)
+		// some go by their middle name
+		if g.r.Float64() < 0.1 {
+			emp.PreferredName = emp.MiddleName
+		}
+	}
+	if gender == "Male" && g.r.Float64() < 0.02 {
+		emp.Suffix = pick(g.r, []string{"Jr.", "II", "III"})
+	}
+	emp.Email = g.email(emp.FirstName, emp.LastName)
+	if g.r.Float64() < 0.9 {
+		emp.Phone = g.phone(p.office)
+	}
+	emp.SocialSecurityNumber = g.ssn()
+
+	dob := g.birthDate(p)
+	age := years(dob, end)
+	maritalWeights := []int{30, 55, 12, 3}
+	if age < 25 {
+		maritalWeights = []int{85, 15, 0, 0}
+	}
+	marital := []string{"Single", "Married", "Divorced", "Widowed"}[g.weighted(maritalWeights)]
+
+	origin := nationalities[0]
+	if g.r.Float64() >= 0.82 {
+		origin = nationalities[1+g.r.Intn(len(nationalities)-1)]
+	}
+
+	emp.PersonalDetails = models.PersonalDetails{
+		PreferredGender:   pronouns,
+		DateOfBirth:       dob,
+		Gender:            gender,
+		MaritalStatus:     marital,
+		Nationality:       origin.nationality,
+		PlaceOfBirth:      pick(g.r, origin.birthplaces),
+		Address:           g.homeAddress(p.office),
+		EmergencyContacts: g.emergencyContacts(p.office, emp.LastName, marital),
+	}
+
+	emp.JobHistory = g.jobHistory(p)
+	emp.StatusHistory = g.statusHistory(p, end)
+	emp.CompensationDetails = g.compensationHistory(p, end)
+
+	p.emp = emp
+	return emp
+}
+
+// birthDate makes employees old enough for their jobs and retirees of retirement age
+func (g *generator) birthDate(p *person) time.Time {
+	var age int
+	switch {
+	case p.leftStatus == "Retired":
+		return p.left.AddDate(-60-g.r.Intn(7), 0, -g.r.Intn(365))
+	case p.manager == nil:
+		age = 38 + g.r.Intn(12)
+	case p.manager.manager == nil:
+		age = 34 + g.r.Intn(12)
+	case currentJob(p).manages:
+		age = 28 + g.r.Intn(15)
+	default:
+		age = 21 + 2*p.steps[0].job.level + g.r.Intn(12)
+	}
+	return p.hired.AddDate(-age, 0, -g.r.Intn(365))
+}
+
+// jobHistory lists the jobs of p, each ending the day before the next starts, under their manager
+func (g *generator) jobHistory(p *person) []models.JobHistory {
+	history := make([]models.JobHistory, 0, len(p.steps))
+	for i, s := range p.steps {
+		entry := models.JobHistory{
+			JobID:            s.job.id,
+			Title:            s.job.title,
+			Department:       s.job.dept.name,
+			StartDate:        s.start,
+			Location:         p.office.name,
+			EmploymentType:   p.employmentType,
+			Responsibilities: sample(g, s.job.dept.duties, 2),
+		}
+		if i+1 < len(p.steps) {
+			end := p.steps[i+1].start.AddDate(0, 0, -1)
+			entry.EndDate = &end
+		} else if !p.left.IsZero() {
+			end := p.left
+			entry.EndDate = &end
+		}
+		if m := p.manager; m != nil {
+			entry.Manager = &models.Manager{
+				Name:       m.emp.FirstName + " " + m.emp.LastName,
+				EmployeeID: m.id,
+				Email:      m.emp.Email,
+			}
+		}
+		history = append(history, entry)
+	}
+	return history
+}
+
+// statusHistory starts with the hire and may include a leave of absence, a move to remote work
+// and, for former employees, the day after their last day
+func (g *generator) statusHistory(p *person, end time.Time) []models.StatusHistory {
+	history := []models.StatusHistory{{Status: "Active", Date: p.hired, Reason: "Hired"}}
+	last := p.hired
+
+	if g.r.Float64() < 0.1 {
+		start := g.dayBetween(p.hired.AddDate(0, 0, 90), end.AddDate(0, 0, -200), g.r.Float64())
+		back := start.AddDate(0, 0, 60+g.r.Intn(120))
+		if start.After(last) && back.Before(end) {
+			history = append(history,
+				models.StatusHistory{Status: "Leave of Absence", Date: start, Reason: pick(g.r, leaveReasons)},
+				models.StatusHistory{Status: "Active", Date: back, Reason: "Returned from leave"})
+			last = back
+		}
+	}
+
+	current := p.left.IsZero()
+	if current && p.dept.remote && g.r.Float64() < 0.15 {
+		since := g.dayBetween(last.AddDate(0, 0, 1), end, g.r.Float64())
+		if since.After(last) {
+			history = append(history, models.StatusHistory{Status: "Remote Work", Date: since, Reason: "Approved for remote work"})
+			p.remoteSince, last = since, since
+		}
+	}
+	if current && g.r.Float64() < 0.03 {
+		start := g.dayBetween(maxTime(last.AddDate(0, 0, 1), end.AddDate(0, 0, -120)), end, g.r.Float64())
+		if start.After(last) {
+			history = append(history, models.StatusHistory{Status: "Leave of Absence", Date: start, Reason: pick(g.r, leaveReasons)})
+		}
+	}
+
+	if !current {
+		history = append(history, models.StatusHistory{Status: p.leftStatus, Date: p.left.AddDate(0, 0, 1), Reason: p.leftReason})
+	}
+	return history
+}
+
+// compensationHistory starts at the hire with a salary in the band of the first job, raises it
+// by 8 to 15% on promotion and gives a merit raise of 2 to 5% on every other work anniversary
+func (g *generator) compensationHistory(p *person, end time.Time) []models.CompensationDetails {
+	salary := g.startingSalary(p.steps[0].job)
+	history := []models.CompensationDetails{g.compensation(p, p.steps[0].job, p.hired, salary)}
+
+	next := 1
+	for year := 1; ; year++ {
+		anniversary := p.hired.AddDate(year, 0, 0)
+		for next < len(p.steps) && !p.steps[next].start.After(anniversary) {
+			s := p.steps[next]
+			salary = math.Max(salary*(1.08+0.07*g.r.Float64()), g.startingSalary(s.job))
+			history = append(history, g.compensation(p, s.job, s.start, salary))
+			next++
+		}
+		if anniversary.After(end) {
+			break
+		}
+		if history[len(history)-1].EffectiveDate.Equal(anniversary) {
+			continue
+		}
+		salary *= 1.02 + 0.03*g.r.Float64()
+		history = append(history, g.compensation(p, p.steps[next-1].job, anniversary, salary))
+	}
+	return history
+}
+
+func (g *generator) compensation(p *person, job *jobSpec, effective time.Time, salary float64) models.CompensationDetails {
+	frequency := "Bi-weekly"
+	switch p.employmentType {
+	case "Part-time", "Temporary":
+		frequency = "Weekly"
+	case "Contract":
+		frequency = "Monthly"
+	}
+
+	bonus := 0.0
+	switch {
+	case job.manages:
+		bonus = 0.1
+	case job.level >= 2:
+		bonus = 0.05
+	}
+
+	allowances := []models.Allowance{}
+	if job.manages {
+		allowances = append(allowances, models.Allowance{Type: "Phone", Amount: 600})
+	}
+	if !p.remoteSince.IsZero() && !effective.Before(p.remoteSince) {
+		allowances = append(allowances, models.Allowance{Type: "Home office", Amount: 1200})
+	}
+
+	return models.CompensationDetails{
+		EffectiveDate: effective,
+		Salary:        roundTo(salary, 100),
+		Currency:      "USD",
+		PayFrequency:  frequency,
+		Bonuses:       int(roundTo(salary*bonus, 100)),
+		Allowances:    allowances,
+	}
+}
+
+// startingSalary is within 8% of the middle of the job's band
+func (g *generator) startingSalary(job *jobSpec) float64 {
+	return job.dept.salary * job.band * (0.92 + 0.16*g.r.Float64())
+}
+
+// email returns a unique work address, numbering namesakes
+func (g *generator) email(first, last string) string {
+	local := emailLocal(first) + "." + emailLocal(last)
+	n := g.emails[local]
+	g.emails[local]++
+	if n > 0 {
+		local += strconv.Itoa(n + 1)
+	}
+	return local + "@example.com"
+}
+
+func emailLocal(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r
+		}
+		return -1
+	}, strings.ToLower(name))
+}
+
+// phone returns an E.164 number with an area code of the office
+func (g *generator) phone(o *office) string {
+	return fmt.Sprintf("+1%s%d%06d", pick(g.r, o.areaCodes), 2+g.r.Intn(8), g.r.Intn(1000000))
+}
+
+// ssn returns a unique social security number outside the ranges never issued
+func (g *generator) ssn() string {
+	for {
+		area := 1 + g.r.Intn(899)
+		if area == 666 {
+			continue
+		}
+		ssn := fmt.Sprintf("%03d-%02d-%04d", area, 1+g.r.Intn(99), 1+g.r.Intn(9999))
+		if !g.ssns[ssn] {
+			g.ssns[ssn] = true
+			return ssn
+		}
+	}
+}
+
+func (g *generator) homeAddress(o *office) models.Address {
+	street := fmt.Sprintf("%d %s %s", 1+g.r.Intn(9899), pick(g.r, streetNames), pick(g.r, streetSuffixes))
+	if g.r.Float64() < 0.3 {
+		street += fmt.Sprintf(", Apt %d", 1+g.r.Intn(40))
+	}
+	return models.Address{
+		Street:  street,
+		City:    pick(g.r, o.suburbs),
+		State:   o.state,
+		ZipCode: fmt.Sprintf("%s%02d", o.zipCode[:3], g.r.Intn(100)),
+		Country: "USA",
+	}
+}
+
+// emergencyContacts names a spouse for married employees, otherwise family or a friend
+func (g *generator) emergencyContacts(o *office, lastName, marital string) []models.EmergencyContact {
+	relation := pick(g.r, []string{"Parent", "Parent", "Sibling", "Partner", "Friend"})
+	if marital == "Married" {
+		relation = "Spouse"
+	}
+	contacts := []models.EmergencyContact{g.contact(o, relation, lastName)}
+	if g.r.Float64() < 0.35 {
+		contacts = append(contacts, g.contact(o, pick(g.r, []string{"Parent", "Sibling"}), lastName))
+	}
+	return contacts
+}
+
+func (g *generator) contact(o *office, relation, lastName string) models.EmergencyContact {
+	first := pick(g.r, [][]string{maleNames, femaleNames, neutralNames}[g.weighted([]int{47, 47, 6})])
+	last := lastName
+	if relation == "Partner" || relation == "Friend" {
+		last = pick(g.r, lastNames)
+	}
+	contact := models.EmergencyContact{Name: first + " " + last, Relation: relation, Phone: g.phone(o)}
+	if g.r.Float64() < 0.4 {
+		contact.Email = emailLocal(first) + "." + emailLocal(last) + "@example.org"
+	}
+	return contact
+}
+
+// sample returns n items of items in random order, or all of them when there are fewer
+func sample[T any](g *generator, items []T, n int) []T {
+	shuffled := append([]T(nil), items...)
+	g.r.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	return shuffled[:min(n, len(shuffled))]
+}
+
+// years is the age on at of someone born on dob
+func years(dob, at time.Time) int {
+	age := at.Year() - dob.Year()
+	if at.YearDay() < dob.YearDay() {
+		age--
+	}
+	return age
+}
+
+func roundTo(x, unit float64) float64 {
+	return math.Round(x/unit) * unit
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,425 @@
+// Package synth generates realistic, reproducible HR data for development, demos and load
+// tests. Every employee passes models.Employee.Validate and the collection validators, and the
+// dataset as a whole passes the audit: managers are active employees, reporting lines have no
+// cycles and every job's headcount lists exactly the employees holding it.
+package synth
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"hcmnext/models"
+)
+
+// Limits of Options.Employees
+const (
+	MinEmployees = 10
+	MaxEmployees = 100000
+)
+
+// span is the number of direct reports of a manager
+const span = 7
+
+// history is how long before AsOf the company was founded
+const history = 15
+
+// Options controls the size and contents of a generated dataset
+type Options struct {
+	// Seed makes generation deterministic: the same options give the same dataset
+	Seed int64
+	// Employees is the number of employee records, current and former, between MinEmployees
+	// and MaxEmployees
+	Employees int
+	// Attrition is the share of the records that are former employees, at most 0.5
+	Attrition float64
+	// AsOf is the day the dataset describes; histories end on or before it
+	AsOf time.Time
+}
+
+// DefaultOptions generate a small company
+var DefaultOptions = Options{Seed: 1, Employees: 200, Attrition: 0.1}
+
+// Dataset is a generated company
+type Dataset struct {
+	Employees []models.Employee
+	Jobs      []models.Job
+}
+
+func (opts Options) validate() error {
+	if opts.Employees < MinEmployees || opts.Employees > MaxEmployees {
+		return fmt.Errorf("employees must be between %d and %d, not %d", MinEmployees, MaxEmployees, opts.Employees)
+	}
+	if opts.Attrition < 0 || opts.Attrition > 0.5 {
+		return fmt.Errorf("attrition must be between 0 and 0.5, not %g", opts.Attrition)
+	}
+	if opts.AsOf.IsZero() {
+		return fmt.Errorf("asOf is required")
+	}
+	return nil
+}
+
+// jobSpec is a job of the dataset: a title within a department
+type jobSpec struct {
+	id    string
+	dept  *department
+	title string
+	// level indexes levels and experience; management titles are Lead
+	level int
+	// band multiplies the department's base salary
+	band    float64
+	manages bool
+	// holders are the current employees in the job
+	holders []*person
+	// offices are the offices of everyone who has held the job
+	offices map[*office]bool
+	created time.Time
+}
+
+// step is one entry of a job history
+type step struct {
+	job   *jobSpec
+	start time.Time
+}
+
+// person is an employee while the dataset is being generated
+type person struct {
+	index   int
+	id      string
+	dept    *department
+	office  *office
+	manager *person
+	// reports counts the direct reports
+	reports        int
+	hired          time.Time
+	steps          []step
+	employmentType string
+	// remoteSince is the day the employee started working remotely, zero when they do not
+	remoteSince time.Time
+	// left is the last day of a former employee
+	left       time.Time
+	leftStatus string
+	leftReason string
+
+	emp models.Employee
+}
+
+type generator struct {
+	r       *rand.Rand
+	asOf    time.Time
+	founded time.Time
+
+	people  []*person
+	jobs    []*jobSpec
+	jobKeys map[string]*jobSpec
+	offices []*office
+	// recruiters are the current Human Resources employees, found on first use
+	recruiters []*person
+
+	emails map[string]int
+	ssns   map[string]bool
+}
+
+// Generate builds a company of opts.Employees employees: an org tree under a chief executive with
+// departments of teams of up to seven, the job, status and compensation histories of every
+// employee, former employees who resigned, were let go or retired, and a job for every title
+// held whose headcount matches the current employees holding it.
+func Generate(opts Options) (*Dataset, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	asOf := day(opts.AsOf)
+	g := &generator{
+		r:       rand.New(rand.NewSource(opts.Seed)),
+		asOf:    asOf,
+		founded: asOf.AddDate(-history, 0, 0),
+		jobKeys: make(map[string]*jobSpec),
+		emails:  make(map[string]int),
+		ssns:    make(map[string]bool),
+	}
+
+	former := int(math.Round(float64(opts.Employees) * opts.Attrition))
+	current := opts.Employees - former
+
+	// a startup works from its headquarters and opens an office for every few hundred employees
+	for i := 0; i < min(current/200+1, len(offices)); i++ {
+		g.offices = append(g.offices, &offices[i])
+	}
+
+	g.buildOrg(current)
+	g.addFormer(former)
+	g.assignIDs()
+
+	// ladders name titles nobody ended up holding
+	var held []*jobSpec
+	for _, spec := range g.jobs {
+		if !spec.created.IsZero() {
+			spec.id = fmt.Sprintf("J%04d", len(held)+1)
+			held = append(held, spec)
+		}
+	}
+	g.jobs = held
+
+	dataset := &Dataset{}
+	for _, p := range g.people {
+		dataset.Employees = append(dataset.Employees, g.employee(p))
+	}
+	for _, p := range g.people {
+		if p.left.IsZero() {
+			job := currentJob(p)
+			job.holders = append(job.holders, p)
+		}
+	}
+	for _, spec := range g.jobs {
+		dataset.Jobs = append(dataset.Jobs, g.job(spec))
+	}
+	return dataset, nil
+}
+
+// buildOrg creates the current employees: the chief executive, and the departments reporting to
+// them with the larger ones first
+func (g *generator) buildOrg(count int) {
+	ceo := g.newPerson(&executive, nil, g.offices[0])
+	ceo.hired = g.founded
+	g.careerOf(ceo, []*jobSpec{g.jobFor(&executive, executive.head, 3, 1, true)}, g.asOf)
+
+	used := departments[:max(1, min(len(departments), (count-1)/6))]
+	sizes := make([]int, len(used))
+	total := 0
+	for i, dept := range used {
+		sizes[i] = 1
+		total += dept.weight
+	}
+	for i := len(used); i < count-1; i++ {
+		n := g.r.Intn(total)
+		for j, dept := range used {
+			if n < dept.weight {
+				sizes[j]++
+				break
+			}
+			n -= dept.weight
+		}
+	}
+
+	for i := range used {
+		dept := &used[i]
+
+		// the company grows over time; handing out the hire dates in order hires managers before
+		// their teams, as a member's manager always comes earlier in members
+		hires := make([]time.Time, sizes[i])
+		for j := range hires {
+			hires[j] = g.dayBetween(g.founded.AddDate(0, 0, 30), g.asOf.AddDate(0, 0, -7), math.Sqrt(g.r.Float64()))
+		}
+		sort.Slice(hires, func(a, b int) bool { return hires[a].Before(hires[b]) })
+
+		members := make([]*person, sizes[i])
+		for j := range members {
+			manager, office := ceo, g.offices[0]
+			if j > 0 {
+				manager = members[(j-1)/span]
+				office = manager.office
+				if g.r.Float64() < 0.3 {
+					office = g.offices[g.r.Intn(len(g.offices))]
+				}
+			}
+			p := g.newPerson(dept, manager, office)
+			p.hired = hires[j]
+			members[j] = p
+
+			manages := j*span+1 < len(members)
+			var ladder []*jobSpec
+			switch {
+			case j == 0:
+				ladder = []*jobSpec{g.managerJob(dept), g.directorJob(dept), g.headJob(dept)}
+			case manages && (j*span+1)*span+1 < len(members) && j <= span:
+				ladder = []*jobSpec{g.icJob(dept, 2), g.managerJob(dept), g.directorJob(dept)}
+			case manages:
+				ladder = []*jobSpec{g.icJob(dept, 2), g.managerJob(dept)}
+			default:
+				level := g.weighted([]int{25, 35, 28, 12})
+				for l := 0; l <= level; l++ {
+					ladder = append(ladder, g.icJob(dept, l))
+				}
+			}
+			g.careerOf(p, ladder, g.asOf)
+		}
+	}
+}
+
+// addFormer creates employees who have left, each having worked for a manager of today's org
+func (g *generator) addFormer(count int) {
+	var ceo *person
+	var managers []*person
+	for _, p := range g.people {
+		switch {
+		case p.manager == nil:
+			ceo = p
+		case p.reports > 0 && p.hired.Before(g.asOf.AddDate(-2, 0, 0)):
+			managers = append(managers, p)
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		manager := ceo
+		dept := &departments[0]
+		if len(managers) > 0 {
+			manager = managers[g.r.Intn(len(managers))]
+			dept = manager.dept
+		}
+		p := g.newPerson(dept, manager, manager.office)
+		p.hired = g.dayBetween(manager.hired, g.asOf.AddDate(-1, 0, 0), g.r.Float64())
+		p.left = g.dayBetween(p.hired.AddDate(0, 3, 0), g.asOf.AddDate(0, 0, -2), g.r.Float64())
+		if g.r.Float64() < 0.15 {
+			p.leftStatus, p.leftReason = "Retired", "Retirement"
+		} else {
+			p.leftStatus, p.leftReason = "Terminated", pick(g.r, terminationReasons)
+		}
+
+		var ladder []*jobSpec
+		for l := 0; l <= g.weighted([]int{35, 35, 22, 8}); l++ {
+			ladder = append(ladder, g.icJob(dept, l))
+		}
+		g.careerOf(p, ladder, p.left)
+	}
+}
+
+// assignIDs numbers the employees in the order they were hired
+func (g *generator) assignIDs() {
+	sort.SliceStable(g.people, func(i, j int) bool {
+		if !g.people[i].hired.Equal(g.people[j].hired) {
+			return g.people[i].hired.Before(g.people[j].hired)
+		}
+		return g.people[i].index < g.people[j].index
+	})
+	for i, p := range g.people {
+		p.id = fmt.Sprintf("E%06d", i+1)
+	}
+}
+
+func (g *generator) newPerson(dept *department, manager *person, office *office) *person {
+	p := &person{index: len(g.people), dept: dept, manager: manager, office: office, employmentType: "Full-time"}
+	if manager != nil {
+		manager.reports++
+	}
+	g.people = append(g.people, p)
+	return p
+}
+
+// careerOf picks how far up ladder the employee started, given their tenure up to end, and
+// spreads their promotions over it; the last job of the ladder is their current or last one
+func (g *generator) careerOf(p *person, ladder []*jobSpec, end time.Time) {
+	tenure := int(end.Sub(p.hired).Hours() / 24)
+	promotions := min(len(ladder)-1, tenure/550)
+	if promotions > 0 {
+		promotions -= g.r.Intn(2)
+	}
+	ladder = ladder[len(ladder)-1-promotions:]
+
+	segment := tenure / len(ladder)
+	prev := p.hired
+	for i, job := range ladder {
+		start := p.hired
+		if i > 0 {
+			start = p.hired.AddDate(0, 0, i*segment+g.r.Intn(segment/4+1)-segment/8)
+			if !start.After(prev.AddDate(0, 0, 1)) {
+				start = prev.AddDate(0, 0, 2)
+			}
+		}
+		p.steps = append(p.steps, step{job: job, start: start})
+		prev = start
+
+		job.offices[p.office] = true
+		if job.created.IsZero() || start.Before(job.created) {
+			job.created = start
+		}
+	}
+
+	if !currentJob(p).manages && p.dept.name != executive.name {
+		switch n := g.r.Float64(); {
+		case n < 0.07:
+			p.employmentType = "Part-time"
+		case n < 0.13:
+			p.employmentType = "Contract"
+		case n < 0.15:
+			p.employmentType = "Temporary"
+		}
+	}
+}
+
+// currentJob returns the employee's current or last job
+func currentJob(p *person) *jobSpec {
+	return p.steps[len(p.steps)-1].job
+}
+
+func (g *generator) icJob(dept *department, level int) *jobSpec {
+	return g.jobFor(dept, dept.ladder[level], level, []float64{1, 1.3, 1.65, 2}[level], false)
+}
+
+func (g *generator) managerJob(dept *department) *jobSpec {
+	return g.jobFor(dept, dept.manager, 3, 2.1, true)
+}
+
+func (g *generator) directorJob(dept *department) *jobSpec {
+	return g.jobFor(dept, dept.director, 3, 2.6, true)
+}
+
+func (g *generator) headJob(dept *department) *jobSpec {
+	return g.jobFor(dept, dept.head, 3, 3.2, true)
+}
+
+// jobFor returns the job for title in dept, creating it the first time it is asked for
+func (g *generator) jobFor(dept *department, title string, level int, band float64, manages bool) *jobSpec {
+	key := dept.name + "/" + title
+	if spec, ok := g.jobKeys[key]; ok {
+		return spec
+	}
+	spec := &jobSpec{
+		dept:    dept,
+		title:   title,
+		level:   level,
+		band:    band,
+		manages: manages,
+		offices: make(map[*office]bool),
+	}
+	g.jobs = append(g.jobs, spec)
+	g.jobKeys[key] = spec
+	return spec
+}
+
+// dayBetween returns the day at fraction u of the way from from to to, or to when it is earlier
+func (g *generator) dayBetween(from, to time.Time, u float64) time.Time {
+	days := int(to.Sub(from).Hours() / 24)
+	if days <= 0 {
+		return to
+	}
+	return from.AddDate(0, 0, int(u*float64(days+1)))
+}
+
+// weighted returns an index of weights with probability proportional to its weight
+func (g *generator) weighted(weights []int) int {
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	n := g.r.Intn(total)
+	for i, w := range weights {
+		if n < w {
+			return i
+		}
+		n -= w
+	}
+	return len(weights) - 1
+}
+
+func pick[T any](r *rand.Rand, items []T) T {
+	return items[r.Intn(len(items))]
+}
+
+// day truncates t to midnight UTC
+func day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,83 @@
+package synth
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"hcmnext/database"
+	"hcmnext/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// batchSize is the number of documents written per bulk write
+const batchSize = 500
+
+// WriteFixtures writes Employee.ndjson and Job.ndjson into dir, one document per line in MongoDB
+// extended JSON, for hcmnext seed to load
+func (d *Dataset) WriteFixtures(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(dir, "Employee.ndjson"), d.Employees); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, "Job.ndjson"), d.Jobs)
+}
+
+func writeFile[T any](path string, docs []T) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteNDJSON(f, docs); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteNDJSON writes docs to w, one per line in relaxed MongoDB extended JSON
+func WriteNDJSON[T any](w io.Writer, docs []T) error {
+	out := bufio.NewWriter(w)
+	for i := range docs {
+		line, err := bson.MarshalExtJSON(docs[i], false, false)
+		if err != nil {
+			return err
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	return out.Flush()
+}
+
+// Store writes the dataset to the tenant of ctx, replacing employees and jobs with the same ids
+func (d *Dataset) Store(ctx context.Context, db *database.Database) error {
+	if err := store(ctx, db, "Employee", d.Employees, func(e *models.Employee) string { return e.EmployeeID }); err != nil {
+		return err
+	}
+	return store(ctx, db, "Job", d.Jobs, func(j *models.Job) string { return j.JobID })
+}
+
+// store upserts docs into collection by their key, in batches
+func store[T any](ctx context.Context, db *database.Database, collection string, docs []T, id func(*T) string) error {
+	key := database.KeyField(collection)
+	for start := 0; start < len(docs); start += batchSize {
+		end := min(start+batchSize, len(docs))
+		batch := make([]mongo.WriteModel, 0, end-start)
+		for i := start; i < end; i++ {
+			batch = append(batch, mongo.NewReplaceOneModel().
+				SetFilter(bson.M{key: id(&docs[i])}).
+				SetReplacement(&docs[i]).
+				SetUpsert(true))
+		}
+		if _, err := db.For(ctx).BulkWrite(collection, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package tenant
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"hcmnext/auth"
+	"hcmnext/database"
+	"hcmnext/problem"
+)
+
+// Resolver picks the tenant of each request from its access token or host name
+type Resolver struct {
+	registry *Registry
+	// baseDomain is the domain tenant subdomains live under, e.g. hcm.example.com for acme.hcm.example.com
+	baseDomain string
+}
+
+// NewResolver creates a resolver; baseDomain may be empty when tenants are only reached through tokens and custom hosts
+func NewResolver(registry *Registry, baseDomain string) *Resolver {
+	return &Resolver{registry: registry, baseDomain: strings.ToLower(strings.TrimPrefix(baseDomain, "."))}
+}
+
+// Middleware attaches the tenant of the request to its context, which scopes every query made for it.
+// It runs after authentication: a token issued for one tenant is rejected on another tenant's host.
+// When tenants are isolated, requests that name no known tenant are rejected, except for static assets
+// and the server's own endpoints, see sharedPath.
+func (rv *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rv.registry.db.MultiTenant() || sharedPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id, err := rv.resolve(r)
+		switch {
+		case err == nil && id == "":
+			problem.Error(w, r, http.StatusBadRequest, problem.CodeTenantRequired, "A tenant is required: use the tenant's host name or a token issued for the tenant")
+			return
+		case errors.Is(err, ErrWrongTenant):
+			problem.Error(w, r, http.StatusForbidden, problem.CodeTenantMismatch, err.Error())
+			return
+		case errors.Is(err, ErrUnknownTenant):
+			problem.Error(w, r, http.StatusNotFound, problem.CodeTenantNotFound, "Unknown tenant")
+			return
+		case err != nil:
+			logger.ErrorContext(r.Context(), "Error resolving tenant", "host", r.Host, "err", err)
+			problem.Error(w, r, http.StatusInternalServerError, problem.CodeInternal, "Failed to resolve tenant")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(database.WithTenant(r.Context(), id)))
+	})
+}
+
+// sharedPath reports whether path serves every tenant alike: static assets, Prometheus metrics,
+// the health probes, the server status and the API description
+func sharedPath(path string) bool {
+	switch path {
+	case "/metrics", "/healthz", "/readyz", "/api/admin/status", "/api/openapi.json", "/api/docs":
+		return true
+	}
+	return strings.HasPrefix(path, "/static/")
+}
+
+// resolve returns the tenant named by the request's host and token, which must agree when both name one
+func (rv *Resolver) resolve(r *http.Request) (string, error) {
+	hostID, err := rv.hostTenant(r)
+	if err != nil {
+		return "", err
+	}
+	tokenID := auth.FromContext(r.Context()).Tenant
+	if hostID != "" && tokenID != "" && hostID != tokenID {
+		return "", ErrWrongTenant
+	}
+
+	id := hostID
+	if id == "" {
+		id = tokenID
+	}
+	if id == "" {
+		return "", nil
+	}
+	if _, err := rv.registry.Get(r.Context(), id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// hostTenant returns the tenant whose subdomain or custom host the request was sent to, or "" for none
+func (rv *Resolver) hostTenant(r *http.Request) (string, error) {
+	host := strings.ToLower(r.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" || host == "localhost" || net.ParseIP(host) != nil || host == rv.baseDomain {
+		return "", nil
+	}
+
+	if rv.baseDomain != "" {
+		if label, ok := strings.CutSuffix(host, "."+rv.baseDomain); ok {
+			if strings.Contains(label, ".") {
+				return "", ErrUnknownTenant
+			}
+			return label, nil
+		}
+	}
+
+	t, err := rv.registry.ByHost(r.Context(), host)
+	if errors.Is(err, ErrUnknownTenant) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return t.TenantID, nil
+}
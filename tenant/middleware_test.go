@@ -0,0 +1,90 @@
+package tenant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"hcmnext/auth"
+	"hcmnext/database"
+	"hcmnext/models"
+)
+
+// newTestResolver returns a resolver over a registry that already holds acme, reachable at
+// acme.hcm.example.com and hr.acme.com, and globex. The client never connects: every lookup
+// a test makes is answered from the registry's cache.
+func newTestResolver(t *testing.T) *Resolver {
+	t.Helper()
+
+	db, err := database.NewDatabase("mongodb://127.0.0.1:1/?serverSelectionTimeoutMS=100", "hcm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMultiTenant(true)
+
+	reg := NewRegistry(db)
+	acme := &models.Tenant{TenantID: "acme", Hosts: []string{"hr.acme.com"}}
+	globex := &models.Tenant{TenantID: "globex"}
+	now := time.Now()
+	reg.byID["acme"] = cachedTenant{tenant: acme, loadedAt: now}
+	reg.byID["globex"] = cachedTenant{tenant: globex, loadedAt: now}
+	reg.byHost["hr.acme.com"] = cachedTenant{tenant: acme, loadedAt: now}
+
+	return NewResolver(reg, "hcm.example.com")
+}
+
+func TestResolverMiddleware(t *testing.T) {
+	rv := newTestResolver(t)
+
+	tests := []struct {
+		name        string
+		host        string
+		path        string
+		tokenTenant string
+		wantStatus  int
+		wantTenant  string
+	}{
+		{name: "subdomain", host: "acme.hcm.example.com", wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "subdomain with port", host: "acme.hcm.example.com:8080", wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "custom host", host: "hr.acme.com", wantStatus: http.StatusOK, wantTenant: "acme"},
+		{name: "token", host: "hcm.example.com", tokenTenant: "globex", wantStatus: http.StatusOK, wantTenant: "globex"},
+		{name: "token on its own host", host: "globex.hcm.example.com", tokenTenant: "globex", wantStatus: http.StatusOK, wantTenant: "globex"},
+		{name: "token on another tenant's host", host: "acme.hcm.example.com", tokenTenant: "globex", wantStatus: http.StatusForbidden},
+		{name: "token on another tenant's custom host", host: "hr.acme.com", tokenTenant: "globex", wantStatus: http.StatusForbidden},
+		{name: "nested subdomain", host: "a.acme.hcm.example.com", wantStatus: http.StatusNotFound},
+		{name: "invalid token tenant", host: "localhost", tokenTenant: "Not_A_Tenant", wantStatus: http.StatusNotFound},
+		{name: "no tenant", host: "hcm.example.com", wantStatus: http.StatusBadRequest},
+		{name: "no tenant on an address", host: "127.0.0.1:8080", wantStatus: http.StatusBadRequest},
+		{name: "shared path", host: "hcm.example.com", path: "/healthz", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotTenant string
+			handler := rv.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotTenant = database.TenantFromContext(r.Context())
+			}))
+
+			path := tt.path
+			if path == "" {
+				path = "/api/employees"
+			}
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			r.Host = tt.host
+			if tt.tokenTenant != "" {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{Subject: "user", Tenant: tt.tokenTenant}))
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if gotTenant != tt.wantTenant {
+				t.Errorf("tenant = %q, want %q", gotTenant, tt.wantTenant)
+			}
+		})
+	}
+}
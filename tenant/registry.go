@@ -0,0 +1,159 @@
+// Package tenant resolves the tenant of each request and holds every tenant's configuration
+// and assistant quotas. The data of each tenant is isolated by the database package.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"hcmnext/database"
+	"hcmnext/logging"
+	"hcmnext/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var logger = logging.For("tenant")
+
+var (
+	ErrUnknownTenant = errors.New("unknown tenant")
+	ErrWrongTenant   = errors.New("credentials belong to another tenant")
+	ErrAIDisabled    = errors.New("the assistant is disabled for this tenant")
+	ErrQuotaExceeded = errors.New("the tenant's daily assistant quota is used up")
+)
+
+// Collections of the registry and of each tenant's usage
+const (
+	tenantCollection = "Tenant"
+	usageCollection  = "TenantUsage"
+)
+
+// cacheTTL is how long a tenant record is used before it is read again
+const cacheTTL = time.Minute
+
+type cachedTenant struct {
+	tenant   *models.Tenant
+	loadedAt time.Time
+}
+
+// Registry looks up tenants in the shared Tenant collection
+type Registry struct {
+	db *database.Database
+
+	mu     sync.Mutex
+	byID   map[string]cachedTenant
+	byHost map[string]cachedTenant
+}
+
+// NewRegistry creates a new instance of Registry
+func NewRegistry(db *database.Database) *Registry {
+	return &Registry{
+		db:     db,
+		byID:   make(map[string]cachedTenant),
+		byHost: make(map[string]cachedTenant),
+	}
+}
+
+// Get returns the tenant with id. When tenants are not isolated the only tenant is "",
+// which has the deployment's defaults and no quotas.
+func (reg *Registry) Get(ctx context.Context, id string) (*models.Tenant, error) {
+	if !reg.db.MultiTenant() {
+		return &models.Tenant{}, nil
+	}
+	if !database.ValidTenantID(id) {
+		return nil, ErrUnknownTenant
+	}
+	return reg.lookup(reg.byID, id, bson.M{"tenantId": id})
+}
+
+// ByHost returns the tenant that lists host among its host names
+func (reg *Registry) ByHost(ctx context.Context, host string) (*models.Tenant, error) {
+	return reg.lookup(reg.byHost, host, bson.M{"hosts": host})
+}
+
+// FromContext returns the tenant of the request on ctx
+func (reg *Registry) FromContext(ctx context.Context) (*models.Tenant, error) {
+	return reg.Get(ctx, database.TenantFromContext(ctx))
+}
+
+func (reg *Registry) lookup(cache map[string]cachedTenant, key string, filter bson.M) (*models.Tenant, error) {
+	reg.mu.Lock()
+	cached, ok := cache[key]
+	reg.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < cacheTTL {
+		return cached.tenant, nil
+	}
+
+	var t models.Tenant
+	err := reg.db.FindOne(tenantCollection, filter, &t)
+	if err == mongo.ErrNoDocuments || (err == nil && t.Disabled) {
+		return nil, ErrUnknownTenant
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reg.mu.Lock()
+	cache[key] = cachedTenant{tenant: &t, loadedAt: time.Now()}
+	reg.mu.Unlock()
+	return &t, nil
+}
+
+// ReserveAIRequest counts an assistant request against the tenant of ctx and returns its
+// configuration, or an error if the assistant is disabled or a daily quota is used up.
+func (reg *Registry) ReserveAIRequest(ctx context.Context) (*models.Tenant, error) {
+	t, err := reg.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if t.AI.Disabled {
+		return nil, ErrAIDisabled
+	}
+	if !reg.db.MultiTenant() {
+		return t, nil
+	}
+
+	// the request is counted before the check so concurrent requests cannot all slip under the limit
+	var usage models.TenantUsage
+	err = reg.db.For(ctx).FindOneAndUpsert(usageCollection, bson.M{"_id": today()}, bson.M{
+		"$inc": bson.M{"aiRequests": 1, "aiTokens": 0},
+	}, &usage)
+	if err != nil {
+		return nil, err
+	}
+	if t.Quota.AIRequestsPerDay > 0 && usage.AIRequests > t.Quota.AIRequestsPerDay {
+		return nil, ErrQuotaExceeded
+	}
+	if t.Quota.AITokensPerDay > 0 && usage.AITokens >= t.Quota.AITokensPerDay {
+		return nil, ErrQuotaExceeded
+	}
+	return t, nil
+}
+
+// RecordAITokens adds the tokens a model call used to the daily usage of the tenant of ctx
+func (reg *Registry) RecordAITokens(ctx context.Context, tokens int) error {
+	if tokens == 0 || !reg.db.MultiTenant() {
+		return nil
+	}
+	var usage models.TenantUsage
+	return reg.db.For(ctx).FindOneAndUpsert(usageCollection, bson.M{"_id": today()}, bson.M{
+		"$inc": bson.M{"aiTokens": tokens},
+	}, &usage)
+}
+
+// Usage returns the assistant use of the tenant of ctx today
+func (reg *Registry) Usage(ctx context.Context) (*models.TenantUsage, error) {
+	usage := models.TenantUsage{Day: today()}
+	err := reg.db.For(ctx).FindOne(usageCollection, bson.M{"_id": usage.Day}, &usage)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+func today() string {
+	return time.Now().UTC().Format("2006-01-02")
+}
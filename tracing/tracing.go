@@ -0,0 +1,80 @@
+// Package tracing sets up OpenTelemetry tracing and starts the spans of HTTP requests.
+//
+// Spans are exported over OTLP/HTTP to a configured collector, or written to stdout for local
+// use. Without an exporter the global tracer provider is a no-op and spans cost next to nothing.
+// Other packages start their own spans with Tracer.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options select where spans go
+type Options struct {
+	// Exporter is otlp, stdout or none
+	Exporter string
+	// Endpoint is the OTLP/HTTP collector URL, e.g. http://otel-collector:4318; when empty the
+	// exporter falls back to OTEL_EXPORTER_OTLP_ENDPOINT and then to localhost
+	Endpoint string
+	// ServiceName names the service in every span
+	ServiceName string
+	// SampleRatio is the share of new traces recorded, between 0 and 1; traces started
+	// upstream keep the caller's decision
+	SampleRatio float64
+}
+
+// Tracer returns the tracer of package pkg
+func Tracer(pkg string) trace.Tracer {
+	return otel.Tracer("hcmnext/" + pkg)
+}
+
+// Setup installs the global tracer provider and W3C trace context propagation. The returned
+// function flushes the spans still buffered and must be called on shutdown.
+func Setup(ctx context.Context, opts Options) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch opts.Exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		var clientOpts []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpointURL(opts.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, clientOpts...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(opts.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"hcmnext/bulk"
+	"hcmnext/config"
+	"hcmnext/hr"
+)
+
+// importEmployees upserts the employees of a CSV or XLSX spreadsheet by employeeId, like
+// POST /api/employees/import, and prints the import report as JSON
+func importEmployees(args []string) {
+	var tenant, format, mappingPath string
+	var dryRun bool
+	cfg := loadConfig(config.Command{Name: "import", Database: true, Flags: func(flags *flag.FlagSet) {
+		tenantsFlag(flags, &tenant)
+		flags.StringVar(&format, "format", "", "csv or xlsx; taken from the file extension when empty")
+		flags.StringVar(&mappingPath, "mapping", "", "JSON file mapping column headers to employee field paths")
+		flags.BoolVar(&dryRun, "dry-run", false, "validate every row without writing anything")
+	}}, args)
+	if len(cfg.Args) != 1 {
+		fatal("Nothing to import", errors.New("name one spreadsheet to import"))
+	}
+	path := cfg.Args[0]
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	mapping := bulk.Mapping{}
+	if mappingPath != "" {
+		data, err := os.ReadFile(mappingPath)
+		if err != nil {
+			fatal("Failed to read mapping", err)
+		}
+		if err := json.Unmarshal(data, &mapping); err != nil {
+			fatal("Failed to read mapping", fmt.Errorf("%s must be a JSON object from column header to field path: %w", mappingPath, err))
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fatal("Failed to open spreadsheet", err)
+	}
+	defer f.Close()
+
+	db := openDatabase(cfg)
+	defer db.Close()
+	if db.MultiTenant() && tenant == "" {
+		db.Close()
+		fatal("Nothing to import", errors.New("-tenant is required when tenants are isolated"))
+	}
+
+	importer := bulk.NewImporter(db)
+	var report *bulk.ImportReport
+	if dryRun {
+		report, err = importer.DryRun(operatorContext(tenant), f, format, mapping)
+	} else {
+		report, err = importer.Import(operatorContext(tenant), f, format, mapping)
+	}
+	if err != nil {
+		db.Close()
+		fatal("Failed to import spreadsheet", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		slog.Error("Error writing import report", "err", err)
+	}
+	if report.Status == bulk.ImportFailed || report.Failed > 0 {
+		db.Close()
+		os.Exit(1)
+	}
+}
+
+// export writes employees or jobs as csv, ndjson or parquet, like GET /api/employees/export and
+// GET /api/jobs/export, to a file or stdout:
+//
+//	hcmnext export employees -format parquet -filter 'status=Active' -out employees.parquet
+func export(args []string) {
+	if len(args) == 0 || (args[0] != "employees" && args[0] != "jobs") {
+		fatal("Nothing to export", errors.New("name what to export: employees or jobs"))
+	}
+	what, args := args[0], args[1:]
+
+	var tenant, filterQuery, fields, out string
+	opts := bulk.ExportOptions{}
+	cfg := loadConfig(config.Command{Name: "export " + what, Database: true, Flags: func(flags *flag.FlagSet) {
+		tenantsFlag(flags, &tenant)
+		flags.StringVar(&opts.Format, "format", bulk.FormatCSV, "csv, ndjson or parquet")
+		flags.StringVar(&fields, "fields", "", "comma-separated columns to include; all when empty")
+		flags.StringVar(&filterQuery, "filter", "", "employee list filters as a query string, e.g. status=Active&department=Sales")
+		flags.BoolVar(&opts.ShowPII, "show-pii", false, "write personal data unmasked")
+		flags.StringVar(&out, "out", "", "file to write; stdout when empty")
+	}}, args)
+	for _, field := range strings.Split(fields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			opts.Fields = append(opts.Fields, field)
+		}
+	}
+
+	check, run := bulk.CheckJobOptions, func(ctx context.Context, e *bulk.Exporter, w io.Writer) (int, error) {
+		return e.ExportJobs(ctx, w, opts)
+	}
+	if what == "employees" {
+		query, err := url.ParseQuery(filterQuery)
+		if err != nil {
+			fatal("Invalid -filter", err)
+		}
+		filter, err := hr.ParseEmployeeFilter(query)
+		if err != nil {
+			fatal("Invalid -filter", err)
+		}
+		check, run = bulk.CheckEmployeeOptions, func(ctx context.Context, e *bulk.Exporter, w io.Writer) (int, error) {
+			return e.ExportEmployees(ctx, w, filter, opts)
+		}
+	}
+	if err := check(opts); err != nil {
+		fatal("Invalid export options", err)
+	}
+
+	db := openDatabase(cfg)
+	defer db.Close()
+	if db.MultiTenant() && tenant == "" {
+		db.Close()
+		fatal("Nothing to export", errors.New("-tenant is required when tenants are isolated"))
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			db.Close()
+			fatal("Failed to create export file", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	count, err := run(operatorContext(tenant), bulk.NewExporter(db), w)
+	if err != nil {
+		db.Close()
+		fatal("Failed to export "+what, err)
+	}
+	slog.Info("Exported", "records", count, "format", opts.Format)
+}
@@ -0,0 +1,419 @@
+package vectorstore
+
+import (
+	"container/heap"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+)
+
+// HNSWOptions tunes the in-process index
+type HNSWOptions struct {
+	// M is the number of neighbours kept per node on the upper layers, layer 0 keeps 2*M
+	M int
+	// EfConstruction is the candidate list size used while inserting
+	EfConstruction int
+	// EfSearch is the minimum candidate list size used while searching
+	EfSearch int
+	// Seed makes level assignment deterministic
+	Seed int64
+}
+
+// DefaultHNSWOptions are reasonable settings for indexes up to a few hundred thousand records
+var DefaultHNSWOptions = HNSWOptions{M: 16, EfConstruction: 200, EfSearch: 64, Seed: 1}
+
+// HNSW is an embedded, in-memory hierarchical navigable small world index using cosine similarity
+type HNSW struct {
+	mu        sync.RWMutex
+	opts      HNSWOptions
+	levelMult float64
+	rng       *rand.Rand
+
+	nodes    []*hnswNode
+	ids      map[string]int
+	entry    int
+	maxLevel int
+	deleted  int
+	dim      int
+}
+
+type hnswNode struct {
+	Record    Record
+	Vector    []float32
+	Level     int
+	Neighbors [][]int
+	Deleted   bool
+}
+
+// NewHNSW creates an empty in-process index
+func NewHNSW(opts HNSWOptions) *HNSW {
+	if opts.M <= 0 {
+		opts.M = DefaultHNSWOptions.M
+	}
+	if opts.EfConstruction <= 0 {
+		opts.EfConstruction = DefaultHNSWOptions.EfConstruction
+	}
+	if opts.EfSearch <= 0 {
+		opts.EfSearch = DefaultHNSWOptions.EfSearch
+	}
+	return &HNSW{
+		opts:      opts,
+		levelMult: 1 / math.Log(float64(opts.M)),
+		rng:       rand.New(rand.NewSource(opts.Seed)),
+		ids:       make(map[string]int),
+		entry:     -1,
+	}
+}
+
+// Len returns the number of live records in the index
+func (h *HNSW) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.ids)
+}
+
+// Upsert inserts the records, replacing any existing record with the same id
+func (h *HNSW) Upsert(ctx context.Context, records []Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(record.Vector) == 0 {
+			return fmt.Errorf("record %s has no vector", record.ID)
+		}
+		if h.dim == 0 {
+			h.dim = len(record.Vector)
+		}
+		if len(record.Vector) != h.dim {
+			return fmt.Errorf("record %s has dimension %d, index has %d", record.ID, len(record.Vector), h.dim)
+		}
+
+		if existing, ok := h.ids[record.ID]; ok {
+			h.nodes[existing].Deleted = true
+			h.deleted++
+		}
+		h.insertLocked(record)
+	}
+
+	// tombstoned nodes still route searches, rebuild once they dominate the graph
+	if h.deleted > len(h.ids) {
+		h.rebuildLocked()
+	}
+	return nil
+}
+
+// Delete removes the records with the given ids
+func (h *HNSW) Delete(ctx context.Context, ids []string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, id := range ids {
+		if idx, ok := h.ids[id]; ok {
+			h.nodes[idx].Deleted = true
+			delete(h.ids, id)
+			h.deleted++
+		}
+	}
+	if h.deleted > len(h.ids) {
+		h.rebuildLocked()
+	}
+	return nil
+}
+
+// Search returns the k records closest to query that match filter
+func (h *HNSW) Search(ctx context.Context, query []float32, k int, filter Filter) ([]Match, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.entry < 0 || k <= 0 {
+		return nil, nil
+	}
+	if len(query) != h.dim {
+		return nil, fmt.Errorf("query has dimension %d, index has %d", len(query), h.dim)
+	}
+
+	q := normalize(query)
+	ef := h.opts.EfSearch
+	if k > ef {
+		ef = k
+	}
+	if len(filter) > 0 || h.deleted > 0 {
+		// filtered out and tombstoned nodes still take up candidate slots
+		ef *= 4
+	}
+
+	ep := h.entry
+	for level := h.maxLevel; level > 0; level-- {
+		ep = h.greedyLocked(q, ep, level)
+	}
+	candidates := h.searchLayerLocked(q, []int{ep}, ef, 0)
+
+	var matches []Match
+	for _, c := range candidates {
+		node := h.nodes[c.idx]
+		if node.Deleted || !filter.matches(node.Record.Metadata) {
+			continue
+		}
+		matches = append(matches, Match{Record: node.Record, Score: 1 - c.dist})
+		if len(matches) == k {
+			break
+		}
+	}
+	return matches, nil
+}
+
+// SaveFile writes the index to path so it survives restarts
+func (h *HNSW) SaveFile(path string) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	snapshot := hnswSnapshot{Opts: h.opts, Nodes: h.nodes, Entry: h.entry, MaxLevel: h.maxLevel, Dim: h.dim}
+	if err := gob.NewEncoder(f).Encode(snapshot); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadHNSWFile reads an index written by SaveFile
+func LoadHNSWFile(path string) (*HNSW, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var snapshot hnswSnapshot
+	if err := gob.NewDecoder(f).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("error decoding index %s: %v", path, err)
+	}
+
+	h := NewHNSW(snapshot.Opts)
+	h.nodes = snapshot.Nodes
+	h.entry = snapshot.Entry
+	h.maxLevel = snapshot.MaxLevel
+	h.dim = snapshot.Dim
+	for idx, node := range h.nodes {
+		if node.Deleted {
+			h.deleted++
+			continue
+		}
+		h.ids[node.Record.ID] = idx
+	}
+	return h, nil
+}
+
+type hnswSnapshot struct {
+	Opts     HNSWOptions
+	Nodes    []*hnswNode
+	Entry    int
+	MaxLevel int
+	Dim      int
+}
+
+func (h *HNSW) insertLocked(record Record) {
+	vector := normalize(record.Vector)
+	level := int(-math.Log(1-h.rng.Float64()) * h.levelMult)
+
+	// the raw vector is not needed once normalized
+	record.Vector = nil
+	node := &hnswNode{Record: record, Vector: vector, Level: level, Neighbors: make([][]int, level+1)}
+	idx := len(h.nodes)
+	h.nodes = append(h.nodes, node)
+	h.ids[record.ID] = idx
+
+	if h.entry < 0 {
+		h.entry = idx
+		h.maxLevel = level
+		return
+	}
+
+	ep := h.entry
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.greedyLocked(vector, ep, l)
+	}
+
+	entryPoints := []int{ep}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayerLocked(vector, entryPoints, h.opts.EfConstruction, l)
+
+		neighbors := make([]int, 0, h.opts.M)
+		for _, c := range candidates {
+			if len(neighbors) == h.opts.M {
+				break
+			}
+			neighbors = append(neighbors, c.idx)
+		}
+		node.Neighbors[l] = neighbors
+
+		for _, n := range neighbors {
+			h.linkLocked(n, idx, l)
+		}
+
+		entryPoints = entryPoints[:0]
+		for _, c := range candidates {
+			entryPoints = append(entryPoints, c.idx)
+		}
+	}
+
+	if level > h.maxLevel {
+		h.maxLevel = level
+		h.entry = idx
+	}
+}
+
+// linkLocked adds to as a neighbour of from on level, pruning from's list to the closest maxM
+func (h *HNSW) linkLocked(from, to, level int) {
+	node := h.nodes[from]
+	node.Neighbors[level] = append(node.Neighbors[level], to)
+
+	maxM := h.opts.M
+	if level == 0 {
+		maxM = 2 * h.opts.M
+	}
+	if len(node.Neighbors[level]) <= maxM {
+		return
+	}
+
+	neighbors := node.Neighbors[level]
+	sort.Slice(neighbors, func(i, j int) bool {
+		return h.distance(node.Vector, neighbors[i]) < h.distance(node.Vector, neighbors[j])
+	})
+	node.Neighbors[level] = neighbors[:maxM]
+}
+
+// greedyLocked walks level towards q starting at ep and returns the closest node found
+func (h *HNSW) greedyLocked(q []float32, ep, level int) int {
+	best := ep
+	bestDist := h.distance(q, ep)
+	for changed := true; changed; {
+		changed = false
+		for _, n := range h.nodes[best].Neighbors[level] {
+			if d := h.distance(q, n); d < bestDist {
+				best, bestDist, changed = n, d, true
+			}
+		}
+	}
+	return best
+}
+
+// searchLayerLocked returns up to ef nodes closest to q on level, nearest first
+func (h *HNSW) searchLayerLocked(q []float32, entryPoints []int, ef, level int) []candidate {
+	visited := make(map[int]bool, ef*4)
+	candidates := &candidateHeap{}
+	results := &candidateHeap{max: true}
+
+	for _, ep := range entryPoints {
+		if visited[ep] {
+			continue
+		}
+		visited[ep] = true
+		c := candidate{idx: ep, dist: h.distance(q, ep)}
+		heap.Push(candidates, c)
+		heap.Push(results, c)
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+
+	for candidates.Len() > 0 {
+		current := heap.Pop(candidates).(candidate)
+		if results.Len() >= ef && current.dist > results.items[0].dist {
+			break
+		}
+
+		node := h.nodes[current.idx]
+		if level >= len(node.Neighbors) {
+			continue
+		}
+		for _, n := range node.Neighbors[level] {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+
+			d := h.distance(q, n)
+			if results.Len() < ef || d < results.items[0].dist {
+				heap.Push(candidates, candidate{idx: n, dist: d})
+				heap.Push(results, candidate{idx: n, dist: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	sorted := make([]candidate, results.Len())
+	for i := len(sorted) - 1; i >= 0; i-- {
+		sorted[i] = heap.Pop(results).(candidate)
+	}
+	return sorted
+}
+
+// rebuildLocked re-inserts every live node into a fresh graph
+func (h *HNSW) rebuildLocked() {
+	live := make([]*hnswNode, 0, len(h.ids))
+	for _, node := range h.nodes {
+		if !node.Deleted {
+			live = append(live, node)
+		}
+	}
+
+	h.nodes = nil
+	h.ids = make(map[string]int, len(live))
+	h.entry = -1
+	h.maxLevel = 0
+	h.deleted = 0
+	for _, node := range live {
+		record := node.Record
+		record.Vector = node.Vector
+		h.insertLocked(record)
+	}
+}
+
+func (h *HNSW) distance(q []float32, idx int) float32 {
+	return 1 - dot(q, h.nodes[idx].Vector)
+}
+
+type candidate struct {
+	idx  int
+	dist float32
+}
+
+// candidateHeap is a min-heap on distance, or a max-heap when max is set
+type candidateHeap struct {
+	items []candidate
+	max   bool
+}
+
+func (c *candidateHeap) Len() int { return len(c.items) }
+func (c *candidateHeap) Less(i, j int) bool {
+	if c.max {
+		return c.items[i].dist > c.items[j].dist
+	}
+	return c.items[i].dist < c.items[j].dist
+}
+func (c *candidateHeap) Swap(i, j int)      { c.items[i], c.items[j] = c.items[j], c.items[i] }
+func (c *candidateHeap) Push(x interface{}) { c.items = append(c.items, x.(candidate)) }
+func (c *candidateHeap) Pop() interface{} {
+	last := c.items[len(c.items)-1]
+	c.items = c.items[:len(c.items)-1]
+	return last
+}
@@ -0,0 +1,195 @@
+package vectorstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Milvus stores records in a Milvus collection through its v2 RESTful API.
+// The collection has an id primary key, a cosine-indexed vector, the text and a JSON metadata field.
+type Milvus struct {
+	address    string
+	token      string
+	collection string
+	database   string
+	httpClient *http.Client
+}
+
+// NewMilvus creates a Milvus adapter; token may be empty or "user:password"
+func NewMilvus(address, token, database, collection string) *Milvus {
+	return &Milvus{
+		address:    strings.TrimRight(address, "/"),
+		token:      token,
+		database:   database,
+		collection: collection,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// EnsureCollection creates the collection with a vector field of size dim if it does not exist
+func (m *Milvus) EnsureCollection(ctx context.Context, dim int) error {
+	var has struct {
+		Has bool `json:"has"`
+	}
+	if err := m.call(ctx, "/v2/vectordb/collections/has", map[string]interface{}{
+		"collectionName": m.collection,
+	}, &has); err != nil {
+		return err
+	}
+	if has.Has {
+		return nil
+	}
+
+	return m.call(ctx, "/v2/vectordb/collections/create", map[string]interface{}{
+		"collectionName": m.collection,
+		"schema": map[string]interface{}{
+			"autoId":             false,
+			"enableDynamicField": false,
+			"fields": []map[string]interface{}{
+				{"fieldName": "id", "dataType": "VarChar", "isPrimary": true, "elementTypeParams": map[string]interface{}{"max_length": 256}},
+				{"fieldName": "vector", "dataType": "FloatVector", "elementTypeParams": map[string]interface{}{"dim": dim}},
+				{"fieldName": "text", "dataType": "VarChar", "elementTypeParams": map[string]interface{}{"max_length": 16384}},
+				{"fieldName": "metadata", "dataType": "JSON"},
+			},
+		},
+		"indexParams": []map[string]interface{}{
+			{"fieldName": "vector", "indexName": "vector", "metricType": "COSINE"},
+		},
+	}, nil)
+}
+
+// Upsert inserts the records, replacing any existing record with the same id
+func (m *Milvus) Upsert(ctx context.Context, records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	data := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		metadata := record.Metadata
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+		data = append(data, map[string]interface{}{
+			"id":       record.ID,
+			"vector":   record.Vector,
+			"text":     record.Text,
+			"metadata": metadata,
+		})
+	}
+
+	return m.call(ctx, "/v2/vectordb/entities/upsert", map[string]interface{}{
+		"collectionName": m.collection,
+		"data":           data,
+	}, nil)
+}
+
+// Search returns the k records closest to query that match filter
+func (m *Milvus) Search(ctx context.Context, query []float32, k int, filter Filter) ([]Match, error) {
+	body := map[string]interface{}{
+		"collectionName": m.collection,
+		"data":           [][]float32{query},
+		"annsField":      "vector",
+		"limit":          k,
+		"outputFields":   []string{"id", "text", "metadata"},
+	}
+	if expr := filterExpression(filter); expr != "" {
+		body["filter"] = expr
+	}
+
+	var hits []struct {
+		ID       string            `json:"id"`
+		Text     string            `json:"text"`
+		Metadata map[string]string `json:"metadata"`
+		Distance float32           `json:"distance"`
+	}
+	if err := m.call(ctx, "/v2/vectordb/entities/search", body, &hits); err != nil {
+		return nil, err
+	}
+
+	matches := make([]Match, 0, len(hits))
+	for _, hit := range hits {
+		// with the COSINE metric Milvus reports the similarity itself as the distance
+		matches = append(matches, Match{
+			Record: Record{ID: hit.ID, Text: hit.Text, Metadata: hit.Metadata},
+			Score:  hit.Distance,
+		})
+	}
+	return matches, nil
+}
+
+// Delete removes the records with the given ids
+func (m *Milvus) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = strconv.Quote(id)
+	}
+	return m.call(ctx, "/v2/vectordb/entities/delete", map[string]interface{}{
+		"collectionName": m.collection,
+		"filter":         fmt.Sprintf("id in [%s]", strings.Join(quoted, ",")),
+	}, nil)
+}
+
+// filterExpression turns a Filter into a boolean expression over the metadata JSON field
+func filterExpression(filter Filter) string {
+	var clauses []string
+	for k, v := range filter {
+		clauses = append(clauses, fmt.Sprintf("metadata[%s] == %s", strconv.Quote(k), strconv.Quote(v)))
+	}
+	return strings.Join(clauses, " and ")
+}
+
+// call posts body to path and decodes the data field of the response into out
+func (m *Milvus) call(ctx context.Context, path string, body map[string]interface{}, out interface{}) error {
+	if m.database != "" {
+		body["dbName"] = m.database
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.address+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.token != "" {
+		req.Header.Set("Authorization", "Bearer "+m.token)
+	}
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("milvus %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("milvus %s: error decoding response (status %d): %v", path, resp.StatusCode, err)
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("milvus %s: code %d: %s", path, result.Code, result.Message)
+	}
+
+	if out != nil && len(result.Data) > 0 {
+		if err := json.Unmarshal(result.Data, out); err != nil {
+			return fmt.Errorf("milvus %s: error decoding data: %v", path, err)
+		}
+	}
+	return nil
+}
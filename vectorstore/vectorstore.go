@@ -0,0 +1,69 @@
+package vectorstore
+
+import (
+	"context"
+	"math"
+)
+
+// Record is a piece of text and its embedding, keyed by a stable id
+type Record struct {
+	ID       string            `json:"id"`
+	Text     string            `json:"text"`
+	Vector   []float32         `json:"vector"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Match is a record returned from a similarity search
+type Match struct {
+	Record
+	// Score is the cosine similarity to the query, higher is closer
+	Score float32 `json:"score"`
+}
+
+// Filter restricts a search to records whose metadata has all of the given values
+type Filter map[string]string
+
+// Store is implemented by every vector database the AI layer can search
+type Store interface {
+	// Upsert inserts the records, replacing any existing record with the same id
+	Upsert(ctx context.Context, records []Record) error
+	// Search returns the k records closest to query that match filter
+	Search(ctx context.Context, query []float32, k int, filter Filter) ([]Match, error)
+	// Delete removes the records with the given ids
+	Delete(ctx context.Context, ids []string) error
+}
+
+// matches reports whether metadata satisfies every entry in f
+func (f Filter) matches(metadata map[string]string) bool {
+	for k, v := range f {
+		if metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize returns a unit length copy of v so cosine similarity becomes a dot product
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	out := make([]float32, len(v))
+	if sum == 0 {
+		return out
+	}
+	norm := float32(math.Sqrt(sum))
+	for i, x := range v {
+		out[i] = x / norm
+	}
+	return out
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}